@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/services"
+)
+
+// runCapture implementa el subcomando "capture": graba el output de una terminal
+// activa vía WebSocket en formato asciicast v2 (o raw) para usarlo como fixture
+// en services/testdata/golden.
+//
+//	claude-monitor capture -terminal <id> -out services/testdata/golden/nombre.cast
+func runCapture(args []string) int {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+
+	var (
+		server     = fs.String("server", "http://127.0.0.1:9090", "URL del servidor")
+		terminalID = fs.String("terminal", "", "ID de la terminal a grabar (requerido)")
+		out        = fs.String("out", "", "Archivo de salida (default: <terminal>.cast)")
+		raw        = fs.Bool("raw", false, "Grabar bytes crudos en lugar de asciicast")
+		duration   = fs.Duration("duration", 0, "Duración máxima de la grabación (0 = hasta Ctrl-C)")
+		username   = fs.String("user", os.Getenv(EnvUsername), "Usuario Basic Auth")
+		password   = fs.String("password", os.Getenv(EnvPassword), "Password Basic Auth")
+		token      = fs.String("token", os.Getenv(EnvAPIToken), "API token")
+		version    = fs.String("claude-version", "", "Version del CLI de Claude grabado (default: claude --version)")
+	)
+	fs.Parse(args)
+
+	if *terminalID == "" {
+		fmt.Fprintln(os.Stderr, "capture: -terminal es requerido")
+		fs.Usage()
+		return 2
+	}
+	if *out == "" {
+		*out = *terminalID + ".cast"
+		if *raw {
+			*out = *terminalID + ".raw"
+		}
+	}
+
+	wsURL, err := captureWebSocketURL(*server, *terminalID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+		return 1
+	}
+
+	header := http.Header{}
+	switch {
+	case *token != "":
+		header.Set("X-API-Token", *token)
+	case *password != "":
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(*username, *password)
+	}
+
+	// El WebSocket solo acepta un ticket de un solo uso, pedido con las credenciales
+	ticket, err := captureTicket(*server, *terminalID, header)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+		return 1
+	}
+	wsURL += "?ticket=" + url.QueryEscape(ticket)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		if resp != nil {
+			fmt.Fprintf(os.Stderr, "capture: error conectando (%s): %v\n", resp.Status, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "capture: error conectando: %v\n", err)
+		}
+		return 1
+	}
+	defer conn.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	// El primer mensaje es el snapshot inicial: da el tamaño de la pantalla
+	width, height := 80, 24
+	var first captureMessage
+	if err := conn.ReadJSON(&first); err == nil && first.Type == "snapshot" && first.Snapshot != nil {
+		width, height = first.Snapshot.Width, first.Snapshot.Height
+	}
+
+	var writer *services.AsciicastWriter
+	if !*raw {
+		writer, err = services.NewAsciicastWriter(f, services.AsciicastHeader{
+			Width:  width,
+			Height: height,
+			Title:  "claude-monitor capture " + *terminalID,
+			Env:    captureEnv(*version),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+			return 1
+		}
+	}
+
+	write := func(data []byte) error {
+		if writer != nil {
+			return writer.WriteOutput(data)
+		}
+		_, err := f.Write(data)
+		return err
+	}
+
+	if first.Type == "output" {
+		write(first.bytes())
+	}
+
+	fmt.Fprintf(os.Stderr, "Grabando terminal %s (%dx%d) en %s. Ctrl-C para terminar.\n", *terminalID, width, height, *out)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+
+	msgs := make(chan captureMessage)
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			var msg captureMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				errCh <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	frames := 0
+	for {
+		select {
+		case msg := <-msgs:
+			switch msg.Type {
+			case "output":
+				if err := write(msg.bytes()); err != nil {
+					fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+					return 1
+				}
+				frames++
+			case "closed":
+				fmt.Fprintf(os.Stderr, "Terminal cerrada, %d frames grabados\n", frames)
+				return 0
+			}
+		case err := <-errCh:
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				fmt.Fprintf(os.Stderr, "Conexión cerrada, %d frames grabados\n", frames)
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "capture: %v (%d frames grabados)\n", err, frames)
+			return 1
+		case <-timeout:
+			fmt.Fprintf(os.Stderr, "Duración alcanzada, %d frames grabados\n", frames)
+			return 0
+		case <-sigCh:
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			fmt.Fprintf(os.Stderr, "\n%d frames grabados\n", frames)
+			return 0
+		}
+	}
+}
+
+// captureMessage mensajes del WebSocket de terminal relevantes para la grabación
+type captureMessage struct {
+	Type     string                     `json:"type"`
+	Data     string                     `json:"data"`
+	Raw      string                     `json:"raw"` // bytes exactos (base64) si data no es UTF-8 válido
+	Snapshot *services.TerminalSnapshot `json:"snapshot"`
+}
+
+// bytes output exacto de la PTY: raw si viene (runas partidas entre chunks), si no data
+func (m captureMessage) bytes() []byte {
+	if m.Raw != "" {
+		if data, err := base64.StdEncoding.DecodeString(m.Raw); err == nil {
+			return data
+		}
+	}
+	return []byte(m.Data)
+}
+
+// captureTicket pide un ticket para el WebSocket de la terminal
+func captureTicket(server, terminalID string, header http.Header) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("URL de servidor invalida: %v", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/terminals/" + url.PathEscape(terminalID) + "/ws-ticket"
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error pidiendo ticket: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("error pidiendo ticket (%s)", resp.Status)
+	}
+
+	var body struct {
+		Data auth.Ticket `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("respuesta de ticket invalida: %v", err)
+	}
+	return body.Data.Ticket, nil
+}
+
+// captureWebSocketURL construye ws(s)://host/api/terminals/{id}/ws desde la URL del servidor
+func captureWebSocketURL(server, terminalID string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("URL de servidor invalida: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("esquema no soportado: %s", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/terminals/" + url.PathEscape(terminalID) + "/ws"
+	return u.String(), nil
+}
+
+// captureEnv entorno de la cabecera asciicast. CLAUDE_VERSION permite saber con
+// qué CLI se grabó un fixture y detectar cuándo hay que volver a grabarlo.
+func captureEnv(version string) map[string]string {
+	env := map[string]string{"TERM": "xterm-256color"}
+	if version == "" {
+		version = services.DetectClaudeVersion()
+	}
+	if version != "" {
+		env["CLAUDE_VERSION"] = version
+	} else {
+		fmt.Fprintln(os.Stderr, "capture: aviso: version de Claude desconocida, usa -claude-version")
+	}
+	return env
+}
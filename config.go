@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"claude-monitor/pkg/logger"
+	"claude-monitor/services"
 )
 
 // Environment variable names
@@ -25,7 +28,6 @@ type Config struct {
 	// Auth (sensibles - no serializar a JSON, solo desde env vars)
 	Username string `json:"-"`
 	Password string `json:"-"`
-	APIToken string `json:"-"`
 
 	// CORS
 	AllowedOrigins []string `json:"allowed_origins"`
@@ -48,6 +50,28 @@ type Config struct {
 
 	// Cache
 	CacheDurationMinutes int `json:"cache_duration_minutes"`
+
+	// Hooks: URL con la que los hooks de Claude Code contactan al servidor
+	// (vacío = http://127.0.0.1:<port>)
+	HooksBaseURL string `json:"hooks_base_url"`
+
+	// Reglas de detección de estado de Claude (vacío = <dataDir>/detection_rules.json)
+	DetectionRulesFile string `json:"detection_rules_file"`
+
+	// Watchdog de terminales Claude atascadas u ociosas
+	Watchdog services.WatchdogConfig `json:"watchdog"`
+
+	// Cola de jobs headless (claude -p)
+	Jobs services.JobsConfig `json:"jobs"`
+
+	// Límites de recursos por defecto de las terminales y muestreo de uso
+	Limits services.LimitsConfig `json:"limits"`
+
+	// Límites de lectura y diff de archivos (/api/filesystem)
+	Files services.FilesConfig `json:"files"`
+
+	// Observación de cambios de archivos en el WorkDir de las terminales
+	Watch services.WatchConfig `json:"watch"`
 }
 
 // DefaultConfig configuración por defecto con valores seguros
@@ -64,7 +88,6 @@ func DefaultConfig() *Config {
 		// Auth
 		Username: "admin",
 		Password: "",
-		APIToken: "",
 
 		// CORS - vacío en desarrollo, configurar en producción
 		AllowedOrigins: []string{},
@@ -87,6 +110,21 @@ func DefaultConfig() *Config {
 
 		// Cache
 		CacheDurationMinutes: 5,
+
+		// Watchdog
+		Watchdog: services.DefaultWatchdogConfig(),
+
+		// Jobs
+		Jobs: services.DefaultJobsConfig(),
+
+		// Límites de recursos
+		Limits: services.DefaultLimitsConfig(),
+
+		// Archivos
+		Files: services.DefaultFilesConfig(),
+
+		// Observación de archivos
+		Watch: services.DefaultWatchConfig(),
 	}
 }
 
@@ -114,9 +152,6 @@ func LoadConfig(path string) (*Config, error) {
 	// Cargar credenciales desde env vars (prioridad sobre defaults)
 	loadCredentialsFromEnv(cfg)
 
-	// Validar que hay autenticación configurada
-	validateAuth(cfg)
-
 	return cfg, nil
 }
 
@@ -129,19 +164,46 @@ func loadCredentialsFromEnv(cfg *Config) {
 	if password := os.Getenv(EnvPassword); password != "" {
 		cfg.Password = password
 	}
+}
 
-	if token := os.Getenv(EnvAPIToken); token != "" {
-		cfg.APIToken = token
+// importLegacyAPIToken registra CLAUDE_MONITOR_API_TOKEN en el almacén de tokens
+// (token "env" con todos los permisos) para no romper instalaciones existentes
+func importLegacyAPIToken(tokens *services.APITokenStore) {
+	secret := os.Getenv(EnvAPIToken)
+	if secret == "" {
+		return
+	}
+	imported, err := tokens.ImportLegacy(secret)
+	if errors.Is(err, services.ErrLegacyAPITokenRevoked) {
+		logger.Warn(EnvAPIToken+" fue revocado y no se importa",
+			"hint", "Quita la variable del entorno",
+		)
+		return
+	}
+	if err != nil {
+		logger.Error("Error importando "+EnvAPIToken, "error", err)
+		return
+	}
+	if imported {
+		logger.Warn(EnvAPIToken+" importado como token de API con todos los permisos",
+			"name", services.LegacyAPITokenName,
+			"hint", "Crea tokens con scopes en /api/tokens, revoca este y quita la variable",
+		)
 	}
 }
 
 // validateAuth valida que hay al menos un método de autenticación
-func validateAuth(cfg *Config) {
+// (usuarios de users.json, tokens de API o credenciales de entorno)
+func validateAuth(cfg *Config, users, tokens int) {
 	log := logger.Get()
 
-	if cfg.Password == "" && cfg.APIToken == "" {
+	if users > 0 {
+		log.Info("Autenticación con usuarios configurada", "users", users)
+	}
+
+	if cfg.Password == "" && users == 0 && tokens == 0 {
 		log.Warn("Sin autenticación configurada",
-			"hint", "Usa "+EnvPassword+" o "+EnvAPIToken+" para configurar credenciales",
+			"hint", "Usa "+EnvPassword+" o crea usuarios en users.json para configurar credenciales",
 		)
 		// Usar password por defecto para desarrollo (inseguro)
 		cfg.Password = "admin"
@@ -152,8 +214,8 @@ func validateAuth(cfg *Config) {
 		log.Info("Autenticación Basic Auth configurada", "user", cfg.Username)
 	}
 
-	if cfg.APIToken != "" {
-		log.Info("Autenticación API Token configurada")
+	if tokens > 0 {
+		log.Info("Autenticación con tokens de API configurada", "tokens", tokens)
 	}
 }
 
@@ -166,5 +228,26 @@ func SaveConfig(path string, cfg *Config) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// GetHooksBaseURL retorna la URL base para los hooks de Claude Code
+func (c *Config) GetHooksBaseURL() string {
+	if c.HooksBaseURL != "" {
+		return c.HooksBaseURL
+	}
+	host := c.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("http://%s:%d", host, c.Port)
+}
+
+// GetDetectionRulesFile retorna el path del archivo de reglas de detección
+func (c *Config) GetDetectionRulesFile(dataDir string) string {
+	if c.DetectionRulesFile != "" {
+		return c.DetectionRulesFile
+	}
+	return filepath.Join(dataDir, "detection_rules.json")
+}
+
 // Global config instance
 var config *Config
+
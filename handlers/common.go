@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
 
+	"claude-monitor/pkg/auth"
 	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/services"
 )
 
 // URLParam obtiene un parámetro de URL de Chi
@@ -26,6 +31,24 @@ func URLParamDecoded(r *http.Request, key string) string {
 	return decoded
 }
 
+// RequireNameParams rechaza con 400 las peticiones en las que alguno de los
+// parámetros de URL (decodificado) contiene "/", "\" o "..": son nombres de
+// directorio o archivo y no deben salir de su carpeta
+func RequireNameParams(keys ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, key := range keys {
+				value := URLParamDecoded(r, key)
+				if strings.ContainsAny(value, "/\\") || strings.Contains(value, "..") {
+					WriteBadRequest(w, key+" invalido")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // APIMeta metadatos de respuesta
 type APIMeta struct {
 	Total  int `json:"total,omitempty"`
@@ -103,3 +126,70 @@ func WriteInternalError(w http.ResponseWriter, message string) {
 func WriteConflict(w http.ResponseWriter, message string) {
 	apierrors.WriteError(w, apierrors.Conflict(message))
 }
+
+// RequestActor identifica quién realiza la petición (para auditoría)
+func RequestActor(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Name
+	}
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	if r.Header.Get("X-API-Token") != "" || r.Header.Get("Authorization") != "" {
+		return "api-token"
+	}
+	return "anonymous"
+}
+
+// recordAudit registra en el log de auditoría una operación hecha por la petición
+func recordAudit(audit *services.AuditLog, r *http.Request, action, terminalID string, details map[string]string) {
+	if audit == nil {
+		return
+	}
+	audit.Record(services.AuditEntry{
+		Actor:      RequestActor(r),
+		Action:     action,
+		TerminalID: terminalID,
+		Details:    details,
+	})
+}
+
+// newWebSocketUpgrader crea un upgrader que verifica el Origin: con orígenes
+// configurados debe estar en la lista ("*" = cualquiera); sin ellos solo se
+// aceptan páginas del mismo host (cualquier puerto). Las peticiones sin Origin
+// (clientes que no son navegadores) se aceptan.
+func newWebSocketUpgrader(allowedOrigins []string) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			return websocketOriginAllowed(r, allowedOrigins)
+		},
+	}
+}
+
+// websocketOriginAllowed verifica el Origin de una petición de upgrade
+func websocketOriginAllowed(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(allowedOrigins) > 0 {
+		for _, o := range allowedOrigins {
+			if o == "*" || o == origin {
+				return true
+			}
+		}
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.EqualFold(u.Hostname(), host)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"claude-monitor/services"
+)
+
+// DetectionRulesHandler maneja las reglas de detección de estado de Claude
+type DetectionRulesHandler struct {
+	rules *services.DetectionRulesService
+}
+
+// NewDetectionRulesHandler crea un nuevo handler
+func NewDetectionRulesHandler(rules *services.DetectionRulesService) *DetectionRulesHandler {
+	return &DetectionRulesHandler{
+		rules: rules,
+	}
+}
+
+// ValidateDetectionRulesRequest cuerpo de POST /detection-rules/validate
+type ValidateDetectionRulesRequest struct {
+	Rules         *services.DetectionRules `json:"rules"`
+	ClaudeVersion string                   `json:"claude_version,omitempty"`
+	Samples       []string                 `json:"samples,omitempty"`
+}
+
+// ValidateDetectionRulesResponse resultado de validar reglas
+type ValidateDetectionRulesResponse struct {
+	Valid   bool                             `json:"valid"`
+	Errors  []services.DetectionRuleError    `json:"errors,omitempty"`
+	RuleSet string                           `json:"rule_set,omitempty"`
+	Samples []services.DetectionSampleResult `json:"samples,omitempty"`
+}
+
+// Get godoc
+// @Summary      Obtener reglas de detección
+// @Description  Retorna las reglas de detección de estado de Claude cargadas, el conjunto activo y el último error de carga
+// @Tags         detection
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=services.DetectionRulesStatus}
+// @Router       /detection-rules [get]
+// @Security     BasicAuth
+func (h *DetectionRulesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.rules.Status())
+}
+
+// Update godoc
+// @Summary      Reemplazar reglas de detección
+// @Description  Valida, guarda y activa nuevas reglas de detección sin reiniciar el servidor
+// @Tags         detection
+// @Accept       json
+// @Produce      json
+// @Param        request  body      services.DetectionRules  true  "Reglas"
+// @Success      200      {object}  handlers.APIResponse{data=services.DetectionRulesStatus}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /detection-rules [put]
+// @Security     BasicAuth
+func (h *DetectionRulesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var rules services.DetectionRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.rules.Save(&rules); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, h.rules.Status())
+}
+
+// Reload godoc
+// @Summary      Recargar reglas de detección
+// @Description  Recarga las reglas desde el archivo. Si el archivo es inválido se mantienen las reglas activas
+// @Tags         detection
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=services.DetectionRulesStatus}
+// @Failure      400  {object}  handlers.APIResponse
+// @Router       /detection-rules/reload [post]
+// @Security     BasicAuth
+func (h *DetectionRulesHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	if err := h.rules.Reload(); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, h.rules.Status())
+}
+
+// Validate godoc
+// @Summary      Validar reglas de detección
+// @Description  Valida reglas sin activarlas y opcionalmente las evalúa contra textos de ejemplo
+// @Tags         detection
+// @Accept       json
+// @Produce      json
+// @Param        request  body      handlers.ValidateDetectionRulesRequest  true  "Reglas y ejemplos"
+// @Success      200      {object}  handlers.APIResponse{data=handlers.ValidateDetectionRulesResponse}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /detection-rules/validate [post]
+// @Security     BasicAuth
+func (h *DetectionRulesHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	var req ValidateDetectionRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	// Sin reglas se validan las activas
+	if req.Rules == nil {
+		req.Rules = h.rules.Status().Rules
+	}
+	if req.Rules == nil {
+		WriteBadRequest(w, "rules requerido")
+		return
+	}
+	if req.ClaudeVersion == "" {
+		req.ClaudeVersion = h.rules.ClaudeVersion()
+	}
+
+	resp := ValidateDetectionRulesResponse{
+		Errors: req.Rules.Validate(),
+	}
+	resp.Valid = len(resp.Errors) == 0
+
+	if resp.Valid {
+		if set := req.Rules.SelectRuleSet(req.ClaudeVersion); set != nil {
+			resp.RuleSet = set.Name
+		}
+		if len(req.Samples) > 0 {
+			samples, err := services.TestDetectionRules(req.Rules, req.ClaudeVersion, req.Samples)
+			if err != nil {
+				WriteBadRequest(w, err.Error())
+				return
+			}
+			resp.Samples = samples
+		}
+	}
+
+	WriteSuccess(w, resp)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"claude-monitor/pkg/logger"
+	"claude-monitor/services"
+)
+
+// EventsHandler maneja el stream de eventos del servidor y el watchdog
+type EventsHandler struct {
+	events   *services.EventBus
+	watchdog *services.Watchdog
+	upgrader websocket.Upgrader
+}
+
+// NewEventsHandler crea un nuevo handler
+func NewEventsHandler(events *services.EventBus, watchdog *services.Watchdog, allowedOrigins []string) *EventsHandler {
+	return &EventsHandler{
+		events:   events,
+		watchdog: watchdog,
+		upgrader: newWebSocketUpgrader(allowedOrigins),
+	}
+}
+
+// WebSocket godoc
+// @Summary      Stream de eventos
+// @Description  WebSocket con los eventos del servidor (cambios de estado Claude, permisos, watchdog, terminales terminadas). Se autentica solo con un ticket de POST /events/ws-ticket
+// @Tags         events
+// @Param        ticket       query     string  true   "Ticket de un solo uso"
+// @Param        types        query     string  false  "Tipos de evento separados por coma (ej: watchdog.stuck,watchdog.idle)"
+// @Param        terminal_id  query     string  false  "Solo eventos de esta terminal"
+// @Success      101          {string}  string  "Switching Protocols"
+// @Router       /events/ws [get]
+// @Security     BasicAuth
+func (h *EventsHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	filter := services.EventFilter{
+		TerminalID: r.URL.Query().Get("terminal_id"),
+	}
+	if types := r.URL.Query().Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, services.EventType(t))
+			}
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("Error upgrading WebSocket", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	events, cancel := h.events.Subscribe(filter)
+	defer cancel()
+
+	const (
+		pingInterval = 30 * time.Second
+		pongTimeout  = 60 * time.Second
+		writeTimeout = 10 * time.Second
+	)
+
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		return nil
+	})
+
+	// El cliente no envía mensajes; leer solo para detectar cierre y procesar pongs
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// Watchdog godoc
+// @Summary      Estado del watchdog
+// @Description  Retorna los umbrales del watchdog y las terminales Claude atascadas u ociosas
+// @Tags         events
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=services.WatchdogStatus}
+// @Router       /watchdog [get]
+// @Security     BasicAuth
+func (h *EventsHandler) Watchdog(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.watchdog.Status())
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/pkg/logger"
+	"claude-monitor/services"
+)
+
+// Líneas de contexto por defecto y máximas de un diff
+const (
+	defaultDiffContext = 3
+	maxDiffContext     = 100
+)
+
+// FilesHandler maneja lectura, preview, diff, subida y descarga de archivos
+type FilesHandler struct {
+	files *services.FileService
+}
+
+// NewFilesHandler crea un nuevo handler
+func NewFilesHandler(files *services.FileService) *FilesHandler {
+	return &FilesHandler{
+		files: files,
+	}
+}
+
+// writeFileError traduce los errores de FileService a respuestas HTTP
+func writeFileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPathNotAllowed):
+		WriteErrorMsg(w, apierrors.ErrCodePathNotAllowed, err.Error())
+	case os.IsNotExist(err):
+		WriteNotFound(w, "archivo")
+	case errors.Is(err, services.ErrFileTooLarge):
+		WriteError(w, apierrors.TooLarge(err.Error()))
+	case errors.Is(err, services.ErrFileExists):
+		WriteConflict(w, err.Error())
+	case errors.Is(err, services.ErrNotRegularFile), errors.Is(err, services.ErrInvalidRange), errors.Is(err, services.ErrNotGitRepo),
+		errors.Is(err, services.ErrNotDirectory), errors.Is(err, services.ErrInvalidUpload), errors.Is(err, services.ErrArchiveFormat):
+		WriteBadRequest(w, err.Error())
+	default:
+		WriteInternalError(w, err.Error())
+	}
+}
+
+// queryInt64 lee un entero no negativo de la query (def si no está)
+func queryInt64(r *http.Request, key string, def int64) (int64, bool) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// Read godoc
+// @Summary      Leer archivo
+// @Description  Lee un fragmento de un archivo (hasta max_read_bytes). Los binarios se devuelven en base64. Usar next_offset para continuar
+// @Tags         filesystem
+// @Produce      json
+// @Param        path    query     string  true   "Path absoluto del archivo"
+// @Param        offset  query     int     false  "Byte inicial (default: 0)"
+// @Param        length  query     int     false  "Bytes a leer (default y máximo: max_read_bytes)"
+// @Success      200     {object}  handlers.APIResponse{data=services.FileContent}
+// @Failure      400     {object}  handlers.APIResponse
+// @Failure      404     {object}  handlers.APIResponse
+// @Router       /filesystem/file [get]
+// @Security     BasicAuth
+func (h *FilesHandler) Read(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteBadRequest(w, "path requerido")
+		return
+	}
+	offset, ok := queryInt64(r, "offset", 0)
+	if !ok {
+		WriteBadRequest(w, "offset invalido")
+		return
+	}
+	length, ok := queryInt64(r, "length", 0)
+	if !ok {
+		WriteBadRequest(w, "length invalido")
+		return
+	}
+
+	content, err := h.files.ReadFile(path, offset, length)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	WriteSuccess(w, content)
+}
+
+// Raw godoc
+// @Summary      Descargar archivo
+// @Description  Sirve el contenido crudo del archivo con soporte de Range (206), If-Modified-Since y Content-Type
+// @Tags         filesystem
+// @Produce      octet-stream
+// @Param        path   query     string  true   "Path absoluto del archivo"
+// @Param        Range  header    string  false  "Rango de bytes (bytes=0-1023)"
+// @Success      200
+// @Success      206
+// @Failure      400    {object}  handlers.APIResponse
+// @Failure      404    {object}  handlers.APIResponse
+// @Failure      416
+// @Router       /filesystem/raw [get]
+// @Security     BasicAuth
+func (h *FilesHandler) Raw(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteBadRequest(w, "path requerido")
+		return
+	}
+
+	f, info, err := h.files.Open(path)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	defer f.Close()
+
+	// Nunca interpretar el archivo como HTML del propio monitor
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// Preview godoc
+// @Summary      Preview de archivo
+// @Description  Metadatos para mostrar el archivo: tipo MIME, lenguaje para resaltado de sintaxis, binario, líneas, fin de línea y primeras líneas
+// @Tags         filesystem
+// @Produce      json
+// @Param        path  query     string  true  "Path absoluto del archivo"
+// @Success      200   {object}  handlers.APIResponse{data=services.FilePreview}
+// @Failure      400   {object}  handlers.APIResponse
+// @Failure      404   {object}  handlers.APIResponse
+// @Router       /filesystem/preview [get]
+// @Security     BasicAuth
+func (h *FilesHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteBadRequest(w, "path requerido")
+		return
+	}
+
+	preview, err := h.files.Preview(path)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	WriteSuccess(w, preview)
+}
+
+// Diff godoc
+// @Summary      Diff de archivos
+// @Description  Diff unificado de dos archivos (old y new) o de un archivo contra git HEAD (path, against=HEAD)
+// @Tags         filesystem
+// @Produce      json
+// @Param        old      query     string  false  "Archivo original"
+// @Param        new      query     string  false  "Archivo modificado"
+// @Param        path     query     string  false  "Archivo a comparar contra HEAD"
+// @Param        against  query     string  false  "HEAD (default cuando se usa path)"
+// @Param        context  query     int     false  "Líneas de contexto (default: 3)"
+// @Success      200      {object}  handlers.APIResponse{data=services.FileDiff}
+// @Failure      400      {object}  handlers.APIResponse
+// @Failure      404      {object}  handlers.APIResponse
+// @Failure      413      {object}  handlers.APIResponse
+// @Router       /filesystem/diff [get]
+// @Security     BasicAuth
+func (h *FilesHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	context, ok := queryInt64(r, "context", defaultDiffContext)
+	if !ok || context > maxDiffContext {
+		WriteBadRequest(w, "context invalido")
+		return
+	}
+
+	var result *services.FileDiff
+	var err error
+	switch {
+	case q.Get("path") != "":
+		if against := q.Get("against"); against != "" && !strings.EqualFold(against, "HEAD") {
+			WriteBadRequest(w, "against solo admite HEAD")
+			return
+		}
+		result, err = h.files.DiffHead(q.Get("path"), int(context))
+	case q.Get("old") != "" && q.Get("new") != "":
+		result, err = h.files.Diff(q.Get("old"), q.Get("new"), int(context))
+	default:
+		WriteBadRequest(w, "indicar old y new, o path para comparar contra HEAD")
+		return
+	}
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	WriteSuccess(w, result)
+}
+
+// Upload godoc
+// @Summary      Subir archivos
+// @Description  Escribe los archivos de un formulario multipart en un directorio existente. La subida es completa o no se escribe nada; el total no puede superar max_upload_bytes
+// @Tags         filesystem
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        path       query     string  true   "Directorio destino"
+// @Param        overwrite  query     bool    false  "Reemplazar archivos existentes"
+// @Param        file       formData  file    true   "Archivos (uno o más)"
+// @Success      201        {object}  handlers.APIResponse{data=services.UploadResult}
+// @Failure      400        {object}  handlers.APIResponse
+// @Failure      404        {object}  handlers.APIResponse
+// @Failure      409        {object}  handlers.APIResponse
+// @Failure      413        {object}  handlers.APIResponse
+// @Router       /filesystem/upload [post]
+// @Security     BasicAuth
+func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteBadRequest(w, "path requerido")
+		return
+	}
+
+	extendTransferDeadline(w, h.files.TransferTimeout())
+	r.Body = http.MaxBytesReader(w, r.Body, h.files.UploadLimit())
+	mr, err := r.MultipartReader()
+	if err != nil {
+		WriteBadRequest(w, "se esperaba multipart/form-data")
+		return
+	}
+
+	result, err := h.files.Upload(path, mr, r.URL.Query().Get("overwrite") == "true")
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	logger.Info("Archivos subidos", "dir", result.Dir, "files", len(result.Files), "bytes", result.Size)
+	WriteCreated(w, result)
+}
+
+// Download godoc
+// @Summary      Descargar archivo o directorio
+// @Description  Descarga un archivo como adjunto o un directorio comprimido (zip, tar o tar.gz) generado al vuelo, hasta max_archive_bytes sin comprimir. Los symlinks del directorio no se incluyen
+// @Tags         filesystem
+// @Produce      octet-stream
+// @Param        path    query     string  true   "Path absoluto del archivo o directorio"
+// @Param        format  query     string  false  "Formato para directorios: zip (default), tar o tar.gz"
+// @Success      200
+// @Failure      400     {object}  handlers.APIResponse
+// @Failure      404     {object}  handlers.APIResponse
+// @Failure      413     {object}  handlers.APIResponse
+// @Router       /filesystem/download [get]
+// @Security     BasicAuth
+func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteBadRequest(w, "path requerido")
+		return
+	}
+
+	f, info, err := h.files.Open(path)
+	switch {
+	case err == nil:
+		defer f.Close()
+		extendTransferDeadline(w, h.files.TransferTimeout())
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	case !errors.Is(err, services.ErrNotRegularFile):
+		writeFileError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.ArchiveZip
+	}
+	archive, err := h.files.PrepareArchive(path, format)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	extendTransferDeadline(w, h.files.TransferTimeout())
+	w.Header().Set("Content-Type", archive.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archive.FileName()}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := archive.Write(w); err != nil {
+		// Los encabezados ya se enviaron: solo queda cortar la respuesta
+		logger.Warn("Descarga de directorio interrumpida", "path", archive.Root, "error", err)
+		return
+	}
+	logger.Info("Directorio descargado", "path", archive.Root, "format", archive.Format, "files", archive.Files, "bytes", archive.Size)
+}
+
+// extendTransferDeadline amplía los plazos de lectura y escritura de la
+// conexión: los ReadTimeout/WriteTimeout del servidor cortarían una subida o
+// descarga grande a los 30s
+func extendTransferDeadline(w http.ResponseWriter, timeout time.Duration) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(timeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		logger.Warn("No se pudo ampliar el plazo de lectura", "error", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		logger.Warn("No se pudo ampliar el plazo de escritura", "error", err)
+	}
+}
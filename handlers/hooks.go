@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/pkg/logger"
+	"claude-monitor/services"
+)
+
+// Tamaño máximo del payload de un hook
+const maxHookPayloadSize = 1 << 20
+
+// HooksHandler recibe eventos de los hooks de Claude Code
+type HooksHandler struct {
+	terminals   *services.TerminalService
+	permissions *services.PermissionService
+}
+
+// NewHooksHandler crea un nuevo handler
+func NewHooksHandler(terminals *services.TerminalService, permissions *services.PermissionService) *HooksHandler {
+	return &HooksHandler{
+		terminals:   terminals,
+		permissions: permissions,
+	}
+}
+
+// hookOutput respuesta leída por Claude Code desde el stdout del hook
+type hookOutput struct {
+	HookSpecificOutput *hookSpecificOutput `json:"hookSpecificOutput,omitempty"`
+}
+
+type hookSpecificOutput struct {
+	HookEventName            string `json:"hookEventName"`
+	PermissionDecision       string `json:"permissionDecision"`
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+}
+
+// Receive godoc
+// @Summary      Recibir evento de hook
+// @Description  Endpoint invocado por los hooks de Claude Code generados con enable_hooks. Se autentica con el header X-Hook-Token propio de cada terminal. La respuesta es el JSON que Claude Code interpreta como salida del hook (en PreToolUse puede incluir la decisión de la política de permisos)
+// @Tags         hooks
+// @Accept       json
+// @Produce      json
+// @Param        terminalID    path    string                true  "ID de la terminal"
+// @Param        X-Hook-Token  header  string                true  "Token de hooks de la terminal"
+// @Param        request       body    services.HookPayload  true  "Payload del hook"
+// @Success      200
+// @Failure      400  {object}  handlers.APIResponse
+// @Failure      401  {object}  handlers.APIResponse
+// @Failure      404  {object}  handlers.APIResponse
+// @Router       /hooks/{terminalID} [post]
+func (h *HooksHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+	if !h.terminals.ValidateHookToken(id, r.Header.Get("X-Hook-Token")) {
+		logger.Warn("Hook con token inválido", "terminal_id", id, "ip", r.RemoteAddr)
+		WriteError(w, apierrors.ErrUnauthorized)
+		return
+	}
+
+	var payload services.HookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHookPayloadSize)).Decode(&payload); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.terminals.ApplyHookEvent(id, &payload); err != nil {
+		if strings.Contains(err.Error(), "no encontrada") {
+			WriteNotFound(w, "terminal")
+			return
+		}
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	output := hookOutput{}
+	if payload.HookEventName == services.HookPreToolUse && h.permissions != nil {
+		info, _ := h.terminals.Get(id)
+		workDir := ""
+		if info != nil {
+			workDir = info.WorkDir
+		}
+
+		command := payload.ToolInputString("command")
+		if command == "" {
+			command = payload.ToolInputString("file_path")
+		}
+
+		decision, ok := h.permissions.DecideToolUse(id, workDir, services.PermissionRequest{
+			TerminalID: id,
+			Tool:       payload.ToolName,
+			Command:    command,
+		})
+		if ok {
+			action := "allow"
+			if decision.Action == services.PermissionDeny {
+				action = "deny"
+			}
+			output.HookSpecificOutput = &hookSpecificOutput{
+				HookEventName:            string(services.HookPreToolUse),
+				PermissionDecision:       action,
+				PermissionDecisionReason: "claude-monitor: " + decision.Reason,
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(output)
+}
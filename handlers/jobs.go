@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/pkg/validator"
+	"claude-monitor/services"
+)
+
+// Espera máxima de GET /jobs/{id}/output?wait=N (menor que el WriteTimeout del servidor)
+const maxJobOutputWait = 25
+
+// JobsHandler maneja jobs headless (claude -p)
+type JobsHandler struct {
+	jobs *services.JobService
+}
+
+// NewJobsHandler crea un nuevo handler
+func NewJobsHandler(jobs *services.JobService) *JobsHandler {
+	return &JobsHandler{
+		jobs: jobs,
+	}
+}
+
+// Submit godoc
+// @Summary      Encolar job
+// @Description  Encola una ejecución no interactiva de claude -p en el directorio indicado. La salida por defecto es stream-json
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        request  body      validator.JobSubmitRequest  true  "Prompt, directorio y opciones"
+// @Success      201      {object}  handlers.APIResponse{data=services.Job}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /jobs [post]
+// @Security     BasicAuth
+func (h *JobsHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	req, err := validator.DecodeAndValidate(r, validator.ValidateJobSubmit)
+	if err != nil {
+		if apiErr, ok := err.(*apierrors.APIError); ok {
+			apierrors.WriteError(w, apiErr)
+		} else {
+			WriteBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	job, err := h.jobs.Submit(services.JobRequest{
+		Name:            req.Name,
+		Prompt:          req.Prompt,
+		WorkDir:         req.WorkDir,
+		Model:           req.Model,
+		SystemPrompt:    req.SystemPrompt,
+		AllowedTools:    req.AllowedTools,
+		DisallowedTools: req.DisallowedTools,
+		PermissionMode:  req.PermissionMode,
+		MaxTurns:        req.MaxTurns,
+		OutputFormat:    req.OutputFormat,
+		TimeoutSeconds:  req.TimeoutSeconds,
+	})
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteCreated(w, job)
+}
+
+// List godoc
+// @Summary      Listar jobs
+// @Description  Jobs del más reciente al más antiguo
+// @Tags         jobs
+// @Produce      json
+// @Param        status  query     string  false  "queued, running, succeeded, failed, cancelled o timed_out"
+// @Param        limit   query     int     false  "Máximo de jobs (default: 100)"
+// @Success      200     {object}  handlers.APIResponse{data=[]services.Job}
+// @Failure      400     {object}  handlers.APIResponse
+// @Router       /jobs [get]
+// @Security     BasicAuth
+func (h *JobsHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter := services.JobFilter{
+		Status: services.JobStatus(r.URL.Query().Get("status")),
+		Limit:  100,
+	}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			WriteBadRequest(w, "limit invalido")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	jobs := h.jobs.List(filter)
+	json.NewEncoder(w).Encode(SuccessWithMeta(jobs, &APIMeta{Total: len(jobs), Limit: filter.Limit}))
+}
+
+// Get godoc
+// @Summary      Obtener job
+// @Description  Estado, resultado, costo y session_id del job
+// @Tags         jobs
+// @Produce      json
+// @Param        jobID  path      string  true  "ID del job"
+// @Success      200    {object}  handlers.APIResponse{data=services.Job}
+// @Failure      404    {object}  handlers.APIResponse
+// @Router       /jobs/{jobID} [get]
+// @Security     BasicAuth
+func (h *JobsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(URLParam(r, "jobID"))
+	if err != nil {
+		WriteNotFound(w, "job")
+		return
+	}
+
+	WriteSuccess(w, job)
+}
+
+// Output godoc
+// @Summary      Salida de un job
+// @Description  Líneas de salida desde el offset from. Con wait espera hasta N segundos a que haya líneas nuevas (tail por long-polling usando next como siguiente from)
+// @Tags         jobs
+// @Produce      json
+// @Param        jobID  path      string  true   "ID del job"
+// @Param        from   query     int     false  "Primera línea (default: 0)"
+// @Param        wait   query     int     false  "Segundos de espera (máx 25)"
+// @Success      200    {object}  handlers.APIResponse{data=services.JobOutput}
+// @Failure      400    {object}  handlers.APIResponse
+// @Failure      404    {object}  handlers.APIResponse
+// @Router       /jobs/{jobID}/output [get]
+// @Security     BasicAuth
+func (h *JobsHandler) Output(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "jobID")
+	if _, err := h.jobs.Get(id); err != nil {
+		WriteNotFound(w, "job")
+		return
+	}
+
+	from, wait := 0, 0
+	if s := r.URL.Query().Get("from"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			WriteBadRequest(w, "from invalido")
+			return
+		}
+		from = n
+	}
+	if s := r.URL.Query().Get("wait"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxJobOutputWait {
+			WriteBadRequest(w, "wait invalido")
+			return
+		}
+		wait = n
+	}
+
+	output, err := h.jobs.Output(id, from, time.Duration(wait)*time.Second)
+	if err != nil {
+		WriteInternalError(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, output)
+}
+
+// Cancel godoc
+// @Summary      Cancelar job
+// @Description  Un job en cola se cancela de inmediato; uno en ejecución recibe la señal y pasa a cancelled al terminar el proceso
+// @Tags         jobs
+// @Produce      json
+// @Param        jobID  path      string  true  "ID del job"
+// @Success      200    {object}  handlers.APIResponse{data=services.Job}
+// @Failure      404    {object}  handlers.APIResponse
+// @Failure      409    {object}  handlers.APIResponse
+// @Router       /jobs/{jobID}/cancel [post]
+// @Security     BasicAuth
+func (h *JobsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "jobID")
+	if _, err := h.jobs.Get(id); err != nil {
+		WriteNotFound(w, "job")
+		return
+	}
+
+	job, err := h.jobs.Cancel(id)
+	if err != nil {
+		WriteConflict(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, job)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"claude-monitor/services"
+)
+
+// NotificationsHandler maneja canales y reglas de notificación
+type NotificationsHandler struct {
+	notifier *services.NotifierService
+}
+
+// NewNotificationsHandler crea un nuevo handler
+func NewNotificationsHandler(notifier *services.NotifierService) *NotificationsHandler {
+	return &NotificationsHandler{
+		notifier: notifier,
+	}
+}
+
+// Get godoc
+// @Summary      Configuración de notificaciones
+// @Description  Retorna los canales (con secretos ocultos), las reglas por defecto y las entregas recientes
+// @Tags         notifications
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=services.NotificationStatus}
+// @Router       /notifications [get]
+// @Security     BasicAuth
+func (h *NotificationsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.notifier.Status())
+}
+
+// SetChannels godoc
+// @Summary      Reemplazar canales de notificación
+// @Description  Reemplaza la lista de canales (webhook, slack, ntfy, gotify, smtp). Los secretos enviados como "********" conservan su valor anterior
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        request  body      []services.NotificationChannel  true  "Canales"
+// @Success      200      {object}  handlers.APIResponse{data=services.NotificationStatus}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /notifications/channels [put]
+// @Security     BasicAuth
+func (h *NotificationsHandler) SetChannels(w http.ResponseWriter, r *http.Request) {
+	var channels []services.NotificationChannel
+	if err := json.NewDecoder(r.Body).Decode(&channels); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.notifier.SetChannels(channels); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, h.notifier.Status())
+}
+
+// TestChannel godoc
+// @Summary      Probar canal de notificación
+// @Description  Envía una notificación de prueba por el canal
+// @Tags         notifications
+// @Produce      json
+// @Param        channel  path      string  true  "Nombre del canal"
+// @Success      200      {object}  handlers.APIResponse{data=services.NotificationDelivery}
+// @Failure      404      {object}  handlers.APIResponse
+// @Router       /notifications/channels/{channel}/test [post]
+// @Security     BasicAuth
+func (h *NotificationsHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	name := URLParamDecoded(r, "channel")
+
+	delivery, err := h.notifier.TestChannel(name)
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrado") {
+			WriteNotFound(w, "canal")
+		} else {
+			WriteBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, delivery)
+}
+
+// SetDefaultRules godoc
+// @Summary      Configurar reglas de notificación por defecto
+// @Description  Reglas aplicadas a las terminales sin reglas propias ni de su session-root
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        request  body      services.NotificationRules  true  "Reglas"
+// @Success      200      {object}  handlers.APIResponse{data=services.NotificationRules}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /notifications/rules [put]
+// @Security     BasicAuth
+func (h *NotificationsHandler) SetDefaultRules(w http.ResponseWriter, r *http.Request) {
+	var rules services.NotificationRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.notifier.SetDefaultRules(&rules); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, rules)
+}
+
+// GetTerminalRules godoc
+// @Summary      Obtener reglas de notificación de terminal
+// @Tags         notifications
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse{data=services.NotificationRules}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/notification-rules [get]
+// @Security     BasicAuth
+func (h *NotificationsHandler) GetTerminalRules(w http.ResponseWriter, r *http.Request) {
+	rules, ok := h.notifier.GetTerminalRules(URLParam(r, "terminalID"))
+	if !ok {
+		WriteNotFound(w, "reglas")
+		return
+	}
+
+	WriteSuccess(w, rules)
+}
+
+// SetTerminalRules godoc
+// @Summary      Configurar reglas de notificación de terminal
+// @Description  Reemplaza las reglas de notificación de una terminal (tienen prioridad sobre las del session-root)
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                      true  "ID de la terminal"
+// @Param        request     body      services.NotificationRules  true  "Reglas"
+// @Success      200         {object}  handlers.APIResponse{data=services.NotificationRules}
+// @Failure      400         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/notification-rules [put]
+// @Security     BasicAuth
+func (h *NotificationsHandler) SetTerminalRules(w http.ResponseWriter, r *http.Request) {
+	var rules services.NotificationRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.notifier.SetTerminalRules(URLParam(r, "terminalID"), &rules); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, rules)
+}
+
+// DeleteTerminalRules godoc
+// @Summary      Eliminar reglas de notificación de terminal
+// @Tags         notifications
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/notification-rules [delete]
+// @Security     BasicAuth
+func (h *NotificationsHandler) DeleteTerminalRules(w http.ResponseWriter, r *http.Request) {
+	h.notifier.DeleteTerminalRules(URLParam(r, "terminalID"))
+	WriteSuccess(w, map[string]string{"message": "Reglas eliminadas"})
+}
+
+// GetSessionRootRules godoc
+// @Summary      Obtener reglas de notificación de session-root
+// @Tags         notifications
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse{data=services.NotificationRules}
+// @Failure      404       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/notification-rules [get]
+// @Security     BasicAuth
+func (h *NotificationsHandler) GetSessionRootRules(w http.ResponseWriter, r *http.Request) {
+	rules, ok := h.notifier.GetSessionRootRules(URLParamDecoded(r, "rootPath"))
+	if !ok {
+		WriteNotFound(w, "reglas")
+		return
+	}
+
+	WriteSuccess(w, rules)
+}
+
+// SetSessionRootRules godoc
+// @Summary      Configurar reglas de notificación de session-root
+// @Description  Reglas aplicadas a las terminales cuyo directorio de trabajo pertenece al session-root
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        rootPath  path      string                      true  "Path del session-root (URL encoded)"
+// @Param        request   body      services.NotificationRules  true  "Reglas"
+// @Success      200       {object}  handlers.APIResponse{data=services.NotificationRules}
+// @Failure      400       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/notification-rules [put]
+// @Security     BasicAuth
+func (h *NotificationsHandler) SetSessionRootRules(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+	if rootPath == "" {
+		WriteBadRequest(w, "root path requerido")
+		return
+	}
+
+	var rules services.NotificationRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.notifier.SetSessionRootRules(rootPath, &rules); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, rules)
+}
+
+// DeleteSessionRootRules godoc
+// @Summary      Eliminar reglas de notificación de session-root
+// @Tags         notifications
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/notification-rules [delete]
+// @Security     BasicAuth
+func (h *NotificationsHandler) DeleteSessionRootRules(w http.ResponseWriter, r *http.Request) {
+	h.notifier.DeleteSessionRootRules(URLParamDecoded(r, "rootPath"))
+	WriteSuccess(w, map[string]string{"message": "Reglas eliminadas"})
+}
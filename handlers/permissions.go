@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/pkg/validator"
+	"claude-monitor/services"
+)
+
+// PermissionsHandler maneja respuestas a prompts de permiso, políticas y auditoría
+type PermissionsHandler struct {
+	permissions *services.PermissionService
+	audit       *services.AuditLog
+}
+
+// NewPermissionsHandler crea un nuevo handler
+func NewPermissionsHandler(permissions *services.PermissionService, audit *services.AuditLog) *PermissionsHandler {
+	return &PermissionsHandler{
+		permissions: permissions,
+		audit:       audit,
+	}
+}
+
+// Answer godoc
+// @Summary      Responder permiso
+// @Description  Responde el prompt de permiso pendiente de una terminal Claude (allow, always_allow, deny)
+// @Tags         permissions
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                                true  "ID de la terminal"
+// @Param        request     body      validator.PermissionAnswerRequest     true  "Acción"
+// @Success      200         {object}  handlers.APIResponse{data=services.PermissionAnswer}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Failure      409         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/permission [post]
+// @Security     BasicAuth
+func (h *PermissionsHandler) Answer(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+	if id == "" {
+		WriteBadRequest(w, "terminal id requerido")
+		return
+	}
+
+	req, err := validator.DecodeAndValidate(r, validator.ValidatePermissionAnswer)
+	if err != nil {
+		if apiErr, ok := err.(*apierrors.APIError); ok {
+			apierrors.WriteError(w, apiErr)
+		} else {
+			WriteBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	answer, err := h.permissions.Answer(id, services.PermissionAction(req.Action), RequestActor(r))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "no encontrada"):
+			WriteNotFound(w, "terminal")
+		case strings.Contains(err.Error(), "no hay permiso pendiente"):
+			WriteConflict(w, err.Error())
+		default:
+			WriteBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, answer)
+}
+
+// GetTerminalPolicy godoc
+// @Summary      Obtener política de permisos de terminal
+// @Description  Retorna la política de permisos configurada para la terminal y la política efectiva
+// @Tags         permissions
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse{data=services.PermissionPolicy}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/permission-policy [get]
+// @Security     BasicAuth
+func (h *PermissionsHandler) GetTerminalPolicy(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+
+	policy, ok := h.permissions.GetTerminalPolicy(id)
+	if !ok {
+		WriteNotFound(w, "politica")
+		return
+	}
+
+	WriteSuccess(w, policy)
+}
+
+// SetTerminalPolicy godoc
+// @Summary      Configurar política de permisos de terminal
+// @Description  Reemplaza la política de auto-respuesta de permisos de una terminal. Las reglas se evalúan en orden y gana la primera que coincide
+// @Tags         permissions
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                     true  "ID de la terminal"
+// @Param        request     body      services.PermissionPolicy  true  "Política"
+// @Success      200         {object}  handlers.APIResponse{data=services.PermissionPolicy}
+// @Failure      400         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/permission-policy [put]
+// @Security     BasicAuth
+func (h *PermissionsHandler) SetTerminalPolicy(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+
+	var policy services.PermissionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.permissions.SetTerminalPolicy(id, &policy); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	h.audit.Record(services.AuditEntry{
+		Actor:      RequestActor(r),
+		Action:     "permission.policy.set",
+		TerminalID: id,
+		Details:    map[string]string{"scope": "terminal", "rules": strconv.Itoa(len(policy.Rules))},
+	})
+
+	WriteSuccess(w, policy)
+}
+
+// DeleteTerminalPolicy godoc
+// @Summary      Eliminar política de permisos de terminal
+// @Tags         permissions
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/permission-policy [delete]
+// @Security     BasicAuth
+func (h *PermissionsHandler) DeleteTerminalPolicy(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+
+	h.permissions.DeleteTerminalPolicy(id)
+	h.audit.Record(services.AuditEntry{
+		Actor:      RequestActor(r),
+		Action:     "permission.policy.delete",
+		TerminalID: id,
+		Details:    map[string]string{"scope": "terminal"},
+	})
+
+	WriteSuccess(w, map[string]string{"message": "Politica eliminada"})
+}
+
+// GetSessionRootPolicy godoc
+// @Summary      Obtener política de permisos de session-root
+// @Tags         permissions
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse{data=services.PermissionPolicy}
+// @Failure      404       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/permission-policy [get]
+// @Security     BasicAuth
+func (h *PermissionsHandler) GetSessionRootPolicy(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+
+	policy, ok := h.permissions.GetSessionRootPolicy(rootPath)
+	if !ok {
+		WriteNotFound(w, "politica")
+		return
+	}
+
+	WriteSuccess(w, policy)
+}
+
+// SetSessionRootPolicy godoc
+// @Summary      Configurar política de permisos de session-root
+// @Description  Política aplicada a todas las terminales cuyo directorio de trabajo pertenece al session-root (salvo que la terminal tenga su propia política)
+// @Tags         permissions
+// @Accept       json
+// @Produce      json
+// @Param        rootPath  path      string                     true  "Path del session-root (URL encoded)"
+// @Param        request   body      services.PermissionPolicy  true  "Política"
+// @Success      200       {object}  handlers.APIResponse{data=services.PermissionPolicy}
+// @Failure      400       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/permission-policy [put]
+// @Security     BasicAuth
+func (h *PermissionsHandler) SetSessionRootPolicy(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+	if rootPath == "" {
+		WriteBadRequest(w, "root path requerido")
+		return
+	}
+
+	var policy services.PermissionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.permissions.SetSessionRootPolicy(rootPath, &policy); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	h.audit.Record(services.AuditEntry{
+		Actor:   RequestActor(r),
+		Action:  "permission.policy.set",
+		Details: map[string]string{"scope": "session_root", "root": rootPath, "rules": strconv.Itoa(len(policy.Rules))},
+	})
+
+	WriteSuccess(w, policy)
+}
+
+// DeleteSessionRootPolicy godoc
+// @Summary      Eliminar política de permisos de session-root
+// @Tags         permissions
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/permission-policy [delete]
+// @Security     BasicAuth
+func (h *PermissionsHandler) DeleteSessionRootPolicy(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+
+	h.permissions.DeleteSessionRootPolicy(rootPath)
+	h.audit.Record(services.AuditEntry{
+		Actor:   RequestActor(r),
+		Action:  "permission.policy.delete",
+		Details: map[string]string{"scope": "session_root", "root": rootPath},
+	})
+
+	WriteSuccess(w, map[string]string{"message": "Politica eliminada"})
+}
+
+// Audit godoc
+// @Summary      Log de auditoría
+// @Description  Retorna decisiones de permisos y cambios de políticas, de la más reciente a la más antigua
+// @Tags         permissions
+// @Produce      json
+// @Param        terminal_id  query     string  false  "Filtrar por terminal"
+// @Param        action       query     string  false  "Filtrar por acción (ej: permission.auto)"
+// @Param        actor        query     string  false  "Filtrar por actor"
+// @Param        since        query     string  false  "RFC3339"
+// @Param        limit        query     int     false  "Máximo de entradas (default: 100)"
+// @Success      200          {object}  handlers.APIResponse{data=[]services.AuditEntry}
+// @Failure      400          {object}  handlers.APIResponse
+// @Router       /audit [get]
+// @Security     BasicAuth
+func (h *PermissionsHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := services.AuditFilter{
+		TerminalID: q.Get("terminal_id"),
+		Action:     q.Get("action"),
+		Actor:      q.Get("actor"),
+		Limit:      100,
+	}
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			WriteBadRequest(w, "limit invalido")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if s := q.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			WriteBadRequest(w, "since invalido (RFC3339)")
+			return
+		}
+		filter.Since = since
+	}
+
+	entries, err := h.audit.List(filter)
+	if err != nil {
+		WriteInternalError(w, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(SuccessWithMeta(entries, &APIMeta{Total: len(entries), Limit: filter.Limit}))
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"claude-monitor/pkg/auth"
+	apierrors "claude-monitor/pkg/errors"
+	"claude-monitor/services"
+)
+
+// SchedulesHandler maneja prompts programados (cron)
+type SchedulesHandler struct {
+	scheduler *services.Scheduler
+}
+
+// NewSchedulesHandler crea un nuevo handler
+func NewSchedulesHandler(scheduler *services.Scheduler) *SchedulesHandler {
+	return &SchedulesHandler{
+		scheduler: scheduler,
+	}
+}
+
+// ScheduleRequest cuerpo de POST/PUT /schedules
+type ScheduleRequest struct {
+	Name          string                `json:"name,omitempty"`
+	Cron          string                `json:"cron"`
+	Timezone      string                `json:"timezone,omitempty"`
+	Mode          services.ScheduleMode `json:"mode,omitempty"`
+	Request       services.JobRequest   `json:"request"`
+	SkipIfRunning bool                  `json:"skip_if_running,omitempty"`
+	KeepTerminal  bool                  `json:"keep_terminal,omitempty"`
+	Disabled      bool                  `json:"disabled,omitempty"`
+}
+
+// decodeSchedule lee el cuerpo como Schedule
+func decodeSchedule(r *http.Request) (services.Schedule, error) {
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return services.Schedule{}, err
+	}
+	return services.Schedule{
+		Name:          req.Name,
+		Cron:          req.Cron,
+		Timezone:      req.Timezone,
+		Mode:          req.Mode,
+		Request:       req.Request,
+		SkipIfRunning: req.SkipIfRunning,
+		KeepTerminal:  req.KeepTerminal,
+		Disabled:      req.Disabled,
+	}, nil
+}
+
+// allowScheduleMode el modo terminal abre terminales Claude: además del
+// jobs:write de la ruta requiere terminals:write
+func allowScheduleMode(w http.ResponseWriter, r *http.Request, modes ...services.ScheduleMode) bool {
+	for _, mode := range modes {
+		if mode == services.ScheduleTerminal && !auth.FromContext(r.Context()).Can(auth.PermTerminalsWrite) {
+			apierrors.WriteError(w, apierrors.New(apierrors.ErrCodeForbidden, "permiso requerido: "+string(auth.PermTerminalsWrite)))
+			return false
+		}
+	}
+	return true
+}
+
+// List godoc
+// @Summary      Listar schedules
+// @Tags         schedules
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.Schedule}
+// @Router       /schedules [get]
+// @Security     BasicAuth
+func (h *SchedulesHandler) List(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.scheduler.List())
+}
+
+// Create godoc
+// @Summary      Crear schedule
+// @Description  Programa un prompt con una expresión cron de 5 campos. mode headless lo ejecuta como job claude -p y terminal abre una terminal Claude y le envía el prompt (requiere además terminals:write)
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param        request  body      handlers.ScheduleRequest  true  "Schedule"
+// @Success      201      {object}  handlers.APIResponse{data=services.Schedule}
+// @Failure      400      {object}  handlers.APIResponse
+// @Failure      403      {object}  handlers.APIResponse
+// @Router       /schedules [post]
+// @Security     BasicAuth
+func (h *SchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	sched, err := decodeSchedule(r)
+	if err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+	if !allowScheduleMode(w, r, sched.Mode) {
+		return
+	}
+
+	created, err := h.scheduler.Create(sched)
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteCreated(w, created)
+}
+
+// Get godoc
+// @Summary      Obtener schedule
+// @Tags         schedules
+// @Produce      json
+// @Param        scheduleID  path      string  true  "ID del schedule"
+// @Success      200         {object}  handlers.APIResponse{data=services.Schedule}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /schedules/{scheduleID} [get]
+// @Security     BasicAuth
+func (h *SchedulesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	sched, err := h.scheduler.Get(URLParam(r, "scheduleID"))
+	if err != nil {
+		WriteNotFound(w, "schedule")
+		return
+	}
+
+	WriteSuccess(w, sched)
+}
+
+// Update godoc
+// @Summary      Actualizar schedule
+// @Description  Reemplaza la definición del schedule y recalcula la próxima ejecución
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param        scheduleID  path      string                    true  "ID del schedule"
+// @Param        request     body      handlers.ScheduleRequest  true  "Schedule"
+// @Success      200         {object}  handlers.APIResponse{data=services.Schedule}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      403         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /schedules/{scheduleID} [put]
+// @Security     BasicAuth
+func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "scheduleID")
+	existing, err := h.scheduler.Get(id)
+	if err != nil {
+		WriteNotFound(w, "schedule")
+		return
+	}
+
+	sched, err := decodeSchedule(r)
+	if err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+	if !allowScheduleMode(w, r, existing.Mode, sched.Mode) {
+		return
+	}
+
+	updated, err := h.scheduler.Update(id, sched)
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, updated)
+}
+
+// Delete godoc
+// @Summary      Eliminar schedule
+// @Description  Elimina el schedule; su historial de ejecuciones se conserva
+// @Tags         schedules
+// @Produce      json
+// @Param        scheduleID  path      string  true  "ID del schedule"
+// @Success      200         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /schedules/{scheduleID} [delete]
+// @Security     BasicAuth
+func (h *SchedulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.scheduler.Delete(URLParam(r, "scheduleID")); err != nil {
+		WriteNotFound(w, "schedule")
+		return
+	}
+
+	WriteSuccess(w, map[string]string{"message": "Schedule eliminado"})
+}
+
+// Runs godoc
+// @Summary      Historial de ejecuciones
+// @Description  Ejecuciones de la más reciente a la más antigua, con el job, la terminal y el session_id resultantes
+// @Tags         schedules
+// @Produce      json
+// @Param        scheduleID  path      string  true   "ID del schedule"
+// @Param        limit       query     int     false  "Máximo de ejecuciones (default: 50)"
+// @Success      200         {object}  handlers.APIResponse{data=[]services.ScheduleRun}
+// @Failure      400         {object}  handlers.APIResponse
+// @Router       /schedules/{scheduleID}/runs [get]
+// @Security     BasicAuth
+func (h *SchedulesHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			WriteBadRequest(w, "limit invalido")
+			return
+		}
+		limit = n
+	}
+
+	runs := h.scheduler.Runs(URLParam(r, "scheduleID"), limit)
+	if runs == nil {
+		runs = []services.ScheduleRun{}
+	}
+	json.NewEncoder(w).Encode(SuccessWithMeta(runs, &APIMeta{Total: len(runs), Limit: limit}))
+}
+
+// RunNow godoc
+// @Summary      Ejecutar schedule ahora
+// @Description  Lanza una ejecución manual; con skip_if_running queda como skipped si la anterior sigue en curso
+// @Tags         schedules
+// @Produce      json
+// @Param        scheduleID  path      string  true  "ID del schedule"
+// @Success      201         {object}  handlers.APIResponse{data=services.ScheduleRun}
+// @Failure      403         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /schedules/{scheduleID}/run [post]
+// @Security     BasicAuth
+func (h *SchedulesHandler) RunNow(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "scheduleID")
+	sched, err := h.scheduler.Get(id)
+	if err != nil {
+		WriteNotFound(w, "schedule")
+		return
+	}
+	if !allowScheduleMode(w, r, sched.Mode) {
+		return
+	}
+
+	run, err := h.scheduler.RunNow(id)
+	if err != nil {
+		WriteNotFound(w, "schedule")
+		return
+	}
+
+	WriteCreated(w, run)
+}
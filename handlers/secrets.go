@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"claude-monitor/services"
+)
+
+// SecretsHandler maneja el almacén de secretos cifrados
+type SecretsHandler struct {
+	secrets *services.SecretStore
+}
+
+// NewSecretsHandler crea un nuevo handler
+func NewSecretsHandler(secrets *services.SecretStore) *SecretsHandler {
+	return &SecretsHandler{
+		secrets: secrets,
+	}
+}
+
+// SetSecretRequest cuerpo de PUT /secrets/{name}
+type SetSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// List godoc
+// @Summary      Listar secretos
+// @Description  Retorna solo nombres y fechas; los valores nunca se exponen
+// @Tags         secrets
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.SecretInfo}
+// @Router       /secrets [get]
+// @Security     BasicAuth
+func (h *SecretsHandler) List(w http.ResponseWriter, r *http.Request) {
+	secrets := h.secrets.List()
+	json.NewEncoder(w).Encode(SuccessWithMeta(secrets, &APIMeta{Total: len(secrets)}))
+}
+
+// Set godoc
+// @Summary      Crear o reemplazar secreto
+// @Description  Cifra y guarda el valor. Las terminales lo referencian por nombre en secrets (variable -> nombre del secreto)
+// @Tags         secrets
+// @Accept       json
+// @Produce      json
+// @Param        name     path      string                     true  "Nombre del secreto"
+// @Param        request  body      handlers.SetSecretRequest  true  "Valor"
+// @Success      200      {object}  handlers.APIResponse{data=services.SecretInfo}
+// @Failure      400      {object}  handlers.APIResponse
+// @Failure      500      {object}  handlers.APIResponse
+// @Router       /secrets/{name} [put]
+// @Security     BasicAuth
+func (h *SecretsHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	info, err := h.secrets.Set(URLParam(r, "name"), req.Value)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalido") || strings.Contains(err.Error(), "requerido") {
+			WriteBadRequest(w, err.Error())
+		} else {
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, info)
+}
+
+// Delete godoc
+// @Summary      Eliminar secreto
+// @Tags         secrets
+// @Produce      json
+// @Param        name  path      string  true  "Nombre del secreto"
+// @Success      200   {object}  handlers.APIResponse
+// @Failure      404   {object}  handlers.APIResponse
+// @Router       /secrets/{name} [delete]
+// @Security     BasicAuth
+func (h *SecretsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.secrets.Delete(URLParam(r, "name")); err != nil {
+		WriteNotFound(w, "secreto")
+		return
+	}
+
+	WriteSuccess(w, map[string]string{"message": "Secreto eliminado"})
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
 
 	"claude-monitor/services"
 )
@@ -12,13 +14,17 @@ import (
 type SessionRootsHandler struct {
 	claude    *services.ClaudeService
 	analytics *services.AnalyticsService
+	git       *services.GitService
+	audit     *services.AuditLog
 }
 
 // NewSessionRootsHandler crea un nuevo handler
-func NewSessionRootsHandler(claude *services.ClaudeService, analytics *services.AnalyticsService) *SessionRootsHandler {
+func NewSessionRootsHandler(claude *services.ClaudeService, analytics *services.AnalyticsService, git *services.GitService, audit *services.AuditLog) *SessionRootsHandler {
 	return &SessionRootsHandler{
 		claude:    claude,
 		analytics: analytics,
+		git:       git,
+		audit:     audit,
 	}
 }
 
@@ -73,10 +79,12 @@ func (h *SessionRootsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	var totalMessages int
 	var totalSize int64
 	var emptySessions int
+	var totalTokens services.TokenUsage
 
 	for _, s := range sessions {
 		totalMessages += s.MessageCount
 		totalSize += s.SizeBytes
+		totalTokens.Add(s.Tokens)
 		if s.MessageCount == 0 {
 			emptySessions++
 		}
@@ -91,11 +99,73 @@ func (h *SessionRootsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		"total_messages": totalMessages,
 		"total_size":     totalSize,
 		"empty_sessions": emptySessions,
+		"total_tokens":   totalTokens,
+	}
+	if summary := h.git.Summary(root.RealPath); summary != nil {
+		response["git"] = summary
 	}
 
 	WriteSuccess(w, response)
 }
 
+// Move godoc
+// @Summary      Mover session-root
+// @Description  Mueve todas las sesiones a otro directorio cuando el repositorio se reubicó en disco. Cada sesión se mueve de forma atómica; las que fallan se reportan en failed
+// @Tags         session-roots
+// @Accept       json
+// @Produce      json
+// @Param        rootPath  path      string                   true  "Path del session-root (URL encoded)"
+// @Param        request   body      object{new_path=string}  true  "Nueva ruta absoluta del repositorio"
+// @Success      200       {object}  handlers.APIResponse{data=services.SessionRootMoveResult}
+// @Failure      400       {object}  handlers.APIResponse
+// @Failure      404       {object}  handlers.APIResponse
+// @Failure      409       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/move [post]
+// @Security     BasicAuth
+func (h *SessionRootsHandler) Move(w http.ResponseWriter, r *http.Request) {
+	path := URLParamDecoded(r, "rootPath")
+	if path == "" {
+		WriteBadRequest(w, "root path requerido")
+		return
+	}
+
+	var req struct {
+		NewPath string `json:"new_path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+	if req.NewPath == "" {
+		WriteBadRequest(w, "new_path es requerido")
+		return
+	}
+
+	result, err := h.claude.MoveSessionRoot(path, req.NewPath)
+	if err != nil {
+		if err == os.ErrInvalid {
+			WriteBadRequest(w, "new_path debe ser una ruta absoluta")
+			return
+		}
+		if err == os.ErrExist {
+			WriteConflict(w, "el session-root ya esta en ese directorio")
+			return
+		}
+		if os.IsNotExist(err) {
+			WriteNotFound(w, "session-root")
+			return
+		}
+		WriteInternalError(w, err.Error())
+		return
+	}
+
+	h.analytics.Invalidate(path)
+	h.analytics.Invalidate(result.NewProjectPath)
+	recordAudit(h.audit, r, "session_root.move", "", map[string]string{"session_root": path, "new_path": req.NewPath})
+
+	WriteSuccess(w, result)
+}
+
 // Delete godoc
 // @Summary      Eliminar session-root
 // @Description  Elimina un session-root y todas sus sesiones
@@ -122,6 +192,7 @@ func (h *SessionRootsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Invalidar cache
 	h.analytics.Invalidate(path)
+	recordAudit(h.audit, r, "session_root.delete", "", map[string]string{"session_root": path})
 
 	WriteSuccess(w, map[string]string{"message": "Session-root eliminado"})
 }
@@ -153,3 +224,51 @@ func (h *SessionRootsHandler) GetActivity(w http.ResponseWriter, r *http.Request
 
 	WriteSuccess(w, activity)
 }
+
+// Git godoc
+// @Summary      Estado git del session-root
+// @Description  Rama, archivos modificados, ahead/behind y commits recientes del repositorio del session-root, junto con los commits hechos durante cada sesión (solo sesiones con commits)
+// @Tags         session-roots
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse
+// @Failure      400       {object}  handlers.APIResponse
+// @Failure      404       {object}  handlers.APIResponse
+// @Failure      500       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/git [get]
+// @Security     BasicAuth
+func (h *SessionRootsHandler) Git(w http.ResponseWriter, r *http.Request) {
+	path := URLParamDecoded(r, "rootPath")
+	if path == "" {
+		WriteBadRequest(w, "root path requerido")
+		return
+	}
+
+	root, err := h.claude.GetProject(path)
+	if err != nil {
+		WriteNotFound(w, "session-root")
+		return
+	}
+
+	status, err := h.git.Status(root.RealPath)
+	if err != nil {
+		if errors.Is(err, services.ErrNotGitRepo) {
+			WriteBadRequest(w, err.Error())
+		} else {
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	sessions, _ := h.claude.ListSessions(path)
+	commits, err := h.git.CorrelateSessions(root.RealPath, sessions)
+	if err != nil {
+		WriteInternalError(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"status":   status,
+		"sessions": commits,
+	})
+}
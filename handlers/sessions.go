@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 	"strconv"
@@ -14,14 +15,18 @@ type SessionsHandler struct {
 	claude    *services.ClaudeService
 	terminals *services.TerminalService
 	analytics *services.AnalyticsService
+	git       *services.GitService
+	audit     *services.AuditLog
 }
 
 // NewSessionsHandler crea un nuevo handler
-func NewSessionsHandler(claude *services.ClaudeService, terminals *services.TerminalService, analytics *services.AnalyticsService) *SessionsHandler {
+func NewSessionsHandler(claude *services.ClaudeService, terminals *services.TerminalService, analytics *services.AnalyticsService, git *services.GitService, audit *services.AuditLog) *SessionsHandler {
 	return &SessionsHandler{
 		claude:    claude,
 		terminals: terminals,
 		analytics: analytics,
+		git:       git,
+		audit:     audit,
 	}
 }
 
@@ -84,6 +89,46 @@ func (h *SessionsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	WriteSuccess(w, session)
 }
 
+// Commits godoc
+// @Summary      Commits de la sesión
+// @Description  Commits de las ramas locales del repositorio de la sesión hechos entre su creación y su última actividad (más un margen de 2 minutos)
+// @Tags         sessions
+// @Produce      json
+// @Param        rootPath   path      string  true  "Path del session-root (URL encoded)"
+// @Param        sessionID  path      string  true  "ID de la sesión"
+// @Success      200        {object}  handlers.APIResponse{data=services.SessionCommits}
+// @Failure      400        {object}  handlers.APIResponse
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/sessions/{sessionID}/commits [get]
+// @Security     BasicAuth
+func (h *SessionsHandler) Commits(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+	sessionID := URLParam(r, "sessionID")
+
+	if rootPath == "" || sessionID == "" {
+		WriteBadRequest(w, "root path y session id requeridos")
+		return
+	}
+
+	session, err := h.claude.GetSession(rootPath, sessionID)
+	if err != nil {
+		WriteNotFound(w, "sesion")
+		return
+	}
+
+	commits, err := h.git.SessionCommits(*session)
+	if err != nil {
+		if errors.Is(err, services.ErrNotGitRepo) {
+			WriteBadRequest(w, err.Error())
+		} else {
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, commits)
+}
+
 // GetMessages godoc
 // @Summary      Obtener mensajes de sesión
 // @Description  Retorna todos los mensajes de una sesión
@@ -115,6 +160,78 @@ func (h *SessionsHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(SuccessWithMeta(messages, &APIMeta{Total: len(messages)}))
 }
 
+// ListAgents godoc
+// @Summary      Listar subagentes de una sesión
+// @Description  Retorna los transcripts de subagentes lanzados con Task, enlazados a su llamada en la sesión padre
+// @Tags         sessions
+// @Produce      json
+// @Param        rootPath   path      string  true  "Path del session-root (URL encoded)"
+// @Param        sessionID  path      string  true  "ID de la sesión"
+// @Success      200        {object}  handlers.APIResponse{data=[]services.SessionAgent}
+// @Failure      400        {object}  handlers.APIResponse
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/sessions/{sessionID}/agents [get]
+// @Security     BasicAuth
+func (h *SessionsHandler) ListAgents(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+	sessionID := URLParam(r, "sessionID")
+
+	if rootPath == "" || sessionID == "" {
+		WriteBadRequest(w, "root path y session id requeridos")
+		return
+	}
+
+	agents, err := h.claude.ListSessionAgents(rootPath, sessionID)
+	if err != nil {
+		WriteNotFound(w, "sesion")
+		return
+	}
+
+	json.NewEncoder(w).Encode(SuccessWithMeta(agents, &APIMeta{Total: len(agents)}))
+}
+
+// GetAgent godoc
+// @Summary      Obtener subagente
+// @Tags         sessions
+// @Produce      json
+// @Param        rootPath   path      string  true  "Path del session-root (URL encoded)"
+// @Param        sessionID  path      string  true  "ID de la sesión"
+// @Param        agentID    path      string  true  "ID del subagente"
+// @Success      200        {object}  handlers.APIResponse{data=services.SessionAgent}
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/sessions/{sessionID}/agents/{agentID} [get]
+// @Security     BasicAuth
+func (h *SessionsHandler) GetAgent(w http.ResponseWriter, r *http.Request) {
+	agent, err := h.claude.GetSessionAgent(URLParamDecoded(r, "rootPath"), URLParam(r, "sessionID"), URLParam(r, "agentID"))
+	if err != nil {
+		WriteNotFound(w, "subagente")
+		return
+	}
+
+	WriteSuccess(w, agent)
+}
+
+// GetAgentMessages godoc
+// @Summary      Obtener mensajes de un subagente
+// @Tags         sessions
+// @Produce      json
+// @Param        rootPath   path      string  true  "Path del session-root (URL encoded)"
+// @Param        sessionID  path      string  true  "ID de la sesión"
+// @Param        agentID    path      string  true  "ID del subagente"
+// @Success      200        {object}  handlers.APIResponse{data=[]services.SessionMessage}
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/sessions/{sessionID}/agents/{agentID}/messages [get]
+// @Security     BasicAuth
+func (h *SessionsHandler) GetAgentMessages(w http.ResponseWriter, r *http.Request) {
+	messages, err := h.claude.GetSessionAgentMessages(URLParamDecoded(r, "rootPath"), URLParam(r, "sessionID"), URLParam(r, "agentID"))
+	if err != nil {
+		WriteNotFound(w, "subagente")
+		return
+	}
+
+	json.NewEncoder(w).Encode(SuccessWithMeta(messages, &APIMeta{Total: len(messages)}))
+}
+
 // GetRealTimeMessages godoc
 // @Summary      Obtener mensajes en tiempo real
 // @Description  Retorna mensajes nuevos desde una línea específica (para polling)
@@ -187,6 +304,7 @@ func (h *SessionsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Invalidar cache
 	h.analytics.Invalidate(rootPath)
+	recordAudit(h.audit, r, "session.delete", "", map[string]string{"session_root": rootPath, "session_id": sessionID})
 
 	WriteSuccess(w, map[string]string{"message": "Sesion eliminada"})
 }
@@ -223,6 +341,7 @@ func (h *SessionsHandler) DeleteMultiple(w http.ResponseWriter, r *http.Request)
 	// Eliminar del registro de terminales
 	for _, id := range req.SessionIDs {
 		h.terminals.RemoveFromSaved(id)
+		recordAudit(h.audit, r, "session.delete", "", map[string]string{"session_root": rootPath, "session_id": id})
 	}
 
 	// Invalidar cache
@@ -258,6 +377,7 @@ func (h *SessionsHandler) CleanEmpty(w http.ResponseWriter, r *http.Request) {
 
 	// Invalidar cache
 	h.analytics.Invalidate(rootPath)
+	recordAudit(h.audit, r, "session.clean", "", map[string]string{"session_root": rootPath, "deleted": strconv.Itoa(deleted)})
 
 	WriteSuccess(w, map[string]interface{}{"deleted": deleted})
 }
@@ -310,6 +430,7 @@ func (h *SessionsHandler) Import(w http.ResponseWriter, r *http.Request) {
 
 	// Marcar como importado en el servicio de terminales
 	h.terminals.MarkAsImported(req.SessionID, name, session.RealPath)
+	recordAudit(h.audit, r, "session.import", req.SessionID, map[string]string{"session_root": rootPath, "session_id": req.SessionID})
 
 	WriteSuccess(w, map[string]interface{}{
 		"session_id":   req.SessionID,
@@ -364,13 +485,15 @@ func (h *SessionsHandler) Rename(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, "session.rename", "", map[string]string{"session_root": rootPath, "session_id": sessionID, "name": req.Name})
+
 	session.Name = req.Name
 	WriteSuccess(w, session)
 }
 
 // Move godoc
 // @Summary      Mover sesión a otro directorio
-// @Description  Mueve una sesión a otro directorio, actualizando todas las rutas internas del JSONL
+// @Description  Mueve una sesión y sus subagentes a otro directorio de forma atómica, reescribiendo solo los campos de ruta (cwd, file_path, path...) del JSONL
 // @Tags         sessions
 // @Accept       json
 // @Produce      json
@@ -412,7 +535,7 @@ func (h *SessionsHandler) Move(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err == os.ErrExist {
-			WriteConflict(w, "la sesion ya esta en ese directorio")
+			WriteConflict(w, "la sesion ya existe en el directorio destino")
 			return
 		}
 		if os.IsNotExist(err) {
@@ -426,6 +549,7 @@ func (h *SessionsHandler) Move(w http.ResponseWriter, r *http.Request) {
 	// Invalidar cache
 	h.analytics.Invalidate(rootPath)
 	h.analytics.Invalidate(services.EncodeProjectPath(req.NewPath))
+	recordAudit(h.audit, r, "session.move", "", map[string]string{"session_root": rootPath, "session_id": sessionID, "new_path": req.NewPath})
 
 	WriteSuccess(w, nil)
 }
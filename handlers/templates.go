@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"claude-monitor/services"
+)
+
+// TemplatesHandler maneja templates de terminal y el default de cada session-root
+type TemplatesHandler struct {
+	templates *services.TemplateService
+}
+
+// NewTemplatesHandler crea un nuevo handler
+func NewTemplatesHandler(templates *services.TemplateService) *TemplatesHandler {
+	return &TemplatesHandler{
+		templates: templates,
+	}
+}
+
+// DefaultTemplateRequest cuerpo de PUT /session-roots/{rootPath}/default-template
+type DefaultTemplateRequest struct {
+	TemplateID string `json:"template_id"`
+}
+
+// List godoc
+// @Summary      Listar templates de terminal
+// @Tags         templates
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.TerminalTemplate}
+// @Router       /templates [get]
+// @Security     BasicAuth
+func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.templates.List())
+}
+
+// Create godoc
+// @Summary      Crear template de terminal
+// @Description  Guarda un preset de modelo, system prompt, herramientas, modo de permisos y directorios adicionales
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        request  body      services.TerminalTemplate  true  "Template"
+// @Success      201      {object}  handlers.APIResponse{data=services.TerminalTemplate}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /templates [post]
+// @Security     BasicAuth
+func (h *TemplatesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var tmpl services.TerminalTemplate
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	created, err := h.templates.Create(tmpl)
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteCreated(w, created)
+}
+
+// Get godoc
+// @Summary      Obtener template de terminal
+// @Tags         templates
+// @Produce      json
+// @Param        templateID  path      string  true  "ID del template"
+// @Success      200         {object}  handlers.APIResponse{data=services.TerminalTemplate}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /templates/{templateID} [get]
+// @Security     BasicAuth
+func (h *TemplatesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Get(URLParam(r, "templateID"))
+	if err != nil {
+		WriteNotFound(w, "template")
+		return
+	}
+
+	WriteSuccess(w, tmpl)
+}
+
+// Update godoc
+// @Summary      Actualizar template de terminal
+// @Description  Reemplaza el template; afecta solo a las terminales creadas después
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        templateID  path      string                     true  "ID del template"
+// @Param        request     body      services.TerminalTemplate  true  "Template"
+// @Success      200         {object}  handlers.APIResponse{data=services.TerminalTemplate}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /templates/{templateID} [put]
+// @Security     BasicAuth
+func (h *TemplatesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "templateID")
+	if _, err := h.templates.Get(id); err != nil {
+		WriteNotFound(w, "template")
+		return
+	}
+
+	var tmpl services.TerminalTemplate
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	updated, err := h.templates.Update(id, tmpl)
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, updated)
+}
+
+// Delete godoc
+// @Summary      Eliminar template de terminal
+// @Description  Elimina el template y lo quita como default de los session-roots que lo usaban
+// @Tags         templates
+// @Produce      json
+// @Param        templateID  path      string  true  "ID del template"
+// @Success      200         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /templates/{templateID} [delete]
+// @Security     BasicAuth
+func (h *TemplatesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.templates.Delete(URLParam(r, "templateID")); err != nil {
+		WriteNotFound(w, "template")
+		return
+	}
+
+	WriteSuccess(w, map[string]string{"message": "Template eliminado"})
+}
+
+// GetSessionRootDefault godoc
+// @Summary      Obtener template por defecto de session-root
+// @Tags         templates
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse{data=services.TerminalTemplate}
+// @Failure      404       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/default-template [get]
+// @Security     BasicAuth
+func (h *TemplatesHandler) GetSessionRootDefault(w http.ResponseWriter, r *http.Request) {
+	tmpl, ok := h.templates.GetSessionRootDefault(URLParamDecoded(r, "rootPath"))
+	if !ok {
+		WriteNotFound(w, "template")
+		return
+	}
+
+	WriteSuccess(w, tmpl)
+}
+
+// SetSessionRootDefault godoc
+// @Summary      Configurar template por defecto de session-root
+// @Description  Template aplicado a las terminales nuevas cuyo directorio de trabajo pertenece al session-root cuando no se indica template_id
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        rootPath  path      string                           true  "Path del session-root (URL encoded)"
+// @Param        request   body      handlers.DefaultTemplateRequest  true  "Template"
+// @Success      200       {object}  handlers.APIResponse{data=services.TerminalTemplate}
+// @Failure      400       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/default-template [put]
+// @Security     BasicAuth
+func (h *TemplatesHandler) SetSessionRootDefault(w http.ResponseWriter, r *http.Request) {
+	rootPath := URLParamDecoded(r, "rootPath")
+	if rootPath == "" {
+		WriteBadRequest(w, "root path requerido")
+		return
+	}
+
+	var req DefaultTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	if err := h.templates.SetSessionRootDefault(rootPath, req.TemplateID); err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	tmpl, _ := h.templates.GetSessionRootDefault(rootPath)
+	WriteSuccess(w, tmpl)
+}
+
+// DeleteSessionRootDefault godoc
+// @Summary      Quitar template por defecto de session-root
+// @Tags         templates
+// @Produce      json
+// @Param        rootPath  path      string  true  "Path del session-root (URL encoded)"
+// @Success      200       {object}  handlers.APIResponse
+// @Router       /session-roots/{rootPath}/default-template [delete]
+// @Security     BasicAuth
+func (h *TemplatesHandler) DeleteSessionRootDefault(w http.ResponseWriter, r *http.Request) {
+	h.templates.DeleteSessionRootDefault(URLParamDecoded(r, "rootPath"))
+	WriteSuccess(w, map[string]string{"message": "Template por defecto eliminado"})
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"claude-monitor/pkg/auth"
 	apierrors "claude-monitor/pkg/errors"
 	"claude-monitor/pkg/logger"
 	"claude-monitor/pkg/validator"
@@ -17,20 +21,20 @@ import (
 // TerminalsHandler maneja endpoints de terminales
 type TerminalsHandler struct {
 	terminals           *services.TerminalService
+	templates           *services.TemplateService
+	audit               *services.AuditLog
 	upgrader            websocket.Upgrader
 	allowedPathPrefixes []string
 }
 
 // NewTerminalsHandler crea un nuevo handler
-func NewTerminalsHandler(terminals *services.TerminalService, allowedPathPrefixes []string) *TerminalsHandler {
+func NewTerminalsHandler(terminals *services.TerminalService, templates *services.TemplateService, audit *services.AuditLog, allowedPathPrefixes, allowedOrigins []string) *TerminalsHandler {
 	return &TerminalsHandler{
 		terminals:           terminals,
+		templates:           templates,
+		audit:               audit,
 		allowedPathPrefixes: allowedPathPrefixes,
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin:     func(r *http.Request) bool { return true },
-		},
+		upgrader:            newWebSocketUpgrader(allowedOrigins),
 	}
 }
 
@@ -50,11 +54,11 @@ func (h *TerminalsHandler) List(w http.ResponseWriter, r *http.Request) {
 
 // Create godoc
 // @Summary      Crear terminal
-// @Description  Crea una nueva terminal PTY (tipo claude o terminal estándar)
+// @Description  Crea una nueva terminal PTY (tipo claude o terminal estándar). Con template_id, o con el template por defecto del session-root, los campos no enviados se toman del template. env, env_files y secrets (variable -> nombre del secreto) se inyectan en el proceso; limits restringe memoria, CPU y procesos
 // @Tags         terminals
 // @Accept       json
 // @Produce      json
-// @Param        request  body      services.TerminalConfig  true  "Configuración de terminal"
+// @Param        request  body      validator.TerminalConfigRequest  true  "Configuración de terminal"
 // @Success      201      {object}  handlers.APIResponse{data=services.TerminalInfo}
 // @Failure      400      {object}  handlers.APIResponse
 // @Failure      409      {object}  handlers.APIResponse
@@ -80,11 +84,43 @@ func (h *TerminalsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		WorkDir:         req.WorkDir,
 		Type:            req.Type,
 		Model:           req.Model,
+		SystemPrompt:    req.SystemPrompt,
+		PermissionMode:  req.PermissionMode,
 		Resume:          req.Resume,
 		Continue:        req.Continue,
 		AllowedTools:    req.AllowedTools,
 		DisallowedTools: req.DisallowedTools,
+		AdditionalDirs:  req.AdditionalDirs,
+		EnableHooks:     req.EnableHooks,
+		Worktree:        req.Worktree,
+		WorktreeBranch:  req.WorktreeBranch,
+		WatchFiles:      req.WatchFiles,
+		Env:             req.Env,
+		EnvFiles:        req.EnvFiles,
+		Secrets:         req.Secrets,
 	}
+	if req.Limits != nil {
+		cfg.Limits = &services.ResourceLimits{
+			MemoryMB:       req.Limits.MemoryMB,
+			CPUPercent:     req.Limits.CPUPercent,
+			MaxProcesses:   req.Limits.MaxProcesses,
+			CPUSeconds:     req.Limits.CPUSeconds,
+			OpenFiles:      req.Limits.OpenFiles,
+			FileSizeMB:     req.Limits.FileSizeMB,
+			AddressSpaceMB: req.Limits.AddressSpaceMB,
+		}
+	}
+
+	// Aplicar template explícito o el default del session-root
+	template, err := h.templates.Resolve(req.TemplateID, req.WorkDir)
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+	if template != nil {
+		cfg = template.Apply(cfg)
+	}
+	cfg.CreatedBy = RequestActor(r)
 
 	terminal, err := h.terminals.Create(cfg)
 	if err != nil {
@@ -98,6 +134,8 @@ func (h *TerminalsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, "terminal.create", terminal.ID, map[string]string{"work_dir": terminal.WorkDir})
+
 	WriteCreated(w, terminal)
 }
 
@@ -131,7 +169,7 @@ func (h *TerminalsHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 // Delete godoc
 // @Summary      Eliminar terminal
-// @Description  Elimina una terminal guardada (no activa)
+// @Description  Elimina una terminal guardada (no activa). Su worktree se elimina si no tiene cambios sin commit (409 en caso contrario); la rama se conserva si no está integrada
 // @Tags         terminals
 // @Accept       json
 // @Produce      json
@@ -148,10 +186,16 @@ func (h *TerminalsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.terminals.Delete(id); err != nil {
-		WriteBadRequest(w, err.Error())
+		if errors.Is(err, services.ErrWorktreeDirty) {
+			WriteConflict(w, err.Error())
+		} else {
+			WriteBadRequest(w, err.Error())
+		}
 		return
 	}
 
+	recordAudit(h.audit, r, "terminal.delete", id, nil)
+
 	WriteSuccess(w, map[string]string{"message": "Terminal eliminada"})
 }
 
@@ -179,9 +223,276 @@ func (h *TerminalsHandler) Kill(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, "terminal.kill", id, nil)
+
 	WriteSuccess(w, map[string]string{"message": "Terminal terminada"})
 }
 
+// Processes godoc
+// @Summary      Listar procesos de la terminal
+// @Description  Árbol de procesos bajo el PTY (leído de /proc): comando, cwd, estado, tiempo de CPU, RSS y puertos TCP en escucha
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse{data=[]services.ProcessInfo}
+// @Failure      404         {object}  handlers.APIResponse
+// @Failure      500         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/processes [get]
+// @Security     BasicAuth
+func (h *TerminalsHandler) Processes(w http.ResponseWriter, r *http.Request) {
+	processes, err := h.terminals.Processes(URLParam(r, "terminalID"))
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrada") {
+			WriteNotFound(w, "terminal")
+		} else {
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(SuccessWithMeta(processes, &APIMeta{Total: len(processes)}))
+}
+
+// Git godoc
+// @Summary      Estado git de la terminal
+// @Description  Rama, archivos modificados, ahead/behind y commits recientes del repositorio del work_dir, más los commits hechos desde que arrancó la terminal
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse{data=services.TerminalGit}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Failure      500         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/git [get]
+// @Security     BasicAuth
+func (h *TerminalsHandler) Git(w http.ResponseWriter, r *http.Request) {
+	git, err := h.terminals.Git(URLParam(r, "terminalID"))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "no encontrada"):
+			WriteNotFound(w, "terminal")
+		case errors.Is(err, services.ErrNotGitRepo):
+			WriteBadRequest(w, err.Error())
+		default:
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, git)
+}
+
+// FileChanges godoc
+// @Summary      Cambios de archivos de la terminal
+// @Description  Archivos creados, modificados y eliminados en el work_dir desde que se observa la terminal (respeta .gitignore). Requiere watch_files o watch.enabled
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID  path      string  true   "ID de la terminal"
+// @Param        since       query     string  false  "Solo cambios posteriores (RFC3339)"
+// @Param        limit       query     int     false  "Máximo de eventos (los más recientes)"
+// @Success      200         {object}  handlers.APIResponse{data=services.FileChangeLog}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/file-changes [get]
+// @Security     BasicAuth
+func (h *TerminalsHandler) FileChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			WriteBadRequest(w, "since invalido (RFC3339)")
+			return
+		}
+		since = t
+	}
+
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			WriteBadRequest(w, "limit invalido")
+			return
+		}
+		limit = n
+	}
+
+	changes, err := h.terminals.FileChanges(URLParam(r, "terminalID"), since, limit)
+	if err != nil {
+		WriteNotFound(w, "terminal")
+		return
+	}
+
+	WriteSuccess(w, changes)
+}
+
+// writeWorktreeError traduce errores de worktrees a respuestas HTTP
+func writeWorktreeError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "no encontrada"):
+		WriteNotFound(w, "terminal")
+	case errors.Is(err, services.ErrNoWorktree):
+		WriteNotFound(w, "worktree")
+	case errors.Is(err, services.ErrWorktreeDirty), errors.Is(err, services.ErrWorktreeActive),
+		errors.Is(err, services.ErrMergeConflict), errors.Is(err, services.ErrMergeTargetDirty):
+		WriteConflict(w, err.Error())
+	case strings.Contains(err.Error(), "invalida"), strings.Contains(err.Error(), "requerida"),
+		strings.Contains(err.Error(), "ya no existe"), strings.Contains(err.Error(), "squash"):
+		WriteBadRequest(w, err.Error())
+	default:
+		WriteInternalError(w, err.Error())
+	}
+}
+
+// ListWorktrees godoc
+// @Summary      Listar worktrees
+// @Description  Worktrees dedicados de todas las terminales con su estado: cambios sin commit, commits por delante de la rama base y si ya está integrada
+// @Tags         terminals
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.WorktreeStatus}
+// @Router       /worktrees [get]
+// @Security     BasicAuth
+func (h *TerminalsHandler) ListWorktrees(w http.ResponseWriter, r *http.Request) {
+	worktrees := h.terminals.ListWorktrees()
+	json.NewEncoder(w).Encode(SuccessWithMeta(worktrees, &APIMeta{Total: len(worktrees)}))
+}
+
+// Worktree godoc
+// @Summary      Obtener worktree de la terminal
+// @Description  Worktree y rama dedicados de la terminal con su estado actual
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      200         {object}  handlers.APIResponse{data=services.WorktreeStatus}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/worktree [get]
+// @Security     BasicAuth
+func (h *TerminalsHandler) Worktree(w http.ResponseWriter, r *http.Request) {
+	status, err := h.terminals.GetWorktree(URLParam(r, "terminalID"))
+	if err != nil {
+		writeWorktreeError(w, err)
+		return
+	}
+
+	WriteSuccess(w, status)
+}
+
+// MergeWorktree godoc
+// @Summary      Integrar rama del worktree
+// @Description  Integra la rama del worktree en su rama base (o into). Si la rama destino está activa en el repositorio principal se hace merge --no-ff (o squash) allí; si no, solo fast-forward. Un conflicto deshace el merge y retorna 409 con los archivos en conflicto
+// @Tags         terminals
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                         true   "ID de la terminal"
+// @Param        request     body      services.WorktreeMergeOptions  false  "Opciones"
+// @Success      200         {object}  handlers.APIResponse{data=services.WorktreeMergeResult}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Failure      409         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/worktree/merge [post]
+// @Security     BasicAuth
+func (h *TerminalsHandler) MergeWorktree(w http.ResponseWriter, r *http.Request) {
+	var opts services.WorktreeMergeOptions
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+			WriteBadRequest(w, "JSON invalido")
+			return
+		}
+	}
+
+	result, err := h.terminals.MergeWorktree(URLParam(r, "terminalID"), opts)
+	if err != nil {
+		if errors.Is(err, services.ErrMergeConflict) && result != nil {
+			WriteConflict(w, err.Error()+": "+strings.Join(result.Conflicts, ", "))
+			return
+		}
+		writeWorktreeError(w, err)
+		return
+	}
+
+	WriteSuccess(w, result)
+}
+
+// RemoveWorktree godoc
+// @Summary      Eliminar worktree de la terminal
+// @Description  Elimina el worktree de una terminal no activa. force=true descarta cambios sin commit; delete_branch=true elimina la rama aunque no esté integrada (por defecto solo se elimina si ya lo está)
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID     path      string  true   "ID de la terminal"
+// @Param        force          query     bool    false  "Descartar cambios sin commit"
+// @Param        delete_branch  query     bool    false  "Eliminar la rama aunque no esté integrada"
+// @Success      200            {object}  handlers.APIResponse{data=services.WorktreeStatus}
+// @Failure      404            {object}  handlers.APIResponse
+// @Failure      409            {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/worktree [delete]
+// @Security     BasicAuth
+func (h *TerminalsHandler) RemoveWorktree(w http.ResponseWriter, r *http.Request) {
+	force := r.URL.Query().Get("force") == "true"
+	deleteBranch := r.URL.Query().Get("delete_branch") == "true"
+
+	status, err := h.terminals.RemoveWorktree(URLParam(r, "terminalID"), force, deleteBranch)
+	if err != nil {
+		writeWorktreeError(w, err)
+		return
+	}
+
+	WriteSuccess(w, status)
+}
+
+// SignalProcessRequest cuerpo de POST /terminals/{id}/processes/{pid}/signal
+type SignalProcessRequest struct {
+	Signal string `json:"signal"` // TERM, KILL, INT, HUP, QUIT, STOP, CONT, USR1, USR2
+}
+
+// SignalProcess godoc
+// @Summary      Enviar señal a un proceso de la terminal
+// @Description  Envía una señal a un proceso descendiente de la terminal (por ejemplo, un servidor de desarrollo que quedó corriendo). Por defecto TERM
+// @Tags         terminals
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                         true  "ID de la terminal"
+// @Param        pid         path      int                            true  "PID del proceso"
+// @Param        request     body      handlers.SignalProcessRequest  false "Señal"
+// @Success      200         {object}  handlers.APIResponse
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/processes/{pid}/signal [post]
+// @Security     BasicAuth
+func (h *TerminalsHandler) SignalProcess(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+	pid, err := strconv.Atoi(URLParam(r, "pid"))
+	if err != nil || pid <= 0 {
+		WriteBadRequest(w, "pid invalido")
+		return
+	}
+
+	var req SignalProcessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+	if req.Signal == "" {
+		req.Signal = "TERM"
+	}
+
+	if err := h.terminals.SignalProcess(id, pid, req.Signal); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "terminal no encontrada"):
+			WriteNotFound(w, "terminal")
+		case strings.Contains(err.Error(), "no encontrado"):
+			WriteNotFound(w, "proceso")
+		case strings.Contains(err.Error(), "invalida") || strings.Contains(err.Error(), "proceso principal"):
+			WriteBadRequest(w, err.Error())
+		default:
+			WriteInternalError(w, err.Error())
+		}
+		return
+	}
+
+	WriteSuccess(w, map[string]interface{}{"pid": pid, "signal": strings.ToUpper(req.Signal)})
+}
+
 // Resume godoc
 // @Summary      Reanudar terminal
 // @Description  Reanuda una terminal guardada
@@ -206,6 +517,7 @@ func (h *TerminalsHandler) Resume(w http.ResponseWriter, r *http.Request) {
 		WriteInternalError(w, err.Error())
 		return
 	}
+	recordAudit(h.audit, r, "terminal.resume", id, nil)
 
 	WriteSuccess(w, terminal)
 }
@@ -247,13 +559,67 @@ func (h *TerminalsHandler) Resize(w http.ResponseWriter, r *http.Request) {
 	WriteSuccess(w, map[string]string{"message": "Terminal redimensionada"})
 }
 
+// Input godoc
+// @Summary      Enviar entrada a terminal
+// @Description  Escribe texto y teclas con nombre (Enter, Esc, Ctrl-C, Up, Shift-Tab...) en la terminal. Opcionalmente espera a que Claude entre en un estado (wait_for) antes de responder, como máximo timeout_seconds (1-25, default 25; si no llega, timed_out=true)
+// @Tags         terminals
+// @Accept       json
+// @Produce      json
+// @Param        terminalID  path      string                            true  "ID de la terminal"
+// @Param        request     body      validator.TerminalInputRequest    true  "Texto, teclas y estado a esperar"
+// @Success      200         {object}  handlers.APIResponse{data=services.TerminalInputResult}
+// @Failure      400         {object}  handlers.APIResponse
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/input [post]
+// @Security     BasicAuth
+func (h *TerminalsHandler) Input(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+	if id == "" {
+		WriteBadRequest(w, "terminal id requerido")
+		return
+	}
+
+	req, err := validator.DecodeAndValidate(r, validator.ValidateTerminalInput)
+	if err != nil {
+		if apiErr, ok := err.(*apierrors.APIError); ok {
+			apierrors.WriteError(w, apiErr)
+		} else {
+			WriteBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	if !h.terminals.IsActive(id) {
+		WriteNotFound(w, "terminal")
+		return
+	}
+
+	result, err := h.terminals.SendInput(r.Context(), id, services.TerminalInput{
+		Text:        req.Text,
+		Keys:        req.Keys,
+		WaitFor:     services.ClaudeState(req.WaitFor),
+		WaitTimeout: time.Duration(req.TimeoutSeconds) * time.Second,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrada") {
+			WriteNotFound(w, "terminal")
+			return
+		}
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteSuccess(w, result)
+}
+
 // WebSocket godoc
 // @Summary      Conectar WebSocket a terminal
-// @Description  Establece conexión WebSocket para interactuar con la terminal en tiempo real
+// @Description  Establece conexión WebSocket para interactuar con la terminal en tiempo real. Se autentica solo con un ticket de POST /terminals/{terminalID}/ws-ticket
 // @Tags         terminals
 // @Accept       json
 // @Produce      json
 // @Param        terminalID  path      string  true  "ID de la terminal"
+// @Param        ticket      query     string  true  "Ticket de un solo uso"
 // @Success      101         {string}  string  "Switching Protocols"
 // @Failure      400         {string}  string
 // @Failure      404         {string}  string
@@ -271,6 +637,9 @@ func (h *TerminalsHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sin terminals:write la conexión solo recibe la salida
+	readOnly := !auth.FromContext(r.Context()).Can(auth.PermTerminalsWrite)
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error("Error upgrading WebSocket", "error", err)
@@ -340,6 +709,9 @@ func (h *TerminalsHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
 			}
 			break
 		}
+		if readOnly {
+			continue
+		}
 
 		switch msg.Type {
 		case "input":
@@ -582,6 +954,8 @@ func (h *TerminalsHandler) Archive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, "terminal.archive", id, nil)
+
 	WriteSuccess(w, map[string]string{"message": "Terminal archivada"})
 }
 
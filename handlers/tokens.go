@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"claude-monitor/services"
+)
+
+// TokensHandler maneja los tokens de API con scopes
+type TokensHandler struct {
+	tokens *services.APITokenStore
+	audit  *services.AuditLog
+}
+
+// NewTokensHandler crea un nuevo handler
+func NewTokensHandler(tokens *services.APITokenStore, audit *services.AuditLog) *TokensHandler {
+	return &TokensHandler{
+		tokens: tokens,
+		audit:  audit,
+	}
+}
+
+// writeTokenError mapea errores del almacén de tokens a respuestas HTTP
+func writeTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPITokenNotFound):
+		WriteNotFound(w, "token")
+	case strings.Contains(err.Error(), "invalido") || strings.Contains(err.Error(), "requerido"):
+		WriteBadRequest(w, err.Error())
+	default:
+		WriteInternalError(w, err.Error())
+	}
+}
+
+// List godoc
+// @Summary      Listar tokens de API
+// @Description  Retorna scopes, caducidad y último uso; los secretos nunca se exponen
+// @Tags         tokens
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.APIToken}
+// @Router       /tokens [get]
+// @Security     BasicAuth
+func (h *TokensHandler) List(w http.ResponseWriter, r *http.Request) {
+	tokens := h.tokens.List()
+	json.NewEncoder(w).Encode(SuccessWithMeta(tokens, &APIMeta{Total: len(tokens)}))
+}
+
+// Create godoc
+// @Summary      Crear token de API
+// @Description  El secreto (campo token) solo se retorna en esta respuesta. Se envía en X-API-Token o Authorization: Bearer
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        request  body      services.APITokenRequest  true  "Nombre, scopes, prefijos de ruta y caducidad"
+// @Success      201      {object}  handlers.APIResponse{data=services.NewAPIToken}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /tokens [post]
+// @Security     BasicAuth
+func (h *TokensHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req services.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	token, err := h.tokens.Create(req, RequestActor(r))
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	recordAudit(h.audit, r, "token.create", "", map[string]string{"token_id": token.ID, "name": token.Name})
+
+	WriteCreated(w, token)
+}
+
+// Get godoc
+// @Summary      Obtener token de API
+// @Tags         tokens
+// @Produce      json
+// @Param        tokenID  path      string  true  "ID del token"
+// @Success      200      {object}  handlers.APIResponse{data=services.APIToken}
+// @Failure      404      {object}  handlers.APIResponse
+// @Router       /tokens/{tokenID} [get]
+// @Security     BasicAuth
+func (h *TokensHandler) Get(w http.ResponseWriter, r *http.Request) {
+	token, err := h.tokens.Get(URLParam(r, "tokenID"))
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	WriteSuccess(w, token)
+}
+
+// Update godoc
+// @Summary      Reemplazar token de API
+// @Description  Cambia nombre, scopes, prefijos de ruta y caducidad; el secreto no cambia
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        tokenID  path      string                    true  "ID del token"
+// @Param        request  body      services.APITokenRequest  true  "Token"
+// @Success      200      {object}  handlers.APIResponse{data=services.APIToken}
+// @Failure      400      {object}  handlers.APIResponse
+// @Failure      404      {object}  handlers.APIResponse
+// @Router       /tokens/{tokenID} [put]
+// @Security     BasicAuth
+func (h *TokensHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req services.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	token, err := h.tokens.Update(URLParam(r, "tokenID"), req)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	recordAudit(h.audit, r, "token.update", "", map[string]string{"token_id": token.ID, "name": token.Name})
+
+	WriteSuccess(w, token)
+}
+
+// Delete godoc
+// @Summary      Revocar token de API
+// @Tags         tokens
+// @Produce      json
+// @Param        tokenID  path      string  true  "ID del token"
+// @Success      200      {object}  handlers.APIResponse
+// @Failure      404      {object}  handlers.APIResponse
+// @Router       /tokens/{tokenID} [delete]
+// @Security     BasicAuth
+func (h *TokensHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "tokenID")
+	if err := h.tokens.Delete(id); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	recordAudit(h.audit, r, "token.revoke", "", map[string]string{"token_id": id})
+
+	WriteSuccess(w, map[string]string{"message": "Token revocado"})
+}
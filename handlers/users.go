@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/services"
+)
+
+// UsersHandler maneja los usuarios de la API y sus roles
+type UsersHandler struct {
+	users *services.UserStore
+}
+
+// NewUsersHandler crea un nuevo handler
+func NewUsersHandler(users *services.UserStore) *UsersHandler {
+	return &UsersHandler{
+		users: users,
+	}
+}
+
+// CreateUserRequest cuerpo de POST /users
+type CreateUserRequest struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Role     auth.Role `json:"role"`
+}
+
+// writeUserError mapea errores del almacén de usuarios a respuestas HTTP
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		WriteNotFound(w, "usuario")
+	case errors.Is(err, services.ErrUserExists), errors.Is(err, services.ErrLastAdmin):
+		WriteConflict(w, err.Error())
+	case strings.Contains(err.Error(), "invalido") || strings.Contains(err.Error(), "requerido"):
+		WriteBadRequest(w, err.Error())
+	default:
+		WriteInternalError(w, err.Error())
+	}
+}
+
+// Me godoc
+// @Summary      Identidad actual
+// @Description  Retorna el usuario autenticado, su rol y sus permisos
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=auth.Principal}
+// @Router       /me [get]
+// @Security     BasicAuth
+func (h *UsersHandler) Me(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, auth.FromContext(r.Context()))
+}
+
+// List godoc
+// @Summary      Listar usuarios
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.User}
+// @Router       /users [get]
+// @Security     BasicAuth
+func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
+	users := h.users.List()
+	json.NewEncoder(w).Encode(SuccessWithMeta(users, &APIMeta{Total: len(users)}))
+}
+
+// Create godoc
+// @Summary      Crear usuario
+// @Description  Roles: admin (todo), operator (crear y manejar terminales), viewer (solo lectura)
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      handlers.CreateUserRequest  true  "Usuario"
+// @Success      201      {object}  handlers.APIResponse{data=services.User}
+// @Failure      400      {object}  handlers.APIResponse
+// @Failure      409      {object}  handlers.APIResponse
+// @Router       /users [post]
+// @Security     BasicAuth
+func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	user, err := h.users.Create(req.Username, req.Password, req.Role)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	WriteCreated(w, user)
+}
+
+// Get godoc
+// @Summary      Obtener usuario
+// @Tags         users
+// @Produce      json
+// @Param        username  path      string  true  "Nombre de usuario"
+// @Success      200       {object}  handlers.APIResponse{data=services.User}
+// @Failure      404       {object}  handlers.APIResponse
+// @Router       /users/{username} [get]
+// @Security     BasicAuth
+func (h *UsersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, err := h.users.Get(URLParam(r, "username"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	WriteSuccess(w, user)
+}
+
+// Update godoc
+// @Summary      Actualizar usuario
+// @Description  Cambia contraseña, rol o estado. Siempre debe quedar al menos un admin activo
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        username  path      string               true  "Nombre de usuario"
+// @Param        request   body      services.UserUpdate  true  "Cambios"
+// @Success      200       {object}  handlers.APIResponse{data=services.User}
+// @Failure      400       {object}  handlers.APIResponse
+// @Failure      404       {object}  handlers.APIResponse
+// @Failure      409       {object}  handlers.APIResponse
+// @Router       /users/{username} [put]
+// @Security     BasicAuth
+func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req services.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	user, err := h.users.Update(URLParam(r, "username"), req)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	WriteSuccess(w, user)
+}
+
+// Delete godoc
+// @Summary      Eliminar usuario
+// @Tags         users
+// @Produce      json
+// @Param        username  path      string  true  "Nombre de usuario"
+// @Success      200       {object}  handlers.APIResponse
+// @Failure      404       {object}  handlers.APIResponse
+// @Failure      409       {object}  handlers.APIResponse
+// @Router       /users/{username} [delete]
+// @Security     BasicAuth
+func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.users.Delete(URLParam(r, "username")); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	WriteSuccess(w, map[string]string{"message": "Usuario eliminado"})
+}
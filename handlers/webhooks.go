@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"claude-monitor/services"
+)
+
+// WebhooksHandler maneja suscripciones de webhooks y su log de entregas
+type WebhooksHandler struct {
+	webhooks *services.WebhookService
+}
+
+// NewWebhooksHandler crea un nuevo handler
+func NewWebhooksHandler(webhooks *services.WebhookService) *WebhooksHandler {
+	return &WebhooksHandler{
+		webhooks: webhooks,
+	}
+}
+
+// CreateWebhookRequest cuerpo de POST /webhooks
+type CreateWebhookRequest struct {
+	URL         string               `json:"url"`
+	Events      []services.EventType `json:"events,omitempty"`
+	TerminalID  string               `json:"terminal_id,omitempty"`
+	Secret      string               `json:"secret,omitempty"`
+	Description string               `json:"description,omitempty"`
+}
+
+// Create godoc
+// @Summary      Crear suscripción de webhook
+// @Description  Registra una URL que recibe los eventos del monitor (JSON POST firmado con HMAC si se indica secret). Sin events recibe todos
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        request  body      handlers.CreateWebhookRequest  true  "Suscripción"
+// @Success      201      {object}  handlers.APIResponse{data=services.WebhookSubscription}
+// @Failure      400      {object}  handlers.APIResponse
+// @Router       /webhooks [post]
+// @Security     BasicAuth
+func (h *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteBadRequest(w, "JSON invalido")
+		return
+	}
+
+	sub, err := h.webhooks.Create(services.WebhookSubscription{
+		URL:         req.URL,
+		Events:      req.Events,
+		TerminalID:  req.TerminalID,
+		Secret:      req.Secret,
+		Description: req.Description,
+	})
+	if err != nil {
+		WriteBadRequest(w, err.Error())
+		return
+	}
+
+	WriteCreated(w, sub)
+}
+
+// List godoc
+// @Summary      Listar webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {object}  handlers.APIResponse{data=[]services.WebhookSubscription}
+// @Router       /webhooks [get]
+// @Security     BasicAuth
+func (h *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, h.webhooks.List())
+}
+
+// Get godoc
+// @Summary      Obtener webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        webhookID  path      string  true  "ID del webhook"
+// @Success      200        {object}  handlers.APIResponse{data=services.WebhookSubscription}
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /webhooks/{webhookID} [get]
+// @Security     BasicAuth
+func (h *WebhooksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.webhooks.Get(URLParam(r, "webhookID"))
+	if err != nil {
+		WriteNotFound(w, "webhook")
+		return
+	}
+
+	WriteSuccess(w, sub)
+}
+
+// Delete godoc
+// @Summary      Eliminar webhook
+// @Description  Elimina la suscripción; sus entregas pendientes dejan de reintentarse
+// @Tags         webhooks
+// @Produce      json
+// @Param        webhookID  path      string  true  "ID del webhook"
+// @Success      200        {object}  handlers.APIResponse
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /webhooks/{webhookID} [delete]
+// @Security     BasicAuth
+func (h *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhooks.Delete(URLParam(r, "webhookID")); err != nil {
+		WriteNotFound(w, "webhook")
+		return
+	}
+
+	WriteSuccess(w, map[string]string{"message": "Webhook eliminado"})
+}
+
+// Deliveries godoc
+// @Summary      Log de entregas de un webhook
+// @Description  Entregas de la más reciente a la más antigua, con intentos, status HTTP y payload
+// @Tags         webhooks
+// @Produce      json
+// @Param        webhookID  path      string  true   "ID del webhook"
+// @Param        status     query     string  false  "pending, succeeded o failed"
+// @Param        limit      query     int     false  "Máximo de entregas (default: 100)"
+// @Success      200        {object}  handlers.APIResponse{data=[]services.WebhookDelivery}
+// @Failure      400        {object}  handlers.APIResponse
+// @Failure      404        {object}  handlers.APIResponse
+// @Router       /webhooks/{webhookID}/deliveries [get]
+// @Security     BasicAuth
+func (h *WebhooksHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "webhookID")
+	if _, err := h.webhooks.Get(id); err != nil {
+		WriteNotFound(w, "webhook")
+		return
+	}
+
+	filter := services.WebhookDeliveryFilter{
+		SubscriptionID: id,
+		Status:         services.WebhookDeliveryStatus(r.URL.Query().Get("status")),
+		Limit:          100,
+	}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			WriteBadRequest(w, "limit invalido")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries := h.webhooks.Deliveries(filter)
+	json.NewEncoder(w).Encode(SuccessWithMeta(deliveries, &APIMeta{Total: len(deliveries), Limit: filter.Limit}))
+}
+
+// Redeliver godoc
+// @Summary      Reenviar entrega
+// @Description  Crea una nueva entrega con el mismo payload que la indicada
+// @Tags         webhooks
+// @Produce      json
+// @Param        webhookID   path      string  true  "ID del webhook"
+// @Param        deliveryID  path      string  true  "ID de la entrega"
+// @Success      201         {object}  handlers.APIResponse{data=services.WebhookDelivery}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /webhooks/{webhookID}/deliveries/{deliveryID}/redeliver [post]
+// @Security     BasicAuth
+func (h *WebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "webhookID")
+	deliveryID := URLParam(r, "deliveryID")
+
+	orig, err := h.webhooks.GetDelivery(deliveryID)
+	if err != nil || orig.SubscriptionID != id {
+		WriteNotFound(w, "entrega")
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(deliveryID)
+	if err != nil {
+		WriteNotFound(w, "webhook")
+		return
+	}
+
+	WriteCreated(w, delivery)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/services"
+)
+
+// TicketsHandler emite tickets de un solo uso para abrir WebSockets
+type TicketsHandler struct {
+	tickets   *auth.TicketStore
+	terminals *services.TerminalService
+}
+
+// NewTicketsHandler crea un nuevo handler
+func NewTicketsHandler(tickets *auth.TicketStore, terminals *services.TerminalService) *TicketsHandler {
+	return &TicketsHandler{
+		tickets:   tickets,
+		terminals: terminals,
+	}
+}
+
+// issue emite un ticket para la identidad de la petición y la ruta indicada
+func (h *TicketsHandler) issue(w http.ResponseWriter, r *http.Request, path string) {
+	ticket, err := h.tickets.Issue(auth.FromContext(r.Context()), path)
+	if err != nil {
+		WriteInternalError(w, err.Error())
+		return
+	}
+
+	WriteCreated(w, ticket)
+}
+
+// Terminal godoc
+// @Summary      Ticket de WebSocket de terminal
+// @Description  Retorna un ticket de un solo uso (30s) para abrir /terminals/{terminalID}/ws?ticket=...
+// @Tags         terminals
+// @Produce      json
+// @Param        terminalID  path      string  true  "ID de la terminal"
+// @Success      201         {object}  handlers.APIResponse{data=auth.Ticket}
+// @Failure      404         {object}  handlers.APIResponse
+// @Router       /terminals/{terminalID}/ws-ticket [post]
+// @Security     BasicAuth
+func (h *TicketsHandler) Terminal(w http.ResponseWriter, r *http.Request) {
+	id := URLParam(r, "terminalID")
+	if !h.terminals.IsActive(id) {
+		WriteNotFound(w, "terminal")
+		return
+	}
+
+	h.issue(w, r, "/api/terminals/"+id+"/ws")
+}
+
+// Events godoc
+// @Summary      Ticket de WebSocket de eventos
+// @Description  Retorna un ticket de un solo uso (30s) para abrir /events/ws?ticket=...
+// @Tags         events
+// @Produce      json
+// @Success      201  {object}  handlers.APIResponse{data=auth.Ticket}
+// @Router       /events/ws-ticket [post]
+// @Security     BasicAuth
+func (h *TicketsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	h.issue(w, r, "/api/events/ws")
+}
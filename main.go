@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"claude-monitor/middleware"
+	"claude-monitor/pkg/auth"
 	"claude-monitor/pkg/logger"
 	"claude-monitor/pkg/metrics"
 	"claude-monitor/services"
@@ -41,6 +42,11 @@ import (
 const Version = "2.1.0"
 
 func main() {
+	// Subcomandos
+	if len(os.Args) > 1 && os.Args[1] == "capture" {
+		os.Exit(runCapture(os.Args[2:]))
+	}
+
 	// Flags
 	var (
 		port            int
@@ -102,6 +108,17 @@ func main() {
 	// Inicializar servicios
 	dataDir := getExecutableDir()
 
+	// Usuarios y roles (users.json); las credenciales de entorno actúan como admin
+	userStore := services.NewUserStore(dataDir)
+
+	// Tokens de API con scopes (api_tokens.json)
+	tokenStore := services.NewAPITokenStore(dataDir)
+	importLegacyAPIToken(tokenStore)
+	validateAuth(cfg, userStore.Count(), tokenStore.Count())
+
+	// Tickets de un solo uso para abrir WebSockets
+	wsTickets := auth.NewTicketStore(auth.DefaultTicketTTL)
+
 	claudeService := services.NewClaudeService(cfg.ClaudeDir)
 
 	// Inicializar nombres de sesiones
@@ -110,20 +127,78 @@ func main() {
 	}
 
 	terminalService := services.NewTerminalService(dataDir, cfg.AllowedPathPrefixes...)
+	terminalService.SetHooksBaseURL(cfg.GetHooksBaseURL())
 	analyticsService := services.NewAnalyticsService(
 		claudeService,
 		time.Duration(cfg.CacheDurationMinutes)*time.Minute,
 	)
+	auditLog := services.NewAuditLog(dataDir)
+	permissionService := services.NewPermissionService(dataDir, terminalService, auditLog)
+
+	// Reglas de detección de estado (recarga en caliente)
+	detectionRules := services.NewDetectionRulesService(cfg.GetDetectionRulesFile(dataDir), services.DetectClaudeVersion())
+	go detectionRules.Watch(services.DefaultDetectionRulesReloadInterval)
+
+	// Bus de eventos y watchdog de terminales Claude
+	eventBus := services.NewEventBus()
+	terminalService.SetEventBus(eventBus)
+	watchdog := services.NewWatchdog(cfg.Watchdog, terminalService, eventBus)
+	go watchdog.Run()
+	notifier := services.NewNotifierService(dataDir, terminalService, eventBus)
+	webhookService := services.NewWebhookService(dataDir, eventBus)
+	sessionPoller := services.NewSessionFilePoller(cfg.ClaudeDir, eventBus)
+	go sessionPoller.Run(services.DefaultSessionPollInterval)
+
+	// Templates de terminal y secretos inyectados en su entorno
+	templateService := services.NewTemplateService(dataDir)
+	secretStore := services.NewSecretStore(dataDir)
+	terminalService.SetSecretStore(secretStore)
+
+	// Límites de recursos y muestreo de uso de las terminales
+	terminalService.SetResourceLimits(cfg.Limits)
+	resourceMonitor := services.NewResourceMonitor(cfg.Limits, terminalService)
+	go resourceMonitor.Run()
+
+	// Cola de jobs headless (claude -p)
+	jobService := services.NewJobService(dataDir, cfg.Jobs, eventBus, cfg.AllowedPathPrefixes...)
+	scheduler := services.NewScheduler(dataDir, jobService, terminalService, eventBus)
+	go scheduler.Run()
+
+	// Lectura, preview y diff de archivos dentro de los prefijos permitidos
+	// (nunca los datos del monitor: usuarios, tokens, secretos y su clave, config)
+	fileService := services.NewFileService(cfg.Files, cfg.AllowedPathPrefixes, dataDir, configPath, services.SecretKeyFile(dataDir))
+
+	// Estado git de terminales y session-roots
+	gitService := services.NewGitService()
+	terminalService.SetGitService(gitService)
+	terminalService.SetFileWatch(cfg.Watch)
 
 	// Crear router con Chi
 	router := NewRouter(
 		claudeService,
 		terminalService,
 		analyticsService,
+		permissionService,
+		auditLog,
+		detectionRules,
+		eventBus,
+		watchdog,
+		notifier,
+		webhookService,
+		jobService,
+		scheduler,
+		templateService,
+		secretStore,
+		fileService,
+		gitService,
+		userStore,
+		tokenStore,
+		wsTickets,
 		cfg.HostName,
 		Version,
 		cfg.ClaudeDir,
 		cfg.AllowedPathPrefixes,
+		cfg.AllowedOrigins,
 	)
 
 	// Configurar rutas
@@ -144,7 +219,7 @@ func main() {
 		metrics.MetricsMiddleware,
 		LoggingMiddleware,
 		CORSMiddleware,
-		AuthMiddleware,
+		AuthMiddleware(userStore, tokenStore, wsTickets),
 		JSONMiddleware,
 	)
 
@@ -192,6 +267,15 @@ func main() {
 	}
 
 	// Iniciar graceful shutdown
+	detectionRules.Stop()
+	watchdog.Stop()
+	notifier.Stop()
+	webhookService.Stop()
+	sessionPoller.Stop()
+	scheduler.Stop()
+	resourceMonitor.Stop()
+	gitService.Stop()
+	jobService.Shutdown()
 	gracefulShutdown(log, server, terminalService, time.Duration(shutdownTimeout)*time.Second)
 }
 
@@ -10,7 +10,10 @@ import (
 	"strings"
 	"time"
 
+	"claude-monitor/pkg/auth"
+	apierrors "claude-monitor/pkg/errors"
 	"claude-monitor/pkg/logger"
+	"claude-monitor/services"
 )
 
 // responseWriter wrapper para capturar status code
@@ -39,6 +42,11 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Unwrap expone el writer original a http.ResponseController (deadlines)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func newResponseWriter(w http.ResponseWriter) *responseWriter {
 	return &responseWriter{w, http.StatusOK}
 }
@@ -140,54 +148,91 @@ func isOriginAllowed(origin string, allowed []string) bool {
 	return false
 }
 
-// AuthMiddleware valida autenticación via Basic Auth o API Token
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Endpoints públicos (sin autenticación)
-		if r.URL.Path == "/metrics" || r.URL.Path == "/api/health" || r.URL.Path == "/api/ready" {
-			next.ServeHTTP(w, r)
-			return
-		}
+// AuthMiddleware valida autenticación via Basic Auth (usuarios de users.json
+// o credenciales de entorno) o token de API, y añade la identidad al contexto.
+// Los tokens solo acceden a las rutas de sus path_prefixes. Los WebSockets se
+// autentican solo con un ticket de un solo uso (?ticket=).
+func AuthMiddleware(users *services.UserStore, tokens *services.APITokenStore, tickets *auth.TicketStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Endpoints públicos (sin autenticación)
+			if r.URL.Path == "/metrics" || r.URL.Path == "/api/health" || r.URL.Path == "/api/ready" {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		log := logger.FromContext(r.Context())
+			// Hooks de Claude Code: autenticados por token de terminal en el handler
+			if strings.HasPrefix(r.URL.Path, "/api/hooks/") {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		// Check API Token first
-		token := r.Header.Get("X-API-Token")
-		if token != "" && config.APIToken != "" && token == config.APIToken {
-			next.ServeHTTP(w, r)
-			return
-		}
+			log := logger.FromContext(r.Context())
 
-		// Check query param token (for WebSocket connections)
-		queryToken := r.URL.Query().Get("token")
-		if queryToken != "" && config.APIToken != "" && queryToken == config.APIToken {
-			next.ServeHTTP(w, r)
-			return
-		}
+			if principal := authenticate(r, users, tokens, tickets); principal != nil {
+				if !principal.AllowsPath(r.URL.EscapedPath()) {
+					log.Warn("Ruta no permitida para el token", "token", principal.Name)
+					apierrors.WriteError(w, apierrors.New(apierrors.ErrCodeForbidden, "ruta no permitida para el token"))
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+				return
+			}
 
-		// Check Basic Auth
-		user, pass, ok := r.BasicAuth()
-		if ok && user == config.Username && pass == config.Password {
-			next.ServeHTTP(w, r)
-			return
-		}
+			// Unauthorized
+			log.Warn("Authentication failed",
+				"ip", r.RemoteAddr,
+				"user_agent", r.UserAgent(),
+			)
+			w.Header().Set("WWW-Authenticate", `Basic realm="Claude Monitor API"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
+}
 
-		// Check query params for basic auth (WebSocket fallback)
-		queryUser := r.URL.Query().Get("user")
-		queryPass := r.URL.Query().Get("pass")
-		if queryUser == config.Username && queryPass == config.Password {
-			next.ServeHTTP(w, r)
-			return
-		}
+// authenticate identifica la petición (nil si las credenciales no son válidas)
+func authenticate(r *http.Request, users *services.UserStore, tokens *services.APITokenStore, tickets *auth.TicketStore) *auth.Principal {
+	// WebSocket: solo el ticket emitido para esa ruta (nunca credenciales en la query string)
+	if strings.HasSuffix(r.URL.Path, "/ws") {
+		return tickets.Redeem(r.URL.Query().Get("ticket"), r.URL.Path)
+	}
 
-		// Unauthorized
-		log.Warn("Authentication failed",
-			"ip", r.RemoteAddr,
-			"user_agent", r.UserAgent(),
-		)
-		w.Header().Set("WWW-Authenticate", `Basic realm="Claude Monitor API"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	})
+	// Check API Token first (X-API-Token o Authorization: Bearer)
+	token := r.Header.Get("X-API-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token == "" {
+		token = bearer
+	}
+	if token != "" {
+		return authenticateToken(tokens, token)
+	}
+
+	// Check Basic Auth
+	if user, pass, ok := r.BasicAuth(); ok {
+		return authenticateUser(users, user, pass)
+	}
+
+	return nil
+}
+
+// authenticateUser valida usuario y contraseña contra users.json y, si no,
+// contra las credenciales de entorno (admin)
+func authenticateUser(users *services.UserStore, user, pass string) *auth.Principal {
+	if u := users.Authenticate(user, pass); u != nil {
+		return auth.NewPrincipal(u.Username, u.Role)
+	}
+	if config.Password != "" && user == config.Username && pass == config.Password {
+		return auth.NewPrincipal(user, auth.RoleAdmin)
+	}
+	return nil
+}
+
+// authenticateToken valida un token de API y retorna su identidad con los scopes del token
+func authenticateToken(tokens *services.APITokenStore, secret string) *auth.Principal {
+	t := tokens.Authenticate(secret)
+	if t == nil {
+		return nil
+	}
+	return auth.NewScopedPrincipal("token:"+t.Name, t.Scopes, t.PathPrefixes)
 }
 
 // JSONMiddleware añade Content-Type JSON a las respuestas
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/services"
+)
+
+// authFixture almacenes de identidades con un usuario por rol y las
+// credenciales de entorno admin/secret
+type authFixture struct {
+	users   *services.UserStore
+	tokens  *services.APITokenStore
+	tickets *auth.TicketStore
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	previousIterations, previousConfig := auth.PasswordIterations, config
+	t.Cleanup(func() { auth.PasswordIterations, config = previousIterations, previousConfig })
+	auth.PasswordIterations = 1000
+	config = &Config{Username: "admin", Password: "secret"}
+
+	dir := t.TempDir()
+	f := &authFixture{
+		users:   services.NewUserStore(dir),
+		tokens:  services.NewAPITokenStore(dir),
+		tickets: auth.NewTicketStore(time.Minute),
+	}
+	for _, u := range []struct {
+		name string
+		role auth.Role
+	}{{"ana", auth.RoleViewer}, {"olga", auth.RoleOperator}} {
+		if _, err := f.users.Create(u.name, "password-"+u.name, u.role); err != nil {
+			t.Fatalf("Create user: %v", err)
+		}
+	}
+	return f
+}
+
+// token crea un token de API y retorna su secreto
+func (f *authFixture) token(t *testing.T, scopes []auth.Permission, prefixes ...string) string {
+	t.Helper()
+	created, err := f.tokens.Create(services.APITokenRequest{Name: "test", Scopes: scopes, PathPrefixes: prefixes}, "admin")
+	if err != nil {
+		t.Fatalf("Create token: %v", err)
+	}
+	return created.Token
+}
+
+// handler AuthMiddleware delante de next
+func (f *authFixture) handler(next http.Handler) http.Handler {
+	return ChainMiddleware(next, AuthMiddleware(f.users, f.tokens, f.tickets))
+}
+
+// principalEcho responde 200 con el nombre de la identidad autenticada
+var principalEcho = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if p := auth.FromContext(r.Context()); p != nil {
+		w.Write([]byte(p.Name))
+	}
+})
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddleware_WebSocketTicketOnly(t *testing.T) {
+	f := newAuthFixture(t)
+	h := f.handler(principalEcho)
+	const wsPath = "/api/terminals/t1/ws"
+
+	// Credenciales válidas sin ticket: el WebSocket no las acepta
+	req := httptest.NewRequest(http.MethodGet, wsPath, nil)
+	req.SetBasicAuth("admin", "secret")
+	if rec := serve(h, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ws with basic auth = %d, want 401", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, wsPath, nil)
+	req.Header.Set("X-API-Token", f.token(t, auth.KnownPermissions))
+	if rec := serve(h, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ws with api token = %d, want 401", rec.Code)
+	}
+
+	// Ticket emitido para otra terminal
+	other, _ := f.tickets.Issue(auth.NewPrincipal("olga", auth.RoleOperator), "/api/terminals/t2/ws")
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, wsPath+"?ticket="+other.Ticket, nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ws with ticket for another path = %d, want 401", rec.Code)
+	}
+
+	// Ticket válido: una sola vez, con la identidad que lo pidió
+	ticket, _ := f.tickets.Issue(auth.NewPrincipal("olga", auth.RoleOperator), wsPath)
+	rec := serve(h, httptest.NewRequest(http.MethodGet, wsPath+"?ticket="+ticket.Ticket, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "olga" {
+		t.Errorf("ws with ticket = %d %q, want 200 olga", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, wsPath+"?ticket="+ticket.Ticket, nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused ticket = %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_PublicAndHooks(t *testing.T) {
+	f := newAuthFixture(t)
+	reached := false
+	h := f.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	for _, path := range []string{"/api/health", "/api/ready", "/metrics", "/api/hooks/t1/pre-tool-use"} {
+		reached = false
+		rec := serve(h, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusOK || !reached {
+			t.Errorf("%s = %d (handler reached: %v), want 200 without credentials", path, rec.Code, reached)
+		}
+	}
+
+	// El resto exige credenciales
+	reached = false
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/api/hooksx", nil)); rec.Code != http.StatusUnauthorized || reached {
+		t.Errorf("/api/hooksx = %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_Credentials(t *testing.T) {
+	f := newAuthFixture(t)
+	h := f.handler(principalEcho)
+
+	cases := []struct {
+		name   string
+		setup  func(*http.Request)
+		status int
+		who    string
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized, ""},
+		{"env admin", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK, "admin"},
+		{"users.json", func(r *http.Request) { r.SetBasicAuth("ana", "password-ana") }, http.StatusOK, "ana"},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("ana", "nope") }, http.StatusUnauthorized, ""},
+		{"bearer", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+f.token(t, []auth.Permission{auth.PermTerminalsRead}))
+		}, http.StatusOK, "token:test"},
+		{"unknown token", func(r *http.Request) { r.Header.Set("X-API-Token", "cmt_nope") }, http.StatusUnauthorized, ""},
+		{"token outside prefixes", func(r *http.Request) {
+			r.Header.Set("X-API-Token", f.token(t, []auth.Permission{auth.PermTerminalsRead}, "/api/jobs"))
+		}, http.StatusForbidden, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/terminals", nil)
+		tc.setup(req)
+		rec := serve(h, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.status)
+		}
+		if tc.who != "" && rec.Body.String() != tc.who {
+			t.Errorf("%s: principal = %q, want %q", tc.name, rec.Body.String(), tc.who)
+		}
+	}
+}
+
+func TestAuthMiddleware_PathPrefixTraversal(t *testing.T) {
+	f := newAuthFixture(t)
+	h := f.handler(principalEcho)
+	token := f.token(t, []auth.Permission{auth.PermSessionsRead, auth.PermTerminalsRead}, "/api/session-roots/-home-ci-app")
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/api/session-roots/-home-ci-app/sessions", http.StatusOK},
+		// chi enruta sobre la ruta escapada: {rootPath} sería "-home-ci-app/../../terminals"
+		{"/api/session-roots/-home-ci-app%2F..%2F..%2Fterminals", http.StatusForbidden},
+		{"/api/session-roots/-home-ci-app%2f..%2fother-app", http.StatusForbidden},
+		{"/api/session-roots/-home-ci-app/../../terminals", http.StatusForbidden},
+		{"/api/session-roots/-home-ci-app/%2E%2E/other-app", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("X-API-Token", token)
+		if rec := serve(h, req); rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.path, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestRouter_Permissions(t *testing.T) {
+	f := newAuthFixture(t)
+	dir := t.TempDir()
+	bus := services.NewEventBus()
+	claude := services.NewClaudeService(dir)
+	terminals := services.NewTerminalService(dir)
+	jobs := services.NewJobService(dir, services.JobsConfig{}, bus)
+	defer jobs.Shutdown()
+	scheduler := services.NewScheduler(dir, jobs, terminals, bus)
+	defer scheduler.Stop()
+
+	router := NewRouter(
+		claude, terminals, services.NewAnalyticsService(claude, time.Minute),
+		nil, nil, nil, bus, nil, nil, nil, jobs, scheduler, nil, nil, nil, nil,
+		f.users, f.tokens, f.tickets,
+		"test", Version, dir, nil, nil,
+	)
+	router.SetupRoutes()
+	h := f.handler(router.Handler())
+
+	jobsOnly := f.token(t, []auth.Permission{auth.PermJobsRead, auth.PermJobsWrite})
+	schedule := func(mode string) string {
+		return `{"cron": "@daily", "mode": "` + mode + `", "request": {"prompt": "hola", "work_dir": "` + dir + `"}}`
+	}
+
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		user, token string
+		status      int
+	}{
+		{"invalidate as operator", http.MethodPost, "/api/analytics/invalidate", "", "olga", "", http.StatusForbidden},
+		{"invalidate as admin", http.MethodPost, "/api/analytics/invalidate", "", "admin", "", http.StatusOK},
+		{"analytics read as viewer", http.MethodGet, "/api/analytics/cache", "", "ana", "", http.StatusOK},
+		{"headless schedule with jobs:write", http.MethodPost, "/api/schedules", schedule("headless"), "", jobsOnly, http.StatusCreated},
+		{"terminal schedule without terminals:write", http.MethodPost, "/api/schedules", schedule("terminal"), "", jobsOnly, http.StatusForbidden},
+		{"terminal schedule as operator", http.MethodPost, "/api/schedules", schedule("terminal"), "olga", "", http.StatusCreated},
+		{"schedule as viewer", http.MethodPost, "/api/schedules", schedule("headless"), "ana", "", http.StatusForbidden},
+		{"root path with encoded slash", http.MethodGet, "/api/session-roots/..%2F..%2Fetc", "", "admin", "", http.StatusBadRequest},
+		{"session id with dot segments", http.MethodGet, "/api/session-roots/-home-x/sessions/..%2F..%2Fx", "", "admin", "", http.StatusBadRequest},
+		{"analytics root path with encoded slash", http.MethodGet, "/api/analytics/session-roots/-home-x%2F..", "", "admin", "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		switch {
+		case tc.token != "":
+			req.Header.Set("X-API-Token", tc.token)
+		case tc.user == "admin":
+			req.SetBasicAuth("admin", "secret")
+		default:
+			req.SetBasicAuth(tc.user, "password-"+tc.user)
+		}
+		if rec := serve(h, req); rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, rec.Code, tc.status, rec.Body.String())
+		}
+	}
+
+	// Un schedule en modo terminal ya creado tampoco se puede lanzar ni modificar sin terminals:write
+	var terminalID string
+	for _, s := range scheduler.List() {
+		if s.Mode == services.ScheduleTerminal {
+			terminalID = s.ID
+		}
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/schedules/"+terminalID+"/run", nil),
+		httptest.NewRequest(http.MethodPut, "/api/schedules/"+terminalID, strings.NewReader(schedule("headless"))),
+	} {
+		req.Header.Set("X-API-Token", jobsOnly)
+		if rec := serve(h, req); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status = %d, want 403", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+}
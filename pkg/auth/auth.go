@@ -0,0 +1,211 @@
+// Package auth define roles, permisos e identidad de las peticiones autenticadas
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierrors "claude-monitor/pkg/errors"
+)
+
+// Role rol de un usuario
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"    // todo, incluida la configuración y los usuarios
+	RoleOperator Role = "operator" // crear y manejar terminales, sesiones, jobs y archivos
+	RoleViewer   Role = "viewer"   // solo lectura
+)
+
+// Permission permiso que requiere una ruta
+type Permission string
+
+const (
+	PermTerminalsRead  Permission = "terminals:read"
+	PermTerminalsWrite Permission = "terminals:write"
+	PermSessionsRead   Permission = "sessions:read"
+	PermSessionsWrite  Permission = "sessions:write"
+	PermSessionsDelete Permission = "sessions:delete"
+	PermAnalyticsRead  Permission = "analytics:read"
+	PermFilesRead      Permission = "files:read"
+	PermFilesWrite     Permission = "files:write"
+	PermJobsRead       Permission = "jobs:read"
+	PermJobsWrite      Permission = "jobs:write"
+	PermSettingsRead   Permission = "settings:read"
+	PermSettingsWrite  Permission = "settings:write"
+	PermUsersAdmin     Permission = "users:admin"
+)
+
+// KnownPermissions todos los permisos
+var KnownPermissions = []Permission{
+	PermTerminalsRead,
+	PermTerminalsWrite,
+	PermSessionsRead,
+	PermSessionsWrite,
+	PermSessionsDelete,
+	PermAnalyticsRead,
+	PermFilesRead,
+	PermFilesWrite,
+	PermJobsRead,
+	PermJobsWrite,
+	PermSettingsRead,
+	PermSettingsWrite,
+	PermUsersAdmin,
+}
+
+var viewerPermissions = []Permission{
+	PermTerminalsRead,
+	PermSessionsRead,
+	PermAnalyticsRead,
+	PermFilesRead,
+	PermJobsRead,
+}
+
+var operatorPermissions = append(append([]Permission(nil), viewerPermissions...),
+	PermTerminalsWrite,
+	PermSessionsWrite,
+	PermSessionsDelete,
+	PermFilesWrite,
+	PermJobsWrite,
+	PermSettingsRead,
+)
+
+// rolePermissions permisos de cada rol
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:    KnownPermissions,
+	RoleOperator: operatorPermissions,
+	RoleViewer:   viewerPermissions,
+}
+
+// ValidRole verifica si un rol existe
+func ValidRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// ValidPermission verifica si un permiso existe
+func ValidPermission(perm Permission) bool {
+	for _, known := range KnownPermissions {
+		if known == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions permisos del rol
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// Principal identidad autenticada de una petición
+type Principal struct {
+	Name         string       `json:"name"`
+	Role         Role         `json:"role,omitempty"` // vacío para tokens de API
+	Permissions  []Permission `json:"permissions"`
+	PathPrefixes []string     `json:"path_prefixes,omitempty"` // rutas de la API permitidas (vacío = todas)
+}
+
+// NewPrincipal crea la identidad de un usuario con los permisos de su rol
+func NewPrincipal(name string, role Role) *Principal {
+	return &Principal{
+		Name:        name,
+		Role:        role,
+		Permissions: role.Permissions(),
+	}
+}
+
+// NewScopedPrincipal crea la identidad de un token de API con sus scopes
+func NewScopedPrincipal(name string, scopes []Permission, pathPrefixes []string) *Principal {
+	return &Principal{
+		Name:         name,
+		Permissions:  scopes,
+		PathPrefixes: pathPrefixes,
+	}
+}
+
+// Can verifica si la identidad tiene un permiso
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range p.Permissions {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPath verifica si la identidad puede acceder a una ruta de la API.
+// Un prefijo cubre la ruta exacta y lo que cuelga de ella ("/api/a" no cubre "/api/ab").
+// path es la ruta escapada (la que usa el router): con prefijos, una "/"
+// codificada o un segmento "." o ".." se rechazan porque el router y el
+// prefijo verían rutas distintas.
+func (p *Principal) AllowsPath(path string) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.PathPrefixes) == 0 {
+		return true
+	}
+	if !canonicalPath(path) {
+		return false
+	}
+	for _, prefix := range p.PathPrefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath verifica que una ruta escapada no tenga "/" codificadas ni
+// segmentos "." o ".." (tampoco codificados)
+func canonicalPath(path string) bool {
+	if strings.Contains(strings.ToLower(path), "%2f") {
+		return false
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if decoded, err := url.PathUnescape(segment); err != nil || decoded == "." || decoded == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// WithPrincipal añade la identidad al contexto
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext obtiene la identidad del contexto (nil si la petición no está autenticada)
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey).(*Principal)
+	return p
+}
+
+// Require middleware que exige un permiso a la identidad de la petición
+func Require(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := FromContext(r.Context())
+			if p == nil {
+				apierrors.WriteError(w, apierrors.ErrUnauthorized)
+				return
+			}
+			if !p.Can(perm) {
+				apierrors.WriteError(w, apierrors.New(apierrors.ErrCodeForbidden, "permiso requerido: "+string(perm)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
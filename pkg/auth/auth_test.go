@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	admin := NewPrincipal("root", RoleAdmin)
+	operator := NewPrincipal("ops", RoleOperator)
+	viewer := NewPrincipal("ana", RoleViewer)
+
+	for _, perm := range KnownPermissions {
+		if !admin.Can(perm) {
+			t.Errorf("admin should have %s", perm)
+		}
+	}
+	if !operator.Can(PermTerminalsWrite) || operator.Can(PermSettingsWrite) || operator.Can(PermUsersAdmin) {
+		t.Errorf("operator permissions: %v", operator.Permissions)
+	}
+	if !viewer.Can(PermTerminalsRead) || viewer.Can(PermTerminalsWrite) || viewer.Can(PermSessionsDelete) {
+		t.Errorf("viewer permissions: %v", viewer.Permissions)
+	}
+
+	var nobody *Principal
+	if nobody.Can(PermTerminalsRead) {
+		t.Error("nil principal should have no permissions")
+	}
+	if ValidRole("root") || !ValidRole(RoleViewer) {
+		t.Error("ValidRole")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	handler := Require(PermTerminalsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		want      int
+	}{
+		{"unauthenticated", nil, http.StatusUnauthorized},
+		{"viewer", NewPrincipal("ana", RoleViewer), http.StatusForbidden},
+		{"operator", NewPrincipal("ops", RoleOperator), http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/terminals", nil)
+			if tt.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), tt.principal))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAllowsPath(t *testing.T) {
+	ci := NewScopedPrincipal("token:ci", []Permission{PermSessionsRead}, []string{"/api/session-roots/-home-ci-app"})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/session-roots/-home-ci-app", true},
+		{"/api/session-roots/-home-ci-app/sessions", true},
+		{"/api/session-roots/-home-ci-app2", false},
+		{"/api/terminals", false},
+		// El router ve {rootPath} = "-home-ci-app/../../terminals"
+		{"/api/session-roots/-home-ci-app%2F..%2F..%2Fterminals", false},
+		{"/api/session-roots/-home-ci-app%2f..%2f..%2fterminals", false},
+		{"/api/session-roots/-home-ci-app/../../terminals", false},
+		{"/api/session-roots/-home-ci-app/%2e%2e/other", false},
+	}
+	for _, tt := range tests {
+		if got := ci.AllowsPath(tt.path); got != tt.want {
+			t.Errorf("AllowsPath(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if !NewPrincipal("root", RoleAdmin).AllowsPath("/api/terminals") {
+		t.Error("Expected unrestricted principal to allow any path")
+	}
+	if ci.Can(PermSessionsDelete) {
+		t.Error("Expected scoped principal without sessions:delete")
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	previous := PasswordIterations
+	t.Cleanup(func() { PasswordIterations = previous })
+	PasswordIterations = 1000
+
+	hash, err := HashPassword("s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyPassword(hash, "s3creto") {
+		t.Error("Expected password to verify")
+	}
+	if VerifyPassword(hash, "otro") {
+		t.Error("Expected wrong password to fail")
+	}
+
+	other, _ := HashPassword("s3creto")
+	if other == hash {
+		t.Error("Expected different salts")
+	}
+	if VerifyPassword("plano", "plano") || VerifyPassword("pbkdf2-sha256$x$y$z", "y") {
+		t.Error("Expected malformed hashes to fail")
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PasswordIterations iteraciones de PBKDF2-SHA256 para hashes nuevos
+var PasswordIterations = 600000
+
+const (
+	passwordScheme  = "pbkdf2-sha256"
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+)
+
+// HashPassword genera un hash "pbkdf2-sha256$<iteraciones>$<salt>$<hash>"
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, PasswordIterations, passwordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, PasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword compara una contraseña con un hash de HashPassword
+func VerifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// DefaultTicketTTL vigencia de un ticket de WebSocket
+const DefaultTicketTTL = 30 * time.Second
+
+// Ticket credencial de un solo uso para abrir un WebSocket concreto
+type Ticket struct {
+	Ticket    string    `json:"ticket"`
+	Path      string    `json:"path"` // ruta del WebSocket para la que vale
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type issuedTicket struct {
+	principal *Principal
+	path      string
+	expires   time.Time
+}
+
+// TicketStore tickets de WebSocket en memoria: los navegadores no pueden enviar
+// cabeceras al abrir un WebSocket y las credenciales en la query string acaban
+// en los logs de proxies, así que se canjea un ticket efímero emitido por una
+// petición autenticada normalmente
+type TicketStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	tickets map[string]issuedTicket
+}
+
+// NewTicketStore crea un almacén de tickets con la vigencia indicada
+func NewTicketStore(ttl time.Duration) *TicketStore {
+	if ttl <= 0 {
+		ttl = DefaultTicketTTL
+	}
+	return &TicketStore{
+		ttl:     ttl,
+		tickets: make(map[string]issuedTicket),
+	}
+}
+
+// Issue emite un ticket para que p abra el WebSocket de path
+func (ts *TicketStore) Issue(p *Principal, path string) (*Ticket, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	expires := now.Add(ts.ttl)
+
+	ts.mu.Lock()
+	for t, issued := range ts.tickets {
+		if now.After(issued.expires) {
+			delete(ts.tickets, t)
+		}
+	}
+	ts.tickets[ticket] = issuedTicket{principal: p, path: path, expires: expires}
+	ts.mu.Unlock()
+
+	return &Ticket{Ticket: ticket, Path: path, ExpiresAt: expires}, nil
+}
+
+// Redeem canjea un ticket para path. Retorna la identidad que lo emitió, o nil
+// si no existe, caducó o es de otra ruta. El ticket se consume en cualquier caso.
+func (ts *TicketStore) Redeem(ticket, path string) *Principal {
+	if ticket == "" {
+		return nil
+	}
+
+	ts.mu.Lock()
+	issued, ok := ts.tickets[ticket]
+	delete(ts.tickets, ticket)
+	ts.mu.Unlock()
+
+	if !ok || issued.path != path || time.Now().After(issued.expires) {
+		return nil
+	}
+	return issued.principal
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicketStore(t *testing.T) {
+	ts := NewTicketStore(time.Minute)
+	ops := NewPrincipal("ops", RoleOperator)
+
+	ticket, err := ts.Issue(ops, "/api/terminals/t1/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Ticket == "" || ticket.Path != "/api/terminals/t1/ws" {
+		t.Errorf("Issue: %+v", ticket)
+	}
+
+	// Un solo uso
+	if p := ts.Redeem(ticket.Ticket, "/api/terminals/t1/ws"); p != ops {
+		t.Errorf("Expected issuing principal, got %+v", p)
+	}
+	if ts.Redeem(ticket.Ticket, "/api/terminals/t1/ws") != nil {
+		t.Error("Expected ticket to be single-use")
+	}
+
+	// Ligado a la ruta: el intento fallido también lo consume
+	other, _ := ts.Issue(ops, "/api/terminals/t1/ws")
+	if ts.Redeem(other.Ticket, "/api/terminals/t2/ws") != nil {
+		t.Error("Expected ticket bound to its terminal")
+	}
+	if ts.Redeem(other.Ticket, "/api/terminals/t1/ws") != nil {
+		t.Error("Expected failed redeem to consume the ticket")
+	}
+
+	if ts.Redeem("", "/api/events/ws") != nil || ts.Redeem("inventado", "/api/events/ws") != nil {
+		t.Error("Expected unknown ticket to fail")
+	}
+}
+
+func TestTicketStore_Expiry(t *testing.T) {
+	ts := NewTicketStore(10 * time.Millisecond)
+
+	ticket, _ := ts.Issue(NewPrincipal("ana", RoleViewer), "/api/events/ws")
+	time.Sleep(20 * time.Millisecond)
+	if ts.Redeem(ticket.Ticket, "/api/events/ws") != nil {
+		t.Error("Expected expired ticket to fail")
+	}
+
+	// Los caducados se purgan al emitir
+	ts.Issue(NewPrincipal("ana", RoleViewer), "/api/events/ws")
+	time.Sleep(20 * time.Millisecond)
+	ts.Issue(NewPrincipal("ana", RoleViewer), "/api/events/ws")
+	if len(ts.tickets) != 1 {
+		t.Errorf("Expected expired tickets to be purged, got %d", len(ts.tickets))
+	}
+}
@@ -0,0 +1,341 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxEdits número máximo de inserciones + borrados que se buscan con Myers.
+// Por encima, la zona distinta se reporta como un único reemplazo.
+const MaxEdits = 2000
+
+// Tipos de línea
+const (
+	KindContext = "context"
+	KindAdd     = "add"
+	KindDelete  = "delete"
+)
+
+// Line línea de un hunk
+type Line struct {
+	Kind    string `json:"kind"`
+	Text    string `json:"text"` // sin el salto de línea final
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	NoEOL   bool   `json:"no_eol,omitempty"` // última línea sin salto de línea
+}
+
+// Hunk bloque de cambios con contexto
+type Hunk struct {
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	Lines    []Line `json:"lines"`
+}
+
+// Result diff línea a línea entre dos textos
+type Result struct {
+	Hunks     []Hunk `json:"hunks"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// op operación del script de edición
+type op struct {
+	kind string
+	a, b int // índices en a (context/delete) y b (context/add)
+}
+
+// Lines calcula el diff de a contra b con context líneas de contexto por hunk
+func Lines(a, b string, context int) *Result {
+	la, lb := toLines(a), toLines(b)
+	ops := editScript(la, lb)
+
+	result := &Result{Hunks: make([]Hunk, 0)}
+	for _, o := range ops {
+		switch o.kind {
+		case KindAdd:
+			result.Additions++
+		case KindDelete:
+			result.Deletions++
+		}
+	}
+	if result.Additions == 0 && result.Deletions == 0 {
+		return result
+	}
+
+	line := func(o op) Line {
+		l := Line{Kind: o.kind}
+		var text string
+		if o.kind == KindAdd {
+			text = lb[o.b]
+			l.NewLine = o.b + 1
+		} else {
+			text = la[o.a]
+			l.OldLine = o.a + 1
+			if o.kind == KindContext {
+				l.NewLine = o.b + 1
+			}
+		}
+		l.Text = strings.TrimSuffix(text, "\n")
+		l.NoEOL = !strings.HasSuffix(text, "\n")
+		return l
+	}
+
+	// Agrupar cambios separados por más de 2*context líneas iguales en hunks distintos
+	for i := 0; i < len(ops); {
+		if ops[i].kind == KindContext {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != KindContext {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == KindContext {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+		stop := end + context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		hunk := Hunk{OldStart: oldPos(ops, start), NewStart: newPos(ops, start)}
+		for _, o := range ops[start:stop] {
+			if o.kind != KindAdd {
+				hunk.OldLines++
+			}
+			if o.kind != KindDelete {
+				hunk.NewLines++
+			}
+			hunk.Lines = append(hunk.Lines, line(o))
+		}
+		// Convención de unified diff: un rango vacío empieza en la línea anterior
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		result.Hunks = append(result.Hunks, hunk)
+		i = stop
+	}
+
+	return result
+}
+
+// toLines separa en líneas conservando el "\n" de cada una
+func toLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// oldPos líneas de a consumidas antes de ops[i]
+func oldPos(ops []op, i int) int {
+	for j := i; j < len(ops); j++ {
+		if ops[j].kind != KindAdd {
+			return ops[j].a
+		}
+	}
+	for j := i - 1; j >= 0; j-- {
+		if ops[j].kind != KindAdd {
+			return ops[j].a + 1
+		}
+	}
+	return 0
+}
+
+// newPos líneas de b consumidas antes de ops[i]
+func newPos(ops []op, i int) int {
+	for j := i; j < len(ops); j++ {
+		if ops[j].kind != KindDelete {
+			return ops[j].b
+		}
+	}
+	for j := i - 1; j >= 0; j-- {
+		if ops[j].kind != KindDelete {
+			return ops[j].b + 1
+		}
+	}
+	return 0
+}
+
+// editScript calcula el script de edición mínimo (Myers) entre a y b
+func editScript(a, b []string) []op {
+	// Prefijo y sufijo comunes fuera del algoritmo
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: KindContext, a: i, b: i})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	middle, ok := myers(ma, mb)
+	if !ok {
+		middle = middle[:0]
+		for i := range ma {
+			middle = append(middle, op{kind: KindDelete, a: i})
+		}
+		for j := range mb {
+			middle = append(middle, op{kind: KindAdd, b: j})
+		}
+	}
+	for _, o := range middle {
+		o.a += prefix
+		o.b += prefix
+		ops = append(ops, o)
+	}
+
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, op{kind: KindContext, a: len(a) - suffix + i, b: len(b) - suffix + i})
+	}
+	return ops
+}
+
+// myers algoritmo O((N+M)D) de Myers. Retorna false si se superan MaxEdits.
+func myers(a, b []string) ([]op, bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+	maxD := n + m
+	if maxD > MaxEdits {
+		maxD = MaxEdits
+	}
+
+	// v[k+offset] = x más lejano en la diagonal k; trace[d] = v antes del paso d
+	offset := maxD + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack reconstruye el script de edición desde (n, m)
+func backtrack(trace [][]int, n, m int) []op {
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d] // índices k+d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: KindContext, a: x, b: y})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, op{kind: KindAdd, b: y})
+		} else {
+			x--
+			ops = append(ops, op{kind: KindDelete, a: x})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, op{kind: KindContext, a: x, b: y})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Unified formatea el resultado como unified diff (formato de diff -u / git)
+func (r *Result) Unified(oldName, newName string) string {
+	if len(r.Hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range r.Hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case KindAdd:
+				sb.WriteByte('+')
+			case KindDelete:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+			if l.NoEOL {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// hunkRange formatea "start,count" omitiendo count cuando es 1
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines_Identical(t *testing.T) {
+	r := Lines("a\nb\n", "a\nb\n", 3)
+	if len(r.Hunks) != 0 || r.Additions != 0 || r.Deletions != 0 {
+		t.Errorf("Expected no changes, got %+v", r)
+	}
+	if r.Unified("a", "b") != "" {
+		t.Error("Unified of identical texts should be empty")
+	}
+}
+
+func TestLines_Unified(t *testing.T) {
+	a := "uno\ndos\ntres\ncuatro\ncinco\nseis\nsiete\nocho\nnueve\ndiez\n"
+	b := "uno\nDOS\ntres\ncuatro\ncinco\nseis\nsiete\nocho\nnueve\ndiez\nonce\n"
+
+	r := Lines(a, b, 1)
+	if r.Additions != 2 || r.Deletions != 1 {
+		t.Errorf("Additions=%d Deletions=%d", r.Additions, r.Deletions)
+	}
+	if len(r.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(r.Hunks))
+	}
+
+	expected := `--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+ uno
+-dos
++DOS
+ tres
+@@ -10 +10,2 @@
+ diez
++once
+`
+	if got := r.Unified("a/f", "b/f"); got != expected {
+		t.Errorf("Unified:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestLines_MergesCloseChanges(t *testing.T) {
+	r := Lines("a\nb\nc\nd\n", "A\nb\nc\nD\n", 1)
+	if len(r.Hunks) != 1 {
+		t.Fatalf("Changes 2 lines apart with context 1 should share a hunk, got %d", len(r.Hunks))
+	}
+	h := r.Hunks[0]
+	if h.OldStart != 1 || h.OldLines != 4 || h.NewStart != 1 || h.NewLines != 4 {
+		t.Errorf("Hunk range: %+v", h)
+	}
+}
+
+func TestLines_NoEOLAndEmpty(t *testing.T) {
+	r := Lines("a\nb", "a\nb\n", 3)
+	u := r.Unified("old", "new")
+	if !strings.Contains(u, "-b\n\\ No newline at end of file\n+b\n") {
+		t.Errorf("Missing no-newline marker:\n%s", u)
+	}
+
+	r = Lines("", "x\ny\n", 3)
+	if r.Additions != 2 || r.Hunks[0].OldStart != 0 || r.Hunks[0].OldLines != 0 || r.Hunks[0].NewStart != 1 {
+		t.Errorf("Diff from empty: %+v", r.Hunks[0])
+	}
+	if !strings.Contains(r.Unified("a", "b"), "@@ -0,0 +1,2 @@") {
+		t.Errorf("Range from empty:\n%s", r.Unified("a", "b"))
+	}
+}
+
+func TestLines_LineNumbers(t *testing.T) {
+	r := Lines("a\nb\nc\n", "a\nx\nb\nc\n", 0)
+	if len(r.Hunks) != 1 || len(r.Hunks[0].Lines) != 1 {
+		t.Fatalf("Hunks: %+v", r.Hunks)
+	}
+	l := r.Hunks[0].Lines[0]
+	if l.Kind != KindAdd || l.Text != "x" || l.NewLine != 2 || l.OldLine != 0 {
+		t.Errorf("Added line: %+v", l)
+	}
+	if h := r.Hunks[0]; h.OldStart != 1 || h.OldLines != 0 || h.NewStart != 2 || h.NewLines != 1 {
+		t.Errorf("Insert-only hunk range: %+v", h)
+	}
+}
+
+func TestLines_TooManyEditsFallsBack(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < MaxEdits; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+	r := Lines(a.String(), b.String(), 3)
+	if r.Additions != MaxEdits || r.Deletions != MaxEdits {
+		t.Errorf("Additions=%d Deletions=%d", r.Additions, r.Deletions)
+	}
+}
@@ -78,6 +78,8 @@ func (e *APIError) HTTPStatus() int {
 		return http.StatusConflict
 	case ErrCodeTooManyRequests:
 		return http.StatusTooManyRequests
+	case ErrCodeTooLarge:
+		return http.StatusRequestEntityTooLarge
 	default:
 		return http.StatusInternalServerError
 	}
@@ -211,3 +213,11 @@ const ErrCodeTooManyRequests ErrorCode = "TOO_MANY_REQUESTS"
 func TooManyRequests(message string) *APIError {
 	return New(ErrCodeTooManyRequests, message)
 }
+
+// TooLarge para recursos que superan un límite de tamaño
+const ErrCodeTooLarge ErrorCode = "TOO_LARGE"
+
+// TooLarge crea un error de tamaño excedido
+func TooLarge(message string) *APIError {
+	return New(ErrCodeTooLarge, message)
+}
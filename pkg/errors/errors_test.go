@@ -59,6 +59,7 @@ func TestAPIError_HTTPStatus(t *testing.T) {
 		{"path not allowed", ErrCodePathNotAllowed, http.StatusBadRequest},
 		{"conflict", ErrCodeConflict, http.StatusConflict},
 		{"too many requests", ErrCodeTooManyRequests, http.StatusTooManyRequests},
+		{"too large", ErrCodeTooLarge, http.StatusRequestEntityTooLarge},
 		{"internal", ErrCodeInternal, http.StatusInternalServerError},
 		{"unknown", ErrorCode("UNKNOWN"), http.StatusInternalServerError},
 	}
@@ -136,6 +136,39 @@ var (
 		[]string{"tool"},
 	)
 
+	// Terminal resource metrics
+	terminalCPUPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "claude_monitor_terminal_cpu_percent",
+			Help: "CPU usage of the terminal process tree (100 = one core)",
+		},
+		[]string{"terminal_id"},
+	)
+
+	terminalMemoryRSS = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "claude_monitor_terminal_memory_rss_bytes",
+			Help: "Resident memory of the terminal process tree",
+		},
+		[]string{"terminal_id"},
+	)
+
+	terminalProcesses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "claude_monitor_terminal_processes",
+			Help: "Number of processes in the terminal process tree",
+		},
+		[]string{"terminal_id"},
+	)
+
+	terminalLimitKillsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "claude_monitor_terminal_limit_kills_total",
+			Help: "Total processes killed by a resource limit",
+		},
+		[]string{"limit"}, // memory, cpu_time, file_size
+	)
+
 	// Rate limiting metrics
 	rateLimitHitsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -183,6 +216,10 @@ func Init(version string) {
 		ptyReadOpsTotal,
 		ptyWriteOpsTotal,
 		permissionPromptsTotal,
+		terminalCPUPercent,
+		terminalMemoryRSS,
+		terminalProcesses,
+		terminalLimitKillsTotal,
 		rateLimitHitsTotal,
 		healthCheckDuration,
 		healthCheckStatus,
@@ -328,6 +365,11 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Unwrap expone el writer original a http.ResponseController (deadlines)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Claude State metrics
 
 // RecordClaudeStateChange records a Claude state transition
@@ -377,6 +419,27 @@ func RecordPermissionPrompt(tool string) {
 	permissionPromptsTotal.WithLabelValues(tool).Inc()
 }
 
+// Terminal resource metrics
+
+// SetTerminalResources sets the live resource usage of a terminal
+func SetTerminalResources(terminalID string, cpuPercent float64, rssBytes uint64, processes int) {
+	terminalCPUPercent.WithLabelValues(terminalID).Set(cpuPercent)
+	terminalMemoryRSS.WithLabelValues(terminalID).Set(float64(rssBytes))
+	terminalProcesses.WithLabelValues(terminalID).Set(float64(processes))
+}
+
+// ClearTerminalResources clears resource metrics for a terminal
+func ClearTerminalResources(terminalID string) {
+	terminalCPUPercent.DeleteLabelValues(terminalID)
+	terminalMemoryRSS.DeleteLabelValues(terminalID)
+	terminalProcesses.DeleteLabelValues(terminalID)
+}
+
+// RecordTerminalLimitKill records a process killed by a resource limit
+func RecordTerminalLimitKill(limit string) {
+	terminalLimitKillsTotal.WithLabelValues(limit).Inc()
+}
+
 // Rate limiting metrics
 
 // RecordRateLimitHit records a rate limit hit
@@ -162,6 +162,14 @@ func (v *Validator) Range(field string, value, min, max int) *Validator {
 	return v
 }
 
+// EnvName valida el nombre de una variable de entorno
+func (v *Validator) EnvName(field, name string) *Validator {
+	if matched, _ := regexp.MatchString(`^[A-Za-z_][A-Za-z0-9_]*$`, name); !matched {
+		v.AddError(field, "nombre de variable inválido")
+	}
+	return v
+}
+
 // DecodeAndValidate decodifica JSON y valida
 func DecodeAndValidate[T any](r *http.Request, validate func(*T, *Validator)) (*T, error) {
 	var req T
@@ -187,10 +195,35 @@ type TerminalConfigRequest struct {
 	WorkDir         string   `json:"work_dir"`
 	Type            string   `json:"type,omitempty"`
 	Model           string   `json:"model,omitempty"`
+	SystemPrompt    string   `json:"system_prompt,omitempty"`
+	PermissionMode  string   `json:"permission_mode,omitempty"`
 	Resume          bool     `json:"resume,omitempty"`
 	Continue        bool     `json:"continue,omitempty"`
 	AllowedTools    []string `json:"allowed_tools,omitempty"`
 	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	AdditionalDirs  []string `json:"additional_dirs,omitempty"`
+	EnableHooks     bool     `json:"enable_hooks,omitempty"`
+	Worktree        bool     `json:"worktree,omitempty"`        // git worktree y rama dedicados
+	WorktreeBranch  string   `json:"worktree_branch,omitempty"` // por defecto claude/<id>
+	WatchFiles      bool     `json:"watch_files,omitempty"`     // observar cambios de archivos en work_dir
+	TemplateID      string   `json:"template_id,omitempty"`     // template base; los demás campos lo sobrescriben
+
+	Env      map[string]string `json:"env,omitempty"`
+	EnvFiles []string          `json:"env_files,omitempty"` // relativos a work_dir
+	Secrets  map[string]string `json:"secrets,omitempty"`   // variable -> nombre del secreto
+
+	Limits *ResourceLimitsRequest `json:"limits,omitempty"`
+}
+
+// ResourceLimitsRequest límites de recursos de una terminal (0 = sin límite)
+type ResourceLimitsRequest struct {
+	MemoryMB       int64 `json:"memory_mb,omitempty"`
+	CPUPercent     int64 `json:"cpu_percent,omitempty"` // 100 = un núcleo
+	MaxProcesses   int64 `json:"max_processes,omitempty"`
+	CPUSeconds     int64 `json:"cpu_seconds,omitempty"`
+	OpenFiles      int64 `json:"open_files,omitempty"`
+	FileSizeMB     int64 `json:"file_size_mb,omitempty"`
+	AddressSpaceMB int64 `json:"address_space_mb,omitempty"`
 }
 
 // ValidateTerminalConfig valida configuración de terminal
@@ -211,6 +244,59 @@ func ValidateTerminalConfig(req *TerminalConfigRequest, v *Validator) {
 	if req.ID != "" {
 		v.UUID("id", req.ID)
 	}
+
+	if req.TemplateID != "" {
+		v.UUID("template_id", req.TemplateID)
+	}
+
+	if req.WorktreeBranch != "" {
+		v.MaxLength("worktree_branch", req.WorktreeBranch, 200)
+		if !req.Worktree {
+			v.AddError("worktree_branch", "requiere worktree")
+		}
+	}
+
+	v.OneOf("permission_mode", req.PermissionMode, []string{
+		"default", "acceptEdits", "bypassPermissions", "plan",
+	})
+
+	for i, dir := range req.AdditionalDirs {
+		field := fmt.Sprintf("additional_dirs[%d]", i)
+		v.AbsolutePath(field, dir)
+		v.NoPathTraversal(field, dir)
+	}
+
+	for i, f := range req.EnvFiles {
+		field := fmt.Sprintf("env_files[%d]", i)
+		if filepath.IsAbs(f) {
+			v.AddError(field, "debe ser relativo a work_dir")
+		}
+		v.NoPathTraversal(field, f)
+	}
+
+	for name := range req.Env {
+		v.EnvName("env."+name, name)
+	}
+	for name, secret := range req.Secrets {
+		v.EnvName("secrets."+name, name)
+		v.Required("secrets."+name, secret)
+	}
+
+	if l := req.Limits; l != nil {
+		for field, value := range map[string]int64{
+			"limits.memory_mb":        l.MemoryMB,
+			"limits.cpu_percent":      l.CPUPercent,
+			"limits.max_processes":    l.MaxProcesses,
+			"limits.cpu_seconds":      l.CPUSeconds,
+			"limits.open_files":       l.OpenFiles,
+			"limits.file_size_mb":     l.FileSizeMB,
+			"limits.address_space_mb": l.AddressSpaceMB,
+		} {
+			if value < 0 {
+				v.AddError(field, "no puede ser negativo")
+			}
+		}
+	}
 }
 
 // SessionIDsRequest request para eliminar múltiples sesiones
@@ -241,3 +327,86 @@ func ValidateResize(req *ResizeRequest, v *Validator) {
 	v.Range("rows", int(req.Rows), 1, 500)
 	v.Range("cols", int(req.Cols), 1, 500)
 }
+
+// TerminalInputRequest request para enviar entrada a una terminal
+type TerminalInputRequest struct {
+	Text           string   `json:"text,omitempty"`
+	Keys           []string `json:"keys,omitempty"`
+	WaitFor        string   `json:"wait_for,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"` // espera máxima de wait_for (1-25, default 25)
+}
+
+// ValidateTerminalInput valida request de entrada a terminal
+func ValidateTerminalInput(req *TerminalInputRequest, v *Validator) {
+	if req.Text == "" && len(req.Keys) == 0 {
+		v.AddError("text", "se requiere text o keys")
+	}
+
+	v.MaxLength("text", req.Text, 64*1024)
+
+	if len(req.Keys) > 100 {
+		v.AddError("keys", "máximo 100 teclas por request")
+	}
+
+	v.OneOf("wait_for", req.WaitFor, []string{
+		"waiting_input", "generating", "permission_prompt",
+		"tool_running", "background_task", "error", "exited",
+	})
+
+	if req.TimeoutSeconds != 0 {
+		v.Range("timeout_seconds", req.TimeoutSeconds, 1, 25)
+	}
+}
+
+// PermissionAnswerRequest request para responder un prompt de permiso
+type PermissionAnswerRequest struct {
+	Action string `json:"action"`
+}
+
+// ValidatePermissionAnswer valida request de respuesta de permiso
+func ValidatePermissionAnswer(req *PermissionAnswerRequest, v *Validator) {
+	v.Required("action", req.Action)
+	v.OneOf("action", req.Action, []string{"allow", "always_allow", "deny"})
+}
+
+// JobSubmitRequest request para encolar un job headless
+type JobSubmitRequest struct {
+	Name            string   `json:"name,omitempty"`
+	Prompt          string   `json:"prompt"`
+	WorkDir         string   `json:"work_dir"`
+	Model           string   `json:"model,omitempty"`
+	SystemPrompt    string   `json:"system_prompt,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	PermissionMode  string   `json:"permission_mode,omitempty"`
+	MaxTurns        int      `json:"max_turns,omitempty"`
+	OutputFormat    string   `json:"output_format,omitempty"`
+	TimeoutSeconds  int      `json:"timeout_seconds,omitempty"`
+}
+
+// ValidateJobSubmit valida request de job headless
+func ValidateJobSubmit(req *JobSubmitRequest, v *Validator) {
+	v.Required("prompt", req.Prompt)
+	v.MaxLength("prompt", req.Prompt, 256*1024)
+
+	v.Required("work_dir", req.WorkDir)
+	v.AbsolutePath("work_dir", req.WorkDir)
+	v.NoPathTraversal("work_dir", req.WorkDir)
+	v.DirExists("work_dir", req.WorkDir)
+
+	if req.Name != "" {
+		v.MaxLength("name", req.Name, 100)
+	}
+
+	v.OneOf("output_format", req.OutputFormat, []string{"stream-json", "json", "text"})
+	v.OneOf("permission_mode", req.PermissionMode, []string{
+		"default", "acceptEdits", "bypassPermissions", "plan",
+	})
+
+	if req.MaxTurns != 0 {
+		v.Range("max_turns", req.MaxTurns, 1, 1000)
+	}
+	if req.TimeoutSeconds != 0 {
+		v.Positive("timeout_seconds", req.TimeoutSeconds)
+	}
+}
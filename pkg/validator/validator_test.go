@@ -210,6 +210,74 @@ func TestValidateTerminalConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "template with overrides",
+			req: TerminalConfigRequest{
+				WorkDir:        "/tmp",
+				TemplateID:     "550e8400-e29b-41d4-a716-446655440000",
+				PermissionMode: "plan",
+				AdditionalDirs: []string{"/var/data"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid template_id",
+			req: TerminalConfigRequest{
+				WorkDir:    "/tmp",
+				TemplateID: "not-a-uuid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative additional dir",
+			req: TerminalConfigRequest{
+				WorkDir:        "/tmp",
+				AdditionalDirs: []string{"data"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "env, env files and secrets",
+			req: TerminalConfigRequest{
+				WorkDir:  "/tmp",
+				Env:      map[string]string{"ANTHROPIC_MODEL": "claude-sonnet-4-5"},
+				EnvFiles: []string{".env", "config/.env.local"},
+				Secrets:  map[string]string{"ANTHROPIC_API_KEY": "project-a-key"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid env name",
+			req: TerminalConfigRequest{
+				WorkDir: "/tmp",
+				Env:     map[string]string{"1BAD-NAME": "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute env file",
+			req: TerminalConfigRequest{
+				WorkDir:  "/tmp",
+				EnvFiles: []string{"/etc/environment"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "env file traversal",
+			req: TerminalConfigRequest{
+				WorkDir:  "/tmp",
+				EnvFiles: []string{"../other/.env"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty secret reference",
+			req: TerminalConfigRequest{
+				WorkDir: "/tmp",
+				Secrets: map[string]string{"API_KEY": ""},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -223,3 +291,57 @@ func TestValidateTerminalConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTerminalInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     TerminalInputRequest
+		wantErr bool
+	}{
+		{"text only", TerminalInputRequest{Text: "hola"}, false},
+		{"keys only", TerminalInputRequest{Keys: []string{"Enter"}}, false},
+		{"empty", TerminalInputRequest{}, true},
+		{"valid wait_for", TerminalInputRequest{Text: "x", WaitFor: "waiting_input", TimeoutSeconds: 20}, false},
+		{"invalid wait_for", TerminalInputRequest{Text: "x", WaitFor: "sleeping"}, true},
+		{"timeout out of range", TerminalInputRequest{Text: "x", TimeoutSeconds: 601}, true},
+		{"timeout above write timeout", TerminalInputRequest{Text: "x", TimeoutSeconds: 26}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			ValidateTerminalInput(&tt.req, v)
+			if v.HasErrors() != tt.wantErr {
+				t.Errorf("ValidateTerminalInput() hasErrors = %v, want %v, errors: %v",
+					v.HasErrors(), tt.wantErr, v.Errors())
+			}
+		})
+	}
+}
+
+func TestValidateJobSubmit(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     JobSubmitRequest
+		wantErr bool
+	}{
+		{"valid", JobSubmitRequest{Prompt: "hola", WorkDir: "/tmp"}, false},
+		{"valid json", JobSubmitRequest{Prompt: "hola", WorkDir: "/tmp", OutputFormat: "json", MaxTurns: 3}, false},
+		{"missing prompt", JobSubmitRequest{WorkDir: "/tmp"}, true},
+		{"relative work_dir", JobSubmitRequest{Prompt: "hola", WorkDir: "tmp"}, true},
+		{"invalid output_format", JobSubmitRequest{Prompt: "hola", WorkDir: "/tmp", OutputFormat: "xml"}, true},
+		{"invalid permission_mode", JobSubmitRequest{Prompt: "hola", WorkDir: "/tmp", PermissionMode: "yolo"}, true},
+		{"negative timeout", JobSubmitRequest{Prompt: "hola", WorkDir: "/tmp", TimeoutSeconds: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			ValidateJobSubmit(&tt.req, v)
+			if v.HasErrors() != tt.wantErr {
+				t.Errorf("ValidateJobSubmit() hasErrors = %v, want %v, errors: %v",
+					v.HasErrors(), tt.wantErr, v.Errors())
+			}
+		})
+	}
+}
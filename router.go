@@ -8,6 +8,7 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 
 	"claude-monitor/handlers"
+	"claude-monitor/pkg/auth"
 	"claude-monitor/pkg/metrics"
 	"claude-monitor/services"
 )
@@ -20,6 +21,20 @@ type Router struct {
 	sessions     *handlers.SessionsHandler
 	terminals    *handlers.TerminalsHandler
 	analytics    *handlers.AnalyticsHandler
+	permissions  *handlers.PermissionsHandler
+	hooks        *handlers.HooksHandler
+	detection    *handlers.DetectionRulesHandler
+	events       *handlers.EventsHandler
+	notify       *handlers.NotificationsHandler
+	webhooks     *handlers.WebhooksHandler
+	jobs         *handlers.JobsHandler
+	schedules    *handlers.SchedulesHandler
+	templates    *handlers.TemplatesHandler
+	secrets      *handlers.SecretsHandler
+	files        *handlers.FilesHandler
+	users        *handlers.UsersHandler
+	tokens       *handlers.TokensHandler
+	tickets      *handlers.TicketsHandler
 }
 
 // NewRouter crea un nuevo router con todos los handlers
@@ -27,16 +42,46 @@ func NewRouter(
 	claude *services.ClaudeService,
 	terminals *services.TerminalService,
 	analytics *services.AnalyticsService,
+	permissions *services.PermissionService,
+	audit *services.AuditLog,
+	detection *services.DetectionRulesService,
+	events *services.EventBus,
+	watchdog *services.Watchdog,
+	notifier *services.NotifierService,
+	webhooks *services.WebhookService,
+	jobs *services.JobService,
+	scheduler *services.Scheduler,
+	templates *services.TemplateService,
+	secrets *services.SecretStore,
+	files *services.FileService,
+	git *services.GitService,
+	users *services.UserStore,
+	tokens *services.APITokenStore,
+	tickets *auth.TicketStore,
 	hostName, version, claudeDir string,
-	allowedPathPrefixes []string,
+	allowedPathPrefixes, allowedOrigins []string,
 ) *Router {
 	return &Router{
 		chi:          chi.NewRouter(),
 		host:         handlers.NewHostHandler(hostName, version, claudeDir, terminals, claude),
-		sessionRoots: handlers.NewSessionRootsHandler(claude, analytics),
-		sessions:     handlers.NewSessionsHandler(claude, terminals, analytics),
-		terminals:    handlers.NewTerminalsHandler(terminals, allowedPathPrefixes),
+		sessionRoots: handlers.NewSessionRootsHandler(claude, analytics, git, audit),
+		sessions:     handlers.NewSessionsHandler(claude, terminals, analytics, git, audit),
+		terminals:    handlers.NewTerminalsHandler(terminals, templates, audit, allowedPathPrefixes, allowedOrigins),
 		analytics:    handlers.NewAnalyticsHandler(analytics),
+		permissions:  handlers.NewPermissionsHandler(permissions, audit),
+		hooks:        handlers.NewHooksHandler(terminals, permissions),
+		detection:    handlers.NewDetectionRulesHandler(detection),
+		events:       handlers.NewEventsHandler(events, watchdog, allowedOrigins),
+		notify:       handlers.NewNotificationsHandler(notifier),
+		webhooks:     handlers.NewWebhooksHandler(webhooks),
+		jobs:         handlers.NewJobsHandler(jobs),
+		schedules:    handlers.NewSchedulesHandler(scheduler),
+		templates:    handlers.NewTemplatesHandler(templates),
+		secrets:      handlers.NewSecretsHandler(secrets),
+		files:        handlers.NewFilesHandler(files),
+		users:        handlers.NewUsersHandler(users),
+		tokens:       handlers.NewTokensHandler(tokens, audit),
+		tickets:      handlers.NewTicketsHandler(tickets, terminals),
 	}
 }
 
@@ -63,35 +108,98 @@ func (r *Router) SetupRoutes() {
 		))
 	})
 
+	// Permisos por ruta (el rol o los scopes de la identidad autenticada)
+	var (
+		terminalsRead  = auth.Require(auth.PermTerminalsRead)
+		terminalsWrite = auth.Require(auth.PermTerminalsWrite)
+		sessionsRead   = auth.Require(auth.PermSessionsRead)
+		sessionsWrite  = auth.Require(auth.PermSessionsWrite)
+		sessionsDelete = auth.Require(auth.PermSessionsDelete)
+		analyticsRead  = auth.Require(auth.PermAnalyticsRead)
+		filesRead      = auth.Require(auth.PermFilesRead)
+		filesWrite     = auth.Require(auth.PermFilesWrite)
+		jobsRead       = auth.Require(auth.PermJobsRead)
+		jobsWrite      = auth.Require(auth.PermJobsWrite)
+		settingsRead   = auth.Require(auth.PermSettingsRead)
+		settingsWrite  = auth.Require(auth.PermSettingsWrite)
+		usersAdmin     = auth.Require(auth.PermUsersAdmin)
+	)
+
 	// Rutas de API (con middlewares)
 	r.chi.Route("/api", func(api chi.Router) {
 		// Host info
 		api.Get("/host", r.host.Get)
 
+		// Identidad de la petición y usuarios
+		api.Get("/me", r.users.Me)
+		api.Route("/users", func(users chi.Router) {
+			users.Use(usersAdmin)
+			users.Get("/", r.users.List)
+			users.Post("/", r.users.Create)
+			users.Get("/{username}", r.users.Get)
+			users.Put("/{username}", r.users.Update)
+			users.Delete("/{username}", r.users.Delete)
+		})
+
+		// Tokens de API con scopes
+		api.Route("/tokens", func(tokens chi.Router) {
+			tokens.Use(usersAdmin)
+			tokens.Get("/", r.tokens.List)
+			tokens.Post("/", r.tokens.Create)
+			tokens.Get("/{tokenID}", r.tokens.Get)
+			tokens.Put("/{tokenID}", r.tokens.Update)
+			tokens.Delete("/{tokenID}", r.tokens.Delete)
+		})
+
 		// Session Roots (directorios donde se han ejecutado sesiones de Claude)
 		api.Route("/session-roots", func(roots chi.Router) {
-			roots.Get("/", r.sessionRoots.List)
+			roots.With(sessionsRead).Get("/", r.sessionRoots.List)
 
 			// Rutas con path del session-root
 			roots.Route("/{rootPath}", func(root chi.Router) {
-				root.Get("/", r.sessionRoots.Get)
-				root.Delete("/", r.sessionRoots.Delete)
-				root.Get("/activity", r.sessionRoots.GetActivity)
+				root.Use(handlers.RequireNameParams("rootPath"))
+				root.With(sessionsRead).Get("/", r.sessionRoots.Get)
+				root.With(sessionsDelete).Delete("/", r.sessionRoots.Delete)
+				root.With(sessionsRead).Get("/activity", r.sessionRoots.GetActivity)
+				root.With(sessionsWrite).Post("/move", r.sessionRoots.Move)
+				root.With(sessionsRead).Get("/git", r.sessionRoots.Git)
+
+				// Política de permisos del session-root
+				root.With(settingsRead).Get("/permission-policy", r.permissions.GetSessionRootPolicy)
+				root.With(settingsWrite).Put("/permission-policy", r.permissions.SetSessionRootPolicy)
+				root.With(settingsWrite).Delete("/permission-policy", r.permissions.DeleteSessionRootPolicy)
+
+				// Reglas de notificación del session-root
+				root.With(settingsRead).Get("/notification-rules", r.notify.GetSessionRootRules)
+				root.With(settingsWrite).Put("/notification-rules", r.notify.SetSessionRootRules)
+				root.With(settingsWrite).Delete("/notification-rules", r.notify.DeleteSessionRootRules)
+
+				// Template por defecto del session-root
+				root.With(terminalsRead).Get("/default-template", r.templates.GetSessionRootDefault)
+				root.With(terminalsWrite).Put("/default-template", r.templates.SetSessionRootDefault)
+				root.With(terminalsWrite).Delete("/default-template", r.templates.DeleteSessionRootDefault)
 
 				// Sessions dentro del session-root
 				root.Route("/sessions", func(sessions chi.Router) {
-					sessions.Get("/", r.sessions.List)
-					sessions.Post("/delete", r.sessions.DeleteMultiple)
-					sessions.Post("/clean", r.sessions.CleanEmpty)
-					sessions.Post("/import", r.sessions.Import)
+					sessions.With(sessionsRead).Get("/", r.sessions.List)
+					sessions.With(sessionsDelete).Post("/delete", r.sessions.DeleteMultiple)
+					sessions.With(sessionsDelete).Post("/clean", r.sessions.CleanEmpty)
+					sessions.With(sessionsWrite).Post("/import", r.sessions.Import)
 
 					sessions.Route("/{sessionID}", func(session chi.Router) {
-						session.Get("/", r.sessions.Get)
-						session.Delete("/", r.sessions.Delete)
-						session.Put("/rename", r.sessions.Rename)
-						session.Post("/move", r.sessions.Move)
-						session.Get("/messages", r.sessions.GetMessages)
-						session.Get("/messages/realtime", r.sessions.GetRealTimeMessages)
+						session.Use(handlers.RequireNameParams("sessionID"))
+						session.With(sessionsRead).Get("/", r.sessions.Get)
+						session.With(sessionsDelete).Delete("/", r.sessions.Delete)
+						session.With(sessionsWrite).Put("/rename", r.sessions.Rename)
+						session.With(sessionsWrite).Post("/move", r.sessions.Move)
+						session.With(sessionsRead).Get("/messages", r.sessions.GetMessages)
+						session.With(sessionsRead).Get("/messages/realtime", r.sessions.GetRealTimeMessages)
+						session.With(sessionsRead).Get("/commits", r.sessions.Commits)
+
+						// Subagentes (transcripts agent-*.jsonl lanzados con Task)
+						session.With(sessionsRead).Get("/agents", r.sessions.ListAgents)
+						session.With(sessionsRead, handlers.RequireNameParams("agentID")).Get("/agents/{agentID}", r.sessions.GetAgent)
+						session.With(sessionsRead, handlers.RequireNameParams("agentID")).Get("/agents/{agentID}/messages", r.sessions.GetAgentMessages)
 					})
 				})
 			})
@@ -99,48 +207,170 @@ func (r *Router) SetupRoutes() {
 
 		// Terminals
 		api.Route("/terminals", func(terms chi.Router) {
-			terms.Get("/", r.terminals.List)
-			terms.Post("/", r.terminals.Create)
+			terms.With(terminalsRead).Get("/", r.terminals.List)
+			terms.With(terminalsWrite).Post("/", r.terminals.Create)
 
 			terms.Route("/{terminalID}", func(term chi.Router) {
-				term.Get("/", r.terminals.Get)
-				term.Delete("/", r.terminals.Delete)
+				term.With(terminalsRead).Get("/", r.terminals.Get)
+				term.With(terminalsWrite).Delete("/", r.terminals.Delete)
 
-				// WebSocket (sin middleware JSON)
-				term.Get("/ws", r.terminals.WebSocket)
+				// WebSocket (sin middleware JSON; se autentica con el ticket de ws-ticket)
+				term.With(terminalsRead).Post("/ws-ticket", r.tickets.Terminal)
+				term.With(terminalsRead).Get("/ws", r.terminals.WebSocket)
 
 				// Operaciones comunes
-				term.Post("/kill", r.terminals.Kill)
-				term.Post("/resume", r.terminals.Resume)
-				term.Post("/resize", r.terminals.Resize)
+				term.With(terminalsWrite).Post("/kill", r.terminals.Kill)
+				term.With(terminalsWrite).Post("/resume", r.terminals.Resume)
+				term.With(terminalsWrite).Post("/resize", r.terminals.Resize)
+				term.With(terminalsWrite).Post("/input", r.terminals.Input)
+
+				// Árbol de procesos
+				term.With(terminalsRead).Get("/processes", r.terminals.Processes)
+				term.With(terminalsWrite).Post("/processes/{pid}/signal", r.terminals.SignalProcess)
+
+				// Repositorio git del work_dir
+				term.With(terminalsRead).Get("/git", r.terminals.Git)
+
+				// Worktree dedicado
+				term.With(terminalsRead).Get("/worktree", r.terminals.Worktree)
+				term.With(terminalsWrite).Delete("/worktree", r.terminals.RemoveWorktree)
+				term.With(terminalsWrite).Post("/worktree/merge", r.terminals.MergeWorktree)
+
+				// Cambios de archivos en el work_dir
+				term.With(terminalsRead).Get("/file-changes", r.terminals.FileChanges)
 
 				// Info comunes
-				term.Get("/snapshot", r.terminals.Snapshot)
+				term.With(terminalsRead).Get("/snapshot", r.terminals.Snapshot)
 
 				// Operaciones solo para TerminalClaude
-				term.Post("/pause", r.terminals.Pause)
-				term.Post("/unpause", r.terminals.ResumeFromPause)
-				term.Post("/archive", r.terminals.Archive)
+				term.With(terminalsWrite).Post("/pause", r.terminals.Pause)
+				term.With(terminalsWrite).Post("/unpause", r.terminals.ResumeFromPause)
+				term.With(terminalsWrite).Post("/archive", r.terminals.Archive)
 
 				// Info solo para TerminalClaude
-				term.Get("/state", r.terminals.State)
-				term.Get("/messages", r.terminals.Messages)
-				term.Get("/claude-state", r.terminals.ClaudeState)
-				term.Get("/checkpoints", r.terminals.ClaudeCheckpoints)
-				term.Get("/events", r.terminals.ClaudeEvents)
+				term.With(terminalsRead).Get("/state", r.terminals.State)
+				term.With(terminalsRead).Get("/messages", r.terminals.Messages)
+				term.With(terminalsRead).Get("/claude-state", r.terminals.ClaudeState)
+				term.With(terminalsRead).Get("/checkpoints", r.terminals.ClaudeCheckpoints)
+				term.With(terminalsRead).Get("/events", r.terminals.ClaudeEvents)
+
+				// Permisos (solo TerminalClaude)
+				term.With(terminalsWrite).Post("/permission", r.permissions.Answer)
+				term.With(settingsRead).Get("/permission-policy", r.permissions.GetTerminalPolicy)
+				term.With(settingsWrite).Put("/permission-policy", r.permissions.SetTerminalPolicy)
+				term.With(settingsWrite).Delete("/permission-policy", r.permissions.DeleteTerminalPolicy)
+
+				// Reglas de notificación
+				term.With(settingsRead).Get("/notification-rules", r.notify.GetTerminalRules)
+				term.With(settingsWrite).Put("/notification-rules", r.notify.SetTerminalRules)
+				term.With(settingsWrite).Delete("/notification-rules", r.notify.DeleteTerminalRules)
+			})
+		})
+
+		// Worktrees dedicados de terminales
+		api.With(terminalsRead).Get("/worktrees", r.terminals.ListWorktrees)
+
+		// Auditoría
+		api.With(settingsRead).Get("/audit", r.permissions.Audit)
+
+		// Hooks de Claude Code (auth por token de terminal)
+		api.Post("/hooks/{terminalID}", r.hooks.Receive)
+
+		// Reglas de detección de estado de Claude
+		api.Route("/detection-rules", func(det chi.Router) {
+			det.With(settingsRead).Get("/", r.detection.Get)
+			det.With(settingsWrite).Put("/", r.detection.Update)
+			det.With(settingsWrite).Post("/reload", r.detection.Reload)
+			det.With(settingsRead).Post("/validate", r.detection.Validate)
+		})
+
+		// Eventos del servidor y watchdog
+		api.With(terminalsRead).Post("/events/ws-ticket", r.tickets.Events)
+		api.With(terminalsRead).Get("/events/ws", r.events.WebSocket)
+		api.With(terminalsRead).Get("/watchdog", r.events.Watchdog)
+
+		// Notificaciones salientes
+		api.Route("/notifications", func(notif chi.Router) {
+			notif.With(settingsRead).Get("/", r.notify.Get)
+			notif.With(settingsWrite).Put("/channels", r.notify.SetChannels)
+			notif.With(settingsWrite).Post("/channels/{channel}/test", r.notify.TestChannel)
+			notif.With(settingsWrite).Put("/rules", r.notify.SetDefaultRules)
+		})
+
+		// Suscripciones de webhooks
+		api.Route("/webhooks", func(hooks chi.Router) {
+			hooks.With(settingsRead).Get("/", r.webhooks.List)
+			hooks.With(settingsWrite).Post("/", r.webhooks.Create)
+
+			hooks.Route("/{webhookID}", func(hook chi.Router) {
+				hook.With(settingsRead).Get("/", r.webhooks.Get)
+				hook.With(settingsWrite).Delete("/", r.webhooks.Delete)
+				hook.With(settingsRead).Get("/deliveries", r.webhooks.Deliveries)
+				hook.With(settingsWrite).Post("/deliveries/{deliveryID}/redeliver", r.webhooks.Redeliver)
+			})
+		})
+
+		// Jobs headless (claude -p)
+		api.Route("/jobs", func(jobs chi.Router) {
+			jobs.With(jobsRead).Get("/", r.jobs.List)
+			jobs.With(jobsWrite).Post("/", r.jobs.Submit)
+
+			jobs.Route("/{jobID}", func(job chi.Router) {
+				job.With(jobsRead).Get("/", r.jobs.Get)
+				job.With(jobsRead).Get("/output", r.jobs.Output)
+				job.With(jobsWrite).Post("/cancel", r.jobs.Cancel)
+			})
+		})
+
+		// Templates de terminal
+		api.Route("/templates", func(tmpls chi.Router) {
+			tmpls.With(terminalsRead).Get("/", r.templates.List)
+			tmpls.With(terminalsWrite).Post("/", r.templates.Create)
+
+			tmpls.Route("/{templateID}", func(tmpl chi.Router) {
+				tmpl.With(terminalsRead).Get("/", r.templates.Get)
+				tmpl.With(terminalsWrite).Put("/", r.templates.Update)
+				tmpl.With(terminalsWrite).Delete("/", r.templates.Delete)
+			})
+		})
+
+		// Secretos cifrados (solo nombres; los valores nunca se retornan)
+		api.Route("/secrets", func(secrets chi.Router) {
+			secrets.With(settingsRead).Get("/", r.secrets.List)
+			secrets.With(settingsWrite).Put("/{name}", r.secrets.Set)
+			secrets.With(settingsWrite).Delete("/{name}", r.secrets.Delete)
+		})
+
+		// Prompts programados (cron)
+		api.Route("/schedules", func(scheds chi.Router) {
+			scheds.With(jobsRead).Get("/", r.schedules.List)
+			scheds.With(jobsWrite).Post("/", r.schedules.Create)
+
+			scheds.Route("/{scheduleID}", func(sched chi.Router) {
+				sched.With(jobsRead).Get("/", r.schedules.Get)
+				sched.With(jobsWrite).Put("/", r.schedules.Update)
+				sched.With(jobsWrite).Delete("/", r.schedules.Delete)
+				sched.With(jobsRead).Get("/runs", r.schedules.Runs)
+				sched.With(jobsWrite).Post("/run", r.schedules.RunNow)
 			})
 		})
 
 		// Analytics
 		api.Route("/analytics", func(anal chi.Router) {
-			anal.Get("/global", r.analytics.GetGlobal)
-			anal.Get("/session-roots/{rootPath}", r.analytics.GetSessionRoot)
-			anal.Post("/invalidate", r.analytics.Invalidate)
-			anal.Get("/cache", r.analytics.GetCacheStatus)
+			anal.With(analyticsRead).Get("/global", r.analytics.GetGlobal)
+			anal.With(analyticsRead, handlers.RequireNameParams("rootPath")).Get("/session-roots/{rootPath}", r.analytics.GetSessionRoot)
+			anal.With(settingsWrite).Post("/invalidate", r.analytics.Invalidate)
+			anal.With(analyticsRead).Get("/cache", r.analytics.GetCacheStatus)
 		})
 
 		// Filesystem
-		api.Get("/filesystem/dir", r.terminals.ListDir)
+		api.With(filesRead).Get("/filesystem/dir", r.terminals.ListDir)
+		api.With(filesRead).Get("/filesystem/file", r.files.Read)
+		api.With(filesRead).Get("/filesystem/raw", r.files.Raw)
+		api.With(filesRead).Get("/filesystem/preview", r.files.Preview)
+		api.With(filesRead).Get("/filesystem/diff", r.files.Diff)
+		api.With(filesRead).Get("/filesystem/download", r.files.Download)
+		api.With(filesWrite).Post("/filesystem/upload", r.files.Upload)
 	})
 }
 
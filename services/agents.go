@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Nombres de la herramienta que lanza subagentes en Claude Code
+var taskToolNames = map[string]bool{"Task": true, "Agent": true}
+
+// TokenUsage tokens consumidos según message.usage de las respuestas del asistente
+type TokenUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// Add suma otro consumo
+func (u *TokenUsage) Add(o TokenUsage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationInputTokens += o.CacheCreationInputTokens
+	u.CacheReadInputTokens += o.CacheReadInputTokens
+}
+
+// Total suma de todos los tipos de tokens
+func (u TokenUsage) Total() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// SessionAgent transcript de un subagente lanzado desde una sesión con la herramienta Task
+type SessionAgent struct {
+	ID                string     `json:"id"`
+	SessionID         string     `json:"session_id"` // sesión padre
+	ProjectPath       string     `json:"project_path"`
+	FilePath          string     `json:"file_path"`
+	ToolUseID         string     `json:"tool_use_id,omitempty"` // llamada a Task en la sesión padre
+	Description       string     `json:"description,omitempty"`
+	SubagentType      string     `json:"subagent_type,omitempty"`
+	FirstMessage      string     `json:"first_message"`
+	MessageCount      int        `json:"message_count"`
+	UserMessages      int        `json:"user_messages"`
+	AssistantMessages int        `json:"assistant_messages"`
+	Tokens            TokenUsage `json:"tokens"`
+	SizeBytes         int64      `json:"size_bytes"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ModifiedAt        time.Time  `json:"modified_at"`
+}
+
+// taskCall tool_use de Task en el transcript de la sesión padre
+type taskCall struct {
+	ToolUseID    string
+	Description  string
+	SubagentType string
+	Prompt       string
+}
+
+// parseTaskCalls extrae las llamadas a Task del contenido de un mensaje del asistente
+func parseTaskCalls(content json.RawMessage) []taskCall {
+	var blocks []struct {
+		Type  string `json:"type"`
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Input struct {
+			Description  string `json:"description"`
+			SubagentType string `json:"subagent_type"`
+			Prompt       string `json:"prompt"`
+		} `json:"input"`
+	}
+	if json.Unmarshal(content, &blocks) != nil {
+		return nil
+	}
+
+	var calls []taskCall
+	for _, b := range blocks {
+		if b.Type != "tool_use" || !taskToolNames[b.Name] || b.ID == "" {
+			continue
+		}
+		calls = append(calls, taskCall{
+			ToolUseID:    b.ID,
+			Description:  b.Input.Description,
+			SubagentType: b.Input.SubagentType,
+			Prompt:       b.Input.Prompt,
+		})
+	}
+	return calls
+}
+
+// parseTaskResult retorna el agentId y el tool_use_id de un resultado de Task
+func parseTaskResult(line transcriptLine) (agentID, toolUseID string) {
+	var result struct {
+		AgentID string `json:"agentId"`
+	}
+	if json.Unmarshal(line.ToolUseResult, &result) != nil || result.AgentID == "" {
+		return "", ""
+	}
+
+	var blocks []struct {
+		Type      string `json:"type"`
+		ToolUseID string `json:"tool_use_id"`
+	}
+	if json.Unmarshal(line.Message.Content, &blocks) != nil {
+		return "", ""
+	}
+	for _, b := range blocks {
+		if b.Type == "tool_result" && b.ToolUseID != "" {
+			return result.AgentID, b.ToolUseID
+		}
+	}
+	return "", ""
+}
+
+// isAgentFile verifica si un nombre de archivo es un transcript de subagente
+func isAgentFile(name string) bool {
+	return strings.HasPrefix(name, "agent-") && strings.HasSuffix(name, ".jsonl")
+}
+
+// extractAgentID extrae el ID de un agent-<id>.jsonl
+func extractAgentID(filename string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(filename, "agent-"), ".jsonl")
+}
+
+// readTranscriptSessionID retorna el sessionId de las primeras líneas de un transcript
+func readTranscriptSessionID(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	for i := 0; i < 10 && scanner.Scan(); i++ {
+		var line transcriptLine
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && line.SessionID != "" {
+			return line.SessionID
+		}
+	}
+	return ""
+}
+
+// agentFilesBySession agrupa los transcripts de subagentes de un proyecto por
+// sesión padre. Claude Code los guarda en <sesion>/subagents/agent-<id>.jsonl;
+// versiones anteriores los dejaban junto a la sesión con el sessionId del padre.
+func (s *ClaudeService) agentFilesBySession(projectDir string) map[string][]string {
+	index := make(map[string][]string)
+
+	entries, err := os.ReadDir(projectDir)
+	if err != nil {
+		return index
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if !isValidUUIDSession(entry.Name() + ".jsonl") {
+				continue
+			}
+			matches, _ := filepath.Glob(filepath.Join(projectDir, entry.Name(), "subagents", "agent-*.jsonl"))
+			index[entry.Name()] = append(index[entry.Name()], matches...)
+			continue
+		}
+		if !isAgentFile(entry.Name()) {
+			continue
+		}
+		filePath := filepath.Join(projectDir, entry.Name())
+		if sessionID := readTranscriptSessionID(filePath); sessionID != "" {
+			index[sessionID] = append(index[sessionID], filePath)
+		}
+	}
+
+	return index
+}
+
+// loadSessionAgents parsea los transcripts de subagentes y los enlaza con las
+// llamadas a Task de la sesión padre: primero por el agentId del resultado de
+// Task y, si no está, por el prompt enviado al subagente.
+func loadSessionAgents(projectPath, sessionID string, files []string, parent transcriptStats) []SessionAgent {
+	agents := make([]SessionAgent, 0, len(files))
+	prompts := make([]string, 0, len(files))
+	for _, filePath := range files {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			continue
+		}
+		stats := parseTranscript(filePath)
+		agents = append(agents, SessionAgent{
+			ID:                extractAgentID(filepath.Base(filePath)),
+			SessionID:         sessionID,
+			ProjectPath:       projectPath,
+			FilePath:          filePath,
+			FirstMessage:      stats.firstMessage,
+			MessageCount:      stats.userCount + stats.assistantCount,
+			UserMessages:      stats.userCount,
+			AssistantMessages: stats.assistantCount,
+			Tokens:            stats.tokens,
+			SizeBytes:         info.Size(),
+			CreatedAt:         stats.createdAt,
+			ModifiedAt:        info.ModTime(),
+		})
+		prompts = append(prompts, stats.firstPrompt)
+	}
+
+	// Ordenar por creación para que el enlace por prompt siga el orden de las llamadas
+	order := make([]int, len(agents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return agents[order[i]].CreatedAt.Before(agents[order[j]].CreatedAt)
+	})
+
+	tasks := make(map[string]taskCall, len(parent.tasks))
+	for _, t := range parent.tasks {
+		tasks[t.ToolUseID] = t
+	}
+	used := make(map[string]bool)
+	link := func(a *SessionAgent, t taskCall) {
+		a.ToolUseID = t.ToolUseID
+		a.Description = t.Description
+		a.SubagentType = t.SubagentType
+		used[t.ToolUseID] = true
+	}
+
+	for _, i := range order {
+		if t, ok := tasks[parent.agentLinks[agents[i].ID]]; ok {
+			link(&agents[i], t)
+		}
+	}
+	for _, i := range order {
+		if agents[i].ToolUseID != "" || prompts[i] == "" {
+			continue
+		}
+		for _, t := range parent.tasks {
+			if !used[t.ToolUseID] && t.Prompt == prompts[i] {
+				link(&agents[i], t)
+				break
+			}
+		}
+	}
+
+	sorted := make([]SessionAgent, 0, len(agents))
+	for _, i := range order {
+		sorted = append(sorted, agents[i])
+	}
+	return sorted
+}
+
+// addAgents incluye los mensajes y tokens de los subagentes en los totales de la sesión
+func (sess *ClaudeSession) addAgents(agents []SessionAgent) {
+	sess.AgentCount = len(agents)
+	for _, a := range agents {
+		sess.AgentMessages += a.MessageCount
+		sess.MessageCount += a.MessageCount
+		sess.Tokens.Add(a.Tokens)
+	}
+}
+
+// ListSessionAgents lista los subagentes de una sesión en orden de creación
+func (s *ClaudeService) ListSessionAgents(projectPath, sessionID string) ([]SessionAgent, error) {
+	projectDir := filepath.Join(s.claudeDir, projectPath)
+	filePath := filepath.Join(projectDir, sessionID+".jsonl")
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, err
+	}
+
+	files := s.agentFilesBySession(projectDir)[sessionID]
+	return loadSessionAgents(projectPath, sessionID, files, parseTranscript(filePath)), nil
+}
+
+// GetSessionAgent obtiene un subagente de una sesión
+func (s *ClaudeService) GetSessionAgent(projectPath, sessionID, agentID string) (*SessionAgent, error) {
+	agents, err := s.ListSessionAgents(projectPath, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range agents {
+		if agents[i].ID == agentID {
+			return &agents[i], nil
+		}
+	}
+	return nil, fmt.Errorf("subagente no encontrado: %s", agentID)
+}
+
+// GetSessionAgentMessages obtiene los mensajes del transcript de un subagente
+func (s *ClaudeService) GetSessionAgentMessages(projectPath, sessionID, agentID string) ([]SessionMessage, error) {
+	agent, err := s.GetSessionAgent(projectPath, sessionID, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return readTranscriptMessages(agent.FilePath)
+}
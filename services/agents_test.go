@@ -0,0 +1,129 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testSessionID = "11111111-2222-3333-4444-555555555555"
+	testProject   = "-home-user-proj"
+)
+
+func writeTranscript(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newAgentsFixture crea una sesión con dos llamadas a Task: una enlazada por
+// agentId (formato subagents/) y otra por prompt (formato anterior)
+func newAgentsFixture(t *testing.T) *ClaudeService {
+	dir := t.TempDir()
+	project := filepath.Join(dir, testProject)
+
+	writeTranscript(t, filepath.Join(project, testSessionID+".jsonl"),
+		`{"type":"user","sessionId":"`+testSessionID+`","timestamp":"2025-01-01T10:00:00Z","message":{"role":"user","content":"revisa el repo"}}`,
+		// Misma respuesta en dos líneas: el usage se cuenta una vez
+		`{"type":"assistant","sessionId":"`+testSessionID+`","message":{"id":"msg_1","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"assistant","sessionId":"`+testSessionID+`","message":{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_A","name":"Task","input":{"description":"Buscar tests","subagent_type":"Explore","prompt":"busca tests"}},{"type":"tool_use","id":"toolu_B","name":"Task","input":{"description":"Leer docs","subagent_type":"general-purpose","prompt":"lee la documentacion"}}],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"user","sessionId":"`+testSessionID+`","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_A","content":"hecho"}]},"toolUseResult":{"agentId":"a1","status":"completed"}}`,
+		`not json`,
+	)
+
+	writeTranscript(t, filepath.Join(project, testSessionID, "subagents", "agent-a1.jsonl"),
+		`{"type":"user","sessionId":"`+testSessionID+`","agentId":"a1","isSidechain":true,"timestamp":"2025-01-01T10:00:01Z","message":{"role":"user","content":"busca tests"}}`,
+		`{"type":"assistant","sessionId":"`+testSessionID+`","agentId":"a1","isSidechain":true,"message":{"id":"msg_a1","content":[{"type":"text","text":"encontrados"}],"usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":50}}}`,
+	)
+	writeTranscript(t, filepath.Join(project, "agent-b2.jsonl"),
+		`{"type":"user","sessionId":"`+testSessionID+`","agentId":"b2","isSidechain":true,"timestamp":"2025-01-01T10:00:02Z","message":{"role":"user","content":"lee la documentacion"}}`,
+		`{"type":"assistant","sessionId":"`+testSessionID+`","agentId":"b2","isSidechain":true,"message":{"id":"msg_b2","content":[{"type":"text","text":"listo"}],"usage":{"input_tokens":7,"output_tokens":3}}}`,
+	)
+	// Subagente de otra sesión: no debe aparecer
+	writeTranscript(t, filepath.Join(project, "agent-c3.jsonl"),
+		`{"type":"user","sessionId":"99999999-2222-3333-4444-555555555555","agentId":"c3","message":{"role":"user","content":"otro"}}`,
+	)
+
+	return NewClaudeService(dir)
+}
+
+func TestListSessionAgents_Linking(t *testing.T) {
+	s := newAgentsFixture(t)
+
+	agents, err := s.ListSessionAgents(testProject, testSessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("Expected 2 agents, got %d", len(agents))
+	}
+
+	a1, b2 := agents[0], agents[1]
+	if a1.ID != "a1" || a1.ToolUseID != "toolu_A" || a1.SubagentType != "Explore" || a1.Description != "Buscar tests" {
+		t.Errorf("a1 linked by agentId: %+v", a1)
+	}
+	if b2.ID != "b2" || b2.ToolUseID != "toolu_B" || b2.SubagentType != "general-purpose" {
+		t.Errorf("b2 linked by prompt: %+v", b2)
+	}
+	if a1.MessageCount != 2 || a1.Tokens.Total() != 170 || a1.SessionID != testSessionID {
+		t.Errorf("a1 counts: messages=%d tokens=%d", a1.MessageCount, a1.Tokens.Total())
+	}
+
+	messages, err := s.GetSessionAgentMessages(testProject, testSessionID, "b2")
+	if err != nil || len(messages) != 2 || messages[0].Content != "lee la documentacion" {
+		t.Errorf("Agent messages: %+v, %v", messages, err)
+	}
+	if _, err := s.GetSessionAgent(testProject, testSessionID, "c3"); err == nil {
+		t.Error("Agent from another session should not be found")
+	}
+}
+
+func TestSessionTotals_IncludeAgents(t *testing.T) {
+	s := newAgentsFixture(t)
+
+	sessions, err := s.ListSessions(testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session (agent files are not sessions), got %d", len(sessions))
+	}
+
+	sess := sessions[0]
+	if sess.UserMessages != 2 || sess.AssistantMessages != 2 {
+		t.Errorf("Own counts: user=%d assistant=%d", sess.UserMessages, sess.AssistantMessages)
+	}
+	if sess.AgentCount != 2 || sess.AgentMessages != 4 || sess.MessageCount != 8 {
+		t.Errorf("Agent totals: count=%d agent_messages=%d message_count=%d", sess.AgentCount, sess.AgentMessages, sess.MessageCount)
+	}
+	// Sesión: 10+5 (msg_1 contado una vez); subagentes: 170 + 10
+	if sess.Tokens.Total() != 195 {
+		t.Errorf("Expected 195 tokens, got %d (%+v)", sess.Tokens.Total(), sess.Tokens)
+	}
+
+	single, err := s.GetSession(testProject, testSessionID)
+	if err != nil || single.MessageCount != sess.MessageCount || single.Tokens != sess.Tokens {
+		t.Errorf("GetSession totals differ: %+v, %v", single, err)
+	}
+}
+
+func TestDeleteSession_RemovesAgents(t *testing.T) {
+	s := newAgentsFixture(t)
+	project := filepath.Join(s.GetClaudeDir(), testProject)
+
+	if err := s.DeleteSession(testProject, testSessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(project, "agent-b2.jsonl")); !os.IsNotExist(err) {
+		t.Error("Legacy agent file should be removed with its session")
+	}
+	if _, err := os.Stat(filepath.Join(project, "agent-c3.jsonl")); err != nil {
+		t.Error("Agent of another session should be kept")
+	}
+}
@@ -24,6 +24,8 @@ type GlobalAnalytics struct {
 	TotalUserMessages     int               `json:"total_user_messages"`
 	TotalAssistantMessages int              `json:"total_assistant_messages"`
 	TotalSizeBytes        int64             `json:"total_size_bytes"`
+	TotalAgents           int               `json:"total_agents"`
+	TotalTokens           TokenUsage        `json:"total_tokens"`
 	EmptySessions         int               `json:"empty_sessions"`
 	ActiveDays            int               `json:"active_days"`
 	ProjectsSummary       []ProjectSummary  `json:"projects_summary"`
@@ -41,6 +43,8 @@ type ProjectSummary struct {
 	UserMessages      int       `json:"user_messages"`
 	AssistantMessages int       `json:"assistant_messages"`
 	SizeBytes         int64     `json:"size_bytes"`
+	Agents            int        `json:"agents"`
+	Tokens            TokenUsage `json:"tokens"`
 	EmptySessions     int       `json:"empty_sessions"`
 	LastActivity      time.Time `json:"last_activity"`
 }
@@ -54,6 +58,8 @@ type ProjectAnalytics struct {
 	TotalUserMessages     int             `json:"total_user_messages"`
 	TotalAssistantMessages int            `json:"total_assistant_messages"`
 	TotalSizeBytes        int64           `json:"total_size_bytes"`
+	TotalAgents           int             `json:"total_agents"`
+	TotalTokens           TokenUsage      `json:"total_tokens"`
 	EmptySessions         int             `json:"empty_sessions"`
 	DailyActivity         []DailyActivity `json:"daily_activity"`
 	TopDays               []DailyActivity `json:"top_days"`
@@ -114,6 +120,8 @@ func (s *AnalyticsService) GetGlobal(forceRefresh bool) (*GlobalAnalytics, error
 			summary.UserMessages += sess.UserMessages
 			summary.AssistantMessages += sess.AssistantMessages
 			summary.SizeBytes += sess.SizeBytes
+			summary.Agents += sess.AgentCount
+			summary.Tokens.Add(sess.Tokens)
 			if sess.MessageCount == 0 {
 				summary.EmptySessions++
 			}
@@ -127,6 +135,8 @@ func (s *AnalyticsService) GetGlobal(forceRefresh bool) (*GlobalAnalytics, error
 		global.TotalUserMessages += summary.UserMessages
 		global.TotalAssistantMessages += summary.AssistantMessages
 		global.TotalSizeBytes += summary.SizeBytes
+		global.TotalAgents += summary.Agents
+		global.TotalTokens.Add(summary.Tokens)
 		global.EmptySessions += summary.EmptySessions
 		global.ProjectsSummary = append(global.ProjectsSummary, summary)
 
@@ -184,6 +194,8 @@ func (s *AnalyticsService) GetProject(projectPath string, forceRefresh bool) (*P
 		analytics.TotalUserMessages += sess.UserMessages
 		analytics.TotalAssistantMessages += sess.AssistantMessages
 		analytics.TotalSizeBytes += sess.SizeBytes
+		analytics.TotalAgents += sess.AgentCount
+		analytics.TotalTokens.Add(sess.Tokens)
 		if sess.MessageCount == 0 {
 			analytics.EmptySessions++
 		}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/pkg/logger"
+)
+
+// ErrAPITokenNotFound token de API inexistente
+var ErrAPITokenNotFound = errors.New("token no encontrado")
+
+// ErrLegacyAPITokenRevoked el secreto de CLAUDE_MONITOR_API_TOKEN ya se importó y se revocó
+var ErrLegacyAPITokenRevoked = errors.New("token de entorno revocado")
+
+// apiTokenPrefix prefijo de los secretos (facilita detectarlos en logs y repositorios)
+const apiTokenPrefix = "cmt_"
+
+// Cada cuánto se persiste como mucho last_used_at de un token (se actualiza en
+// memoria en cada petición)
+const apiTokenLastUsedPersist = time.Minute
+
+// LegacyAPITokenName nombre del token importado de CLAUDE_MONITOR_API_TOKEN
+const LegacyAPITokenName = "env"
+
+// APIToken token de API con scopes (sin el secreto)
+type APIToken struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Scopes       []auth.Permission `json:"scopes"`
+	PathPrefixes []string          `json:"path_prefixes,omitempty"` // rutas de la API permitidas (vacío = todas)
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty"`
+	LastUsedAt   *time.Time        `json:"last_used_at,omitempty"`
+	CreatedBy    string            `json:"created_by,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Expired indica si el token ya caducó
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// APITokenRequest datos de un token al crearlo o reemplazarlo
+type APITokenRequest struct {
+	Name         string            `json:"name"`
+	Scopes       []auth.Permission `json:"scopes"`
+	PathPrefixes []string          `json:"path_prefixes,omitempty"`
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty"`
+}
+
+// NewAPIToken token recién creado: el secreto solo se retorna esta vez
+type NewAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// storedAPIToken token con el hash de su secreto
+type storedAPIToken struct {
+	APIToken
+	SecretHash string `json:"secret_hash"`
+}
+
+// APITokenStore tokens de API revocables, persistidos en api_tokens.json
+type APITokenStore struct {
+	file        string
+	revokedFile string
+
+	mu        sync.RWMutex
+	tokens    map[string]*storedAPIToken
+	persisted map[string]time.Time // último last_used_at guardado por token
+	revoked   map[string]bool      // hashes de tokens "env" revocados (no se reimportan)
+}
+
+// NewAPITokenStore abre <dataDir>/api_tokens.json
+func NewAPITokenStore(dataDir string) *APITokenStore {
+	ts := &APITokenStore{
+		file:        filepath.Join(dataDir, "api_tokens.json"),
+		revokedFile: filepath.Join(dataDir, "api_tokens_revoked.json"),
+		tokens:      make(map[string]*storedAPIToken),
+		persisted:   make(map[string]time.Time),
+		revoked:     make(map[string]bool),
+	}
+	ts.load()
+	return ts
+}
+
+// load carga los tokens desde disco
+func (ts *APITokenStore) load() {
+	data, err := os.ReadFile(ts.file)
+	if err != nil {
+		return
+	}
+	var tokens []*storedAPIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		logger.Error("Error cargando tokens de API", "error", err)
+		return
+	}
+	for _, t := range tokens {
+		ts.tokens[t.ID] = t
+		if t.LastUsedAt != nil {
+			ts.persisted[t.ID] = *t.LastUsedAt
+		}
+	}
+
+	if data, err := os.ReadFile(ts.revokedFile); err == nil {
+		var hashes []string
+		if err := json.Unmarshal(data, &hashes); err != nil {
+			logger.Error("Error cargando tokens revocados", "error", err)
+		}
+		for _, hash := range hashes {
+			ts.revoked[hash] = true
+		}
+	}
+}
+
+// persistRevoked guarda los hashes de tokens "env" revocados
+func (ts *APITokenStore) persistRevoked() error {
+	ts.mu.RLock()
+	hashes := make([]string, 0, len(ts.revoked))
+	for hash := range ts.revoked {
+		hashes = append(hashes, hash)
+	}
+	ts.mu.RUnlock()
+	sort.Strings(hashes)
+
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(ts.revokedFile, data, 0600)
+}
+
+// persist guarda los tokens de forma atómica
+func (ts *APITokenStore) persist() error {
+	ts.mu.Lock()
+	tokens := make([]*storedAPIToken, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		tokens = append(tokens, t)
+		if t.LastUsedAt != nil {
+			ts.persisted[t.ID] = *t.LastUsedAt
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	ts.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(ts.file, data, 0600)
+}
+
+// hashAPIToken hash de un secreto. Los secretos son aleatorios de 256 bits, así
+// que basta un SHA-256 (no hace falta un KDF lento como con las contraseñas)
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPITokenSecret genera un secreto nuevo
+func generateAPITokenSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validateAPITokenRequest valida y normaliza los datos de un token
+func validateAPITokenRequest(req *APITokenRequest) error {
+	if !secretNameRegex.MatchString(req.Name) {
+		return fmt.Errorf("nombre de token invalido: %s", req.Name)
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("scopes requerido")
+	}
+	seen := make(map[auth.Permission]bool)
+	scopes := make([]auth.Permission, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !auth.ValidPermission(scope) {
+			return fmt.Errorf("scope invalido: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	req.Scopes = scopes
+
+	prefixes := make([]string, 0, len(req.PathPrefixes))
+	for _, prefix := range req.PathPrefixes {
+		if prefix != "/api" && !strings.HasPrefix(prefix, "/api/") {
+			return fmt.Errorf("path_prefix invalido (debe empezar por /api/): %s", prefix)
+		}
+		prefixes = append(prefixes, path.Clean(prefix))
+	}
+	req.PathPrefixes = prefixes
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at invalido (ya paso)")
+	}
+	return nil
+}
+
+// List retorna los tokens por fecha de creación
+func (ts *APITokenStore) List() []APIToken {
+	ts.mu.RLock()
+	list := make([]APIToken, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		list = append(list, t.APIToken)
+	}
+	ts.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Count número de tokens
+func (ts *APITokenStore) Count() int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return len(ts.tokens)
+}
+
+// Get retorna un token
+func (ts *APITokenStore) Get(id string) (*APIToken, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	t, ok := ts.tokens[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAPITokenNotFound, id)
+	}
+	token := t.APIToken
+	return &token, nil
+}
+
+// Create crea un token y retorna su secreto (no se vuelve a poder consultar)
+func (ts *APITokenStore) Create(req APITokenRequest, createdBy string) (*NewAPIToken, error) {
+	if err := validateAPITokenRequest(&req); err != nil {
+		return nil, err
+	}
+	secret, err := generateAPITokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	return ts.add(req, hashAPIToken(secret), secret, createdBy)
+}
+
+// add registra un token con el hash de su secreto
+func (ts *APITokenStore) add(req APITokenRequest, hash, secret, createdBy string) (*NewAPIToken, error) {
+	now := time.Now()
+	t := &storedAPIToken{
+		APIToken: APIToken{
+			ID:           generateUUID(),
+			Name:         req.Name,
+			Scopes:       req.Scopes,
+			PathPrefixes: req.PathPrefixes,
+			ExpiresAt:    req.ExpiresAt,
+			CreatedBy:    createdBy,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		SecretHash: hash,
+	}
+
+	ts.mu.Lock()
+	ts.tokens[t.ID] = t
+	ts.mu.Unlock()
+
+	if err := ts.persist(); err != nil {
+		return nil, fmt.Errorf("error guardando tokens: %v", err)
+	}
+	return &NewAPIToken{APIToken: t.APIToken, Token: secret}, nil
+}
+
+// Update reemplaza nombre, scopes, prefijos y caducidad de un token (el secreto se mantiene)
+func (ts *APITokenStore) Update(id string, req APITokenRequest) (*APIToken, error) {
+	if err := validateAPITokenRequest(&req); err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	t, ok := ts.tokens[id]
+	if !ok {
+		ts.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrAPITokenNotFound, id)
+	}
+	next := *t
+	next.Name = req.Name
+	next.Scopes = req.Scopes
+	next.PathPrefixes = req.PathPrefixes
+	next.ExpiresAt = req.ExpiresAt
+	next.UpdatedAt = time.Now()
+	ts.tokens[id] = &next
+	ts.mu.Unlock()
+
+	if err := ts.persist(); err != nil {
+		return nil, fmt.Errorf("error guardando tokens: %v", err)
+	}
+	token := next.APIToken
+	return &token, nil
+}
+
+// Delete revoca un token. El hash de un token importado de
+// CLAUDE_MONITOR_API_TOKEN se recuerda para no volver a importarlo al reiniciar
+// mientras la variable siga definida.
+func (ts *APITokenStore) Delete(id string) error {
+	ts.mu.Lock()
+	t, ok := ts.tokens[id]
+	if !ok {
+		ts.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrAPITokenNotFound, id)
+	}
+	legacy := t.CreatedBy == LegacyAPITokenName
+	if legacy {
+		ts.revoked[t.SecretHash] = true
+	}
+	delete(ts.tokens, id)
+	delete(ts.persisted, id)
+	ts.mu.Unlock()
+
+	if legacy {
+		if err := ts.persistRevoked(); err != nil {
+			return fmt.Errorf("error guardando tokens revocados: %v", err)
+		}
+	}
+	return ts.persist()
+}
+
+// Authenticate busca el token de un secreto. Retorna nil si no existe o caducó.
+func (ts *APITokenStore) Authenticate(secret string) *APIToken {
+	if secret == "" {
+		return nil
+	}
+	hash := hashAPIToken(secret)
+	now := time.Now()
+
+	ts.mu.Lock()
+	var found *storedAPIToken
+	for _, t := range ts.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.SecretHash), []byte(hash)) == 1 {
+			found = t
+			break
+		}
+	}
+	if found == nil || found.Expired(now) {
+		ts.mu.Unlock()
+		return nil
+	}
+	found.LastUsedAt = &now
+	flush := now.Sub(ts.persisted[found.ID]) >= apiTokenLastUsedPersist
+	token := found.APIToken
+	ts.mu.Unlock()
+
+	if flush {
+		if err := ts.persist(); err != nil {
+			logger.Warn("Error guardando tokens de API", "error", err)
+		}
+	}
+	return &token
+}
+
+// ImportLegacy registra el secreto de CLAUDE_MONITOR_API_TOKEN como el token
+// "env" con todos los permisos, si no estaba ya en el almacén. Retorna
+// ErrLegacyAPITokenRevoked si ese secreto se revocó antes.
+func (ts *APITokenStore) ImportLegacy(secret string) (bool, error) {
+	hash := hashAPIToken(secret)
+
+	ts.mu.RLock()
+	if ts.revoked[hash] {
+		ts.mu.RUnlock()
+		return false, ErrLegacyAPITokenRevoked
+	}
+	for _, t := range ts.tokens {
+		if t.SecretHash == hash {
+			ts.mu.RUnlock()
+			return false, nil
+		}
+	}
+	ts.mu.RUnlock()
+
+	req := APITokenRequest{
+		Name:   LegacyAPITokenName,
+		Scopes: append([]auth.Permission(nil), auth.KnownPermissions...),
+	}
+	if _, err := ts.add(req, hash, "", LegacyAPITokenName); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-monitor/pkg/auth"
+)
+
+func TestAPITokenStore_Create(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewAPITokenStore(dir)
+
+	created, err := ts.Create(APITokenRequest{
+		Name:         "ci",
+		Scopes:       []auth.Permission{auth.PermTerminalsRead, auth.PermAnalyticsRead, auth.PermTerminalsRead},
+		PathPrefixes: []string{"/api/session-roots/-home-ci-app/"},
+	}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Token, apiTokenPrefix) || len(created.Scopes) != 2 || created.PathPrefixes[0] != "/api/session-roots/-home-ci-app" {
+		t.Errorf("Create: %+v", created)
+	}
+
+	tok := ts.Authenticate(created.Token)
+	if tok == nil || tok.ID != created.ID || tok.LastUsedAt == nil {
+		t.Fatalf("Authenticate: %+v", tok)
+	}
+	if ts.Authenticate("cmt_otro") != nil || ts.Authenticate("") != nil {
+		t.Error("Expected unknown token to fail")
+	}
+
+	// El secreto no se persiste en claro y last_used_at sí
+	reloaded := NewAPITokenStore(dir)
+	got, err := reloaded.Get(created.ID)
+	if err != nil || got.LastUsedAt == nil || got.CreatedBy != "admin" {
+		t.Errorf("Reloaded: %+v, %v", got, err)
+	}
+	if reloaded.Authenticate(created.Token) == nil {
+		t.Error("Expected reloaded token to authenticate")
+	}
+
+	for _, req := range []APITokenRequest{
+		{Name: "", Scopes: []auth.Permission{auth.PermTerminalsRead}},
+		{Name: "x"},
+		{Name: "x", Scopes: []auth.Permission{"terminals:everything"}},
+		{Name: "x", Scopes: []auth.Permission{auth.PermTerminalsRead}, PathPrefixes: []string{"/metrics"}},
+	} {
+		if _, err := ts.Create(req, ""); err == nil || !strings.Contains(err.Error(), "invalido") && !strings.Contains(err.Error(), "requerido") {
+			t.Errorf("Expected validation error for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestAPITokenStore_ExpiryAndRevoke(t *testing.T) {
+	ts := NewAPITokenStore(t.TempDir())
+
+	expires := time.Now().Add(time.Hour)
+	created, err := ts.Create(APITokenRequest{Name: "temp", Scopes: []auth.Permission{auth.PermJobsRead}, ExpiresAt: &expires}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Authenticate(created.Token) == nil {
+		t.Fatal("Expected valid token")
+	}
+
+	// Caducado: se fuerza en memoria (la API no acepta fechas pasadas)
+	past := time.Now().Add(-time.Second)
+	ts.tokens[created.ID].ExpiresAt = &past
+	if ts.Authenticate(created.Token) != nil {
+		t.Error("Expected expired token to fail")
+	}
+	if _, err := ts.Update(created.ID, APITokenRequest{Name: "temp", Scopes: []auth.Permission{auth.PermJobsRead}, ExpiresAt: &past}); err == nil {
+		t.Error("Expected past expires_at to be rejected")
+	}
+
+	// Update mantiene el secreto
+	updated, err := ts.Update(created.ID, APITokenRequest{Name: "temp2", Scopes: []auth.Permission{auth.PermJobsWrite}})
+	if err != nil || updated.ExpiresAt != nil {
+		t.Fatalf("Update: %+v, %v", updated, err)
+	}
+	if tok := ts.Authenticate(created.Token); tok == nil || tok.Scopes[0] != auth.PermJobsWrite {
+		t.Errorf("Expected updated scopes, got %+v", tok)
+	}
+
+	if err := ts.Delete(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Authenticate(created.Token) != nil {
+		t.Error("Expected revoked token to fail")
+	}
+	if err := ts.Delete(created.ID); !errors.Is(err, ErrAPITokenNotFound) {
+		t.Errorf("Expected ErrAPITokenNotFound, got %v", err)
+	}
+}
+
+func TestAPITokenStore_ImportLegacy(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewAPITokenStore(dir)
+
+	if imported, err := ts.ImportLegacy("legado"); err != nil || !imported {
+		t.Fatalf("ImportLegacy: %v, %v", imported, err)
+	}
+	if imported, _ := ts.ImportLegacy("legado"); imported || ts.Count() != 1 {
+		t.Error("Expected legacy token to be imported once")
+	}
+	tok := ts.Authenticate("legado")
+	if tok == nil || tok.Name != LegacyAPITokenName || len(tok.Scopes) != len(auth.KnownPermissions) {
+		t.Errorf("Legacy token: %+v", tok)
+	}
+
+	// Revocado, no vuelve al reiniciar aunque la variable siga definida
+	if err := ts.Delete(tok.ID); err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewAPITokenStore(dir)
+	if imported, err := reopened.ImportLegacy("legado"); imported || !errors.Is(err, ErrLegacyAPITokenRevoked) {
+		t.Errorf("ImportLegacy after revoke = %v, %v; want ErrLegacyAPITokenRevoked", imported, err)
+	}
+	if reopened.Authenticate("legado") != nil || reopened.Count() != 0 {
+		t.Error("Expected revoked legacy token to stay revoked")
+	}
+
+	// Un secreto distinto en la variable sí se importa
+	if imported, err := reopened.ImportLegacy("otro"); err != nil || !imported {
+		t.Errorf("ImportLegacy(new secret) = %v, %v", imported, err)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Tamaño máximo del log de auditoría antes de rotar
+const maxAuditLogSize = 10 * 1024 * 1024
+
+// AuditEntry entrada del log de auditoría
+type AuditEntry struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Actor      string            `json:"actor"`  // usuario o "policy"
+	Action     string            `json:"action"` // ej: "permission.answer", "permission.auto"
+	TerminalID string            `json:"terminal_id,omitempty"`
+	Tool       string            `json:"tool,omitempty"`
+	Command    string            `json:"command,omitempty"`
+	Decision   string            `json:"decision,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// AuditFilter filtros para consultar el log de auditoría
+type AuditFilter struct {
+	TerminalID string
+	Action     string
+	Actor      string
+	Since      time.Time
+	Limit      int
+}
+
+// AuditLog log de auditoría append-only en formato JSONL
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog crea un log de auditoría en dataDir/audit.jsonl
+func NewAuditLog(dataDir string) *AuditLog {
+	return &AuditLog{
+		path: filepath.Join(dataDir, "audit.jsonl"),
+	}
+}
+
+// Record agrega una entrada al log. Los errores se registran pero no se propagan
+// para no bloquear la operación auditada.
+func (a *AuditLog) Record(entry AuditEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("Error serializando entrada de auditoría", "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rotateIfNeeded()
+
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Error("Error abriendo log de auditoría", "error", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		logger.Error("Error escribiendo log de auditoría", "error", err)
+	}
+}
+
+// rotateIfNeeded rota el archivo si supera el tamaño máximo (conserva un backup)
+func (a *AuditLog) rotateIfNeeded() {
+	info, err := os.Stat(a.path)
+	if err != nil || info.Size() < maxAuditLogSize {
+		return
+	}
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		logger.Warn("Error rotando log de auditoría", "error", err)
+	}
+}
+
+// List retorna las entradas que cumplen el filtro, de la más reciente a la más antigua
+func (a *AuditLog) List(filter AuditFilter) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := make([]AuditEntry, 0)
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !filter.matches(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Invertir: más recientes primero
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
+
+// matches verifica si una entrada cumple el filtro
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.TerminalID != "" && entry.TerminalID != f.TerminalID {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	return true
+}
@@ -28,18 +28,21 @@ type ClaudeProject struct {
 
 // ClaudeSession representa una sesión de Claude
 type ClaudeSession struct {
-	ID                string    `json:"id"`
-	Name              string    `json:"name,omitempty"`
-	ProjectPath       string    `json:"project_path"`
-	RealPath          string    `json:"real_path"`
-	FilePath          string    `json:"file_path"`
-	FirstMessage      string    `json:"first_message"`
-	MessageCount      int       `json:"message_count"`
-	UserMessages      int       `json:"user_messages"`
-	AssistantMessages int       `json:"assistant_messages"`
-	SizeBytes         int64     `json:"size_bytes"`
-	CreatedAt         time.Time `json:"created_at"`
-	ModifiedAt        time.Time `json:"modified_at"`
+	ID                string     `json:"id"`
+	Name              string     `json:"name,omitempty"`
+	ProjectPath       string     `json:"project_path"`
+	RealPath          string     `json:"real_path"`
+	FilePath          string     `json:"file_path"`
+	FirstMessage      string     `json:"first_message"`
+	MessageCount      int        `json:"message_count"`
+	UserMessages      int        `json:"user_messages"`
+	AssistantMessages int        `json:"assistant_messages"`
+	SizeBytes         int64      `json:"size_bytes"`
+	Tokens            TokenUsage `json:"tokens"`         // incluye los subagentes
+	AgentCount        int        `json:"agent_count"`    // transcripts de subagentes (Task)
+	AgentMessages     int        `json:"agent_messages"` // incluidos en message_count
+	CreatedAt         time.Time  `json:"created_at"`
+	ModifiedAt        time.Time  `json:"modified_at"`
 }
 
 // SessionNames almacena nombres personalizados de sesiones
@@ -195,7 +198,8 @@ func EncodeProjectPath(realPath string) string {
 	return "-" + encoded
 }
 
-// isValidUUIDSession verifica si un nombre de archivo es una sesión válida
+// isValidUUIDSession verifica si un nombre de archivo es una sesión válida.
+// Los agent-*.jsonl son transcripts de subagentes (ver agents.go).
 func isValidUUIDSession(name string) bool {
 	if strings.HasPrefix(name, "agent-") {
 		return false
@@ -296,6 +300,7 @@ func (s *ClaudeService) ListSessions(projectPath string) ([]ClaudeSession, error
 	}
 
 	var sessions []ClaudeSession
+	var agentIndex map[string][]string
 	for _, entry := range entries {
 		if entry.IsDir() || !isValidUUIDSession(entry.Name()) {
 			continue
@@ -323,19 +328,25 @@ func (s *ClaudeService) ListSessions(projectPath string) ([]ClaudeSession, error
 			session.SizeBytes = info.Size()
 		}
 
-		firstMsg, userCount, assistantCount, createdAt := s.parseSessionFile(filePath)
-		session.FirstMessage = firstMsg
-		session.UserMessages = userCount
-		session.AssistantMessages = assistantCount
-		session.MessageCount = userCount + assistantCount
-		session.CreatedAt = createdAt
+		stats := parseTranscript(filePath)
+		session.FirstMessage = stats.firstMessage
+		session.UserMessages = stats.userCount
+		session.AssistantMessages = stats.assistantCount
+		session.MessageCount = stats.userCount + stats.assistantCount
+		session.Tokens = stats.tokens
+		session.CreatedAt = stats.createdAt
 		session.Name = GetSessionName(session.ID)
 
 		// Filtrar sesiones vacías o solo con caveats/metadata
-		if session.MessageCount == 0 || strings.HasPrefix(firstMsg, "<local-command-caveat>") || strings.HasPrefix(firstMsg, "Caveat:") {
+		if session.MessageCount == 0 || strings.HasPrefix(stats.firstMessage, "<local-command-caveat>") || strings.HasPrefix(stats.firstMessage, "Caveat:") {
 			continue
 		}
 
+		if agentIndex == nil {
+			agentIndex = s.agentFilesBySession(fullPath)
+		}
+		session.addAgents(loadSessionAgents(projectPath, sessionID, agentIndex[sessionID], stats))
+
 		sessions = append(sessions, session)
 	}
 
@@ -370,12 +381,16 @@ func (s *ClaudeService) GetSession(projectPath, sessionID string) (*ClaudeSessio
 		SizeBytes:   info.Size(),
 	}
 
-	firstMsg, userCount, assistantCount, createdAt := s.parseSessionFile(filePath)
-	session.FirstMessage = firstMsg
-	session.UserMessages = userCount
-	session.AssistantMessages = assistantCount
-	session.MessageCount = userCount + assistantCount
-	session.CreatedAt = createdAt
+	stats := parseTranscript(filePath)
+	session.FirstMessage = stats.firstMessage
+	session.UserMessages = stats.userCount
+	session.AssistantMessages = stats.assistantCount
+	session.MessageCount = stats.userCount + stats.assistantCount
+	session.Tokens = stats.tokens
+	session.CreatedAt = stats.createdAt
+
+	agentFiles := s.agentFilesBySession(filepath.Join(s.claudeDir, projectPath))[sessionID]
+	session.addAgents(loadSessionAgents(projectPath, sessionID, agentFiles, stats))
 
 	return session, nil
 }
@@ -389,6 +404,11 @@ func (s *ClaudeService) DeleteSession(projectPath, sessionID string) error {
 	os.RemoveAll(subagentsDir)
 	os.Remove(filepath.Join(s.claudeDir, projectPath, sessionID))
 
+	// Subagentes en el formato anterior (agent-*.jsonl junto a la sesión)
+	for _, agentFile := range s.agentFilesBySession(filepath.Join(s.claudeDir, projectPath))[sessionID] {
+		os.Remove(agentFile)
+	}
+
 	return os.Remove(filePath)
 }
 
@@ -468,54 +488,102 @@ func (s *ClaudeService) GetProjectActivity(projectPath string) ([]DailyActivity,
 	return activities, nil
 }
 
-// parseSessionFile extrae información de un archivo de sesión
-func (s *ClaudeService) parseSessionFile(filePath string) (firstMessage string, userCount int, assistantCount int, createdAt time.Time) {
+// transcriptStats información extraída de un transcript (sesión o subagente)
+type transcriptStats struct {
+	firstMessage   string // primer mensaje de usuario (truncado)
+	firstPrompt    string // primer mensaje de usuario completo
+	userCount      int
+	assistantCount int
+	createdAt      time.Time
+	tokens         TokenUsage
+	sessionID      string            // sessionId de las líneas (en subagentes, la sesión padre)
+	tasks          []taskCall        // tool_use de Task en orden de aparición
+	agentLinks     map[string]string // agentId -> tool_use_id del resultado de Task
+}
+
+// transcriptLine campos usados de cada línea del transcript
+type transcriptLine struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	SessionID string `json:"sessionId"`
+	Message   struct {
+		ID      string          `json:"id"`
+		Content json.RawMessage `json:"content"`
+		Usage   *TokenUsage     `json:"usage"`
+	} `json:"message"`
+	ToolUseResult json.RawMessage `json:"toolUseResult"`
+}
+
+// parseTranscript extrae conteos, tokens y llamadas a Task de un transcript
+func parseTranscript(filePath string) transcriptStats {
+	stats := transcriptStats{
+		agentLinks: make(map[string]string),
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", 0, 0, time.Time{}
+		return stats
 	}
 	defer file.Close()
 
+	// Una respuesta del asistente se escribe en varias líneas (una por bloque)
+	// que repiten message.usage: se cuenta la última de cada message.id
+	usageByMessage := make(map[string]TokenUsage)
+
 	scanner := bufio.NewScanner(file)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 1024*1024)
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		var msg map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		var line transcriptLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
 			continue
 		}
+		if stats.sessionID == "" {
+			stats.sessionID = line.SessionID
+		}
 
-		if msgType, ok := msg["type"].(string); ok && msgType == "user" {
-			userCount++
+		switch line.Type {
+		case "user":
+			stats.userCount++
 
-			if ts, ok := msg["timestamp"].(string); ok {
-				if t, err := time.Parse(time.RFC3339, ts); err == nil {
-					if createdAt.IsZero() {
-						createdAt = t
-					}
+			if t, err := time.Parse(time.RFC3339, line.Timestamp); err == nil && stats.createdAt.IsZero() {
+				stats.createdAt = t
+			}
+
+			var content string
+			if stats.firstPrompt == "" && json.Unmarshal(line.Message.Content, &content) == nil && content != "" {
+				stats.firstPrompt = content
+				stats.firstMessage = content
+				if len(stats.firstMessage) > 100 {
+					stats.firstMessage = stats.firstMessage[:100] + "..."
 				}
 			}
 
-			if firstMessage == "" {
-				if message, ok := msg["message"].(map[string]interface{}); ok {
-					if content, ok := message["content"].(string); ok {
-						firstMessage = content
-						if len(firstMessage) > 100 {
-							firstMessage = firstMessage[:100] + "..."
-						}
-					}
+			if agentID, toolUseID := parseTaskResult(line); agentID != "" && toolUseID != "" {
+				stats.agentLinks[agentID] = toolUseID
+			}
+
+		case "assistant":
+			stats.assistantCount++
+
+			if u := line.Message.Usage; u != nil {
+				if line.Message.ID != "" {
+					usageByMessage[line.Message.ID] = *u
+				} else {
+					stats.tokens.Add(*u)
 				}
 			}
-		}
 
-		if msgType, ok := msg["type"].(string); ok && msgType == "assistant" {
-			assistantCount++
+			stats.tasks = append(stats.tasks, parseTaskCalls(line.Message.Content)...)
 		}
 	}
 
-	return firstMessage, userCount, assistantCount, createdAt
+	for _, u := range usageByMessage {
+		stats.tokens.Add(u)
+	}
+
+	return stats
 }
 
 // parseSessionDates extrae las fechas de mensajes
@@ -722,8 +790,11 @@ func (s *ClaudeService) GetSessionMessagesFromLine(projectPath, sessionID string
 
 // GetSessionMessages obtiene todos los mensajes de una sesión
 func (s *ClaudeService) GetSessionMessages(projectPath, sessionID string) ([]SessionMessage, error) {
-	filePath := filepath.Join(s.claudeDir, projectPath, sessionID+".jsonl")
+	return readTranscriptMessages(filepath.Join(s.claudeDir, projectPath, sessionID+".jsonl"))
+}
 
+// readTranscriptMessages lee los mensajes de usuario y asistente de un transcript
+func readTranscriptMessages(filePath string) ([]SessionMessage, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -778,42 +849,3 @@ func (s *ClaudeService) GetSessionMessages(projectPath, sessionID string) ([]Ses
 
 	return messages, nil
 }
-
-// MoveSession mueve una sesión a otro proyecto, actualizando rutas internas
-func (s *ClaudeService) MoveSession(oldProjectPath, sessionID, newRealPath string) error {
-	if !filepath.IsAbs(newRealPath) {
-		return os.ErrInvalid
-	}
-
-	session, err := s.GetSession(oldProjectPath, sessionID)
-	if err != nil {
-		return err
-	}
-
-	newProjectPath := EncodeProjectPath(newRealPath)
-	if oldProjectPath == newProjectPath {
-		return os.ErrExist
-	}
-
-	// Crear directorio destino
-	newProjectDir := filepath.Join(s.claudeDir, newProjectPath)
-	if err := os.MkdirAll(newProjectDir, 0755); err != nil {
-		return err
-	}
-
-	// Leer, reemplazar paths, escribir en destino
-	content, err := os.ReadFile(session.FilePath)
-	if err != nil {
-		return err
-	}
-	newContent := strings.ReplaceAll(string(content), session.RealPath, newRealPath)
-
-	newFilePath := filepath.Join(newProjectDir, sessionID+".jsonl")
-	if err := os.WriteFile(newFilePath, []byte(newContent), 0600); err != nil {
-		return err
-	}
-
-	// Eliminar archivo original
-	os.Remove(session.FilePath)
-	return nil
-}
@@ -61,66 +61,19 @@ const (
 // 2. Patrones de Detección
 // ============================================================================
 
-// OutputPattern define un patrón a detectar en el output
+// OutputPattern define un patrón a detectar en el output. Los patrones se
+// cargan desde las reglas de detección (ver detection_rules.go).
 type OutputPattern struct {
 	Name        string
 	Pattern     *regexp.Regexp
 	Type        string
 	Priority    int
+	State       ClaudeState // estado que implica el patrón (vacío = ninguno)
+	Mode        ClaudeMode  // modo que implica el patrón (vacío = ninguno)
+	VimSubMode  VimSubMode  // submodo vim que implica el patrón
 	Description string
 }
 
-// Patrones compilados para detección
-var claudePatterns = []OutputPattern{
-	// Prompts de permiso (alta prioridad)
-	{Name: "permission_allow", Pattern: regexp.MustCompile(`(?i)Allow\s+\w+.*to`), Type: "permission", Priority: 100, Description: "Solicitud de permiso"},
-	{Name: "permission_yn", Pattern: regexp.MustCompile(`\[y/n\]`), Type: "permission", Priority: 100, Description: "Confirmación sí/no"},
-	{Name: "permission_Yn", Pattern: regexp.MustCompile(`\[Y/n\]`), Type: "permission", Priority: 100, Description: "Confirmación (default yes)"},
-	{Name: "permission_yN", Pattern: regexp.MustCompile(`\[y/N\]`), Type: "permission", Priority: 100, Description: "Confirmación (default no)"},
-
-	// Estados de herramientas
-	{Name: "tool_running", Pattern: regexp.MustCompile(`(?i)^Running:`), Type: "tool", Priority: 80, Description: "Herramienta ejecutándose"},
-	{Name: "tool_writing", Pattern: regexp.MustCompile(`(?i)^Writing:`), Type: "tool", Priority: 80, Description: "Escribiendo archivo"},
-	{Name: "tool_reading", Pattern: regexp.MustCompile(`(?i)^Reading:`), Type: "tool", Priority: 80, Description: "Leyendo archivo"},
-	{Name: "tool_searching", Pattern: regexp.MustCompile(`(?i)^Searching:`), Type: "tool", Priority: 80, Description: "Buscando"},
-	{Name: "tool_editing", Pattern: regexp.MustCompile(`(?i)^Editing:`), Type: "tool", Priority: 80, Description: "Editando archivo"},
-
-	// Spinners de progreso (detectar caracteres de spinner)
-	{Name: "spinner", Pattern: regexp.MustCompile(`[⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏⣾⣽⣻⢿⡿⣟⣯⣷]`), Type: "progress", Priority: 70, Description: "Spinner activo"},
-	{Name: "progress_numeric", Pattern: regexp.MustCompile(`\[\d+/\d+\]`), Type: "progress", Priority: 70, Description: "Progreso numérico"},
-	{Name: "progress_percent", Pattern: regexp.MustCompile(`\d+%`), Type: "progress", Priority: 60, Description: "Progreso porcentaje"},
-
-	// Prompts de entrada
-	{Name: "claude_prompt", Pattern: regexp.MustCompile(`^>\s*$`), Type: "prompt", Priority: 50, Description: "Prompt de Claude"},
-	{Name: "input_prompt", Pattern: regexp.MustCompile(`claude>\s*$`), Type: "prompt", Priority: 50, Description: "Prompt con nombre"},
-
-	// Modos especiales
-	{Name: "vim_mode", Pattern: regexp.MustCompile(`(?i)vim mode`), Type: "mode", Priority: 90, Description: "Modo vim activo"},
-	{Name: "plan_mode", Pattern: regexp.MustCompile(`(?i)plan mode`), Type: "mode", Priority: 90, Description: "Modo plan activo"},
-	{Name: "vim_insert", Pattern: regexp.MustCompile(`-- INSERT --`), Type: "vim", Priority: 85, Description: "Vim modo inserción"},
-	{Name: "vim_normal", Pattern: regexp.MustCompile(`-- NORMAL --`), Type: "vim", Priority: 85, Description: "Vim modo normal"},
-	{Name: "vim_visual", Pattern: regexp.MustCompile(`-- VISUAL --`), Type: "vim", Priority: 85, Description: "Vim modo visual"},
-
-	// Errores y éxitos
-	{Name: "error", Pattern: regexp.MustCompile(`(?i)^Error:`), Type: "status", Priority: 95, Description: "Error"},
-	{Name: "warning", Pattern: regexp.MustCompile(`(?i)^Warning:`), Type: "status", Priority: 85, Description: "Advertencia"},
-	{Name: "success_check", Pattern: regexp.MustCompile(`✓`), Type: "status", Priority: 40, Description: "Éxito"},
-	{Name: "failure_x", Pattern: regexp.MustCompile(`✗`), Type: "status", Priority: 40, Description: "Fallo"},
-
-	// Slash commands
-	{Name: "slash_command", Pattern: regexp.MustCompile(`^/\w+`), Type: "command", Priority: 60, Description: "Slash command"},
-
-	// Tokens/costo
-	{Name: "tokens_info", Pattern: regexp.MustCompile(`(?i)tokens?:`), Type: "info", Priority: 30, Description: "Info de tokens"},
-	{Name: "cost_info", Pattern: regexp.MustCompile(`(?i)\$[\d.]+`), Type: "info", Priority: 30, Description: "Info de costo"},
-
-	// Background tasks
-	{Name: "background_task", Pattern: regexp.MustCompile(`(?i)background|task \d+`), Type: "background", Priority: 50, Description: "Tarea en background"},
-
-	// Checkpoint/Rewind
-	{Name: "checkpoint", Pattern: regexp.MustCompile(`(?i)checkpoint|rewind`), Type: "checkpoint", Priority: 70, Description: "Checkpoint/Rewind"},
-}
-
 // ============================================================================
 // 3. Slash Commands conocidos
 // ============================================================================
@@ -249,6 +202,12 @@ type ClaudeStateInfo struct {
 	// Timestamps
 	LastActivity       time.Time `json:"last_activity"`
 	StateChangedAt     time.Time `json:"state_changed_at"`
+
+	// Duraciones de estado (máquina de estados con debounce)
+	StateDurationMs int64                 `json:"state_duration_ms"`       // tiempo en el estado actual
+	StateTotalsMs   map[ClaudeState]int64 `json:"state_totals_ms"`         // tiempo acumulado por estado
+	Transitions     int                   `json:"transitions"`             // cambios de estado confirmados
+	PendingState    ClaudeState           `json:"pending_state,omitempty"` // candidato aún no confirmado
 }
 
 // ClaudeAwareScreenHandler extiende ScreenState con detección de Claude
@@ -266,6 +225,21 @@ type ClaudeAwareScreenHandler struct {
 	eventHistory    []HookEvent
 	maxEventHistory int
 
+	// Máquina de estados (ver claude_state_machine.go)
+	smConfig       StateMachineConfig
+	now            func() time.Time
+	manualClock    bool
+	evalTimer      *time.Timer
+	dirty          bool      // hay output sin evaluar
+	dirtySince     time.Time // primer output sin evaluar
+	lastOutput     time.Time
+	candidate      ClaudeState
+	candidateSince time.Time
+	enteredAt      time.Time
+	stateTotals    map[ClaudeState]time.Duration
+	transitions    int
+	promptRegion   string
+
 	// Callbacks para eventos
 	OnStateChange      func(old, new ClaudeState)
 	OnPermissionPrompt func(tool string)
@@ -276,18 +250,23 @@ type ClaudeAwareScreenHandler struct {
 
 // NewClaudeAwareScreenHandler crea un nuevo handler con detección de Claude
 func NewClaudeAwareScreenHandler(width, height int) *ClaudeAwareScreenHandler {
+	now := time.Now()
 	return &ClaudeAwareScreenHandler{
 		ScreenState: NewScreenState(width, height),
 		stateInfo: ClaudeStateInfo{
 			State:          StateUnknown,
 			Mode:           ModeNormal,
 			PermissionMode: PermDefault,
-			LastActivity:   time.Now(),
-			StateChangedAt: time.Now(),
+			LastActivity:   now,
+			StateChangedAt: now,
 		},
 		checkpoints:     make([]Checkpoint, 0),
 		eventHistory:    make([]HookEvent, 0),
 		maxEventHistory: 100,
+		smConfig:        DefaultStateMachineConfig(),
+		now:             time.Now,
+		enteredAt:       now,
+		stateTotals:     make(map[ClaudeState]time.Duration),
 	}
 }
 
@@ -304,20 +283,19 @@ func (h *ClaudeAwareScreenHandler) Feed(data []byte) error {
 	return nil
 }
 
-// analyzeContent analiza el contenido para detectar estados de Claude
+// analyzeContent analiza el chunk recibido (slash commands y métricas) y
+// programa la evaluación de estado sobre la pantalla renderizada
 func (h *ClaudeAwareScreenHandler) analyzeContent(content string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	oldState := h.stateInfo.State
-	h.stateInfo.LastActivity = time.Now()
-
-	// Detectar patrones activos
-	activePatterns := h.detectPatterns(content)
-	h.stateInfo.ActivePatterns = activePatterns
-
-	// Actualizar estado basado en patrones
-	h.updateStateFromPatterns(activePatterns, content)
+	now := h.now()
+	h.stateInfo.LastActivity = now
+	h.lastOutput = now
+	if !h.dirty {
+		h.dirty = true
+		h.dirtySince = now
+	}
 
 	// Detectar slash commands
 	h.detectSlashCommands(content)
@@ -325,112 +303,34 @@ func (h *ClaudeAwareScreenHandler) analyzeContent(content string) {
 	// Detectar información de tokens/costo
 	h.detectMetrics(content)
 
-	// Notificar cambio de estado
-	if oldState != h.stateInfo.State {
-		h.stateInfo.StateChangedAt = time.Now()
-		if h.OnStateChange != nil {
-			go h.OnStateChange(oldState, h.stateInfo.State)
-		}
-	}
-}
-
-// detectPatterns encuentra patrones activos en el contenido
-func (h *ClaudeAwareScreenHandler) detectPatterns(content string) []string {
-	var patterns []string
-
-	for _, p := range claudePatterns {
-		if p.Pattern.MatchString(content) {
-			patterns = append(patterns, p.Name)
-		}
-	}
-
-	return patterns
+	h.scheduleEvaluation()
 }
 
-// updateStateFromPatterns actualiza el estado basado en patrones detectados
-func (h *ClaudeAwareScreenHandler) updateStateFromPatterns(patterns []string, content string) {
-	// Priorizar por tipo de patrón
-	hasPermission := false
-	hasSpinner := false
-	hasTool := false
-	hasPrompt := false
-	hasError := false
-
+// patternNames retorna los nombres de los patrones
+func patternNames(patterns []OutputPattern) []string {
+	var names []string
 	for _, p := range patterns {
-		switch {
-		case strings.HasPrefix(p, "permission"):
-			hasPermission = true
-		case p == "spinner":
-			hasSpinner = true
-		case strings.HasPrefix(p, "tool_"):
-			hasTool = true
-		case strings.HasSuffix(p, "_prompt"):
-			hasPrompt = true
-		case p == "error":
-			hasError = true
-		case p == "vim_insert":
-			h.stateInfo.VimSubMode = VimInsert
-			h.stateInfo.Mode = ModeVim
-		case p == "vim_normal":
-			h.stateInfo.VimSubMode = VimNormal
-			h.stateInfo.Mode = ModeVim
-		case p == "vim_visual":
-			h.stateInfo.VimSubMode = VimVisual
-			h.stateInfo.Mode = ModeVim
-		case p == "plan_mode":
-			h.stateInfo.Mode = ModePlan
-		}
-	}
-
-	// Determinar estado principal (por prioridad)
-	if hasError {
-		h.stateInfo.State = StateError
-	} else if hasPermission {
-		h.stateInfo.State = StatePermissionPrompt
-		h.stateInfo.PendingPermission = true
-		h.extractPendingTool(content)
-	} else if hasTool {
-		h.stateInfo.State = StateToolRunning
-		h.extractToolName(content)
-	} else if hasSpinner {
-		h.stateInfo.State = StateGenerating
-		h.stateInfo.IsGenerating = true
-	} else if hasPrompt {
-		h.stateInfo.State = StateWaitingInput
-		h.stateInfo.IsGenerating = false
-		h.stateInfo.PendingPermission = false
+		names = append(names, p.Name)
 	}
+	return names
 }
 
 // extractPendingTool extrae el nombre de la herramienta pendiente de permiso
 func (h *ClaudeAwareScreenHandler) extractPendingTool(content string) {
-	// Patrón: "Allow X to" o "Allow X("
-	re := regexp.MustCompile(`(?i)Allow\s+(\w+)`)
-	if matches := re.FindStringSubmatch(content); len(matches) > 1 {
-		h.stateInfo.PendingTool = matches[1]
+	// Patrón por defecto: "Allow X to" o "Allow X("
+	if tool := extractFirst(activeDetectionRules().pendingTool, content); tool != "" {
+		h.stateInfo.PendingTool = tool
 		if h.OnPermissionPrompt != nil {
-			go h.OnPermissionPrompt(matches[1])
+			go h.OnPermissionPrompt(tool)
 		}
 	}
 }
 
 // extractToolName extrae el nombre de la herramienta en ejecución
 func (h *ClaudeAwareScreenHandler) extractToolName(content string) {
-	// Patrones: "Running: X", "Writing: file", etc.
-	patterns := []string{
-		`(?i)Running:\s*(\w+)`,
-		`(?i)Writing:\s*(.+)`,
-		`(?i)Reading:\s*(.+)`,
-		`(?i)Editing:\s*(.+)`,
-		`(?i)Searching:\s*(.+)`,
-	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if matches := re.FindStringSubmatch(content); len(matches) > 1 {
-			h.stateInfo.LastToolUsed = strings.TrimSpace(matches[1])
-			break
-		}
+	// Patrones por defecto: "Running: X", "Writing: file", etc.
+	if tool := extractFirst(activeDetectionRules().toolName, content); tool != "" {
+		h.stateInfo.LastToolUsed = tool
 	}
 }
 
@@ -578,6 +478,8 @@ func (h *ClaudeAwareScreenHandler) GetClaudeState() ClaudeStateInfo {
 	info.RecentEvents = make([]HookEvent, len(h.stateInfo.RecentEvents))
 	copy(info.RecentEvents, h.stateInfo.RecentEvents)
 
+	h.fillDurations(&info)
+
 	return info
 }
 
@@ -636,54 +538,49 @@ func (h *ClaudeAwareScreenHandler) RemoveBackgroundTask(taskID string) {
 	h.stateInfo.BackgroundTasks = tasks
 }
 
+// SetState fija el estado desde una fuente autoritativa (hooks de Claude Code).
+// No pasa por el debounce de la máquina de estados.
+// tool es la herramienta pendiente o en uso, según el estado.
+func (h *ClaudeAwareScreenHandler) SetState(state ClaudeState, tool string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.stateInfo.LastActivity = now
+	h.candidate = ""
+	h.commitState(state, now)
+
+	switch state {
+	case StatePermissionPrompt:
+		if tool != "" {
+			h.stateInfo.PendingTool = tool
+		}
+	case StateToolRunning:
+		if tool != "" {
+			h.stateInfo.LastToolUsed = tool
+		}
+	}
+}
+
 // ============================================================================
 // 7. Funciones de utilidad para detección de pantalla completa
 // ============================================================================
 
-// DetectStateFromScreen analiza toda la pantalla para determinar estado
+// DetectStateFromScreen clasifica la pantalla actual sin debounce ni histéresis
 func (h *ClaudeAwareScreenHandler) DetectStateFromScreen() ClaudeState {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// Obtener contenido de pantalla
 	display := h.ScreenState.GetDisplay()
 	if len(display) == 0 {
 		return StateUnknown
 	}
 
-	// Analizar última línea para prompt
-	lastLine := strings.TrimSpace(display[len(display)-1])
-
-	// Buscar en todas las líneas visibles
-	fullContent := strings.Join(display, "\n")
-
-	// Detectar permiso pendiente
-	for _, p := range claudePatterns {
-		if p.Type == "permission" && p.Pattern.MatchString(fullContent) {
-			return StatePermissionPrompt
-		}
-	}
-
-	// Detectar spinner (generando)
-	for _, p := range claudePatterns {
-		if p.Name == "spinner" && p.Pattern.MatchString(fullContent) {
-			return StateGenerating
-		}
-	}
-
-	// Detectar prompt de entrada
-	if strings.HasSuffix(lastLine, ">") || lastLine == ">" {
-		return StateWaitingInput
-	}
-
-	// Detectar herramienta corriendo
-	for _, p := range claudePatterns {
-		if p.Type == "tool" && p.Pattern.MatchString(fullContent) {
-			return StateToolRunning
-		}
+	result := classifyScreen(activeDetectionRules(), display, h.smConfig.ScanLines)
+	if result.State == "" {
+		return StateUnknown
 	}
-
-	return StateUnknown
+	return result.State
 }
 
 // IsReadyForInput retorna true si Claude está listo para recibir input
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Máquina de estados de Claude basada en la pantalla renderizada
+// ============================================================================
+//
+// El estado no se deduce de cada chunk de la PTY (que trae secuencias de escape
+// y llega partido en cualquier punto) sino de la pantalla renderizada
+// (GetDisplay) cuando el output se calma:
+//
+//  1. Cada Feed actualiza la pantalla y programa una evaluación tras QuietPeriod
+//     sin output. Con output continuo (spinners) se evalúa como mucho cada MaxDelay.
+//  2. La evaluación clasifica las últimas ScanLines líneas no vacías de abajo
+//     hacia arriba: decide la primera línea con un patrón que implica estado y,
+//     dentro de la línea, el patrón de mayor prioridad. Sin coincidencias se
+//     mantiene el estado actual.
+//  3. El estado candidato debe mantenerse Confirm (histéresis) y el estado actual
+//     haber durado MinDwell antes de transicionar. Los estados urgentes
+//     (permission_prompt, error, exited) y la salida de unknown no esperan.
+//  4. Solo se permiten las transiciones de stateTransitions.
+//
+// Tabla de transiciones (origen → destinos permitidos):
+//
+//	unknown           → waiting_input, generating, permission_prompt, tool_running, error, exited
+//	waiting_input     → generating, permission_prompt, tool_running, error, exited
+//	generating        → waiting_input, permission_prompt, tool_running, error, exited
+//	tool_running      → waiting_input, generating, permission_prompt, error, exited
+//	permission_prompt → waiting_input, generating, tool_running, error, exited
+//	background_task   → waiting_input, generating, permission_prompt, tool_running, error, exited
+//	error             → waiting_input, generating, permission_prompt, tool_running, exited
+//	exited            → (terminal)
+//
+// Los hooks de Claude Code (SetState) son autoritativos y no pasan por el debounce.
+
+// StateMachineConfig parámetros de debounce de la máquina de estados
+type StateMachineConfig struct {
+	QuietPeriod time.Duration // silencio requerido antes de evaluar la pantalla
+	MaxDelay    time.Duration // espera máxima con output continuo
+	MinDwell    time.Duration // permanencia mínima en un estado antes de salir
+	Confirm     time.Duration // tiempo que el candidato debe mantenerse (histéresis)
+	ScanLines   int           // líneas no vacías (desde abajo) que se analizan
+}
+
+// DefaultStateMachineConfig configuración por defecto
+func DefaultStateMachineConfig() StateMachineConfig {
+	return StateMachineConfig{
+		QuietPeriod: 150 * time.Millisecond,
+		MaxDelay:    300 * time.Millisecond,
+		MinDwell:    300 * time.Millisecond,
+		Confirm:     200 * time.Millisecond,
+		ScanLines:   12,
+	}
+}
+
+// Transiciones permitidas por estado de origen
+var stateTransitions = map[ClaudeState][]ClaudeState{
+	StateUnknown:          {StateWaitingInput, StateGenerating, StatePermissionPrompt, StateToolRunning, StateError, StateExited},
+	StateWaitingInput:     {StateGenerating, StatePermissionPrompt, StateToolRunning, StateError, StateExited},
+	StateGenerating:       {StateWaitingInput, StatePermissionPrompt, StateToolRunning, StateError, StateExited},
+	StateToolRunning:      {StateWaitingInput, StateGenerating, StatePermissionPrompt, StateError, StateExited},
+	StatePermissionPrompt: {StateWaitingInput, StateGenerating, StateToolRunning, StateError, StateExited},
+	StateBackgroundTask:   {StateWaitingInput, StateGenerating, StatePermissionPrompt, StateToolRunning, StateError, StateExited},
+	StateError:            {StateWaitingInput, StateGenerating, StatePermissionPrompt, StateToolRunning, StateExited},
+	StateExited:           {},
+}
+
+// Estados que transicionan sin histéresis ni permanencia mínima
+var urgentStates = map[ClaudeState]bool{
+	StatePermissionPrompt: true,
+	StateError:            true,
+	StateExited:           true,
+}
+
+// canTransition indica si la tabla permite pasar de from a to
+func canTransition(from, to ClaudeState) bool {
+	for _, s := range stateTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// screenClassification resultado de clasificar la pantalla con las reglas activas
+type screenClassification struct {
+	State      ClaudeState
+	Mode       ClaudeMode
+	VimSubMode VimSubMode
+	Matched    []OutputPattern
+	Region     string // líneas analizadas, para los extractores
+}
+
+// classifyScreen clasifica las últimas scanLines líneas no vacías de la pantalla
+func classifyScreen(rules *compiledDetectionRules, display []string, scanLines int) screenClassification {
+	var region []string
+	for i := len(display) - 1; i >= 0 && len(region) < scanLines; i-- {
+		if line := strings.TrimRight(display[i], " "); strings.TrimSpace(line) != "" {
+			region = append(region, line)
+		}
+	}
+
+	var result screenClassification
+	stateFound := false
+
+	// region está de abajo hacia arriba
+	for _, line := range region {
+		lineState := ClaudeState("")
+		best := -1
+
+		for _, p := range rules.patterns {
+			if !p.Pattern.MatchString(line) {
+				continue
+			}
+			result.Matched = append(result.Matched, p)
+
+			if p.Mode != "" && result.Mode == "" {
+				result.Mode = p.Mode
+			}
+			if p.VimSubMode != "" && result.VimSubMode == "" {
+				result.VimSubMode = p.VimSubMode
+			}
+			if p.State != "" && p.Priority > best {
+				lineState = p.State
+				best = p.Priority
+			}
+		}
+
+		if !stateFound && lineState != "" {
+			result.State = lineState
+			stateFound = true
+		}
+	}
+
+	// Región en orden natural para los extractores
+	for i, j := 0, len(region)-1; i < j; i, j = i+1, j-1 {
+		region[i], region[j] = region[j], region[i]
+	}
+	result.Region = strings.Join(region, "\n")
+
+	return result
+}
+
+// SetStateMachineConfig cambia los parámetros de debounce
+func (h *ClaudeAwareScreenHandler) SetStateMachineConfig(cfg StateMachineConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.smConfig = cfg
+}
+
+// SetClock reemplaza el reloj y desactiva los timers internos: la evaluación
+// queda a cargo de Tick/Settle (replay de grabaciones y tests).
+func (h *ClaudeAwareScreenHandler) SetClock(now func() time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.now = now
+	h.manualClock = true
+	if h.evalTimer != nil {
+		h.evalTimer.Stop()
+		h.evalTimer = nil
+	}
+
+	t := now()
+	h.stateInfo.LastActivity = t
+	h.stateInfo.StateChangedAt = t
+	h.enteredAt = t
+}
+
+// scheduleEvaluation programa la próxima evaluación (llamar con h.mu tomado)
+func (h *ClaudeAwareScreenHandler) scheduleEvaluation() {
+	if h.manualClock {
+		return
+	}
+
+	delay := h.nextEvaluationDelay(h.now())
+	if delay < 0 {
+		return
+	}
+
+	if h.evalTimer == nil {
+		h.evalTimer = time.AfterFunc(delay, h.onEvalTimer)
+	} else {
+		h.evalTimer.Reset(delay)
+	}
+}
+
+// nextEvaluationDelay tiempo hasta la próxima evaluación necesaria (-1 = ninguna)
+func (h *ClaudeAwareScreenHandler) nextEvaluationDelay(now time.Time) time.Duration {
+	cfg := h.smConfig
+
+	delay := time.Duration(-1)
+	consider := func(deadline time.Time) {
+		d := deadline.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		if delay < 0 || d < delay {
+			delay = d
+		}
+	}
+
+	if h.dirty {
+		consider(h.lastOutput.Add(cfg.QuietPeriod))
+		consider(h.dirtySince.Add(cfg.MaxDelay))
+	}
+	if h.candidate != "" {
+		consider(h.candidateDeadline())
+	}
+
+	return delay
+}
+
+// candidateDeadline momento a partir del cual el candidato puede confirmarse
+func (h *ClaudeAwareScreenHandler) candidateDeadline() time.Time {
+	deadline := h.candidateSince.Add(h.smConfig.Confirm)
+	if dwell := h.enteredAt.Add(h.smConfig.MinDwell); dwell.After(deadline) {
+		deadline = dwell
+	}
+	return deadline
+}
+
+// onEvalTimer callback del timer interno
+func (h *ClaudeAwareScreenHandler) onEvalTimer() {
+	h.Tick()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.scheduleEvaluation()
+}
+
+// Tick evalúa la pantalla si corresponde según el reloj: silencio cumplido,
+// espera máxima alcanzada o candidato pendiente de confirmar.
+func (h *ClaudeAwareScreenHandler) Tick() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	cfg := h.smConfig
+
+	quiet := h.dirty && now.Sub(h.lastOutput) >= cfg.QuietPeriod
+	overdue := h.dirty && now.Sub(h.dirtySince) >= cfg.MaxDelay
+	confirm := h.candidate != "" && !now.Before(h.candidateDeadline())
+
+	if !quiet && !overdue && !confirm {
+		return
+	}
+	h.dirty = false
+	h.evaluateScreen(now, false)
+}
+
+// Settle evalúa la pantalla inmediatamente y aplica el estado detectado sin
+// debounce. Pensado para tests y para consultas puntuales.
+func (h *ClaudeAwareScreenHandler) Settle() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.dirty = false
+	h.evaluateScreen(h.now(), true)
+}
+
+// evaluateScreen clasifica la pantalla y decide la transición (llamar con h.mu tomado)
+func (h *ClaudeAwareScreenHandler) evaluateScreen(now time.Time, force bool) {
+	result := classifyScreen(activeDetectionRules(), h.ScreenState.GetDisplay(), h.smConfig.ScanLines)
+
+	h.stateInfo.ActivePatterns = patternNames(result.Matched)
+	if result.Mode != "" {
+		h.stateInfo.Mode = result.Mode
+	}
+	if result.VimSubMode != "" {
+		h.stateInfo.VimSubMode = result.VimSubMode
+	}
+
+	current := h.stateInfo.State
+	target := result.State
+
+	// Sin estado detectado o mismo estado: no hay transición pendiente
+	if target == "" || target == current {
+		h.candidate = ""
+		switch {
+		case target == StateToolRunning:
+			h.extractToolName(result.Region)
+		case target == StatePermissionPrompt && result.Region != h.promptRegion:
+			// Nuevo prompt sin pasar por otro estado (ej: prompts consecutivos)
+			h.promptRegion = result.Region
+			h.extractPendingTool(result.Region)
+		}
+		return
+	}
+
+	if !canTransition(current, target) {
+		h.candidate = ""
+		return
+	}
+
+	if h.candidate != target {
+		h.candidate = target
+		h.candidateSince = now
+	}
+
+	if !force && !urgentStates[target] && current != StateUnknown {
+		if now.Sub(h.candidateSince) < h.smConfig.Confirm || now.Sub(h.enteredAt) < h.smConfig.MinDwell {
+			return
+		}
+	}
+
+	h.candidate = ""
+	h.commitState(target, now)
+
+	switch target {
+	case StatePermissionPrompt:
+		h.promptRegion = result.Region
+		h.extractPendingTool(result.Region)
+	case StateToolRunning:
+		h.extractToolName(result.Region)
+	}
+}
+
+// commitState aplica un cambio de estado, acumula duraciones y notifica
+// (llamar con h.mu tomado)
+func (h *ClaudeAwareScreenHandler) commitState(state ClaudeState, now time.Time) {
+	old := h.stateInfo.State
+
+	switch state {
+	case StatePermissionPrompt:
+		h.stateInfo.PendingPermission = true
+	case StateToolRunning:
+		h.stateInfo.PendingPermission = false
+		h.stateInfo.PendingTool = ""
+	case StateGenerating:
+		h.stateInfo.IsGenerating = true
+		h.stateInfo.PendingPermission = false
+	case StateWaitingInput, StateExited:
+		h.stateInfo.IsGenerating = false
+		h.stateInfo.PendingPermission = false
+		h.stateInfo.PendingTool = ""
+	}
+
+	if old == state {
+		return
+	}
+
+	h.stateTotals[old] += now.Sub(h.enteredAt)
+	h.transitions++
+	h.enteredAt = now
+	h.stateInfo.State = state
+	h.stateInfo.StateChangedAt = now
+
+	if h.OnStateChange != nil {
+		go h.OnStateChange(old, state)
+	}
+}
+
+// fillDurations completa las duraciones de estado en info (llamar con h.mu tomado)
+func (h *ClaudeAwareScreenHandler) fillDurations(info *ClaudeStateInfo) {
+	now := h.now()
+	current := now.Sub(h.enteredAt)
+
+	info.StateDurationMs = current.Milliseconds()
+	info.StateTotalsMs = make(map[ClaudeState]int64, len(h.stateTotals)+1)
+	for state, d := range h.stateTotals {
+		info.StateTotalsMs[state] = d.Milliseconds()
+	}
+	info.StateTotalsMs[h.stateInfo.State] += current.Milliseconds()
+	info.Transitions = h.transitions
+	info.PendingState = h.candidate
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newClockedHandler crea un handler con reloj manual
+func newClockedHandler(t *testing.T) (*ClaudeAwareScreenHandler, func(time.Duration)) {
+	t.Helper()
+
+	now := time.Unix(1000, 0)
+	h := NewClaudeAwareScreenHandler(80, 24)
+	h.SetClock(func() time.Time { return now })
+
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		h.Tick()
+	}
+	return h, advance
+}
+
+func TestStateMachine_QuietPeriod(t *testing.T) {
+	h, advance := newClockedHandler(t)
+
+	h.Feed([]byte("> "))
+	advance(100 * time.Millisecond)
+	if got := h.GetClaudeState().State; got != StateUnknown {
+		t.Fatalf("state before quiet period = %s, want unknown", got)
+	}
+
+	advance(60 * time.Millisecond)
+	if got := h.GetClaudeState().State; got != StateWaitingInput {
+		t.Fatalf("state after quiet period = %s, want waiting_input", got)
+	}
+}
+
+func TestStateMachine_HysteresisAndDwell(t *testing.T) {
+	h, advance := newClockedHandler(t)
+
+	var changes int32
+	h.OnStateChange = func(old, new ClaudeState) { atomic.AddInt32(&changes, 1) }
+
+	h.Feed([]byte("> "))
+	advance(time.Second)
+
+	// El spinner debe mantenerse Confirm antes de transicionar
+	h.Feed([]byte("\r\n⠋ Thinking"))
+	advance(200 * time.Millisecond)
+	info := h.GetClaudeState()
+	if info.State != StateWaitingInput || info.PendingState != StateGenerating {
+		t.Fatalf("after first evaluation: state=%s pending=%s", info.State, info.PendingState)
+	}
+
+	advance(200 * time.Millisecond)
+	if got := h.GetClaudeState().State; got != StateGenerating {
+		t.Fatalf("state after confirm = %s, want generating", got)
+	}
+
+	// Un prompt fugaz entre frames del spinner no provoca transición
+	h.Feed([]byte("\r\x1b[2K> "))
+	advance(160 * time.Millisecond)
+	h.Feed([]byte("\r\x1b[2K⠙ Thinking"))
+	advance(160 * time.Millisecond)
+	advance(time.Second)
+
+	if got := h.GetClaudeState().State; got != StateGenerating {
+		t.Errorf("state after flicker = %s, want generating", got)
+	}
+
+	time.Sleep(20 * time.Millisecond) // callbacks en goroutines
+	if n := atomic.LoadInt32(&changes); n != 2 {
+		t.Errorf("OnStateChange calls = %d, want 2", n)
+	}
+}
+
+func TestStateMachine_UrgentStates(t *testing.T) {
+	h, advance := newClockedHandler(t)
+
+	h.Feed([]byte("⠋ Thinking"))
+	advance(200 * time.Millisecond)
+	if got := h.GetClaudeState().State; got != StateGenerating {
+		t.Fatalf("state = %s, want generating", got)
+	}
+
+	// Permiso: sin histéresis ni permanencia mínima
+	h.Feed([]byte("\r\nAllow Bash to run ls? [y/n]"))
+	advance(150 * time.Millisecond)
+
+	info := h.GetClaudeState()
+	if info.State != StatePermissionPrompt || info.PendingTool != "Bash" {
+		t.Errorf("state=%s pending_tool=%s, want permission_prompt/Bash", info.State, info.PendingTool)
+	}
+}
+
+func TestStateMachine_TransitionTable(t *testing.T) {
+	for from, targets := range stateTransitions {
+		for _, to := range targets {
+			if to == StateUnknown {
+				t.Errorf("transition %s -> unknown should not be allowed", from)
+			}
+			if from == to {
+				t.Errorf("self transition %s listed", from)
+			}
+		}
+	}
+
+	if canTransition(StateExited, StateWaitingInput) {
+		t.Error("exited should be terminal")
+	}
+
+	h := NewClaudeAwareScreenHandler(80, 24)
+	h.SetState(StateExited, "")
+	h.Feed([]byte("> "))
+	h.Settle()
+	if got := h.GetClaudeState().State; got != StateExited {
+		t.Errorf("state after exit = %s, want exited", got)
+	}
+}
+
+func TestStateMachine_Durations(t *testing.T) {
+	h, advance := newClockedHandler(t)
+
+	h.Feed([]byte("> "))
+	advance(150 * time.Millisecond) // unknown 150ms
+	advance(time.Second)            // waiting_input 1s
+
+	h.SetState(StateGenerating, "")
+	advance(500 * time.Millisecond)
+
+	info := h.GetClaudeState()
+	if info.StateDurationMs != 500 {
+		t.Errorf("StateDurationMs = %d, want 500", info.StateDurationMs)
+	}
+	if info.StateTotalsMs[StateWaitingInput] != 1000 || info.StateTotalsMs[StateUnknown] != 150 {
+		t.Errorf("StateTotalsMs = %v", info.StateTotalsMs)
+	}
+	if info.Transitions != 2 {
+		t.Errorf("Transitions = %d, want 2", info.Transitions)
+	}
+}
+
+func TestClassifyScreen_BottomUp(t *testing.T) {
+	display := []string{
+		"Running: Bash(ls)",
+		"total 8",
+		">",
+		"",
+		"",
+	}
+
+	result := classifyScreen(activeDetectionRules(), display, 12)
+	if result.State != StateWaitingInput {
+		t.Errorf("state = %s, want waiting_input (bottom line wins)", result.State)
+	}
+	if len(result.Matched) < 2 {
+		t.Errorf("expected matches from all scanned lines, got %v", patternNames(result.Matched))
+	}
+}
+
+func TestScreenState_UTF8Split(t *testing.T) {
+	s := NewScreenState(40, 5)
+
+	data := []byte("⠋ Pensando… ñ")
+	// Partir en medio de una runa multibyte
+	s.Feed(data[:2])
+	s.Feed(data[2:])
+
+	if got := s.GetDisplay()[0]; got != "⠋ Pensando… ñ" {
+		t.Errorf("display = %q", got)
+	}
+}
+
+func TestScreenState_UTF8InEscapeSequences(t *testing.T) {
+	s := NewScreenState(40, 5)
+	s.Feed([]byte("\x1b]0;✳ Claude\x07✻ Thinking… │ ok"))
+	if got := s.GetDisplay()[0]; got != "✻ Thinking… │ ok" {
+		t.Errorf("display after OSC title = %q", got)
+	}
+
+	// Título partido entre chunks, terminado con ST, y DCS con runas
+	s = NewScreenState(40, 5)
+	s.Feed([]byte("\x1b]0;✳ Cla"))
+	s.Feed([]byte("ude\x1b\\╭─╮\x1bPé\x1b\\│"))
+	if got := s.GetDisplay()[0]; got != "╭─╮│" {
+		t.Errorf("display after split OSC = %q", got)
+	}
+}
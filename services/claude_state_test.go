@@ -91,6 +91,7 @@ func TestClaudeAwareScreenHandler_DetectPermissionPrompt(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(tc.input))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			isPermission := state.State == StatePermissionPrompt
@@ -109,6 +110,7 @@ func TestClaudeAwareScreenHandler_DetectSpinner(t *testing.T) {
 		t.Run("spinner_"+char, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(char + " Loading..."))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			if state.State != StateGenerating {
@@ -132,6 +134,7 @@ func TestClaudeAwareScreenHandler_DetectPrompt(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(tc.input))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			if state.State != tc.want {
@@ -157,6 +160,7 @@ func TestClaudeAwareScreenHandler_DetectToolRunning(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(tc.input))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			if state.State != StateToolRunning {
@@ -169,6 +173,7 @@ func TestClaudeAwareScreenHandler_DetectToolRunning(t *testing.T) {
 func TestClaudeAwareScreenHandler_DetectError(t *testing.T) {
 	handler := NewClaudeAwareScreenHandler(80, 24)
 	handler.Feed([]byte("Error: file not found"))
+	handler.Settle()
 
 	state := handler.GetClaudeState()
 	if state.State != StateError {
@@ -192,6 +197,7 @@ func TestClaudeAwareScreenHandler_DetectVimMode(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(tc.input))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			if state.Mode != tc.wantMode {
@@ -220,6 +226,7 @@ func TestClaudeAwareScreenHandler_DetectSlashCommands(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			h := NewClaudeAwareScreenHandler(80, 24)
 			h.Feed([]byte(tc.input))
+			h.Settle()
 
 			state := h.GetClaudeState()
 			if state.LastSlashCommand != tc.wantCmd {
@@ -344,14 +351,24 @@ func TestClaudeAwareScreenHandler_BackgroundTasks(t *testing.T) {
 }
 
 func TestClaudeAwareScreenHandler_IsReadyForInput(t *testing.T) {
-	t.Skip("IsReadyForInput depends on full screen state detection which requires proper terminal emulation")
-	// This test requires the terminal emulation to properly position
-	// the prompt character at the start of a line for detection
+	handler := NewClaudeAwareScreenHandler(80, 24)
+	handler.Feed([]byte("⠋ Thinking..."))
+
+	if handler.IsReadyForInput() {
+		t.Error("IsReadyForInput should be false while generating")
+	}
+
+	handler.Feed([]byte("\r\x1b[2KDone.\r\n> "))
+
+	if !handler.IsReadyForInput() {
+		t.Errorf("IsReadyForInput should be true with prompt on screen, display: %q", handler.GetDisplay()[:3])
+	}
 }
 
 func TestClaudeAwareScreenHandler_HasPendingPermission(t *testing.T) {
 	handler := NewClaudeAwareScreenHandler(80, 24)
 	handler.Feed([]byte("Allow Edit to write file? [y/n]"))
+	handler.Settle()
 
 	if !handler.HasPendingPermission() {
 		t.Error("HasPendingPermission should return true")
@@ -361,6 +378,7 @@ func TestClaudeAwareScreenHandler_HasPendingPermission(t *testing.T) {
 func TestClaudeAwareScreenHandler_IsGenerating(t *testing.T) {
 	handler := NewClaudeAwareScreenHandler(80, 24)
 	handler.Feed([]byte("⠋ Thinking..."))
+	handler.Settle()
 
 	if !handler.IsGenerating() {
 		t.Error("IsGenerating should return true for spinner")
@@ -421,7 +439,7 @@ func TestKnownSlashCommands(t *testing.T) {
 
 func TestOutputPattern_Priority(t *testing.T) {
 	// Verify patterns have reasonable priorities
-	for _, p := range claudePatterns {
+	for _, p := range activeDetectionRules().patterns {
 		if p.Priority < 0 || p.Priority > 100 {
 			t.Errorf("Pattern %s has invalid priority: %d", p.Name, p.Priority)
 		}
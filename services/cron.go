@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Horizonte máximo de búsqueda de la próxima ejecución (ej: "0 0 30 2 *" nunca ocurre)
+const cronSearchYears = 5
+
+// cronDescriptors atajos equivalentes a una expresión de 5 campos
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@weekdays": "0 0 * * 1-5",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// CronSchedule expresión cron de 5 campos: minuto hora día-del-mes mes día-de-la-semana
+type CronSchedule struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool // día del mes sin restringir
+	dowStar bool // día de la semana sin restringir
+}
+
+// ParseCron interpreta una expresión cron estándar. Soporta listas (1,15),
+// rangos (1-5), pasos (*/10, 8-18/2), nombres (MON-FRI, JAN) y los atajos
+// @hourly, @daily, @weekly, @weekdays, @monthly y @yearly.
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expresion cron invalida %q: se esperan 5 campos", expr)
+	}
+
+	c := &CronSchedule{expr: strings.TrimSpace(expr)}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minuto: %v", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hora: %v", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("dia del mes: %v", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("mes: %v", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("dia de la semana: %v", err)
+	}
+
+	// 7 también es domingo
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return c, nil
+}
+
+// String retorna la expresión original
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+// parseCronField convierte un campo en un bitset de valores permitidos
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("paso invalido en %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(bounds[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseCronValue(rangePart, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/15" equivale a "5-max/15"; un valor suelto solo se incluye a sí mismo
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("valor fuera de rango en %q (%d-%d)", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCronValue convierte un número o un nombre (JAN, MON) en su valor
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("valor invalido %q", s)
+	}
+	return v, nil
+}
+
+// dayMatches aplica la regla clásica de cron: si día del mes y día de la semana
+// están restringidos basta con que coincida uno de los dos
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next retorna el primer instante posterior a after que cumple la expresión,
+// en la zona horaria de after. Retorna el tiempo cero si no hay ninguno.
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-monitor/pkg/logger"
+	"claude-monitor/pkg/patterns"
+)
+
+// Reglas por defecto embebidas en el binario (se usan si no hay archivo de reglas)
+//
+//go:embed detection_rules_default.json
+var defaultDetectionRulesJSON []byte
+
+// Intervalo por defecto para detectar cambios en el archivo de reglas
+const DefaultDetectionRulesReloadInterval = 5 * time.Second
+
+// DetectionRule regla de detección: si Pattern coincide, aplica State/Mode
+type DetectionRule struct {
+	Name        string      `json:"name"`
+	Pattern     string      `json:"pattern"`
+	Type        string      `json:"type"`
+	Priority    int         `json:"priority"`               // 0-100, gana el estado de mayor prioridad
+	State       ClaudeState `json:"state,omitempty"`        // estado resultante (vacío = solo informativo)
+	Mode        ClaudeMode  `json:"mode,omitempty"`         // modo resultante
+	VimSubMode  VimSubMode  `json:"vim_sub_mode,omitempty"` // submodo vim resultante
+	Description string      `json:"description,omitempty"`
+}
+
+// DetectionExtractors regex con un grupo de captura para extraer datos del output
+type DetectionExtractors struct {
+	PendingTool []string `json:"pending_tool,omitempty"` // herramienta que pide permiso
+	ToolName    []string `json:"tool_name,omitempty"`    // herramienta en ejecución
+}
+
+// DetectionRuleSet conjunto de reglas para un rango de versiones de Claude CLI.
+// ClaudeVersions son prefijos de versión ("1.0.", "2."); vacío = conjunto por defecto.
+type DetectionRuleSet struct {
+	Name           string              `json:"name"`
+	ClaudeVersions []string            `json:"claude_versions,omitempty"`
+	Rules          []DetectionRule     `json:"rules"`
+	Extractors     DetectionExtractors `json:"extractors"`
+}
+
+// DetectionRules archivo de reglas versionado
+type DetectionRules struct {
+	Version  int                `json:"version"`
+	RuleSets []DetectionRuleSet `json:"rule_sets"`
+}
+
+// DetectionRuleError error de validación de reglas
+type DetectionRuleError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// compiledDetectionRules reglas compiladas listas para usar en la detección
+type compiledDetectionRules struct {
+	version     int
+	ruleSet     string
+	patterns    []OutputPattern
+	pendingTool []*regexp.Regexp
+	toolName    []*regexp.Regexp
+}
+
+// Reglas activas (intercambiables en caliente)
+var activeRules atomic.Pointer[compiledDetectionRules]
+
+func init() {
+	rules, err := ParseDetectionRules(defaultDetectionRulesJSON)
+	if err != nil {
+		panic("detection rules: reglas por defecto inválidas: " + err.Error())
+	}
+	compiled, err := compileRuleSet(&rules.RuleSets[0], rules.Version)
+	if err != nil {
+		panic("detection rules: reglas por defecto inválidas: " + err.Error())
+	}
+	activeRules.Store(compiled)
+}
+
+// activeDetectionRules retorna las reglas actualmente activas
+func activeDetectionRules() *compiledDetectionRules {
+	return activeRules.Load()
+}
+
+// DefaultDetectionRules retorna una copia de las reglas embebidas
+func DefaultDetectionRules() *DetectionRules {
+	rules, _ := ParseDetectionRules(defaultDetectionRulesJSON)
+	return rules
+}
+
+// ParseDetectionRules parsea y valida un archivo de reglas
+func ParseDetectionRules(data []byte) (*DetectionRules, error) {
+	var rules DetectionRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("JSON invalido: %v", err)
+	}
+	if errs := rules.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("reglas invalidas: %s - %s", errs[0].Field, errs[0].Message)
+	}
+	return &rules, nil
+}
+
+// Validate valida todas las reglas y retorna la lista de errores
+func (r *DetectionRules) Validate() []DetectionRuleError {
+	var errs []DetectionRuleError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, DetectionRuleError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.Version <= 0 {
+		add("version", "debe ser positivo")
+	}
+	if len(r.RuleSets) == 0 {
+		add("rule_sets", "debe contener al menos un conjunto de reglas")
+	}
+
+	setNames := make(map[string]bool)
+	for i, set := range r.RuleSets {
+		prefix := fmt.Sprintf("rule_sets[%d]", i)
+		if set.Name == "" {
+			add(prefix+".name", "es requerido")
+		} else if setNames[set.Name] {
+			add(prefix+".name", "duplicado: %s", set.Name)
+		}
+		setNames[set.Name] = true
+
+		if len(set.Rules) == 0 {
+			add(prefix+".rules", "debe contener al menos una regla")
+		}
+
+		ruleNames := make(map[string]bool)
+		for j, rule := range set.Rules {
+			field := fmt.Sprintf("%s.rules[%d]", prefix, j)
+			if rule.Name == "" {
+				add(field+".name", "es requerido")
+			} else if ruleNames[rule.Name] {
+				add(field+".name", "duplicado: %s", rule.Name)
+			}
+			ruleNames[rule.Name] = true
+
+			if rule.Type == "" {
+				add(field+".type", "es requerido")
+			}
+			if rule.Priority < 0 || rule.Priority > 100 {
+				add(field+".priority", "debe estar entre 0 y 100")
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+				add(field+".pattern", "regex invalida: %q", rule.Pattern)
+			}
+			if rule.State != "" && !isValidClaudeState(rule.State) {
+				add(field+".state", "estado desconocido: %s", rule.State)
+			}
+			if rule.Mode != "" && !isValidClaudeMode(rule.Mode) {
+				add(field+".mode", "modo desconocido: %s", rule.Mode)
+			}
+			if rule.VimSubMode != "" && !isValidVimSubMode(rule.VimSubMode) {
+				add(field+".vim_sub_mode", "submodo desconocido: %s", rule.VimSubMode)
+			}
+		}
+
+		for name, list := range map[string][]string{
+			"pending_tool": set.Extractors.PendingTool,
+			"tool_name":    set.Extractors.ToolName,
+		} {
+			for j, pattern := range list {
+				re, err := regexp.Compile(pattern)
+				if err != nil || re.NumSubexp() < 1 {
+					add(fmt.Sprintf("%s.extractors.%s[%d]", prefix, name, j), "regex invalida o sin grupo de captura: %q", pattern)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// SelectRuleSet elige el conjunto de reglas para una versión de Claude CLI:
+// el primero cuyo prefijo coincida, si no el conjunto sin versiones, si no el primero.
+func (r *DetectionRules) SelectRuleSet(claudeVersion string) *DetectionRuleSet {
+	if claudeVersion != "" {
+		for i, set := range r.RuleSets {
+			for _, prefix := range set.ClaudeVersions {
+				if strings.HasPrefix(claudeVersion, prefix) {
+					return &r.RuleSets[i]
+				}
+			}
+		}
+	}
+	for i, set := range r.RuleSets {
+		if len(set.ClaudeVersions) == 0 {
+			return &r.RuleSets[i]
+		}
+	}
+	if len(r.RuleSets) > 0 {
+		return &r.RuleSets[0]
+	}
+	return nil
+}
+
+// compileRuleSet compila un conjunto de reglas usando el caché global de patrones
+func compileRuleSet(set *DetectionRuleSet, version int) (*compiledDetectionRules, error) {
+	cache := patterns.GetGlobal()
+	compiled := &compiledDetectionRules{
+		version:  version,
+		ruleSet:  set.Name,
+		patterns: make([]OutputPattern, 0, len(set.Rules)),
+	}
+
+	for _, rule := range set.Rules {
+		re, err := cache.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regla %s: %v", rule.Name, err)
+		}
+		compiled.patterns = append(compiled.patterns, OutputPattern{
+			Name:        rule.Name,
+			Pattern:     re,
+			Type:        rule.Type,
+			Priority:    rule.Priority,
+			State:       rule.State,
+			Mode:        rule.Mode,
+			VimSubMode:  rule.VimSubMode,
+			Description: rule.Description,
+		})
+	}
+
+	for _, p := range set.Extractors.PendingTool {
+		re, err := cache.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled.pendingTool = append(compiled.pendingTool, re)
+	}
+	for _, p := range set.Extractors.ToolName {
+		re, err := cache.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled.toolName = append(compiled.toolName, re)
+	}
+
+	return compiled, nil
+}
+
+// extractFirst aplica la primera regex de la lista que coincida y retorna el grupo 1
+func extractFirst(list []*regexp.Regexp, content string) string {
+	for _, re := range list {
+		if m := re.FindStringSubmatch(content); len(m) > 1 {
+			return strings.TrimSpace(m[1])
+		}
+	}
+	return ""
+}
+
+func isValidClaudeState(s ClaudeState) bool {
+	switch s {
+	case StateUnknown, StateWaitingInput, StateGenerating, StatePermissionPrompt,
+		StateToolRunning, StateBackgroundTask, StateError, StateExited:
+		return true
+	}
+	return false
+}
+
+func isValidClaudeMode(m ClaudeMode) bool {
+	switch m {
+	case ModeNormal, ModeVim, ModePlan, ModeCompact:
+		return true
+	}
+	return false
+}
+
+func isValidVimSubMode(m VimSubMode) bool {
+	switch m {
+	case VimInsert, VimNormal, VimVisual, VimCommand:
+		return true
+	}
+	return false
+}
+
+// DetectionSampleResult resultado de evaluar un texto de prueba contra las reglas
+type DetectionSampleResult struct {
+	Sample     string      `json:"sample"`
+	Matched    []string    `json:"matched"`
+	State      ClaudeState `json:"state,omitempty"`
+	Mode       ClaudeMode  `json:"mode,omitempty"`
+	VimSubMode VimSubMode  `json:"vim_sub_mode,omitempty"`
+}
+
+// TestDetectionRules evalúa textos de prueba (pantallas, líneas separadas por \n)
+// contra el conjunto elegido para claudeVersion
+func TestDetectionRules(rules *DetectionRules, claudeVersion string, samples []string) ([]DetectionSampleResult, error) {
+	set := rules.SelectRuleSet(claudeVersion)
+	if set == nil {
+		return nil, fmt.Errorf("sin conjuntos de reglas")
+	}
+	compiled, err := compileRuleSet(set, rules.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cada ejemplo se clasifica como una pantalla (igual que la máquina de estados)
+	scanLines := DefaultStateMachineConfig().ScanLines
+	results := make([]DetectionSampleResult, 0, len(samples))
+	for _, sample := range samples {
+		c := classifyScreen(compiled, strings.Split(sample, "\n"), scanLines)
+		result := DetectionSampleResult{
+			Sample:     sample,
+			Matched:    []string{},
+			State:      c.State,
+			Mode:       c.Mode,
+			VimSubMode: c.VimSubMode,
+		}
+		result.Matched = append(result.Matched, patternNames(c.Matched)...)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DetectClaudeVersion obtiene la versión del CLI de Claude ("claude --version")
+func DetectClaudeVersion() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "claude", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return parseClaudeVersion(string(out))
+}
+
+var claudeVersionRe = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+// parseClaudeVersion extrae "1.2.3" de la salida de "claude --version"
+func parseClaudeVersion(output string) string {
+	return claudeVersionRe.FindString(output)
+}
+
+// DetectionRulesStatus estado de las reglas cargadas
+type DetectionRulesStatus struct {
+	Path          string          `json:"path"`
+	Source        string          `json:"source"` // "file" o "default"
+	Version       int             `json:"version"`
+	ActiveRuleSet string          `json:"active_rule_set"`
+	ClaudeVersion string          `json:"claude_version,omitempty"`
+	LoadedAt      time.Time       `json:"loaded_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	Rules         *DetectionRules `json:"rules"`
+}
+
+// DetectionRulesService carga las reglas desde archivo y las recarga en caliente
+type DetectionRulesService struct {
+	path          string
+	claudeVersion string
+
+	mu        sync.RWMutex
+	rules     *DetectionRules
+	source    string
+	modTime   time.Time
+	loadedAt  time.Time
+	lastError string
+
+	stopCh chan struct{}
+}
+
+// NewDetectionRulesService crea el servicio y carga las reglas (archivo o por defecto)
+func NewDetectionRulesService(path, claudeVersion string) *DetectionRulesService {
+	s := &DetectionRulesService{
+		path:          path,
+		claudeVersion: claudeVersion,
+		stopCh:        make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		logger.Warn("Error cargando reglas de detección, usando las embebidas", "path", path, "error", err)
+	}
+	return s
+}
+
+// Reload recarga las reglas desde el archivo. Si el archivo no existe se usan las
+// reglas embebidas; si es inválido se mantienen las reglas activas.
+func (s *DetectionRulesService) Reload() error {
+	rules := DefaultDetectionRules()
+	source := "default"
+	var modTime time.Time
+
+	if info, err := os.Stat(s.path); err == nil {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return s.setError(err)
+		}
+		parsed, err := ParseDetectionRules(data)
+		if err != nil {
+			return s.setError(err)
+		}
+		rules = parsed
+		source = "file"
+		modTime = info.ModTime()
+	}
+
+	return s.apply(rules, source, modTime)
+}
+
+// Save valida, guarda a disco y activa nuevas reglas
+func (s *DetectionRulesService) Save(rules *DetectionRules) error {
+	if errs := rules.Validate(); len(errs) > 0 {
+		return fmt.Errorf("reglas invalidas: %s - %s", errs[0].Field, errs[0].Message)
+	}
+
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+
+	var modTime time.Time
+	if info, err := os.Stat(s.path); err == nil {
+		modTime = info.ModTime()
+	}
+	return s.apply(rules, "file", modTime)
+}
+
+// apply compila el conjunto adecuado y lo activa globalmente
+func (s *DetectionRulesService) apply(rules *DetectionRules, source string, modTime time.Time) error {
+	set := rules.SelectRuleSet(s.claudeVersion)
+	if set == nil {
+		return s.setError(fmt.Errorf("sin conjuntos de reglas"))
+	}
+
+	compiled, err := compileRuleSet(set, rules.Version)
+	if err != nil {
+		return s.setError(err)
+	}
+
+	activeRules.Store(compiled)
+
+	s.mu.Lock()
+	s.rules = rules
+	s.source = source
+	s.modTime = modTime
+	s.loadedAt = time.Now()
+	s.lastError = ""
+	s.mu.Unlock()
+
+	logger.Info("Reglas de detección cargadas",
+		"source", source,
+		"version", rules.Version,
+		"rule_set", set.Name,
+		"rules", len(set.Rules),
+		"claude_version", s.claudeVersion,
+	)
+	return nil
+}
+
+// setError registra el último error de carga
+func (s *DetectionRulesService) setError(err error) error {
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.mu.Unlock()
+	return err
+}
+
+// Status retorna el estado de las reglas cargadas
+func (s *DetectionRulesService) Status() DetectionRulesStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := DetectionRulesStatus{
+		Path:          s.path,
+		Source:        s.source,
+		ClaudeVersion: s.claudeVersion,
+		LoadedAt:      s.loadedAt,
+		LastError:     s.lastError,
+		Rules:         s.rules,
+	}
+	if active := activeDetectionRules(); active != nil {
+		status.Version = active.version
+		status.ActiveRuleSet = active.ruleSet
+	}
+	return status
+}
+
+// ClaudeVersion retorna la versión de Claude CLI usada para elegir reglas
+func (s *DetectionRulesService) ClaudeVersion() string {
+	return s.claudeVersion
+}
+
+// Watch recarga las reglas cuando cambia la fecha de modificación del archivo.
+// Si el archivo se elimina se vuelve a las reglas embebidas.
+func (s *DetectionRulesService) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				s.mu.RLock()
+				fromFile := s.source == "file"
+				s.mu.RUnlock()
+				if errors.Is(err, os.ErrNotExist) && fromFile {
+					logger.Warn("Archivo de reglas de detección eliminado, usando las embebidas", "path", s.path)
+					if err := s.Reload(); err != nil {
+						logger.Warn("Error cargando reglas de detección embebidas", "error", err)
+					}
+				}
+				continue
+			}
+			s.mu.RLock()
+			changed := !info.ModTime().Equal(s.modTime)
+			s.mu.RUnlock()
+
+			if changed {
+				if err := s.Reload(); err != nil {
+					logger.Warn("Error recargando reglas de detección", "path", s.path, "error", err)
+					// Evitar reintentar el mismo archivo inválido en cada tick
+					s.mu.Lock()
+					s.modTime = info.ModTime()
+					s.mu.Unlock()
+				}
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Stop detiene el watcher
+func (s *DetectionRulesService) Stop() {
+	close(s.stopCh)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// restoreDefaultRules reactiva las reglas embebidas al terminar el test
+func restoreDefaultRules(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		rules := DefaultDetectionRules()
+		compiled, err := compileRuleSet(&rules.RuleSets[0], rules.Version)
+		if err != nil {
+			t.Fatalf("compileRuleSet error: %v", err)
+		}
+		activeRules.Store(compiled)
+	})
+}
+
+func TestDefaultDetectionRules_Valid(t *testing.T) {
+	rules := DefaultDetectionRules()
+	if rules == nil {
+		t.Fatal("default rules failed to parse")
+	}
+	if errs := rules.Validate(); len(errs) > 0 {
+		t.Fatalf("default rules invalid: %+v", errs)
+	}
+	if len(activeDetectionRules().patterns) < 25 {
+		t.Errorf("expected at least 25 default patterns, got %d", len(activeDetectionRules().patterns))
+	}
+}
+
+func TestDetectionRules_ValidateErrors(t *testing.T) {
+	rules := &DetectionRules{
+		Version: 1,
+		RuleSets: []DetectionRuleSet{{
+			Name: "bad",
+			Rules: []DetectionRule{
+				{Name: "a", Pattern: "(", Type: "x", Priority: 10},
+				{Name: "a", Pattern: "ok", Type: "x", Priority: 200},
+				{Name: "b", Pattern: "ok", Type: "x", State: "flying"},
+			},
+			Extractors: DetectionExtractors{ToolName: []string{`no-group`}},
+		}},
+	}
+
+	errs := rules.Validate()
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+
+	for _, want := range []string{
+		"rule_sets[0].rules[0].pattern",
+		"rule_sets[0].rules[1].name",
+		"rule_sets[0].rules[1].priority",
+		"rule_sets[0].rules[2].state",
+		"rule_sets[0].extractors.tool_name[0]",
+	} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %+v", want, errs)
+		}
+	}
+}
+
+func TestDetectionRules_SelectRuleSet(t *testing.T) {
+	rules := &DetectionRules{
+		Version: 1,
+		RuleSets: []DetectionRuleSet{
+			{Name: "v2", ClaudeVersions: []string{"2."}},
+			{Name: "default"},
+		},
+	}
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"2.0.14", "v2"},
+		{"1.0.90", "default"},
+		{"", "default"},
+	}
+
+	for _, tc := range tests {
+		if got := rules.SelectRuleSet(tc.version); got == nil || got.Name != tc.want {
+			t.Errorf("SelectRuleSet(%q) = %v, want %s", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestTestDetectionRules(t *testing.T) {
+	results, err := TestDetectionRules(DefaultDetectionRules(), "", []string{
+		"Allow Bash to run ls? [y/n]",
+		"Running: Bash",
+		"⠋ Thinking",
+		"-- INSERT --",
+	})
+	if err != nil {
+		t.Fatalf("TestDetectionRules error: %v", err)
+	}
+
+	want := []ClaudeState{StatePermissionPrompt, StateToolRunning, StateGenerating, ""}
+	for i, r := range results {
+		if r.State != want[i] {
+			t.Errorf("sample %q: state = %q, want %q", r.Sample, r.State, want[i])
+		}
+	}
+	if results[3].Mode != ModeVim || results[3].VimSubMode != VimInsert {
+		t.Errorf("expected vim insert mode, got %q/%q", results[3].Mode, results[3].VimSubMode)
+	}
+}
+
+func TestDetectionRulesService_ReloadAndSave(t *testing.T) {
+	restoreDefaultRules(t)
+
+	path := filepath.Join(t.TempDir(), "detection_rules.json")
+	s := NewDetectionRulesService(path, "2.0.1")
+
+	if st := s.Status(); st.Source != "default" || st.ActiveRuleSet != "default" {
+		t.Fatalf("expected embedded defaults, got %+v", st)
+	}
+
+	custom := &DetectionRules{
+		Version: 2,
+		RuleSets: []DetectionRuleSet{
+			DefaultDetectionRules().RuleSets[0],
+			{
+				Name:           "v2",
+				ClaudeVersions: []string{"2."},
+				Rules: []DetectionRule{
+					{Name: "custom_permission", Pattern: `Do you want to proceed\?`, Type: "permission", Priority: 100, State: StatePermissionPrompt},
+				},
+			},
+		},
+	}
+	if err := s.Save(custom); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	st := s.Status()
+	if st.Source != "file" || st.ActiveRuleSet != "v2" || st.Version != 2 {
+		t.Fatalf("unexpected status after save: %+v", st)
+	}
+
+	handler := NewClaudeAwareScreenHandler(80, 24)
+	handler.Feed([]byte("Do you want to proceed?"))
+	handler.Settle()
+	if state := handler.GetClaudeState().State; state != StatePermissionPrompt {
+		t.Errorf("expected custom rule to detect permission, got %s", state)
+	}
+
+	// Un archivo inválido no reemplaza las reglas activas
+	if err := os.WriteFile(path, []byte(`{"version":1,"rule_sets":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err == nil {
+		t.Fatal("expected reload error for invalid file")
+	}
+	st = s.Status()
+	if st.ActiveRuleSet != "v2" || st.LastError == "" {
+		t.Errorf("expected previous rules kept with error, got %+v", st)
+	}
+}
+
+func TestDetectionRulesService_Watch(t *testing.T) {
+	restoreDefaultRules(t)
+
+	path := filepath.Join(t.TempDir(), "detection_rules.json")
+	s := NewDetectionRulesService(path, "")
+	go s.Watch(10 * time.Millisecond)
+	defer s.Stop()
+
+	rules := DefaultDetectionRules()
+	rules.Version = 7
+	rules.RuleSets[0].Name = "hot"
+	data, _ := json.Marshal(rules)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := s.Status(); st.ActiveRuleSet == "hot" && st.Version == 7 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("rules not reloaded, status: %+v", s.Status())
+}
+
+func TestDetectionRulesService_WatchFileRemoved(t *testing.T) {
+	restoreDefaultRules(t)
+
+	path := filepath.Join(t.TempDir(), "detection_rules.json")
+	rules := DefaultDetectionRules()
+	rules.Version = 7
+	rules.RuleSets[0].Name = "hot"
+	data, _ := json.Marshal(rules)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewDetectionRulesService(path, "")
+	if st := s.Status(); st.Source != "file" || st.ActiveRuleSet != "hot" {
+		t.Fatalf("expected rules from file, got %+v", st)
+	}
+	go s.Watch(10 * time.Millisecond)
+	defer s.Stop()
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := s.Status(); st.Source == "default" && st.ActiveRuleSet != "hot" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("embedded rules not restored after removing the file, status: %+v", s.Status())
+}
+
+func TestParseClaudeVersion(t *testing.T) {
+	if got := parseClaudeVersion("2.0.14 (Claude Code)\n"); got != "2.0.14" {
+		t.Errorf("parseClaudeVersion = %q", got)
+	}
+	if got := parseClaudeVersion("unknown"); got != "" {
+		t.Errorf("expected empty version, got %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// EventType tipo de evento del bus
+type EventType string
+
+const (
+	EventTerminalCreated   EventType = "terminal.created"
+	EventSessionUpdated    EventType = "session.updated"
+	EventClaudeState       EventType = "claude.state"
+	EventClaudePermission  EventType = "claude.permission"
+	EventTerminalEnded     EventType = "terminal.ended"
+	EventWatchdogStuck     EventType = "watchdog.stuck"
+	EventWatchdogIdle      EventType = "watchdog.idle"
+	EventWatchdogInterrupt EventType = "watchdog.interrupt"
+	EventWatchdogResolved  EventType = "watchdog.resolved"
+	EventJobQueued         EventType = "job.queued"
+	EventJobStarted        EventType = "job.started"
+	EventJobFinished       EventType = "job.finished"
+	EventScheduleRun       EventType = "schedule.run"
+	EventTerminalLimit     EventType = "terminal.limit_exceeded"
+	EventFileChanged       EventType = "file.changed"
+)
+
+// KnownEventTypes tipos de evento que publica el servidor
+var KnownEventTypes = []EventType{
+	EventTerminalCreated,
+	EventTerminalEnded,
+	EventTerminalLimit,
+	EventSessionUpdated,
+	EventClaudeState,
+	EventClaudePermission,
+	EventWatchdogStuck,
+	EventWatchdogIdle,
+	EventWatchdogInterrupt,
+	EventWatchdogResolved,
+	EventJobQueued,
+	EventJobStarted,
+	EventJobFinished,
+	EventScheduleRun,
+	EventFileChanged,
+}
+
+// IsKnownEventType verifica si un tipo de evento existe
+func IsKnownEventType(t EventType) bool {
+	for _, known := range KnownEventTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Tamaño por defecto del buffer de cada suscriptor
+const defaultEventBufferSize = 64
+
+// Event evento publicado en el bus
+type Event struct {
+	ID         string      `json:"id"`
+	Type       EventType   `json:"type"`
+	TerminalID string      `json:"terminal_id,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// EventFilter filtro de suscripción (campos vacíos = todos)
+type EventFilter struct {
+	Types      []EventType
+	TerminalID string
+}
+
+// matches verifica si un evento pasa el filtro
+func (f EventFilter) matches(e Event) bool {
+	if f.TerminalID != "" && f.TerminalID != e.TerminalID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSubscription suscriptor del bus
+type eventSubscription struct {
+	ch     chan Event
+	filter EventFilter
+}
+
+// EventBus distribuye eventos del servidor a múltiples suscriptores.
+// La entrega no bloquea: si el buffer de un suscriptor está lleno el evento se descarta para él.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[*eventSubscription]struct{}
+	closed bool
+}
+
+// NewEventBus crea un nuevo bus de eventos
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*eventSubscription]struct{}),
+	}
+}
+
+// Publish publica un evento. Es seguro llamarlo sobre un bus nil.
+func (b *EventBus) Publish(eventType EventType, terminalID string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		ID:         generateUUID(),
+		Type:       eventType,
+		TerminalID: terminalID,
+		Timestamp:  time.Now(),
+		Data:       data,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			logger.Debug("Evento descartado, suscriptor lento", "type", eventType, "terminal_id", terminalID)
+		}
+	}
+}
+
+// Subscribe registra un suscriptor y retorna su canal y la función para cancelarlo
+func (b *EventBus) Subscribe(filter EventFilter) (<-chan Event, func()) {
+	sub := &eventSubscription{
+		ch:     make(chan Event, defaultEventBufferSize),
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, ok := b.subs[sub]; ok {
+				delete(b.subs, sub)
+				close(sub.ch)
+			}
+			b.mu.Unlock()
+		})
+	}
+	return sub.ch, cancel
+}
+
+// SubscriberCount retorna el número de suscriptores activos
+func (b *EventBus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close cierra el bus y los canales de todos los suscriptores
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Operaciones de FileChange
+const (
+	FileOpCreate = "create"
+	FileOpModify = "modify"
+	FileOpDelete = "delete"
+)
+
+// Máximo de archivos distintos en el resumen de cambios de una terminal
+const maxChangedFiles = 10000
+
+// WatchConfig configuración de la observación de cambios en el WorkDir de las terminales
+type WatchConfig struct {
+	Enabled    bool `json:"enabled"`     // observar todas las terminales (si no, solo las creadas con watch_files)
+	MaxDirs    int  `json:"max_dirs"`    // directorios observados por terminal
+	LogSize    int  `json:"log_size"`    // eventos conservados por terminal
+	DebounceMs int  `json:"debounce_ms"` // ventana en la que se agrupan los eventos antes de emitirlos
+}
+
+// DefaultWatchConfig configuración por defecto (solo terminales con watch_files)
+func DefaultWatchConfig() WatchConfig {
+	return WatchConfig{
+		MaxDirs:    4096,
+		LogSize:    1000,
+		DebounceMs: 250,
+	}
+}
+
+// FileChange cambio de un archivo dentro del WorkDir
+type FileChange struct {
+	Path  string    `json:"path"` // relativo al WorkDir
+	Op    string    `json:"op"`   // create, modify, delete
+	IsDir bool      `json:"is_dir,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// ChangedFile resumen de los cambios de un archivo
+type ChangedFile struct {
+	Path    string    `json:"path"`
+	LastOp  string    `json:"last_op"`
+	Changes int       `json:"changes"`
+	FirstAt time.Time `json:"first_at"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+// FileChangeLog registro de cambios de archivos de una terminal
+type FileChangeLog struct {
+	TerminalID string        `json:"terminal_id"`
+	WorkDir    string        `json:"work_dir"`
+	Watching   bool          `json:"watching"`
+	Incomplete string        `json:"incomplete,omitempty"` // motivo si se perdieron eventos (max_dirs, overflow)
+	Changes    []FileChange  `json:"changes"`              // más recientes al final
+	Files      []ChangedFile `json:"files"`                // ordenados por último cambio
+}
+
+// FileChangeBatch dato del evento file.changed
+type FileChangeBatch struct {
+	WorkDir string       `json:"work_dir"`
+	Changes []FileChange `json:"changes"`
+}
+
+// FileChangesMessage mensaje enviado a los clientes WebSocket de la terminal
+type FileChangesMessage struct {
+	Type      string       `json:"type"` // "fs:changes"
+	WorkDir   string       `json:"work_dir"`
+	Changes   []FileChange `json:"changes"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// dirWatcher observación recursiva de un directorio (inotify en Linux)
+type dirWatcher interface {
+	Close() error
+}
+
+// fileWatch observación y registro de cambios del WorkDir de una terminal
+type fileWatch struct {
+	id      string
+	root    string
+	cfg     WatchConfig
+	ignore  *gitignore
+	prefix  string // WorkDir relativo a la raíz del repositorio
+	onBatch func(batch []FileChange)
+
+	mu         sync.Mutex
+	watcher    dirWatcher
+	log        []FileChange
+	files      map[string]*ChangedFile
+	pending    []FileChange
+	timer      *time.Timer
+	incomplete string
+}
+
+// newFileWatch crea el registro de cambios de una terminal (sin iniciar la observación)
+func newFileWatch(id, root string, cfg WatchConfig, onBatch func([]FileChange)) *fileWatch {
+	ignore, prefix := newGitignore(root)
+	return &fileWatch{
+		id:      id,
+		root:    root,
+		cfg:     cfg,
+		ignore:  ignore,
+		prefix:  prefix,
+		onBatch: onBatch,
+		files:   make(map[string]*ChangedFile),
+	}
+}
+
+// start inicia la observación del WorkDir
+func (fw *fileWatch) start() error {
+	w, err := newDirWatcher(fw.root, fw.cfg.MaxDirs, fw.skipDir, fw.record, fw.limitReached)
+	if err != nil {
+		return err
+	}
+	fw.mu.Lock()
+	fw.watcher = w
+	fw.mu.Unlock()
+	return nil
+}
+
+// stop detiene la observación y emite los eventos pendientes. El registro se conserva.
+func (fw *fileWatch) stop() {
+	fw.mu.Lock()
+	w := fw.watcher
+	fw.watcher = nil
+	fw.mu.Unlock()
+	if w != nil {
+		w.Close()
+	}
+	fw.flush()
+}
+
+// repoPath ruta relativa a la raíz del repositorio
+func (fw *fileWatch) repoPath(rel string) string {
+	if fw.prefix == "" {
+		return rel
+	}
+	return fw.prefix + "/" + rel
+}
+
+// skipDir indica si un directorio no se observa (.git o ignorado por .gitignore).
+// Carga su .gitignore para los directorios que sí se observan.
+func (fw *fileWatch) skipDir(rel string) bool {
+	if rel == "." || rel == "" {
+		return false
+	}
+	if path.Base(rel) == ".git" || fw.ignore.ignored(fw.repoPath(rel), true) {
+		return true
+	}
+	fw.ignore.load(fw.repoPath(rel))
+	return false
+}
+
+// limitReached registra que el registro dejó de estar completo
+func (fw *fileWatch) limitReached(reason string) {
+	fw.mu.Lock()
+	fw.incomplete = reason
+	fw.mu.Unlock()
+	logger.Warn("Observación de archivos incompleta", "terminal_id", fw.id, "reason", reason)
+}
+
+// record registra un cambio (rel relativo al WorkDir, con "/")
+func (fw *fileWatch) record(rel, op string, isDir bool) {
+	base := path.Base(rel)
+	if base == ".git" || fw.ignore.ignored(fw.repoPath(rel), isDir) {
+		return
+	}
+	if base == ".gitignore" && !isDir {
+		fw.ignore.load(fw.repoPath(path.Dir(rel)))
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	change := FileChange{Path: rel, Op: op, IsDir: isDir, Time: time.Now()}
+
+	// Agrupar con un cambio pendiente del mismo archivo
+	for i := range fw.pending {
+		prev := &fw.pending[i]
+		if prev.Path != rel {
+			continue
+		}
+		switch {
+		case prev.Op == FileOpCreate && op == FileOpDelete:
+			// Archivo temporal: no se reporta
+			fw.pending = append(fw.pending[:i], fw.pending[i+1:]...)
+			return
+		case prev.Op == FileOpCreate:
+			prev.Time = change.Time
+			return
+		case prev.Op == FileOpDelete && op == FileOpCreate:
+			prev.Op = FileOpModify // reemplazo atómico (escritura + rename)
+			prev.Time = change.Time
+			return
+		default:
+			prev.Op = op
+			prev.Time = change.Time
+			return
+		}
+	}
+
+	fw.pending = append(fw.pending, change)
+	if fw.timer == nil {
+		fw.timer = time.AfterFunc(time.Duration(fw.cfg.DebounceMs)*time.Millisecond, fw.flush)
+	}
+}
+
+// flush agrega los cambios pendientes al registro y los emite
+func (fw *fileWatch) flush() {
+	fw.mu.Lock()
+	batch := fw.pending
+	fw.pending = nil
+	if fw.timer != nil {
+		fw.timer.Stop()
+		fw.timer = nil
+	}
+
+	for _, c := range batch {
+		fw.log = append(fw.log, c)
+		if c.IsDir {
+			continue
+		}
+		f, ok := fw.files[c.Path]
+		if !ok {
+			if len(fw.files) >= maxChangedFiles {
+				continue
+			}
+			f = &ChangedFile{Path: c.Path, FirstAt: c.Time}
+			fw.files[c.Path] = f
+		}
+		f.LastOp = c.Op
+		f.Changes++
+		f.LastAt = c.Time
+	}
+	if over := len(fw.log) - fw.cfg.LogSize; over > 0 {
+		fw.log = append([]FileChange(nil), fw.log[over:]...)
+	}
+	fw.mu.Unlock()
+
+	if len(batch) > 0 && fw.onBatch != nil {
+		fw.onBatch(batch)
+	}
+}
+
+// snapshot retorna el registro desde since (cero = todo), limitado a los últimos limit eventos
+func (fw *fileWatch) snapshot(since time.Time, limit int) *FileChangeLog {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	result := &FileChangeLog{
+		TerminalID: fw.id,
+		WorkDir:    fw.root,
+		Watching:   fw.watcher != nil,
+		Incomplete: fw.incomplete,
+		Changes:    make([]FileChange, 0),
+		Files:      make([]ChangedFile, 0, len(fw.files)),
+	}
+	for _, c := range fw.log {
+		if c.Time.After(since) {
+			result.Changes = append(result.Changes, c)
+		}
+	}
+	if limit > 0 && len(result.Changes) > limit {
+		result.Changes = result.Changes[len(result.Changes)-limit:]
+	}
+	for _, f := range fw.files {
+		if f.LastAt.After(since) {
+			result.Files = append(result.Files, *f)
+		}
+	}
+	sort.Slice(result.Files, func(i, j int) bool {
+		return result.Files[i].LastAt.After(result.Files[j].LastAt)
+	})
+	return result
+}
+
+// SetFileWatch configura la observación de cambios en el WorkDir de las terminales
+func (s *TerminalService) SetFileWatch(cfg WatchConfig) {
+	def := DefaultWatchConfig()
+	if cfg.MaxDirs <= 0 {
+		cfg.MaxDirs = def.MaxDirs
+	}
+	if cfg.LogSize <= 0 {
+		cfg.LogSize = def.LogSize
+	}
+	if cfg.DebounceMs <= 0 {
+		cfg.DebounceMs = def.DebounceMs
+	}
+	s.watch = cfg
+}
+
+// startFileWatch inicia la observación del WorkDir de una terminal si está
+// habilitada. Al reanudar una terminal se sigue usando su registro anterior.
+func (s *TerminalService) startFileWatch(t Terminal, cfg TerminalConfig) {
+	if !cfg.WatchFiles && !s.watch.Enabled {
+		return
+	}
+	id := t.GetID()
+
+	s.watchesMu.Lock()
+	fw, ok := s.watches[id]
+	if !ok || fw.root != cfg.WorkDir {
+		fw = newFileWatch(id, cfg.WorkDir, s.watch, func(batch []FileChange) {
+			s.publishFileChanges(id, cfg.WorkDir, batch)
+		})
+		s.watches[id] = fw
+	}
+	s.watchesMu.Unlock()
+
+	if err := fw.start(); err != nil {
+		logger.Warn("No se pudo observar el directorio de la terminal", "terminal_id", id, "work_dir", cfg.WorkDir, "error", err)
+	}
+}
+
+// stopFileWatch detiene la observación de una terminal; remove descarta su registro
+func (s *TerminalService) stopFileWatch(id string, remove bool) {
+	s.watchesMu.Lock()
+	fw, ok := s.watches[id]
+	if ok && remove {
+		delete(s.watches, id)
+	}
+	s.watchesMu.Unlock()
+	if ok {
+		fw.stop()
+	}
+}
+
+// publishFileChanges envía un lote de cambios a los clientes de la terminal y al bus
+func (s *TerminalService) publishFileChanges(id, workDir string, batch []FileChange) {
+	s.mu.RLock()
+	t, ok := s.terminals[id]
+	s.mu.RUnlock()
+	if ok {
+		t.BroadcastJSON(FileChangesMessage{
+			Type:      "fs:changes",
+			WorkDir:   workDir,
+			Changes:   batch,
+			Timestamp: time.Now(),
+		})
+	}
+	s.events.Publish(EventFileChanged, id, FileChangeBatch{WorkDir: workDir, Changes: batch})
+}
+
+// FileChanges registro de cambios de archivos de una terminal
+func (s *TerminalService) FileChanges(id string, since time.Time, limit int) (*FileChangeLog, error) {
+	s.watchesMu.Lock()
+	fw, ok := s.watches[id]
+	s.watchesMu.Unlock()
+	if ok {
+		return fw.snapshot(since, limit), nil
+	}
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChangeLog{
+		TerminalID: id,
+		WorkDir:    info.WorkDir,
+		Changes:    make([]FileChange, 0),
+		Files:      make([]ChangedFile, 0),
+	}, nil
+}
+
+// relSlash ruta relativa a root con separador "/"
+func relSlash(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
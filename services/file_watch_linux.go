@@ -0,0 +1,212 @@
+//go:build linux
+
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Eventos inotify observados en cada directorio. IN_CLOSE_WRITE en lugar de
+// IN_MODIFY para reportar una vez por escritura completa.
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// inotifyWatcher observa recursivamente un directorio con inotify
+type inotifyWatcher struct {
+	file    *os.File // fd de inotify no bloqueante (integrado con el poller de Go)
+	fd      int
+	root    string
+	maxDirs int
+	skipDir func(rel string) bool
+	emit    func(rel, op string, isDir bool)
+	onLimit func(reason string)
+
+	mu      sync.Mutex
+	wds     map[int]string // watch descriptor -> directorio relativo ("." = raíz)
+	dirs    map[string]int
+	limited bool
+}
+
+// newDirWatcher inicia la observación de root y sus subdirectorios no ignorados
+func newDirWatcher(root string, maxDirs int, skipDir func(string) bool, emit func(string, string, bool), onLimit func(string)) (dirWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		root:    root,
+		maxDirs: maxDirs,
+		skipDir: skipDir,
+		emit:    emit,
+		onLimit: onLimit,
+		wds:     make(map[int]string),
+		dirs:    make(map[string]int),
+	}
+	if err := w.addTree(".", false); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	go w.readLoop()
+	return w, nil
+}
+
+// Close detiene la observación
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+// addTree observa dir y sus subdirectorios. Con emitExisting reporta como
+// creado el contenido encontrado (directorios creados con archivos dentro
+// antes de poder observarlos).
+func (w *inotifyWatcher) addTree(dir string, emitExisting bool) error {
+	return filepath.WalkDir(filepath.Join(w.root, dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == filepath.Join(w.root, dir) {
+				return err
+			}
+			return nil
+		}
+		rel := relSlash(w.root, p)
+		if !d.IsDir() {
+			if emitExisting {
+				w.emit(rel, FileOpCreate, false)
+			}
+			return nil
+		}
+		if rel != dir && rel != "." && w.skipDir(rel) {
+			return filepath.SkipDir
+		}
+		if emitExisting && rel != dir {
+			w.emit(rel, FileOpCreate, true)
+		}
+		if rel == dir && rel != "." && w.skipDir(rel) {
+			return filepath.SkipDir
+		}
+		return w.addWatch(rel)
+	})
+}
+
+// addWatch observa un directorio. Al llegar a maxDirs deja de añadir.
+func (w *inotifyWatcher) addWatch(rel string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.dirs[rel]; ok {
+		return nil
+	}
+	if len(w.dirs) >= w.maxDirs {
+		if !w.limited {
+			w.limited = true
+			go w.onLimit("max_dirs")
+		}
+		return filepath.SkipAll
+	}
+
+	wd, err := unix.InotifyAddWatch(w.fd, filepath.Join(w.root, rel), inotifyMask)
+	if err != nil {
+		if errors.Is(err, unix.ENOSPC) && !w.limited {
+			w.limited = true
+			go w.onLimit("max_user_watches")
+			return filepath.SkipAll
+		}
+		return nil // directorio eliminado o sin permisos
+	}
+	w.wds[wd] = rel
+	w.dirs[rel] = wd
+	return nil
+}
+
+// removeTree deja de observar dir y sus subdirectorios (directorio movido)
+func (w *inotifyWatcher) removeTree(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for rel, wd := range w.dirs {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, rel)
+			delete(w.wds, wd)
+		}
+	}
+}
+
+// readLoop lee eventos hasta que se cierra el fd
+func (w *inotifyWatcher) readLoop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(raw.Len)
+			if nameEnd > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[nameStart:nameEnd]), "\x00")
+			offset = nameEnd
+
+			w.handle(int(raw.Wd), raw.Mask, name)
+		}
+	}
+}
+
+// handle traduce un evento inotify a create/modify/delete
+func (w *inotifyWatcher) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.onLimit("overflow")
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.wds[wd]
+	if mask&unix.IN_IGNORED != 0 && ok {
+		delete(w.wds, wd)
+		if w.dirs[dir] == wd {
+			delete(w.dirs, dir)
+		}
+	}
+	w.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+
+	rel := name
+	if dir != "." {
+		rel = dir + "/" + name
+	}
+	isDir := mask&unix.IN_ISDIR != 0
+
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		if isDir {
+			if w.skipDir(rel) {
+				return
+			}
+			w.emit(rel, FileOpCreate, true)
+			w.addTree(rel, true)
+			return
+		}
+		w.emit(rel, FileOpCreate, false)
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		w.emit(rel, FileOpModify, false)
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		if isDir {
+			w.removeTree(rel)
+		}
+		w.emit(rel, FileOpDelete, isDir)
+	}
+}
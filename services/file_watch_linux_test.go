@@ -0,0 +1,104 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFileChanges espera hasta que cond se cumpla sobre el registro
+func waitFileChanges(t *testing.T, fw *fileWatch, cond func(*FileChangeLog) bool) *FileChangeLog {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		log := fw.snapshot(time.Time{}, 0)
+		if cond(log) {
+			return log
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for changes: %+v", log)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFileWatch(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.MkdirAll(filepath.Join(dir, "node_modules"), 0755)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("node_modules/\n*.log\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("a"), 0644)
+
+	batches := make(chan []FileChange, 10)
+	cfg := WatchConfig{MaxDirs: 100, LogSize: 100, DebounceMs: 20}
+	fw := newFileWatch("t1", dir, cfg, func(batch []FileChange) { batches <- batch })
+	if err := fw.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fw.stop()
+
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "node_modules", "pkg.js"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "tmp.txt"), []byte("x"), 0644)
+	os.Remove(filepath.Join(dir, "tmp.txt"))
+	os.MkdirAll(filepath.Join(dir, "src", "pkg"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "pkg", "main.go"), []byte("x"), 0644)
+
+	log := waitFileChanges(t, fw, func(l *FileChangeLog) bool {
+		for _, f := range l.Files {
+			if f.Path == "src/pkg/main.go" {
+				return true
+			}
+		}
+		return false
+	})
+
+	ops := make(map[string]string)
+	for _, f := range log.Files {
+		ops[f.Path] = f.LastOp
+	}
+	if ops["new.txt"] != FileOpCreate || ops["existing.txt"] != FileOpModify {
+		t.Errorf("Unexpected ops: %v", ops)
+	}
+	for _, p := range []string{"node_modules/pkg.js", "debug.log", ".git/HEAD", "tmp.txt"} {
+		if _, ok := ops[p]; ok {
+			t.Errorf("Expected %s not reported", p)
+		}
+	}
+	if !log.Watching || log.Incomplete != "" {
+		t.Errorf("Unexpected state: %+v", log)
+	}
+
+	select {
+	case batch := <-batches:
+		if len(batch) == 0 {
+			t.Error("Expected non-empty batch")
+		}
+	default:
+		t.Error("Expected batch callback")
+	}
+
+	os.Remove(filepath.Join(dir, "new.txt"))
+	waitFileChanges(t, fw, func(l *FileChangeLog) bool {
+		for _, f := range l.Files {
+			if f.Path == "new.txt" && f.LastOp == FileOpDelete {
+				return f.Changes == 2
+			}
+		}
+		return false
+	})
+
+	fw.stop()
+	if fw.snapshot(time.Time{}, 0).Watching {
+		t.Error("Expected watching=false after stop")
+	}
+	if n := len(fw.snapshot(time.Time{}, 1).Changes); n != 1 {
+		t.Errorf("Expected limit 1, got %d", n)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+// newDirWatcher no está soportado fuera de Linux
+func newDirWatcher(root string, maxDirs int, skipDir func(string) bool, emit func(string, string, bool), onLimit func(string)) (dirWatcher, error) {
+	return nil, fmt.Errorf("observacion de archivos no soportada en esta plataforma")
+}
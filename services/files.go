@@ -0,0 +1,563 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"claude-monitor/pkg/diff"
+	"claude-monitor/pkg/logger"
+)
+
+// Errores de FileService que los handlers traducen a códigos HTTP
+var (
+	ErrPathNotAllowed = errors.New("path no permitido")
+	ErrFileTooLarge   = errors.New("archivo demasiado grande")
+	ErrNotRegularFile = errors.New("no es un archivo regular")
+	ErrInvalidRange   = errors.New("rango invalido")
+	ErrNotDirectory   = errors.New("no es un directorio")
+	ErrFileExists     = errors.New("el archivo ya existe")
+	ErrInvalidUpload  = errors.New("upload invalido")
+	ErrArchiveFormat  = errors.New("formato de descarga invalido")
+)
+
+// Bytes inspeccionados para detectar archivos binarios (mismo criterio que git)
+const binarySniffBytes = 8000
+
+// Líneas (y bytes como máximo) incluidas en el preview
+const (
+	previewHeadLines = 40
+	previewHeadBytes = 16 * 1024
+)
+
+// FilesConfig límites de lectura, subida y descarga de archivos
+type FilesConfig struct {
+	MaxReadBytes    int64 `json:"max_read_bytes"`    // por petición de lectura y para preview
+	MaxDiffBytes    int64 `json:"max_diff_bytes"`    // por cada lado de un diff
+	MaxUploadBytes  int64 `json:"max_upload_bytes"`  // total de archivos por petición de subida
+	MaxArchiveBytes int64 `json:"max_archive_bytes"` // contenido (sin comprimir) de una descarga de directorio
+
+	// Plazo de una subida o descarga; sustituye a los timeouts de 30s del servidor
+	TransferTimeoutSeconds int `json:"transfer_timeout_seconds"`
+}
+
+// DefaultFilesConfig configuración por defecto
+func DefaultFilesConfig() FilesConfig {
+	return FilesConfig{
+		MaxReadBytes:    1024 * 1024,
+		MaxDiffBytes:    2 * 1024 * 1024,
+		MaxUploadBytes:  100 * 1024 * 1024,
+		MaxArchiveBytes: 1024 * 1024 * 1024,
+
+		TransferTimeoutSeconds: 1800,
+	}
+}
+
+// FileContent fragmento de un archivo
+type FileContent struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	MimeType   string    `json:"mime_type"`
+	Binary     bool      `json:"binary"`
+	Encoding   string    `json:"encoding"` // utf-8 o base64 (binarios)
+	Offset     int64     `json:"offset"`
+	Length     int64     `json:"length"`
+	Truncated  bool      `json:"truncated"` // hay más contenido después de offset+length
+	NextOffset int64     `json:"next_offset,omitempty"`
+	Content    string    `json:"content"`
+}
+
+// FilePreview metadatos para mostrar un archivo con resaltado de sintaxis
+type FilePreview struct {
+	Path       string    `json:"path"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	MimeType   string    `json:"mime_type"`
+	Language   string    `json:"language,omitempty"` // identificador para el resaltador (go, typescript...)
+	Binary     bool      `json:"binary"`
+	Lines      int       `json:"lines"`
+	LineEnding string    `json:"line_ending,omitempty"` // lf, crlf o mixed
+	Truncated  bool      `json:"truncated"`             // lines/line_ending calculados sobre max_read_bytes
+	Head       string    `json:"head,omitempty"`
+}
+
+// FileDiff diff de dos archivos o de un archivo contra git HEAD
+type FileDiff struct {
+	Old       string      `json:"old"`
+	New       string      `json:"new"`
+	Binary    bool        `json:"binary"`
+	Identical bool        `json:"identical"`
+	Additions int         `json:"additions"`
+	Deletions int         `json:"deletions"`
+	Hunks     []diff.Hunk `json:"hunks"`
+	Unified   string      `json:"unified,omitempty"`
+}
+
+// notAllowedError conserva el motivo de ValidatePath y se compara con ErrPathNotAllowed
+type notAllowedError struct{ error }
+
+func (e notAllowedError) Is(target error) bool { return target == ErrPathNotAllowed }
+
+// FileService lectura de archivos restringida a AllowedPathPrefixes
+type FileService struct {
+	cfg             FilesConfig
+	allowedPrefixes []string
+	protected       []string // rutas reales que nunca se sirven ni se escriben
+}
+
+// NewFileService crea el servicio. protected son directorios o archivos del
+// propio monitor (datos, config, clave de secretos) excluidos aunque estén
+// dentro de los prefijos permitidos.
+func NewFileService(cfg FilesConfig, allowedPrefixes []string, protected ...string) *FileService {
+	def := DefaultFilesConfig()
+	if cfg.MaxReadBytes <= 0 {
+		cfg.MaxReadBytes = def.MaxReadBytes
+	}
+	if cfg.MaxDiffBytes <= 0 {
+		cfg.MaxDiffBytes = def.MaxDiffBytes
+	}
+	if cfg.MaxUploadBytes <= 0 {
+		cfg.MaxUploadBytes = def.MaxUploadBytes
+	}
+	if cfg.MaxArchiveBytes <= 0 {
+		cfg.MaxArchiveBytes = def.MaxArchiveBytes
+	}
+	if cfg.TransferTimeoutSeconds <= 0 {
+		cfg.TransferTimeoutSeconds = def.TransferTimeoutSeconds
+	}
+	s := &FileService{
+		cfg:             cfg,
+		allowedPrefixes: allowedPrefixes,
+	}
+	for _, p := range protected {
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		// Se comparan rutas reales: el destino de los symlinks de la ruta
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		s.protected = append(s.protected, abs)
+	}
+	return s
+}
+
+// checkProtected rechaza rutas reales dentro de las rutas protegidas
+func (s *FileService) checkProtected(resolved string) error {
+	if s.isProtected(resolved) {
+		logger.Warn("Acceso a archivos del monitor rechazado", "path", resolved)
+		return notAllowedError{fmt.Errorf("path reservado del monitor: %s", resolved)}
+	}
+	return nil
+}
+
+func (s *FileService) isProtected(resolved string) bool {
+	for _, p := range s.protected {
+		if resolved == p || strings.HasPrefix(resolved, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve valida el path y el destino real de los symlinks
+func (s *FileService) resolve(path string) (string, error) {
+	if err := ValidatePath(path, s.allowedPrefixes); err != nil {
+		return "", notAllowedError{err}
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	if err := ValidatePath(resolved, s.allowedPrefixes); err != nil {
+		return "", notAllowedError{err}
+	}
+	if err := s.checkProtected(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// Open abre un archivo regular permitido (para servirlo con soporte de Range)
+func (s *FileService) Open(path string) (*os.File, os.FileInfo, error) {
+	resolved, err := s.resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, ErrNotRegularFile
+	}
+	return f, info, nil
+}
+
+// ReadFile lee hasta length bytes desde offset (0 = max_read_bytes)
+func (s *FileService) ReadFile(path string, offset, length int64) (*FileContent, error) {
+	f, info, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if offset < 0 || length < 0 || offset > info.Size() {
+		return nil, ErrInvalidRange
+	}
+	if length == 0 || length > s.cfg.MaxReadBytes {
+		length = s.cfg.MaxReadBytes
+	}
+
+	head := make([]byte, binarySniffBytes)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	binary := isBinary(head)
+
+	buf := make([]byte, length)
+	n, err = f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	content := &FileContent{
+		Path:       path,
+		Size:       info.Size(),
+		ModifiedAt: info.ModTime(),
+		MimeType:   detectMimeType(path, head),
+		Binary:     binary,
+		Offset:     offset,
+	}
+
+	if binary {
+		content.Encoding = "base64"
+		content.Content = base64.StdEncoding.EncodeToString(buf)
+	} else {
+		// No cortar un carácter multibyte al final del fragmento
+		if offset+int64(len(buf)) < info.Size() {
+			buf = trimIncompleteRune(buf)
+		}
+		content.Encoding = "utf-8"
+		content.Content = string(buf)
+	}
+	content.Length = int64(len(buf))
+
+	if end := offset + content.Length; end < info.Size() {
+		content.Truncated = true
+		content.NextOffset = end
+	}
+	return content, nil
+}
+
+// Preview retorna metadatos del archivo, lenguaje y las primeras líneas
+func (s *FileService) Preview(path string) (*FilePreview, error) {
+	f, info, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, s.cfg.MaxReadBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	sniff := data
+	if len(sniff) > binarySniffBytes {
+		sniff = sniff[:binarySniffBytes]
+	}
+
+	preview := &FilePreview{
+		Path:       path,
+		Name:       filepath.Base(path),
+		Size:       info.Size(),
+		ModifiedAt: info.ModTime(),
+		MimeType:   detectMimeType(path, sniff),
+		Binary:     isBinary(sniff),
+		Truncated:  info.Size() > int64(len(data)),
+	}
+	if preview.Binary {
+		return preview, nil
+	}
+
+	preview.Language = detectLanguage(path, data)
+	preview.Lines, preview.LineEnding = countLines(data)
+
+	lines := bytes.SplitAfterN(data, []byte("\n"), previewHeadLines+1)
+	if len(lines) > previewHeadLines {
+		lines = lines[:previewHeadLines]
+	}
+	head := bytes.Join(lines, nil)
+	if len(head) > previewHeadBytes {
+		head = head[:previewHeadBytes]
+	}
+	preview.Head = string(trimIncompleteRune(head))
+
+	return preview, nil
+}
+
+// Diff compara dos archivos
+func (s *FileService) Diff(oldPath, newPath string, contextLines int) (*FileDiff, error) {
+	oldData, err := s.readForDiff(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := s.readForDiff(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return buildFileDiff(oldPath, newPath, oldPath, newPath, oldData, newData, contextLines), nil
+}
+
+// DiffHead compara un archivo con su versión en git HEAD. Un archivo que no
+// existe en HEAD se compara contra vacío.
+func (s *FileService) DiffHead(path string, contextLines int) (*FileDiff, error) {
+	newData, err := s.readForDiff(path)
+	if err != nil {
+		return nil, err
+	}
+	resolved, _ := s.resolve(path)
+
+	top, err := gitRoot(filepath.Dir(resolved))
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(top, resolved)
+	if err != nil {
+		return nil, ErrNotGitRepo
+	}
+	rel = filepath.ToSlash(rel)
+
+	var oldData []byte
+	blob, err := headBlob(top, rel)
+	if err != nil {
+		return nil, err
+	}
+	if blob != "" {
+		out, err := runGit(top, "cat-file", "-s", blob)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file -s: salida invalida: %q", out)
+		}
+		if size > s.cfg.MaxDiffBytes {
+			return nil, ErrFileTooLarge
+		}
+		if oldData, err = runGit(top, "cat-file", "blob", blob); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cabeceras del unified diff como las de git diff
+	return buildFileDiff("HEAD:"+rel, path, "a/"+rel, "b/"+rel, oldData, newData, contextLines), nil
+}
+
+// headBlob retorna el id del blob de rel en HEAD, o "" si el archivo no está
+// en HEAD (o allí no es un archivo) o el repositorio no tiene commits.
+// rev-parse --quiet --verify sale con 1 sin mensajes en esos casos, así que no
+// hay que interpretar el texto de los errores de git.
+func headBlob(top, rel string) (string, error) {
+	out, err := runGit(top, "rev-parse", "--quiet", "--verify", "HEAD:"+rel)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	id := strings.TrimSpace(string(out))
+
+	// Un directorio o un submódulo en HEAD con el mismo nombre no es la versión anterior
+	kind, err := runGit(top, "cat-file", "-t", id)
+	if err != nil || strings.TrimSpace(string(kind)) != "blob" {
+		return "", nil
+	}
+	return id, nil
+}
+
+// readForDiff lee un archivo permitido completo si no supera max_diff_bytes
+func (s *FileService) readForDiff(path string) ([]byte, error) {
+	f, info, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if info.Size() > s.cfg.MaxDiffBytes {
+		return nil, ErrFileTooLarge
+	}
+	return io.ReadAll(io.LimitReader(f, s.cfg.MaxDiffBytes))
+}
+
+// buildFileDiff arma el resultado; los binarios solo se comparan por igualdad
+func buildFileDiff(oldName, newName, oldLabel, newLabel string, oldData, newData []byte, contextLines int) *FileDiff {
+	result := &FileDiff{
+		Old:       oldName,
+		New:       newName,
+		Identical: bytes.Equal(oldData, newData),
+		Hunks:     make([]diff.Hunk, 0),
+	}
+
+	if isBinary(oldData) || isBinary(newData) {
+		result.Binary = true
+		return result
+	}
+	if result.Identical {
+		return result
+	}
+
+	d := diff.Lines(string(oldData), string(newData), contextLines)
+	result.Additions = d.Additions
+	result.Deletions = d.Deletions
+	result.Hunks = d.Hunks
+	result.Unified = d.Unified(oldLabel, newLabel)
+	return result
+}
+
+// isBinary considera binario un contenido con bytes NUL o UTF-8 inválido
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffBytes {
+		data = data[:binarySniffBytes]
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(trimIncompleteRune(data))
+}
+
+// trimIncompleteRune quita un carácter UTF-8 incompleto al final
+func trimIncompleteRune(data []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			return data // ASCII: nada pendiente
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			return data
+		}
+	}
+	return data
+}
+
+// countLines cuenta líneas y detecta el tipo de fin de línea
+func countLines(data []byte) (int, string) {
+	if len(data) == 0 {
+		return 0, ""
+	}
+	lf := bytes.Count(data, []byte("\n"))
+	crlf := bytes.Count(data, []byte("\r\n"))
+
+	lines := lf
+	if data[len(data)-1] != '\n' {
+		lines++
+	}
+
+	switch {
+	case lf == 0:
+		return lines, ""
+	case crlf == lf:
+		return lines, "crlf"
+	case crlf == 0:
+		return lines, "lf"
+	default:
+		return lines, "mixed"
+	}
+}
+
+// detectMimeType por extensión y, si no se conoce, por contenido
+func detectMimeType(path string, head []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+// Lenguajes por extensión (identificadores de highlight.js / Shiki)
+var languageByExt = map[string]string{
+	".go": "go", ".mod": "go.mod", ".sum": "text",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".tsx": "tsx", ".vue": "vue", ".svelte": "svelte",
+	".py": "python", ".rb": "ruby", ".rs": "rust", ".java": "java", ".kt": "kotlin",
+	".swift": "swift", ".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp",
+	".cs": "csharp", ".php": "php", ".lua": "lua", ".r": "r", ".scala": "scala",
+	".sh": "bash", ".bash": "bash", ".zsh": "bash", ".fish": "fish", ".ps1": "powershell",
+	".json": "json", ".jsonl": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".ini": "ini", ".env": "dotenv", ".xml": "xml", ".html": "html", ".htm": "html",
+	".css": "css", ".scss": "scss", ".less": "less", ".sql": "sql", ".graphql": "graphql",
+	".proto": "protobuf", ".md": "markdown", ".mdx": "mdx", ".tf": "hcl", ".hcl": "hcl",
+	".diff": "diff", ".patch": "diff", ".txt": "text", ".csv": "csv",
+}
+
+// Lenguajes por nombre de archivo
+var languageByName = map[string]string{
+	"Dockerfile": "dockerfile", "Makefile": "makefile", "GNUmakefile": "makefile",
+	"CMakeLists.txt": "cmake", "Jenkinsfile": "groovy", "Gemfile": "ruby",
+	".bashrc": "bash", ".zshrc": "bash", ".gitignore": "ignore", ".dockerignore": "ignore",
+}
+
+// Intérpretes del shebang
+var languageByInterpreter = map[string]string{
+	"sh": "bash", "bash": "bash", "zsh": "bash", "python": "python", "python3": "python",
+	"node": "javascript", "ruby": "ruby", "perl": "perl", "php": "php", "deno": "typescript",
+}
+
+// detectLanguage por nombre, extensión o shebang
+func detectLanguage(path string, data []byte) string {
+	name := filepath.Base(path)
+	if lang, ok := languageByName[name]; ok {
+		return lang
+	}
+	if strings.HasPrefix(name, "Dockerfile.") {
+		return "dockerfile"
+	}
+	if lang, ok := languageByExt[strings.ToLower(filepath.Ext(name))]; ok {
+		return lang
+	}
+
+	if bytes.HasPrefix(data, []byte("#!")) {
+		line := string(data[2:])
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			interp := filepath.Base(fields[0])
+			if interp == "env" && len(fields) > 1 {
+				interp = fields[1]
+			}
+			if lang, ok := languageByInterpreter[interp]; ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFilesFixture(t *testing.T) (*FileService, string) {
+	dir := t.TempDir()
+	return NewFileService(FilesConfig{MaxReadBytes: 8, MaxDiffBytes: 1024}, []string{dir}), dir
+}
+
+func TestFileService_PathRestrictions(t *testing.T) {
+	s, dir := newFilesFixture(t)
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secreto"), 0600)
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{outside, link, filepath.Join(dir, "..", "x"), "relativo.txt"} {
+		if _, err := s.ReadFile(p, 0, 0); !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("%s: expected ErrPathNotAllowed, got %v", p, err)
+		}
+	}
+	if _, err := s.ReadFile(dir, 0, 0); !errors.Is(err, ErrNotRegularFile) {
+		t.Errorf("Directory: expected ErrNotRegularFile, got %v", err)
+	}
+	if _, err := s.ReadFile(filepath.Join(dir, "nada.txt"), 0, 0); !os.IsNotExist(err) {
+		t.Errorf("Missing file: expected not exist, got %v", err)
+	}
+}
+
+func TestFileService_ReadFile(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("abcdefgñij"), 0600) // ñ ocupa los bytes 7 y 8
+
+	c, err := s.ReadFile(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Límite de 8 bytes: no se corta la ñ
+	if c.Content != "abcdefg" || !c.Truncated || c.NextOffset != 7 || c.Encoding != "utf-8" || c.Binary {
+		t.Errorf("First chunk: %+v", c)
+	}
+
+	c, err = s.ReadFile(path, c.NextOffset, 100)
+	if err != nil || c.Content != "ñij" || c.Truncated {
+		t.Errorf("Second chunk: %+v, %v", c, err)
+	}
+
+	if _, err := s.ReadFile(path, 100, 0); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("Expected ErrInvalidRange, got %v", err)
+	}
+
+	bin := filepath.Join(dir, "b.bin")
+	os.WriteFile(bin, []byte{0x89, 'P', 'N', 'G', 0, 1}, 0600)
+	c, err = s.ReadFile(bin, 0, 0)
+	if err != nil || !c.Binary || c.Encoding != "base64" || c.Content != "iVBORwAB" {
+		t.Errorf("Binary: %+v, %v", c, err)
+	}
+}
+
+func TestFileService_Preview(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	s.cfg.MaxReadBytes = 1024
+
+	path := filepath.Join(dir, "run")
+	os.WriteFile(path, []byte("#!/usr/bin/env python3\r\nprint(1)\r\n"), 0600)
+
+	p, err := s.Preview(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Language != "python" || p.Lines != 2 || p.LineEnding != "crlf" || p.Binary || p.Truncated {
+		t.Errorf("Preview: %+v", p)
+	}
+
+	for name, lang := range map[string]string{"main.go": "go", "Dockerfile": "dockerfile", "App.TSX": "tsx", "notas": ""} {
+		if got := detectLanguage(name, []byte("x")); got != lang {
+			t.Errorf("detectLanguage(%s) = %q, want %q", name, got, lang)
+		}
+	}
+}
+
+func TestFileService_Diff(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("uno\ndos\n"), 0600)
+	os.WriteFile(b, []byte("uno\nDOS\n"), 0600)
+
+	d, err := s.Diff(a, b, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Identical || d.Additions != 1 || d.Deletions != 1 || !strings.Contains(d.Unified, "-dos\n+DOS\n") {
+		t.Errorf("Diff: %+v", d)
+	}
+
+	big := filepath.Join(dir, "big.txt")
+	os.WriteFile(big, []byte(strings.Repeat("x", 2048)), 0600)
+	if _, err := s.Diff(a, big, 3); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+}
+
+func TestFileService_DiffHead(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git no disponible")
+	}
+	s, dir := newFilesFixture(t)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0755)
+	path := filepath.Join(dir, "pkg", "f.go")
+	os.WriteFile(path, []byte("package pkg\n"), 0600)
+	git("add", ".")
+	git("commit", "-qm", "init")
+
+	os.WriteFile(path, []byte("package pkg\n\nvar X = 1\n"), 0600)
+	d, err := s.DiffHead(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Old != "HEAD:pkg/f.go" || d.Additions != 2 || !strings.HasPrefix(d.Unified, "--- a/pkg/f.go\n+++ b/pkg/f.go\n") {
+		t.Errorf("DiffHead: %+v", d)
+	}
+
+	// Archivo sin versionar: se compara contra vacío
+	untracked := filepath.Join(dir, "nuevo.txt")
+	os.WriteFile(untracked, []byte("hola\n"), 0600)
+	d, err = s.DiffHead(untracked, 3)
+	if err != nil || d.Additions != 1 || d.Deletions != 0 {
+		t.Errorf("Untracked: %+v, %v", d, err)
+	}
+
+	// La versión de HEAD se mide antes de leerla
+	shrunk := filepath.Join(dir, "shrunk.txt")
+	os.WriteFile(shrunk, []byte(strings.Repeat("x", 2048)), 0600)
+	git("add", ".")
+	git("commit", "-qm", "big")
+	os.WriteFile(shrunk, []byte("x\n"), 0600)
+	if _, err := s.DiffHead(shrunk, 3); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge for HEAD version, got %v", err)
+	}
+
+	// Repositorio sin commits: todo se compara contra vacío
+	empty := filepath.Join(dir, "empty")
+	os.MkdirAll(empty, 0755)
+	if out, err := exec.Command("git", "-C", empty, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	first := filepath.Join(empty, "a.txt")
+	os.WriteFile(first, []byte("a\n"), 0600)
+	d, err = s.DiffHead(first, 3)
+	if err != nil || d.Additions != 1 || d.Old != "HEAD:a.txt" {
+		t.Errorf("Empty repo: %+v, %v", d, err)
+	}
+
+	outsideRepo := filepath.Join(t.TempDir(), "x.txt")
+	os.WriteFile(outsideRepo, []byte("x"), 0600)
+	s.allowedPrefixes = nil
+	if _, err := s.DiffHead(outsideRepo, 3); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formatos de descarga de directorios
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// Margen para los encabezados multipart sobre max_upload_bytes
+const uploadOverheadBytes = 1024 * 1024
+
+// UploadedFile archivo escrito por una subida
+type UploadedFile struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Overwritten bool   `json:"overwritten,omitempty"`
+}
+
+// UploadResult resultado de una subida
+type UploadResult struct {
+	Dir   string         `json:"dir"`
+	Files []UploadedFile `json:"files"`
+	Size  int64          `json:"size"`
+}
+
+// pendingUpload archivo temporal que se renombra al completar la subida
+type pendingUpload struct {
+	tmp  string
+	dest string
+	size int64
+}
+
+// UploadLimit tamaño máximo del cuerpo de una petición de subida
+func (s *FileService) UploadLimit() int64 {
+	return s.cfg.MaxUploadBytes + uploadOverheadBytes
+}
+
+// TransferTimeout plazo de una subida o descarga completa
+func (s *FileService) TransferTimeout() time.Duration {
+	return time.Duration(s.cfg.TransferTimeoutSeconds) * time.Second
+}
+
+// resolveDir valida que path sea un directorio permitido
+func (s *FileService) resolveDir(path string) (string, error) {
+	resolved, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", ErrNotDirectory
+	}
+	return resolved, nil
+}
+
+// Upload escribe en dir los archivos de un cuerpo multipart. Todos se escriben
+// primero en temporales y solo se renombran si la subida completa es válida.
+func (s *FileService) Upload(dir string, mr *multipart.Reader, overwrite bool) (*UploadResult, error) {
+	resolved, err := s.resolveDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingUpload
+	cleanup := func() {
+		for _, p := range pending {
+			os.Remove(p.tmp)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, uploadReadError(err)
+		}
+		if part.FileName() == "" {
+			part.Close() // campos que no son archivos
+			continue
+		}
+
+		dest, err := s.uploadDest(resolved, part.FileName(), overwrite)
+		if err != nil {
+			part.Close()
+			cleanup()
+			return nil, err
+		}
+		if seen[dest] {
+			part.Close()
+			cleanup()
+			return nil, fmt.Errorf("%w: archivo repetido %s", ErrInvalidUpload, filepath.Base(dest))
+		}
+		seen[dest] = true
+
+		tmp, err := os.CreateTemp(resolved, ".upload-*")
+		if err != nil {
+			part.Close()
+			cleanup()
+			return nil, err
+		}
+		pending = append(pending, pendingUpload{tmp: tmp.Name(), dest: dest})
+
+		// Un byte más que lo disponible para detectar el exceso
+		n, err := io.Copy(tmp, io.LimitReader(part, s.cfg.MaxUploadBytes-total+1))
+		tmp.Close()
+		part.Close()
+		if err != nil {
+			cleanup()
+			return nil, uploadReadError(err)
+		}
+		total += n
+		if total > s.cfg.MaxUploadBytes {
+			cleanup()
+			return nil, fmt.Errorf("%w: maximo %d bytes por subida", ErrFileTooLarge, s.cfg.MaxUploadBytes)
+		}
+		pending[len(pending)-1].size = n
+	}
+
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("%w: sin archivos", ErrInvalidUpload)
+	}
+
+	result := &UploadResult{Dir: resolved, Files: make([]UploadedFile, 0, len(pending)), Size: total}
+	for i, p := range pending {
+		_, statErr := os.Lstat(p.dest)
+		if statErr == nil && !overwrite {
+			// Creado por otro proceso durante la subida
+			for _, rest := range pending[i:] {
+				os.Remove(rest.tmp)
+			}
+			return result, fmt.Errorf("%w: %s", ErrFileExists, p.dest)
+		}
+		os.Chmod(p.tmp, 0644)
+		if err := os.Rename(p.tmp, p.dest); err != nil {
+			for _, rest := range pending[i:] {
+				os.Remove(rest.tmp)
+			}
+			return result, err
+		}
+		result.Files = append(result.Files, UploadedFile{Path: p.dest, Size: p.size, Overwritten: statErr == nil})
+	}
+	return result, nil
+}
+
+// uploadDest valida el nombre de un archivo subido y retorna su destino
+func (s *FileService) uploadDest(dir, name string, overwrite bool) (string, error) {
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("%w: nombre de archivo %q", ErrInvalidUpload, name)
+	}
+	dest := filepath.Join(dir, name)
+	if err := ValidatePath(dest, s.allowedPrefixes); err != nil {
+		return "", notAllowedError{err}
+	}
+	if err := s.checkProtected(dest); err != nil {
+		return "", err
+	}
+
+	info, err := os.Lstat(dest)
+	switch {
+	case err == nil && !overwrite:
+		return "", fmt.Errorf("%w: %s", ErrFileExists, dest)
+	case err == nil && !info.Mode().IsRegular():
+		return "", fmt.Errorf("%w: %s", ErrNotRegularFile, dest)
+	case err != nil && !os.IsNotExist(err):
+		return "", err
+	}
+	return dest, nil
+}
+
+// uploadReadError distingue un cuerpo demasiado grande de uno mal formado
+func uploadReadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return fmt.Errorf("%w: maximo %d bytes por peticion", ErrFileTooLarge, maxErr.Limit)
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+}
+
+// archiveEntry archivo o directorio incluido en una descarga
+type archiveEntry struct {
+	rel  string // con "/", relativo al directorio descargado
+	abs  string
+	info os.FileInfo
+}
+
+// DirArchive descarga de un directorio preparada (validada y con tamaño conocido)
+type DirArchive struct {
+	Root    string
+	Format  string
+	Files   int
+	Size    int64 // contenido sin comprimir
+	entries []archiveEntry
+}
+
+// PrepareArchive valida el directorio y recorre su contenido antes de
+// empezar a responder, para poder rechazar descargas que superen el límite.
+// Los symlinks, los archivos especiales y las rutas protegidas no se incluyen.
+func (s *FileService) PrepareArchive(path, format string) (*DirArchive, error) {
+	switch format {
+	case ArchiveZip, ArchiveTar, ArchiveTarGz:
+	default:
+		return nil, fmt.Errorf("%w: %q (zip, tar o tar.gz)", ErrArchiveFormat, format)
+	}
+	resolved, err := s.resolveDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &DirArchive{Root: resolved, Format: format}
+	err = filepath.WalkDir(resolved, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == resolved {
+				return err
+			}
+			return nil // sin permisos: se omite
+		}
+		if p == resolved {
+			return nil
+		}
+		if s.isProtected(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			a.Files++
+			a.Size += info.Size()
+			if a.Size > s.cfg.MaxArchiveBytes {
+				return fmt.Errorf("%w: maximo %d bytes por descarga", ErrFileTooLarge, s.cfg.MaxArchiveBytes)
+			}
+		}
+		a.entries = append(a.entries, archiveEntry{rel: relSlash(resolved, p), abs: p, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// FileName nombre sugerido para la descarga
+func (a *DirArchive) FileName() string {
+	return filepath.Base(a.Root) + "." + a.Format
+}
+
+// ContentType tipo MIME de la descarga
+func (a *DirArchive) ContentType() string {
+	switch a.Format {
+	case ArchiveZip:
+		return "application/zip"
+	case ArchiveTarGz:
+		return "application/gzip"
+	}
+	return "application/x-tar"
+}
+
+// Write escribe el archivo comprimido en w
+func (a *DirArchive) Write(w io.Writer) error {
+	switch a.Format {
+	case ArchiveZip:
+		return a.writeZip(w)
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		if err := a.writeTar(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+	return a.writeTar(w)
+}
+
+func (a *DirArchive) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, e := range a.entries {
+		header, err := zip.FileInfoHeader(e.info)
+		if err != nil {
+			return err
+		}
+		header.Name = e.rel
+		if e.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if e.info.IsDir() {
+			continue
+		}
+		if err := copyArchiveFile(fw, e); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (a *DirArchive) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, e := range a.entries {
+		header, err := tar.FileInfoHeader(e.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = e.rel
+		if e.info.IsDir() {
+			header.Name += "/"
+		}
+		// Sin usuarios ni grupos del host
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if e.info.IsDir() {
+			continue
+		}
+		if err := copyArchiveFile(tw, e); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// copyArchiveFile copia exactamente el tamaño registrado al preparar la descarga
+// (el tar no admite archivos que cambiaron de tamaño; se rellena o se corta).
+// El archivo abierto debe ser el mismo que se recorrió: si entre tanto se
+// sustituyó por un symlink, Open lo seguiría fuera del directorio.
+func copyArchiveFile(w io.Writer, e archiveEntry) error {
+	f, err := os.Open(e.abs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, e.info) {
+		return fmt.Errorf("archivo reemplazado durante la descarga: %s", e.rel)
+	}
+
+	n, err := io.Copy(w, io.LimitReader(f, e.info.Size()))
+	if err != nil {
+		return err
+	}
+	if missing := e.info.Size() - n; missing > 0 {
+		_, err = io.CopyN(w, zeroReader{}, missing)
+	}
+	return err
+}
+
+// zeroReader lector infinito de ceros
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
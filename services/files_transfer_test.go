@@ -0,0 +1,262 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// multipartBody construye un cuerpo multipart con los archivos indicados (nombre, contenido)
+func multipartBody(t *testing.T, files ...string) *multipart.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("comentario", "ignorado")
+	for i := 0; i+1 < len(files); i += 2 {
+		part, err := mw.CreateFormFile("file", files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(files[i+1]))
+	}
+	mw.Close()
+	return multipart.NewReader(&buf, mw.Boundary())
+}
+
+// dirEntries nombres de los archivos de un directorio
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestFileService_Upload(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	s.cfg.MaxUploadBytes = 10
+
+	result, err := s.Upload(dir, multipartBody(t, "spec.md", "# spec", "a.log", "abc"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 2 || result.Size != 9 || result.Files[0].Path != filepath.Join(dir, "spec.md") {
+		t.Errorf("Upload: %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "spec.md")); string(data) != "# spec" {
+		t.Errorf("Expected spec.md content, got %q", data)
+	}
+
+	// Existente sin overwrite: no se escribe nada
+	if _, err := s.Upload(dir, multipartBody(t, "nuevo.txt", "x", "a.log", "z"), false); !errors.Is(err, ErrFileExists) {
+		t.Errorf("Expected ErrFileExists, got %v", err)
+	}
+	result, err = s.Upload(dir, multipartBody(t, "a.log", "z"), true)
+	if err != nil || !result.Files[0].Overwritten {
+		t.Errorf("Overwrite: %+v, %v", result, err)
+	}
+
+	// Límite por petición: no quedan temporales ni archivos parciales
+	if _, err := s.Upload(dir, multipartBody(t, "b.txt", "123456", "c.txt", "123456"), false); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if names := dirEntries(t, dir); strings.Join(names, ",") != "a.log,spec.md" {
+		t.Errorf("Expected no partial files, got %v", names)
+	}
+
+	if _, err := s.Upload(dir, multipartBody(t), false); !errors.Is(err, ErrInvalidUpload) {
+		t.Errorf("Expected ErrInvalidUpload without files, got %v", err)
+	}
+	if _, err := s.Upload(filepath.Join(dir, "spec.md"), multipartBody(t, "x", "x"), false); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("Expected ErrNotDirectory, got %v", err)
+	}
+	if _, err := s.Upload(t.TempDir(), multipartBody(t, "x", "x"), false); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Expected ErrPathNotAllowed, got %v", err)
+	}
+}
+
+func TestFileService_Archive(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	os.MkdirAll(filepath.Join(dir, "out", "sub", "vacio"), 0755)
+	os.WriteFile(filepath.Join(dir, "out", "a.txt"), []byte("hola"), 0644)
+	os.WriteFile(filepath.Join(dir, "out", "sub", "b.txt"), []byte("mundo"), 0644)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "out", "passwd"))
+
+	want := "a.txt,sub/,sub/b.txt,sub/vacio/"
+
+	a, err := s.PrepareArchive(filepath.Join(dir, "out"), ArchiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Files != 2 || a.Size != 9 || a.FileName() != "out.zip" {
+		t.Errorf("Archive: %+v", a)
+	}
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != want {
+		t.Errorf("Zip entries: %v", names)
+	}
+
+	a, err = s.PrepareArchive(filepath.Join(dir, "out"), ArchiveTarGz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	names = nil
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+		if h.Name == "sub/b.txt" {
+			if data, _ := io.ReadAll(tr); string(data) != "mundo" {
+				t.Errorf("Expected sub/b.txt content, got %q", data)
+			}
+		}
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != want {
+		t.Errorf("Tar entries: %v", names)
+	}
+
+	s.cfg.MaxArchiveBytes = 8
+	if _, err := s.PrepareArchive(filepath.Join(dir, "out"), ArchiveTar); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if _, err := s.PrepareArchive(filepath.Join(dir, "out"), "rar"); !errors.Is(err, ErrArchiveFormat) {
+		t.Errorf("Expected ErrArchiveFormat, got %v", err)
+	}
+}
+
+func TestFileService_ArchiveSymlinkSwap(t *testing.T) {
+	s, dir := newFilesFixture(t)
+	os.MkdirAll(filepath.Join(dir, "out"), 0755)
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(secret, []byte("secreto"), 0600)
+	target := filepath.Join(dir, "out", "a.txt")
+	os.WriteFile(target, []byte("holaaaa"), 0644)
+
+	a, err := s.PrepareArchive(filepath.Join(dir, "out"), ArchiveTar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entre preparar y escribir, el archivo se sustituye por un symlink fuera de los prefijos
+	os.Remove(target)
+	if err := os.Symlink(secret, target); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err == nil {
+		t.Error("Write should fail when a file is swapped for a symlink")
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secreto")) {
+		t.Error("symlink target leaked into the archive")
+	}
+}
+
+func TestFileService_ProtectedPaths(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "claude-monitor")
+	os.MkdirAll(filepath.Join(data, "hooks"), 0755)
+	os.WriteFile(filepath.Join(data, "secrets.key"), []byte("clave"), 0600)
+	os.WriteFile(filepath.Join(data, "users.json"), []byte(`{"users":[]}`), 0600)
+	os.WriteFile(filepath.Join(dir, "notas.txt"), []byte("hola"), 0644)
+	os.Symlink(filepath.Join(data, "secrets.key"), filepath.Join(dir, "clave.txt"))
+	s := NewFileService(FilesConfig{}, []string{dir}, data)
+
+	// Lectura directa, por symlink y de subdirectorios
+	for _, p := range []string{filepath.Join(data, "secrets.key"), filepath.Join(dir, "clave.txt"), filepath.Join(data, "hooks")} {
+		if _, err := s.ReadFile(p, 0, 0); !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("ReadFile(%s): expected ErrPathNotAllowed, got %v", p, err)
+		}
+	}
+	if _, err := s.Preview(filepath.Join(data, "users.json")); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Preview: expected ErrPathNotAllowed, got %v", err)
+	}
+
+	// Descarga: el directorio de datos no se puede pedir ni se incluye en el de arriba
+	if _, err := s.PrepareArchive(data, ArchiveZip); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("PrepareArchive(data): expected ErrPathNotAllowed, got %v", err)
+	}
+	a, err := s.PrepareArchive(dir, ArchiveTar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+	if strings.Join(names, ",") != "notas.txt" {
+		t.Errorf("Tar entries: %v", names)
+	}
+
+	// Subida con overwrite sobre los archivos del monitor
+	if _, err := s.Upload(data, multipartBody(t, "users.json", `{"users":["yo"]}`), true); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Upload into data dir: expected ErrPathNotAllowed, got %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(data, "users.json")); string(got) != `{"users":[]}` {
+		t.Errorf("users.json overwritten: %s", got)
+	}
+
+	// Archivo protegido suelto (config.json fuera del directorio de datos)
+	config := filepath.Join(dir, "config.json")
+	os.WriteFile(config, []byte("{}"), 0600)
+	s = NewFileService(FilesConfig{}, []string{dir}, config)
+	if _, err := s.Upload(dir, multipartBody(t, "config.json", `{"username":"yo"}`), true); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Upload over config: expected ErrPathNotAllowed, got %v", err)
+	}
+	if got, _ := os.ReadFile(config); string(got) != "{}" {
+		t.Errorf("config.json overwritten: %s", got)
+	}
+}
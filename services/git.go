@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"claude-monitor/pkg/cache"
+)
+
+// ErrNotGitRepo el directorio no pertenece a un repositorio git
+var ErrNotGitRepo = errors.New("no es un repositorio git")
+
+const (
+	gitTimeout         = 10 * time.Second
+	gitStatusCacheTTL  = 5 * time.Second
+	gitRecentCommits   = 10
+	gitMaxStatusFiles  = 500
+	gitMaxWindowCommit = 1000
+	// Margen tras la última actividad de una sesión para atribuirle commits
+	sessionCommitGrace = 2 * time.Minute
+)
+
+// Separadores del formato de git log (no aparecen en los campos)
+const (
+	gitFieldSep  = "\x00"
+	gitRecordSep = "\x1e"
+	gitLogFormat = "--format=%H%x00%h%x00%an%x00%ae%x00%cI%x00%s%x1e"
+)
+
+// GitStatus estado de un repositorio
+type GitStatus struct {
+	Root          string          `json:"root"`
+	Branch        string          `json:"branch,omitempty"` // vacío con HEAD desacoplado
+	Head          string          `json:"head,omitempty"`   // vacío si no hay commits
+	Upstream      string          `json:"upstream,omitempty"`
+	Ahead         int             `json:"ahead"`
+	Behind        int             `json:"behind"`
+	Dirty         bool            `json:"dirty"`
+	ChangedFiles  int             `json:"changed_files"`
+	Files         []GitFileStatus `json:"files"` // hasta 500
+	RecentCommits []GitCommit     `json:"recent_commits"`
+	CheckedAt     time.Time       `json:"checked_at"`
+}
+
+// GitFileStatus archivo modificado según git status
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"` // origen de un renombrado
+	Index    string `json:"index"`               // X de git status (. = sin cambios, ? = sin versionar)
+	WorkTree string `json:"worktree"`            // Y de git status
+	Conflict bool   `json:"conflict,omitempty"`
+}
+
+// GitCommit commit del log
+type GitCommit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"short_hash"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"` // fecha del committer
+	Subject   string    `json:"subject"`
+}
+
+// GitSummary resumen para listados (TerminalInfo, session-roots)
+type GitSummary struct {
+	Root         string `json:"root"`
+	Branch       string `json:"branch,omitempty"`
+	Head         string `json:"head,omitempty"` // hash corto
+	Ahead        int    `json:"ahead"`
+	Behind       int    `json:"behind"`
+	Dirty        bool   `json:"dirty"`
+	ChangedFiles int    `json:"changed_files"`
+}
+
+// SessionCommits commits hechos durante la ventana de actividad de una sesión
+type SessionCommits struct {
+	SessionID string      `json:"session_id"`
+	Since     time.Time   `json:"since"`
+	Until     time.Time   `json:"until"`
+	Commits   []GitCommit `json:"commits"`
+}
+
+// GitService consulta repositorios git con una caché corta de estado
+type GitService struct {
+	status *cache.Cache[*GitStatus] // nil = no es repositorio
+}
+
+// NewGitService crea el servicio
+func NewGitService() *GitService {
+	return &GitService{
+		status: cache.New[*GitStatus](gitStatusCacheTTL, 256),
+	}
+}
+
+// Stop detiene la limpieza de la caché
+func (g *GitService) Stop() {
+	g.status.Stop()
+}
+
+// runGit ejecuta git en dir con timeout y retorna stdout. LC_ALL=C fija la
+// salida y los mensajes de error en inglés sea cual sea el locale del servidor.
+func runGit(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// gitRoot retorna el directorio raíz del repositorio que contiene dir
+func gitRoot(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", ErrNotGitRepo
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Status retorna el estado del repositorio que contiene dir
+func (g *GitService) Status(dir string) (*GitStatus, error) {
+	if cached, ok := g.status.Get(dir); ok {
+		if cached == nil {
+			return nil, ErrNotGitRepo
+		}
+		return cached, nil
+	}
+
+	status, err := readGitStatus(dir)
+	if err == ErrNotGitRepo {
+		g.status.Set(dir, nil)
+	} else if err == nil {
+		g.status.Set(dir, status)
+	}
+	return status, err
+}
+
+// Summary resumen del repositorio de dir (nil si no es un repositorio)
+func (g *GitService) Summary(dir string) *GitSummary {
+	if dir == "" {
+		return nil
+	}
+	status, err := g.Status(dir)
+	if err != nil {
+		return nil
+	}
+	head := status.Head
+	if len(head) > 7 {
+		head = head[:7]
+	}
+	return &GitSummary{
+		Root:         status.Root,
+		Branch:       status.Branch,
+		Head:         head,
+		Ahead:        status.Ahead,
+		Behind:       status.Behind,
+		Dirty:        status.Dirty,
+		ChangedFiles: status.ChangedFiles,
+	}
+}
+
+// readGitStatus ejecuta git status y git log sin caché
+func readGitStatus(dir string) (*GitStatus, error) {
+	root, err := gitRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runGit(root, "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=normal")
+	if err != nil {
+		return nil, err
+	}
+	status := parseGitStatus(out)
+	status.Root = root
+	status.CheckedAt = time.Now()
+
+	// Sin commits git log falla: se deja la lista vacía
+	if out, err := runGit(root, "log", "-n", strconv.Itoa(gitRecentCommits), gitLogFormat); err == nil {
+		status.RecentCommits = parseGitLog(out)
+	}
+	return status, nil
+}
+
+// parseGitStatus interpreta git status --porcelain=v2 --branch -z
+func parseGitStatus(out []byte) *GitStatus {
+	status := &GitStatus{
+		Files:         make([]GitFileStatus, 0),
+		RecentCommits: make([]GitCommit, 0),
+	}
+
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+
+		if strings.HasPrefix(entry, "# ") {
+			fields := strings.Fields(entry[2:])
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "branch.oid":
+				if fields[1] != "(initial)" {
+					status.Head = fields[1]
+				}
+			case "branch.head":
+				if fields[1] != "(detached)" {
+					status.Branch = fields[1]
+				}
+			case "branch.upstream":
+				status.Upstream = fields[1]
+			case "branch.ab":
+				if len(fields) == 3 {
+					status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "+"))
+					status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "-"))
+				}
+			}
+			continue
+		}
+
+		var file GitFileStatus
+		switch entry[0] {
+		case '1': // 1 XY sub mH mI mW hH hI path
+			parts := strings.SplitN(entry, " ", 9)
+			if len(parts) < 9 {
+				continue
+			}
+			file = GitFileStatus{Path: parts[8], Index: parts[1][:1], WorkTree: parts[1][1:]}
+		case '2': // 2 XY sub mH mI mW hH hI Xscore path \0 origPath
+			parts := strings.SplitN(entry, " ", 10)
+			if len(parts) < 10 {
+				continue
+			}
+			file = GitFileStatus{Path: parts[9], Index: parts[1][:1], WorkTree: parts[1][1:]}
+			if i+1 < len(entries) {
+				i++
+				file.OrigPath = entries[i]
+			}
+		case 'u': // u XY sub m1 m2 m3 mW h1 h2 h3 path
+			parts := strings.SplitN(entry, " ", 11)
+			if len(parts) < 11 {
+				continue
+			}
+			file = GitFileStatus{Path: parts[10], Index: parts[1][:1], WorkTree: parts[1][1:], Conflict: true}
+		case '?':
+			file = GitFileStatus{Path: entry[2:], Index: "?", WorkTree: "?"}
+		default:
+			continue
+		}
+
+		status.ChangedFiles++
+		if len(status.Files) < gitMaxStatusFiles {
+			status.Files = append(status.Files, file)
+		}
+	}
+
+	status.Dirty = status.ChangedFiles > 0
+	return status
+}
+
+// parseGitLog interpreta la salida de git log con gitLogFormat
+func parseGitLog(out []byte) []GitCommit {
+	commits := make([]GitCommit, 0)
+	for _, record := range strings.Split(string(out), gitRecordSep) {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, gitFieldSep, 6)
+		if len(fields) < 6 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[4])
+		commits = append(commits, GitCommit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Date:      date,
+			Subject:   fields[5],
+		})
+	}
+	return commits
+}
+
+// CommitsBetween commits de las ramas locales con fecha de commit en [since, until]
+func (g *GitService) CommitsBetween(dir string, since, until time.Time) ([]GitCommit, error) {
+	root, err := gitRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	const dateFormat = "2006-01-02 15:04:05 -0700"
+	out, err := runGit(root, "log", "--branches", "-n", strconv.Itoa(gitMaxWindowCommit),
+		"--since="+since.Add(-time.Second).Format(dateFormat),
+		"--until="+until.Add(time.Second).Format(dateFormat),
+		gitLogFormat)
+	if err != nil {
+		// Repositorio sin commits
+		return make([]GitCommit, 0), nil
+	}
+
+	// git filtra con precisión de segundos; se ajusta a la ventana exacta
+	commits := make([]GitCommit, 0)
+	for _, c := range parseGitLog(out) {
+		if !c.Date.Before(since.Truncate(time.Second)) && !c.Date.After(until) {
+			commits = append(commits, c)
+		}
+	}
+	return commits, nil
+}
+
+// sessionWindow ventana de actividad de una sesión
+func sessionWindow(sess ClaudeSession) (time.Time, time.Time) {
+	since := sess.CreatedAt
+	if since.IsZero() {
+		since = sess.ModifiedAt
+	}
+	return since, sess.ModifiedAt.Add(sessionCommitGrace)
+}
+
+// SessionCommits commits hechos en el repositorio de la sesión mientras estuvo activa
+func (g *GitService) SessionCommits(sess ClaudeSession) (*SessionCommits, error) {
+	since, until := sessionWindow(sess)
+	commits, err := g.CommitsBetween(sess.RealPath, since, until)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionCommits{
+		SessionID: sess.ID,
+		Since:     since,
+		Until:     until,
+		Commits:   commits,
+	}, nil
+}
+
+// CorrelateSessions atribuye commits a las sesiones de un repositorio con un
+// único git log sobre la ventana total. Un commit hecho mientras varias
+// sesiones estaban activas se atribuye a todas. Solo retorna sesiones con commits.
+func (g *GitService) CorrelateSessions(dir string, sessions []ClaudeSession) ([]SessionCommits, error) {
+	result := make([]SessionCommits, 0)
+	if len(sessions) == 0 {
+		return result, nil
+	}
+
+	var first, last time.Time
+	for _, sess := range sessions {
+		since, until := sessionWindow(sess)
+		if first.IsZero() || since.Before(first) {
+			first = since
+		}
+		if until.After(last) {
+			last = until
+		}
+	}
+
+	commits, err := g.CommitsBetween(dir, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sess := range sessions {
+		since, until := sessionWindow(sess)
+		sc := SessionCommits{SessionID: sess.ID, Since: since, Until: until}
+		for _, c := range commits {
+			if !c.Date.Before(since.Truncate(time.Second)) && !c.Date.After(until) {
+				sc.Commits = append(sc.Commits, c)
+			}
+		}
+		if len(sc.Commits) > 0 {
+			result = append(result, sc)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Since.After(result[j].Since)
+	})
+	return result, nil
+}
+
+// TerminalGit estado git del WorkDir de una terminal y commits desde su inicio
+type TerminalGit struct {
+	TerminalID string      `json:"terminal_id"`
+	WorkDir    string      `json:"work_dir"`
+	Status     *GitStatus  `json:"status"`
+	Since      time.Time   `json:"since"`
+	Commits    []GitCommit `json:"commits"`
+}
+
+// Git estado del repositorio del WorkDir de una terminal y commits hechos
+// desde que arrancó (o desde su creación si no está activa)
+func (s *TerminalService) Git(id string) (*TerminalGit, error) {
+	if s.git == nil {
+		return nil, ErrNotGitRepo
+	}
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.git.Status(info.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+
+	since := info.StartedAt
+	if since.IsZero() {
+		since = info.CreatedAt
+	}
+	commits, err := s.git.CommitsBetween(info.WorkDir, since, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TerminalGit{
+		TerminalID: id,
+		WorkDir:    info.WorkDir,
+		Status:     status,
+		Since:      since,
+		Commits:    commits,
+	}, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newGitRepo crea un repositorio temporal. commit crea un commit con la fecha dada.
+func newGitRepo(t *testing.T) (string, func(msg string, date time.Time)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git no disponible")
+	}
+	dir := t.TempDir()
+
+	run := func(env []string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run(nil, "init", "-q", "-b", "main")
+
+	commit := func(msg string, date time.Time) {
+		os.WriteFile(filepath.Join(dir, "log.txt"), []byte(msg+"\n"), 0600)
+		run(nil, "add", ".")
+		stamp := date.Format(time.RFC3339)
+		run([]string{"GIT_AUTHOR_DATE=" + stamp, "GIT_COMMITTER_DATE=" + stamp}, "commit", "-qm", msg)
+	}
+	return dir, commit
+}
+
+func TestParseGitStatus(t *testing.T) {
+	out := "# branch.oid 1234567890abcdef\x00" +
+		"# branch.head feature\x00" +
+		"# branch.upstream origin/feature\x00" +
+		"# branch.ab +2 -1\x00" +
+		"1 .M N... 100644 100644 100644 aaa bbb main.go\x00" +
+		"2 R. N... 100644 100644 100644 aaa bbb R100 nuevo nombre.go\x00viejo.go\x00" +
+		"u UU N... 100644 100644 100644 100644 aaa bbb ccc conflicto.go\x00" +
+		"? sin versionar.txt\x00"
+
+	s := parseGitStatus([]byte(out))
+	if s.Head != "1234567890abcdef" || s.Branch != "feature" || s.Upstream != "origin/feature" {
+		t.Errorf("Branch info: %+v", s)
+	}
+	if s.Ahead != 2 || s.Behind != 1 || !s.Dirty || s.ChangedFiles != 4 {
+		t.Errorf("Counts: %+v", s)
+	}
+
+	want := []GitFileStatus{
+		{Path: "main.go", Index: ".", WorkTree: "M"},
+		{Path: "nuevo nombre.go", OrigPath: "viejo.go", Index: "R", WorkTree: "."},
+		{Path: "conflicto.go", Index: "U", WorkTree: "U", Conflict: true},
+		{Path: "sin versionar.txt", Index: "?", WorkTree: "?"},
+	}
+	if len(s.Files) != len(want) {
+		t.Fatalf("Expected %d files, got %+v", len(want), s.Files)
+	}
+	for i, f := range want {
+		if s.Files[i] != f {
+			t.Errorf("File %d: expected %+v, got %+v", i, f, s.Files[i])
+		}
+	}
+
+	// Repositorio recién creado y HEAD desacoplado
+	s = parseGitStatus([]byte("# branch.oid (initial)\x00# branch.head (detached)\x00"))
+	if s.Head != "" || s.Branch != "" || s.Dirty {
+		t.Errorf("Initial/detached: %+v", s)
+	}
+}
+
+func TestGitService_Status(t *testing.T) {
+	dir, commit := newGitRepo(t)
+	g := NewGitService()
+	defer g.Stop()
+
+	commit("primero", time.Now().Add(-time.Hour))
+	commit("segundo", time.Now().Add(-time.Minute))
+	os.WriteFile(filepath.Join(dir, "nuevo.txt"), []byte("x"), 0600)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+
+	status, err := g.Status(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "main" || len(status.Head) != 40 || !status.Dirty || status.ChangedFiles != 1 {
+		t.Errorf("Status: %+v", status)
+	}
+	if len(status.RecentCommits) != 2 || status.RecentCommits[0].Subject != "segundo" || status.RecentCommits[0].Author != "t" {
+		t.Errorf("RecentCommits: %+v", status.RecentCommits)
+	}
+
+	summary := g.Summary(dir)
+	if summary == nil || summary.Head != status.Head[:7] || !summary.Dirty {
+		t.Errorf("Summary: %+v", summary)
+	}
+
+	notRepo := t.TempDir()
+	if _, err := g.Status(notRepo); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+	if g.Summary(notRepo) != nil {
+		t.Error("Expected nil summary outside a repository")
+	}
+}
+
+func TestGitService_SessionCommits(t *testing.T) {
+	dir, commit := newGitRepo(t)
+	g := NewGitService()
+	defer g.Stop()
+
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	commit("antes", base.Add(-time.Hour))
+	commit("sesion a", base.Add(10*time.Minute))
+	commit("ambas", base.Add(30*time.Minute))
+	commit("margen b", base.Add(61*time.Minute)) // dentro del margen tras la sesión b
+	commit("despues", base.Add(3*time.Hour))
+
+	a := ClaudeSession{ID: "a", RealPath: dir, CreatedAt: base, ModifiedAt: base.Add(40 * time.Minute)}
+	b := ClaudeSession{ID: "b", RealPath: dir, CreatedAt: base.Add(20 * time.Minute), ModifiedAt: base.Add(time.Hour)}
+	c := ClaudeSession{ID: "c", RealPath: dir, CreatedAt: base.Add(2 * time.Hour), ModifiedAt: base.Add(2*time.Hour + time.Minute)}
+
+	sc, err := g.SessionCommits(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Commits) != 2 || sc.Commits[0].Subject != "ambas" || sc.Commits[1].Subject != "sesion a" {
+		t.Errorf("SessionCommits a: %+v", sc.Commits)
+	}
+
+	correlated, err := g.CorrelateSessions(dir, []ClaudeSession{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(correlated) != 2 || correlated[0].SessionID != "b" || correlated[1].SessionID != "a" {
+		t.Fatalf("CorrelateSessions: %+v", correlated)
+	}
+	if len(correlated[0].Commits) != 2 || correlated[0].Commits[0].Subject != "margen b" {
+		t.Errorf("Session b commits: %+v", correlated[0].Commits)
+	}
+}
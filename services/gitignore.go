@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ignorePattern patrón de un archivo .gitignore
+type ignorePattern struct {
+	segs     []string // separado por "/"; "**" coincide con cualquier número de segmentos
+	negate   bool     // !patrón
+	dirOnly  bool     // patrón/
+	anchored bool     // contiene "/" al inicio o en medio: relativo al directorio del .gitignore
+}
+
+// gitignore reglas de los .gitignore de un repositorio (más .git/info/exclude).
+// Las rutas son relativas a la raíz del repositorio con separador "/".
+type gitignore struct {
+	root  string
+	mu    sync.RWMutex
+	files map[string][]ignorePattern // directorio del .gitignore ("" = raíz) -> patrones
+}
+
+// newGitignore carga info/exclude y los .gitignore desde la raíz del
+// repositorio hasta dir. root es el directorio que contiene .git (o dir si
+// no está en un repositorio).
+func newGitignore(dir string) (*gitignore, string) {
+	root := findRepoRoot(dir)
+	g := &gitignore{root: root, files: make(map[string][]ignorePattern)}
+
+	if patterns := parseIgnoreFile(filepath.Join(root, ".git", "info", "exclude")); len(patterns) > 0 {
+		g.files["\x00exclude"] = patterns // menor precedencia que cualquier .gitignore
+	}
+
+	prefix, _ := filepath.Rel(root, dir)
+	prefix = filepath.ToSlash(prefix)
+	if prefix == "." {
+		prefix = ""
+	}
+	g.load("")
+	if prefix != "" {
+		parts := strings.Split(prefix, "/")
+		for i := range parts {
+			g.load(strings.Join(parts[:i+1], "/"))
+		}
+	}
+	return g, prefix
+}
+
+// findRepoRoot busca hacia arriba el directorio que contiene .git
+func findRepoRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// load (re)carga el .gitignore del directorio rel
+func (g *gitignore) load(rel string) {
+	patterns := parseIgnoreFile(filepath.Join(g.root, filepath.FromSlash(rel), ".gitignore"))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(patterns) == 0 {
+		delete(g.files, rel)
+		return
+	}
+	g.files[rel] = patterns
+}
+
+// parseIgnoreFile lee los patrones de un archivo con formato gitignore
+func parseIgnoreFile(file string) []ignorePattern {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseIgnoreLine(scanner.Text()); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// parseIgnoreLine interpreta una línea de .gitignore
+func parseIgnoreLine(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+	p.segs = strings.Split(line, "/")
+	return p, true
+}
+
+// ignored indica si rel (relativo a la raíz del repositorio) está ignorado.
+// Un directorio ignorado ignora todo su contenido.
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if g.match(parts[:i], true) {
+			return true
+		}
+	}
+	return g.match(parts, isDir)
+}
+
+// match aplica los patrones de los .gitignore que afectan a la ruta. Gana el
+// último que coincide; los .gitignore más profundos tienen prioridad.
+func (g *gitignore) match(parts []string, isDir bool) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	bases := make([]string, 0, len(g.files))
+	for base := range g.files {
+		bases = append(bases, base)
+	}
+	// "\x00exclude" primero, luego de menos a más profundo
+	sort.Slice(bases, func(i, j int) bool {
+		return ignoreBaseDepth(bases[i]) < ignoreBaseDepth(bases[j])
+	})
+
+	ignored := false
+	for _, base := range bases {
+		var sub []string
+		switch {
+		case base == "\x00exclude" || base == "":
+			sub = parts
+		default:
+			baseParts := strings.Split(base, "/")
+			if len(parts) <= len(baseParts) || !equalSegments(parts[:len(baseParts)], baseParts) {
+				continue
+			}
+			sub = parts[len(baseParts):]
+		}
+
+		for _, p := range g.files[base] {
+			if p.dirOnly && !isDir {
+				continue
+			}
+			var ok bool
+			if p.anchored {
+				ok = matchSegments(p.segs, sub)
+			} else {
+				ok = matchSegment(p.segs[0], sub[len(sub)-1])
+			}
+			if ok {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// ignoreBaseDepth orden de precedencia de un archivo de patrones
+func ignoreBaseDepth(base string) int {
+	switch base {
+	case "\x00exclude":
+		return -1
+	case "":
+		return 0
+	}
+	return strings.Count(base, "/") + 1
+}
+
+func equalSegments(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSegments compara segmentos de un patrón con "**" contra una ruta
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 || !matchSegment(pattern[0], parts[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// matchSegment compara un segmento con un glob (*, ?, [...])
+func matchSegment(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
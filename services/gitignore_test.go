@@ -0,0 +1,54 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git", "info"), 0755)
+	os.MkdirAll(filepath.Join(dir, "web", "src"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "info", "exclude"), []byte("*.swp\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# comentario\nnode_modules/\n*.log\n!keep.log\n/build\ndocs/**/*.tmp\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "web", ".gitignore"), []byte("!debug.log\ndist\n"), 0644)
+
+	g, prefix := newGitignore(filepath.Join(dir, "web"))
+	if prefix != "web" {
+		t.Fatalf("Expected prefix web, got %q", prefix)
+	}
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.swp", false, true},
+		{"node_modules", true, true},
+		{"node_modules", false, false}, // solo directorios
+		{"web/node_modules/pkg/index.js", false, true},
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"web/build", true, false}, // anclado a la raíz
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"web/debug.log", false, false}, // .gitignore más profundo gana
+		{"debug.log", false, true},
+		{"web/dist/app.js", false, true},
+		{"web/src/main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := g.ignored(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+
+	// Recarga al cambiar un .gitignore
+	os.WriteFile(filepath.Join(dir, "web", ".gitignore"), []byte("src/\n"), 0644)
+	g.load("web")
+	if !g.ignored("web/src/main.go", false) || g.ignored("web/dist/app.js", false) {
+		t.Error("Expected reloaded web/.gitignore")
+	}
+}
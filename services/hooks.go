@@ -0,0 +1,290 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Eventos para los que se genera configuración de hooks
+var hookSettingsEvents = []HookEventType{
+	HookPreToolUse,
+	HookPostToolUse,
+	HookNotification,
+	HookUserPromptSubmit,
+	HookStop,
+	HookSubagentStop,
+	HookPreCompact,
+	HookSessionStart,
+	HookSessionEnd,
+}
+
+// Herramientas que modifican archivos (generan checkpoint en PostToolUse)
+var fileEditTools = map[string]bool{
+	"Edit":         true,
+	"MultiEdit":    true,
+	"Write":        true,
+	"NotebookEdit": true,
+}
+
+// HookPayload JSON que Claude Code envía a los hooks por stdin
+type HookPayload struct {
+	SessionID      string                 `json:"session_id"`
+	TranscriptPath string                 `json:"transcript_path,omitempty"`
+	Cwd            string                 `json:"cwd,omitempty"`
+	PermissionMode string                 `json:"permission_mode,omitempty"`
+	HookEventName  HookEventType          `json:"hook_event_name"`
+	ToolName       string                 `json:"tool_name,omitempty"`
+	ToolUseID      string                 `json:"tool_use_id,omitempty"`
+	ToolInput      map[string]interface{} `json:"tool_input,omitempty"`
+	ToolResponse   interface{}            `json:"tool_response,omitempty"`
+	Message        string                 `json:"message,omitempty"`
+	Prompt         string                 `json:"prompt,omitempty"`
+	StopHookActive bool                   `json:"stop_hook_active,omitempty"`
+	Source         string                 `json:"source,omitempty"`
+	Reason         string                 `json:"reason,omitempty"`
+	Trigger        string                 `json:"trigger,omitempty"`
+}
+
+// HookEventData datos guardados en el historial para eventos de hooks
+type HookEventData struct {
+	Source    string `json:"source"` // siempre "hook"
+	SessionID string `json:"session_id,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Command   string `json:"command,omitempty"`
+	FilePath  string `json:"file_path,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// hookRegistry tokens y archivos de settings por terminal
+type hookRegistry struct {
+	mu      sync.RWMutex
+	baseURL string
+	dir     string
+	tokens  map[string]string
+}
+
+// SetHooksBaseURL configura la URL base que usarán los hooks para contactar al servidor
+func (s *TerminalService) SetHooksBaseURL(baseURL string) {
+	s.hooks.mu.Lock()
+	s.hooks.baseURL = strings.TrimSuffix(baseURL, "/")
+	s.hooks.mu.Unlock()
+}
+
+// ValidateHookToken verifica el token de hooks de una terminal
+func (s *TerminalService) ValidateHookToken(terminalID, token string) bool {
+	s.hooks.mu.RLock()
+	expected, ok := s.hooks.tokens[terminalID]
+	s.hooks.mu.RUnlock()
+
+	if !ok || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// writeHooksSettings genera el archivo de settings con hooks para una terminal
+// y retorna su path. Cada hook reenvía el JSON recibido por stdin al endpoint
+// /api/hooks/{id} con un token exclusivo de la terminal.
+func (s *TerminalService) writeHooksSettings(terminalID string) (string, error) {
+	s.hooks.mu.RLock()
+	baseURL := s.hooks.baseURL
+	s.hooks.mu.RUnlock()
+
+	if baseURL == "" {
+		return "", fmt.Errorf("URL base de hooks no configurada")
+	}
+
+	token, err := generateHookToken()
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/hooks/%s", baseURL, terminalID)
+	command := fmt.Sprintf(
+		"curl -s --max-time 10 -X POST -H 'Content-Type: application/json' -H 'X-Hook-Token: %s' --data-binary @- '%s'",
+		token, endpoint,
+	)
+
+	hooks := make(map[string]interface{}, len(hookSettingsEvents))
+	for _, event := range hookSettingsEvents {
+		entry := map[string]interface{}{
+			"hooks": []map[string]interface{}{
+				{"type": "command", "command": command},
+			},
+		}
+		if event == HookPreToolUse || event == HookPostToolUse {
+			entry["matcher"] = "*"
+		}
+		hooks[string(event)] = []interface{}{entry}
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{"hooks": hooks}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.hooks.dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.hooks.dir, terminalID+".json")
+	if err := atomicWriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+
+	s.hooks.mu.Lock()
+	s.hooks.tokens[terminalID] = token
+	s.hooks.mu.Unlock()
+
+	return path, nil
+}
+
+// removeHooksSettings elimina token y archivo de settings de una terminal
+func (s *TerminalService) removeHooksSettings(terminalID string) {
+	s.hooks.mu.Lock()
+	_, ok := s.hooks.tokens[terminalID]
+	delete(s.hooks.tokens, terminalID)
+	s.hooks.mu.Unlock()
+
+	if ok {
+		os.Remove(filepath.Join(s.hooks.dir, terminalID+".json"))
+	}
+}
+
+// generateHookToken genera un token aleatorio para hooks
+func generateHookToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ApplyHookEvent aplica un evento recibido de los hooks de Claude Code al
+// estado de la terminal: historial de eventos, estado, checkpoints y contadores.
+func (s *TerminalService) ApplyHookEvent(terminalID string, payload *HookPayload) error {
+	tc, err := s.getClaudeTerminal(terminalID)
+	if err != nil {
+		return err
+	}
+
+	cs := tc.GetClaudeScreen()
+	if cs == nil {
+		return fmt.Errorf("claude state no disponible: %s", terminalID)
+	}
+
+	data := HookEventData{
+		Source:    "hook",
+		SessionID: payload.SessionID,
+		ToolUseID: payload.ToolUseID,
+		Command:   payload.ToolInputString("command"),
+		FilePath:  payload.ToolInputString("file_path"),
+		Message:   payload.Message,
+		Reason:    payload.Reason,
+	}
+
+	switch payload.HookEventName {
+	case HookPreToolUse:
+		cs.SetState(StateToolRunning, payload.ToolName)
+
+	case HookPostToolUse:
+		if fileEditTools[payload.ToolName] {
+			checkpointID := payload.ToolUseID
+			if checkpointID == "" {
+				checkpointID = generateUUID()
+			}
+			var files []string
+			if data.FilePath != "" {
+				files = []string{data.FilePath}
+			} else if nb := payload.ToolInputString("notebook_path"); nb != "" {
+				files = []string{nb}
+			}
+			cs.AddCheckpoint(checkpointID, payload.ToolName, files)
+		}
+		cs.SetState(StateGenerating, "")
+
+	case HookPermissionRequest:
+		cs.SetState(StatePermissionPrompt, payload.ToolName)
+
+	case HookNotification:
+		msg := strings.ToLower(payload.Message)
+		switch {
+		case strings.Contains(msg, "permission"):
+			cs.SetState(StatePermissionPrompt, extractNotificationTool(payload.Message))
+		case strings.Contains(msg, "waiting for your input"):
+			cs.SetState(StateWaitingInput, "")
+		}
+
+	case HookUserPromptSubmit:
+		tc.IncrementMessageCount(true)
+		cs.SetState(StateGenerating, "")
+
+	case HookStop:
+		tc.IncrementMessageCount(false)
+		cs.SetState(StateWaitingInput, "")
+
+	case HookSessionStart:
+		cs.SetState(StateWaitingInput, "")
+
+	case HookSessionEnd:
+		cs.SetState(StateExited, "")
+
+	case HookSubagentStop, HookPreCompact:
+		// Solo se registran en el historial
+
+	default:
+		return fmt.Errorf("evento de hook desconocido: %s", payload.HookEventName)
+	}
+
+	cs.AddEvent(payload.HookEventName, payload.ToolName, data)
+	tc.BroadcastClaudeEvent("hook", HookEvent{
+		Type:      payload.HookEventName,
+		Tool:      payload.ToolName,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+
+	logger.Debug("Hook recibido",
+		"terminal_id", terminalID,
+		"event", payload.HookEventName,
+		"tool", payload.ToolName,
+	)
+
+	return nil
+}
+
+// ToolInputString retorna un campo string de tool_input
+func (p *HookPayload) ToolInputString(key string) string {
+	if p.ToolInput == nil {
+		return ""
+	}
+	if v, ok := p.ToolInput[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// extractNotificationTool extrae la herramienta de "Claude needs your permission to use Bash"
+func extractNotificationTool(message string) string {
+	const marker = "permission to use "
+	idx := strings.Index(strings.ToLower(message), marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := strings.TrimSpace(message[idx+len(marker):])
+	if fields := strings.Fields(rest); len(fields) > 0 {
+		return strings.TrimRight(fields[0], ".,:")
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newHookTestService(t *testing.T) (*TerminalService, *TerminalClaude) {
+	t.Helper()
+	s := NewTerminalService(t.TempDir())
+	s.SetHooksBaseURL("http://127.0.0.1:9090/")
+
+	tc := NewTerminalClaude("term-1", "test", "/tmp", TerminalConfig{})
+	tc.SetClaudeScreen(NewClaudeAwareScreenHandler(80, 24))
+	s.terminals["term-1"] = tc
+
+	return s, tc
+}
+
+func TestTerminalService_WriteHooksSettings(t *testing.T) {
+	s, _ := newHookTestService(t)
+
+	path, err := s.writeHooksSettings("term-1")
+	if err != nil {
+		t.Fatalf("writeHooksSettings error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("settings file not written: %v", err)
+	}
+
+	var settings struct {
+		Hooks map[string][]struct {
+			Matcher string `json:"matcher"`
+			Hooks   []struct {
+				Type    string `json:"type"`
+				Command string `json:"command"`
+			} `json:"hooks"`
+		} `json:"hooks"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		t.Fatalf("invalid settings JSON: %v", err)
+	}
+
+	pre := settings.Hooks["PreToolUse"]
+	if len(pre) != 1 || pre[0].Matcher != "*" || len(pre[0].Hooks) != 1 {
+		t.Fatalf("PreToolUse hook not configured: %+v", pre)
+	}
+	cmd := pre[0].Hooks[0].Command
+	if !strings.Contains(cmd, "http://127.0.0.1:9090/api/hooks/term-1") {
+		t.Errorf("Hook command has wrong endpoint: %s", cmd)
+	}
+
+	token := s.hooks.tokens["term-1"]
+	if !strings.Contains(cmd, "X-Hook-Token: "+token) {
+		t.Errorf("Hook command missing token: %s", cmd)
+	}
+	if !s.ValidateHookToken("term-1", token) {
+		t.Error("Token should be valid")
+	}
+	if s.ValidateHookToken("term-1", "wrong") || s.ValidateHookToken("term-2", token) {
+		t.Error("Wrong token or terminal should be rejected")
+	}
+
+	s.removeHooksSettings("term-1")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Settings file should be removed")
+	}
+	if s.ValidateHookToken("term-1", token) {
+		t.Error("Token should be revoked")
+	}
+}
+
+func TestTerminalService_WriteHooksSettings_NoBaseURL(t *testing.T) {
+	s := NewTerminalService(t.TempDir())
+	if _, err := s.writeHooksSettings("term-1"); err == nil {
+		t.Error("Should fail without base URL")
+	}
+}
+
+func TestBuildClaudeArgs_Settings(t *testing.T) {
+	args := buildClaudeArgs(TerminalConfig{SettingsFile: "/data/hooks/x.json"})
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--settings /data/hooks/x.json") {
+		t.Errorf("Expected --settings arg, got %v", args)
+	}
+}
+
+func TestTerminalService_ApplyHookEvent(t *testing.T) {
+	s, tc := newHookTestService(t)
+
+	steps := []struct {
+		payload HookPayload
+		want    ClaudeState
+	}{
+		{HookPayload{HookEventName: HookUserPromptSubmit, Prompt: "hola"}, StateGenerating},
+		{HookPayload{HookEventName: HookPreToolUse, ToolName: "Bash", ToolInput: map[string]interface{}{"command": "ls"}}, StateToolRunning},
+		{HookPayload{HookEventName: HookPostToolUse, ToolName: "Bash"}, StateGenerating},
+		{HookPayload{HookEventName: HookNotification, Message: "Claude needs your permission to use Write"}, StatePermissionPrompt},
+		{HookPayload{HookEventName: HookPostToolUse, ToolName: "Write", ToolUseID: "toolu_1", ToolInput: map[string]interface{}{"file_path": "/tmp/a.go"}}, StateGenerating},
+		{HookPayload{HookEventName: HookStop}, StateWaitingInput},
+		{HookPayload{HookEventName: HookSessionEnd, Reason: "exit"}, StateExited},
+	}
+
+	for i, step := range steps {
+		p := step.payload
+		if err := s.ApplyHookEvent("term-1", &p); err != nil {
+			t.Fatalf("step %d: ApplyHookEvent error: %v", i, err)
+		}
+		if state := tc.GetClaudeState(); state.State != step.want {
+			t.Errorf("step %d (%s): state %s, want %s", i, p.HookEventName, state.State, step.want)
+		}
+	}
+
+	checkpoints := tc.GetCheckpoints()
+	if len(checkpoints) != 1 || checkpoints[0].ID != "toolu_1" || checkpoints[0].FilesAffected[0] != "/tmp/a.go" {
+		t.Errorf("Expected checkpoint from Write, got %+v", checkpoints)
+	}
+
+	if events := tc.GetEvents(); len(events) != len(steps) {
+		t.Errorf("Expected %d events, got %d", len(steps), len(events))
+	}
+
+	if tc.GetUserMessages() != 1 || tc.GetAssistantMessages() != 1 {
+		t.Errorf("Message counters: user=%d assistant=%d", tc.GetUserMessages(), tc.GetAssistantMessages())
+	}
+
+	bad := HookPayload{HookEventName: "Bogus"}
+	if err := s.ApplyHookEvent("term-1", &bad); err == nil {
+		t.Error("Unknown event should fail")
+	}
+	if err := s.ApplyHookEvent("missing", &HookPayload{HookEventName: HookStop}); err == nil {
+		t.Error("Missing terminal should fail")
+	}
+}
+
+func TestExtractNotificationTool(t *testing.T) {
+	if got := extractNotificationTool("Claude needs your permission to use Bash"); got != "Bash" {
+		t.Errorf("got %q", got)
+	}
+	if got := extractNotificationTool("Claude is waiting for your input"); got != "" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JobStatus estado de un job headless
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+	JobTimedOut  JobStatus = "timed_out"
+)
+
+// jobTransitions transiciones permitidas entre estados de job
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobQueued:  {JobRunning, JobCancelled, JobFailed},
+	JobRunning: {JobSucceeded, JobFailed, JobCancelled, JobTimedOut},
+}
+
+// canTransitionJob verifica si un job puede pasar de un estado a otro
+func canTransitionJob(from, to JobStatus) bool {
+	for _, s := range jobTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinished indica si el estado es final
+func (s JobStatus) IsFinished() bool {
+	return len(jobTransitions[s]) == 0
+}
+
+// Formatos de salida de claude -p
+const (
+	JobOutputStreamJSON = "stream-json"
+	JobOutputJSON       = "json"
+	JobOutputText       = "text"
+)
+
+// JobRequest parámetros de un job: claude -p <prompt> en un directorio
+type JobRequest struct {
+	Name            string   `json:"name,omitempty"`
+	Prompt          string   `json:"prompt"`
+	WorkDir         string   `json:"work_dir"`
+	Model           string   `json:"model,omitempty"`
+	SystemPrompt    string   `json:"system_prompt,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	PermissionMode  string   `json:"permission_mode,omitempty"`
+	MaxTurns        int      `json:"max_turns,omitempty"`
+	OutputFormat    string   `json:"output_format,omitempty"` // stream-json (default), json o text
+	TimeoutSeconds  int      `json:"timeout_seconds,omitempty"`
+}
+
+// Job ejecución headless de Claude
+type Job struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Status     JobStatus  `json:"status"`
+	Request    JobRequest `json:"request"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Stderr     string     `json:"stderr,omitempty"` // últimos bytes de stderr
+
+	// Resultado extraído de la salida estructurada
+	SessionID   string  `json:"session_id,omitempty"`
+	Result      string  `json:"result,omitempty"`
+	IsError     bool    `json:"is_error,omitempty"`
+	NumTurns    int     `json:"num_turns,omitempty"`
+	CostUSD     float64 `json:"cost_usd,omitempty"`
+	DurationMs  int64   `json:"duration_ms,omitempty"`
+	OutputLines int     `json:"output_lines"`
+}
+
+// JobOutput líneas de salida de un job a partir de un offset
+type JobOutput struct {
+	JobID    string            `json:"job_id"`
+	Status   JobStatus         `json:"status"`
+	From     int               `json:"from"`
+	Next     int               `json:"next"`
+	Lines    []json.RawMessage `json:"lines,omitempty"` // stream-json / json
+	Text     []string          `json:"text,omitempty"`  // formato text
+	Finished bool              `json:"finished"`
+}
+
+// buildJobArgs construye los argumentos de claude -p. El prompt va el último
+// tras "--": así un prompt que empiece por "-" no se interpreta como flag y las
+// opciones con varios valores (--allowed-tools) no se lo tragan.
+func buildJobArgs(req JobRequest) []string {
+	args := []string{"-p", "--output-format", req.OutputFormat}
+
+	// stream-json en modo print requiere --verbose
+	if req.OutputFormat == JobOutputStreamJSON {
+		args = append(args, "--verbose")
+	}
+	if req.Model != "" {
+		args = append(args, "--model", req.Model)
+	}
+	if req.SystemPrompt != "" {
+		args = append(args, "--system-prompt", req.SystemPrompt)
+	}
+	if req.PermissionMode != "" {
+		args = append(args, "--permission-mode", req.PermissionMode)
+	}
+	if req.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(req.MaxTurns))
+	}
+	if len(req.AllowedTools) > 0 {
+		args = append(args, "--allowed-tools", strings.Join(req.AllowedTools, ","))
+	}
+	if len(req.DisallowedTools) > 0 {
+		args = append(args, "--disallowed-tools", strings.Join(req.DisallowedTools, ","))
+	}
+
+	return append(args, "--", req.Prompt)
+}
+
+// jobStreamMessage campos de los mensajes stream-json que interesan al job
+type jobStreamMessage struct {
+	Type         string  `json:"type"`
+	Subtype      string  `json:"subtype"`
+	SessionID    string  `json:"session_id"`
+	Result       string  `json:"result"`
+	IsError      bool    `json:"is_error"`
+	NumTurns     int     `json:"num_turns"`
+	DurationMs   int64   `json:"duration_ms"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// applyOutputLine actualiza el resultado del job con una línea JSON de salida
+func (j *Job) applyOutputLine(line []byte) {
+	var msg jobStreamMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return
+	}
+
+	if msg.SessionID != "" && j.SessionID == "" {
+		j.SessionID = msg.SessionID
+	}
+	if msg.Type != "result" {
+		return
+	}
+
+	j.Result = msg.Result
+	j.IsError = msg.IsError
+	j.NumTurns = msg.NumTurns
+	j.DurationMs = msg.DurationMs
+	j.CostUSD = msg.TotalCostUSD
+	if j.CostUSD == 0 {
+		j.CostUSD = msg.CostUSD
+	}
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Límites de la salida capturada en memoria
+const (
+	maxJobStderr     = 8 * 1024
+	maxJobTextResult = 64 * 1024
+	jobOutputPoll    = 200 * time.Millisecond
+)
+
+// JobsConfig límites de la cola de jobs
+type JobsConfig struct {
+	MaxConcurrent         int `json:"max_concurrent"`
+	DefaultTimeoutSeconds int `json:"default_timeout_seconds"`
+	MaxTimeoutSeconds     int `json:"max_timeout_seconds"`
+}
+
+// DefaultJobsConfig configuración por defecto
+func DefaultJobsConfig() JobsConfig {
+	return JobsConfig{
+		MaxConcurrent:         2,
+		DefaultTimeoutSeconds: 30 * 60,
+		MaxTimeoutSeconds:     4 * 60 * 60,
+	}
+}
+
+// JobFilter filtros para listar jobs
+type JobFilter struct {
+	Status JobStatus
+	Limit  int
+}
+
+// JobService cola de jobs headless (claude -p) con límite de concurrencia
+type JobService struct {
+	config              JobsConfig
+	allowedPathPrefixes []string
+	events              *EventBus
+	file                string
+	outputDir           string
+	command             string // ejecutable de Claude (reemplazable en tests)
+
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	queue   []string                      // IDs en espera, en orden de llegada
+	running map[string]context.CancelFunc // IDs en ejecución -> cancelación
+	cancels map[string]bool               // cancelaciones pedidas por el usuario
+}
+
+// NewJobService crea la cola y recupera los jobs persistidos
+func NewJobService(dataDir string, cfg JobsConfig, events *EventBus, allowedPathPrefixes ...string) *JobService {
+	defaults := DefaultJobsConfig()
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaults.MaxConcurrent
+	}
+	if cfg.DefaultTimeoutSeconds <= 0 {
+		cfg.DefaultTimeoutSeconds = defaults.DefaultTimeoutSeconds
+	}
+	if cfg.MaxTimeoutSeconds <= 0 {
+		cfg.MaxTimeoutSeconds = defaults.MaxTimeoutSeconds
+	}
+
+	js := &JobService{
+		config:              cfg,
+		allowedPathPrefixes: allowedPathPrefixes,
+		events:              events,
+		file:                filepath.Join(dataDir, "jobs.json"),
+		outputDir:           filepath.Join(dataDir, "jobs"),
+		command:             "claude",
+		jobs:                make(map[string]*Job),
+		running:             make(map[string]context.CancelFunc),
+		cancels:             make(map[string]bool),
+	}
+	js.load()
+	js.dispatch()
+	return js
+}
+
+// load carga jobs desde disco. Los que estaban en ejecución se marcan como fallidos
+// y los que estaban en cola se vuelven a encolar.
+func (js *JobService) load() {
+	data, err := os.ReadFile(js.file)
+	if err != nil {
+		return
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		logger.Error("Error cargando jobs", "error", err)
+		return
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	for _, job := range jobs {
+		switch job.Status {
+		case JobRunning:
+			now := time.Now()
+			job.Status = JobFailed
+			job.Error = "interrumpido por reinicio del servidor"
+			job.FinishedAt = &now
+		case JobQueued:
+			js.queue = append(js.queue, job.ID)
+		}
+		js.jobs[job.ID] = job
+	}
+}
+
+// persist guarda los jobs de forma atómica
+func (js *JobService) persist() {
+	js.mu.RLock()
+	jobs := make([]*Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	js.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando jobs", "error", err)
+		return
+	}
+	if err := atomicWriteFile(js.file, data, 0600); err != nil {
+		logger.Error("Error guardando jobs", "error", err)
+	}
+}
+
+// ValidateRequest valida un JobRequest y completa los valores por defecto
+func (js *JobService) ValidateRequest(req *JobRequest) error {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return fmt.Errorf("prompt requerido")
+	}
+	if err := ValidatePath(req.WorkDir, js.allowedPathPrefixes); err != nil {
+		return err
+	}
+	if info, err := os.Stat(req.WorkDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("directorio invalido: %s", req.WorkDir)
+	}
+
+	switch req.OutputFormat {
+	case "":
+		req.OutputFormat = JobOutputStreamJSON
+	case JobOutputStreamJSON, JobOutputJSON, JobOutputText:
+	default:
+		return fmt.Errorf("output_format invalido: %s", req.OutputFormat)
+	}
+
+	if req.TimeoutSeconds <= 0 {
+		req.TimeoutSeconds = js.config.DefaultTimeoutSeconds
+	}
+	if req.TimeoutSeconds > js.config.MaxTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds excede el maximo (%d)", js.config.MaxTimeoutSeconds)
+	}
+
+	return nil
+}
+
+// Submit valida y encola un job
+func (js *JobService) Submit(req JobRequest) (*Job, error) {
+	if err := js.ValidateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:        generateUUID(),
+		Name:      req.Name,
+		Status:    JobQueued,
+		Request:   req,
+		CreatedAt: time.Now(),
+	}
+	if job.Name == "" {
+		job.Name = filepath.Base(req.WorkDir)
+	}
+
+	js.mu.Lock()
+	js.jobs[job.ID] = job
+	js.queue = append(js.queue, job.ID)
+	snapshot := *job
+	js.mu.Unlock()
+
+	js.persist()
+	js.events.Publish(EventJobQueued, "", snapshot)
+	logger.Info("Job encolado", "job_id", job.ID, "work_dir", req.WorkDir)
+
+	js.dispatch()
+	return &snapshot, nil
+}
+
+// List retorna jobs del más reciente al más antiguo
+func (js *JobService) List(filter JobFilter) []Job {
+	js.mu.RLock()
+	list := make([]Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		if filter.Status != "" && job.Status != filter.Status {
+			continue
+		}
+		list = append(list, *job)
+	}
+	js.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	return list
+}
+
+// Get retorna un job
+func (js *JobService) Get(id string) (*Job, error) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job no encontrado: %s", id)
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// Cancel cancela un job en cola o en ejecución
+func (js *JobService) Cancel(id string) (*Job, error) {
+	js.mu.Lock()
+	job, ok := js.jobs[id]
+	if !ok {
+		js.mu.Unlock()
+		return nil, fmt.Errorf("job no encontrado: %s", id)
+	}
+
+	switch job.Status {
+	case JobQueued:
+		for i, qid := range js.queue {
+			if qid == id {
+				js.queue = append(js.queue[:i], js.queue[i+1:]...)
+				break
+			}
+		}
+		js.finishLocked(job, JobCancelled, "cancelado por el usuario")
+		snapshot := *job
+		js.mu.Unlock()
+		js.persist()
+		js.events.Publish(EventJobFinished, "", snapshot)
+		return &snapshot, nil
+
+	case JobRunning:
+		js.cancels[id] = true
+		cancel := js.running[id]
+		snapshot := *job
+		js.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		return &snapshot, nil
+	}
+
+	js.mu.Unlock()
+	return nil, fmt.Errorf("job ya terminado: %s", job.Status)
+}
+
+// finishLocked pasa el job a un estado final (requiere js.mu)
+func (js *JobService) finishLocked(job *Job, status JobStatus, errMsg string) {
+	if !canTransitionJob(job.Status, status) {
+		return
+	}
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if errMsg != "" {
+		job.Error = errMsg
+	}
+}
+
+// dispatch inicia jobs en cola mientras haya capacidad
+func (js *JobService) dispatch() {
+	for {
+		js.mu.Lock()
+		if len(js.queue) == 0 || len(js.running) >= js.config.MaxConcurrent {
+			js.mu.Unlock()
+			return
+		}
+
+		id := js.queue[0]
+		js.queue = js.queue[1:]
+		job, ok := js.jobs[id]
+		if !ok || job.Status != JobQueued {
+			js.mu.Unlock()
+			continue
+		}
+
+		timeout := time.Duration(job.Request.TimeoutSeconds) * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		js.running[id] = cancel
+
+		now := time.Now()
+		job.Status = JobRunning
+		job.StartedAt = &now
+		snapshot := *job
+		js.mu.Unlock()
+
+		js.persist()
+		js.events.Publish(EventJobStarted, "", snapshot)
+		go js.execute(ctx, id, snapshot.Request)
+	}
+}
+
+// execute ejecuta claude -p y captura su salida
+func (js *JobService) execute(ctx context.Context, id string, req JobRequest) {
+	err := js.runCommand(ctx, id, req)
+
+	js.mu.Lock()
+	job := js.jobs[id]
+	cancel := js.running[id]
+	delete(js.running, id)
+	userCancelled := js.cancels[id]
+	delete(js.cancels, id)
+
+	var exitErr *exec.ExitError
+	switch {
+	case userCancelled:
+		js.finishLocked(job, JobCancelled, "cancelado por el usuario")
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		js.finishLocked(job, JobTimedOut, fmt.Sprintf("timeout de %ds excedido", req.TimeoutSeconds))
+	case err == nil && !job.IsError:
+		code := 0
+		job.ExitCode = &code
+		js.finishLocked(job, JobSucceeded, "")
+	case errors.As(err, &exitErr):
+		code := exitErr.ExitCode()
+		job.ExitCode = &code
+		js.finishLocked(job, JobFailed, fmt.Sprintf("claude termino con codigo %d", code))
+	case err != nil:
+		js.finishLocked(job, JobFailed, err.Error())
+	default:
+		code := 0
+		job.ExitCode = &code
+		js.finishLocked(job, JobFailed, "claude reporto un error")
+	}
+	snapshot := *job
+	js.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	js.persist()
+	js.events.Publish(EventJobFinished, "", snapshot)
+	logger.Info("Job terminado", "job_id", id, "status", snapshot.Status)
+
+	js.dispatch()
+}
+
+// runCommand lanza el proceso, escribe stdout en jobs/<id>.jsonl (o .txt) y extrae el resultado
+func (js *JobService) runCommand(ctx context.Context, id string, req JobRequest) error {
+	if err := os.MkdirAll(js.outputDir, 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(js.outputPath(id, req.OutputFormat), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	cmd := exec.CommandContext(ctx, js.command, buildJobArgs(req)...)
+	cmd.Dir = req.WorkDir
+	cmd.Env = os.Environ()
+	cmd.WaitDelay = 5 * time.Second
+	setJobProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &tailBuffer{max: maxJobStderr}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error iniciando claude: %v", err)
+	}
+
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	var text strings.Builder
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			out.Write(line)
+			trimmed := []byte(strings.TrimRight(string(line), "\r\n"))
+
+			js.mu.Lock()
+			job := js.jobs[id]
+			job.OutputLines++
+			if req.OutputFormat == JobOutputText {
+				if text.Len() < maxJobTextResult {
+					text.Write(line)
+				}
+				job.Result = text.String()
+			} else {
+				job.applyOutputLine(trimmed)
+			}
+			js.mu.Unlock()
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				logger.Debug("Error leyendo salida de job", "job_id", id, "error", readErr)
+			}
+			break
+		}
+	}
+
+	err = cmd.Wait()
+
+	js.mu.Lock()
+	js.jobs[id].Stderr = stderr.String()
+	js.mu.Unlock()
+
+	return err
+}
+
+// outputPath archivo con la salida completa del job
+func (js *JobService) outputPath(id, format string) string {
+	if format == JobOutputText {
+		return filepath.Join(js.outputDir, id+".txt")
+	}
+	return filepath.Join(js.outputDir, id+".jsonl")
+}
+
+// Output retorna las líneas de salida desde el offset from. Si wait > 0 y no hay
+// líneas nuevas espera hasta que aparezcan, el job termine o venza el plazo.
+func (js *JobService) Output(id string, from int, wait time.Duration) (*JobOutput, error) {
+	deadline := time.Now().Add(wait)
+
+	for {
+		job, err := js.Get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		finished := job.Status.IsFinished()
+		if job.OutputLines > from || finished || !time.Now().Before(deadline) {
+			return js.readOutput(job, from)
+		}
+		time.Sleep(jobOutputPoll)
+	}
+}
+
+// readOutput lee el archivo de salida a partir de la línea from
+func (js *JobService) readOutput(job *Job, from int) (*JobOutput, error) {
+	if from < 0 {
+		from = 0
+	}
+	result := &JobOutput{
+		JobID:    job.ID,
+		Status:   job.Status,
+		From:     from,
+		Next:     from,
+		Finished: job.Status.IsFinished(),
+	}
+
+	f, err := os.Open(js.outputPath(job.ID, job.Request.OutputFormat))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		if line >= from {
+			if job.Request.OutputFormat == JobOutputText {
+				result.Text = append(result.Text, scanner.Text())
+			} else if json.Valid(scanner.Bytes()) {
+				result.Lines = append(result.Lines, json.RawMessage(append([]byte{}, scanner.Bytes()...)))
+			}
+			result.Next = line + 1
+		}
+		line++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Shutdown cancela los jobs en ejecución (quedan como cancelados)
+func (js *JobService) Shutdown() {
+	js.mu.Lock()
+	for id, cancel := range js.running {
+		js.cancels[id] = true
+		cancel()
+	}
+	js.mu.Unlock()
+}
+
+// tailBuffer conserva los últimos max bytes escritos
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
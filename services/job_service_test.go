@@ -0,0 +1,166 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClaude script que imita claude -p: el prompt (último argumento) decide el comportamiento
+const fakeClaude = `#!/bin/sh
+for prompt; do :; done
+case "$prompt" in
+  sleep) echo '{"type":"system","subtype":"init","session_id":"s-sleep"}'; sleep 5 ;;
+  fail) echo "boom" >&2; exit 3 ;;
+  *)
+    echo '{"type":"system","subtype":"init","session_id":"s-1"}'
+    echo '{"type":"assistant","message":{"content":[{"type":"text","text":"hola"}]}}'
+    echo '{"type":"result","subtype":"success","result":"listo","is_error":false,"num_turns":2,"duration_ms":150,"total_cost_usd":0.01,"session_id":"s-1"}'
+    ;;
+esac
+`
+
+func newTestJobService(t *testing.T, dataDir string, cfg JobsConfig) *JobService {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(script, []byte(fakeClaude), 0755); err != nil {
+		t.Fatal(err)
+	}
+	js := NewJobService(dataDir, cfg, NewEventBus())
+	js.command = script
+	t.Cleanup(js.Shutdown)
+	return js
+}
+
+// waitForJob espera a que el job llegue a un estado final
+func waitForJob(t *testing.T, js *JobService, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := js.Get(id)
+		if job.Status.IsFinished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestJobService_RunAndOutput(t *testing.T) {
+	dataDir := t.TempDir()
+	js := newTestJobService(t, dataDir, JobsConfig{})
+
+	job, err := js.Submit(JobRequest{Prompt: "hola", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if job.Request.OutputFormat != JobOutputStreamJSON {
+		t.Errorf("default output format = %q", job.Request.OutputFormat)
+	}
+
+	done := waitForJob(t, js, job.ID)
+	if done.Status != JobSucceeded || done.SessionID != "s-1" || done.Result != "listo" ||
+		done.NumTurns != 2 || done.CostUSD != 0.01 || done.OutputLines != 3 {
+		t.Errorf("job = %+v", done)
+	}
+
+	out, err := js.Output(job.ID, 1, 0)
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if len(out.Lines) != 2 || out.Next != 3 || !out.Finished {
+		t.Errorf("output = %+v", out)
+	}
+
+	failed, _ := js.Submit(JobRequest{Prompt: "fail", WorkDir: t.TempDir()})
+	done = waitForJob(t, js, failed.ID)
+	if done.Status != JobFailed || done.ExitCode == nil || *done.ExitCode != 3 || !strings.Contains(done.Stderr, "boom") {
+		t.Errorf("failed job = %+v", done)
+	}
+
+	// Los resultados persisten entre reinicios
+	reloaded := NewJobService(dataDir, JobsConfig{}, NewEventBus())
+	if got, err := reloaded.Get(job.ID); err != nil || got.Result != "listo" {
+		t.Errorf("reloaded job = %+v, %v", got, err)
+	}
+}
+
+func TestJobService_ConcurrencyAndCancel(t *testing.T) {
+	js := newTestJobService(t, t.TempDir(), JobsConfig{MaxConcurrent: 1})
+	dir := t.TempDir()
+
+	first, _ := js.Submit(JobRequest{Prompt: "sleep", WorkDir: dir})
+	second, _ := js.Submit(JobRequest{Prompt: "hola", WorkDir: dir})
+
+	if got, _ := js.Get(second.ID); got.Status != JobQueued {
+		t.Errorf("second status = %s, want queued", got.Status)
+	}
+
+	// Cancelar en cola es inmediato
+	third, _ := js.Submit(JobRequest{Prompt: "hola", WorkDir: dir})
+	if cancelled, err := js.Cancel(third.ID); err != nil || cancelled.Status != JobCancelled {
+		t.Errorf("cancel queued = %+v, %v", cancelled, err)
+	}
+
+	if _, err := js.Cancel(first.ID); err != nil {
+		t.Fatalf("Cancel running: %v", err)
+	}
+	if done := waitForJob(t, js, first.ID); done.Status != JobCancelled {
+		t.Errorf("first status = %s, want cancelled", done.Status)
+	}
+	if done := waitForJob(t, js, second.ID); done.Status != JobSucceeded {
+		t.Errorf("second status = %s, want succeeded", done.Status)
+	}
+	if _, err := js.Cancel(second.ID); err == nil {
+		t.Error("cancelling a finished job should fail")
+	}
+}
+
+func TestJobService_Timeout(t *testing.T) {
+	js := newTestJobService(t, t.TempDir(), JobsConfig{})
+
+	job, _ := js.Submit(JobRequest{Prompt: "sleep", WorkDir: t.TempDir(), TimeoutSeconds: 1})
+	if done := waitForJob(t, js, job.ID); done.Status != JobTimedOut {
+		t.Errorf("status = %s, want timed_out", done.Status)
+	}
+
+	if _, err := js.Submit(JobRequest{Prompt: "x", WorkDir: t.TempDir(), TimeoutSeconds: 1 << 30}); err == nil {
+		t.Error("timeout above max should be rejected")
+	}
+}
+
+func TestBuildJobArgs(t *testing.T) {
+	args := buildJobArgs(JobRequest{
+		Prompt:       "hola",
+		OutputFormat: JobOutputStreamJSON,
+		Model:        "sonnet",
+		MaxTurns:     5,
+		AllowedTools: []string{"Read", "Bash(git:*)"},
+	})
+	want := "-p --output-format stream-json --verbose --model sonnet --max-turns 5 --allowed-tools Read,Bash(git:*) -- hola"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("args = %q", got)
+	}
+}
+
+func TestBuildJobArgs_PromptLikeFlag(t *testing.T) {
+	args := buildJobArgs(JobRequest{
+		Prompt:          "--dangerously-skip-permissions",
+		OutputFormat:    JobOutputJSON,
+		DisallowedTools: []string{"Bash"},
+	})
+
+	// El prompt es el único argumento tras "--"
+	n := len(args)
+	if n < 2 || args[n-2] != "--" || args[n-1] != "--dangerously-skip-permissions" {
+		t.Fatalf("prompt not after --: %q", args)
+	}
+	for _, arg := range args[:n-2] {
+		if arg == "--dangerously-skip-permissions" {
+			t.Errorf("prompt leaked before --: %q", args)
+		}
+	}
+}
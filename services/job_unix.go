@@ -0,0 +1,17 @@
+//go:build !windows
+
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setJobProcessGroup ejecuta el job en su propio grupo de procesos para que la
+// cancelación y el timeout terminen también las herramientas que lanzó Claude
+func setJobProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
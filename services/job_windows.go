@@ -0,0 +1,8 @@
+//go:build windows
+
+package services
+
+import "os/exec"
+
+// setJobProcessGroup en Windows se conserva la cancelación por defecto (mata el proceso)
+func setJobProcessGroup(cmd *exec.Cmd) {}
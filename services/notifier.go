@@ -0,0 +1,602 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// NotificationEvent situación que requiere atención
+type NotificationEvent string
+
+const (
+	NotifyPermissionPrompt NotificationEvent = "permission_prompt" // Claude pide permiso
+	NotifyWaitingInput     NotificationEvent = "waiting_input"     // Claude terminó un turno y espera input
+	NotifyError            NotificationEvent = "error"             // Claude muestra un error
+	NotifyExited           NotificationEvent = "exited"            // el proceso de la terminal terminó
+)
+
+// Espera antes de notificar un permiso: las políticas lo pueden responder antes
+const defaultPermissionNotifyDelay = 3 * time.Second
+
+// Número de entregas recientes que se conservan en memoria
+const maxRecentNotifications = 50
+
+// NotificationRule envía los eventos indicados a los canales indicados
+type NotificationRule struct {
+	Events   []NotificationEvent `json:"events"`
+	Channels []string            `json:"channels"`
+	// waiting_input: solo notificar si el turno duró al menos esto
+	MinGenerationSeconds int `json:"min_generation_seconds,omitempty"`
+}
+
+// NotificationRules reglas de una terminal, un session-root o por defecto
+type NotificationRules struct {
+	Rules     []NotificationRule `json:"rules"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// Notification mensaje enviado a los canales
+type Notification struct {
+	Event        NotificationEvent `json:"event"`
+	TerminalID   string            `json:"terminal_id"`
+	TerminalName string            `json:"terminal_name,omitempty"`
+	WorkDir      string            `json:"work_dir,omitempty"`
+	Tool         string            `json:"tool,omitempty"`
+	DurationSec  int64             `json:"duration_seconds,omitempty"` // waiting_input: duración del turno
+	Title        string            `json:"title"`
+	Message      string            `json:"message"`
+	Timestamp    time.Time         `json:"timestamp"`
+}
+
+// NotificationDelivery resultado de enviar una notificación por un canal
+type NotificationDelivery struct {
+	Channel    string            `json:"channel"`
+	Event      NotificationEvent `json:"event"`
+	TerminalID string            `json:"terminal_id"`
+	Success    bool              `json:"success"`
+	Error      string            `json:"error,omitempty"`
+	SentAt     time.Time         `json:"sent_at"`
+}
+
+// NotificationStatus configuración del notificador para la API (secretos ocultos)
+type NotificationStatus struct {
+	Channels []NotificationChannel  `json:"channels"`
+	Default  *NotificationRules     `json:"default,omitempty"`
+	Recent   []NotificationDelivery `json:"recent"`
+}
+
+// notificationsFile formato de notifications.json
+type notificationsFile struct {
+	Channels     []NotificationChannel         `json:"channels"`
+	Default      *NotificationRules            `json:"default,omitempty"`
+	Terminals    map[string]*NotificationRules `json:"terminals"`
+	SessionRoots map[string]*NotificationRules `json:"session_roots"`
+}
+
+// Validate valida las reglas contra los canales configurados
+func (r *NotificationRules) Validate(channels map[string]NotificationChannel) error {
+	for i, rule := range r.Rules {
+		if len(rule.Events) == 0 {
+			return fmt.Errorf("rules[%d]: events requerido", i)
+		}
+		for _, e := range rule.Events {
+			switch e {
+			case NotifyPermissionPrompt, NotifyWaitingInput, NotifyError, NotifyExited:
+			default:
+				return fmt.Errorf("rules[%d]: evento invalido: %s", i, e)
+			}
+		}
+		if len(rule.Channels) == 0 {
+			return fmt.Errorf("rules[%d]: channels requerido", i)
+		}
+		for _, name := range rule.Channels {
+			if _, ok := channels[name]; !ok {
+				return fmt.Errorf("rules[%d]: canal desconocido: %s", i, name)
+			}
+		}
+		if rule.MinGenerationSeconds < 0 {
+			return fmt.Errorf("rules[%d]: min_generation_seconds invalido", i)
+		}
+	}
+	return nil
+}
+
+// NotifierService envía notificaciones cuando una terminal necesita atención
+type NotifierService struct {
+	terminals *TerminalService
+	events    *EventBus
+	file      string
+
+	mu           sync.RWMutex
+	channels     map[string]NotificationChannel
+	order        []string
+	defaults     *NotificationRules
+	byTerminal   map[string]*NotificationRules
+	bySessionDir map[string]*NotificationRules // clave: session-root codificado
+
+	turnsMu sync.Mutex
+	turns   map[string]time.Time // terminal ID -> inicio del turno en curso
+
+	recentMu sync.Mutex
+	recent   []NotificationDelivery
+
+	permissionDelay time.Duration
+	send            func(ctx context.Context, ch NotificationChannel, n Notification) error
+	cancel          func()
+}
+
+// NewNotifierService crea el servicio y se suscribe al bus de eventos
+func NewNotifierService(dataDir string, terminals *TerminalService, events *EventBus) *NotifierService {
+	ns := &NotifierService{
+		terminals:       terminals,
+		events:          events,
+		file:            filepath.Join(dataDir, "notifications.json"),
+		channels:        make(map[string]NotificationChannel),
+		byTerminal:      make(map[string]*NotificationRules),
+		bySessionDir:    make(map[string]*NotificationRules),
+		turns:           make(map[string]time.Time),
+		permissionDelay: defaultPermissionNotifyDelay,
+		send:            sendNotification,
+	}
+	ns.load()
+
+	ch, cancel := events.Subscribe(EventFilter{
+		Types: []EventType{EventClaudeState, EventClaudePermission, EventTerminalEnded},
+	})
+	ns.cancel = cancel
+	go ns.run(ch)
+
+	return ns
+}
+
+// Stop cancela la suscripción al bus
+func (ns *NotifierService) Stop() {
+	ns.cancel()
+}
+
+// load carga canales y reglas desde disco
+func (ns *NotifierService) load() {
+	data, err := os.ReadFile(ns.file)
+	if err != nil {
+		return
+	}
+
+	var stored notificationsFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		logger.Error("Error cargando notificaciones", "error", err)
+		return
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for _, ch := range stored.Channels {
+		if err := ch.Validate(); err != nil {
+			logger.Warn("Canal de notificación invalido", "error", err)
+			continue
+		}
+		ns.channels[ch.Name] = ch
+		ns.order = append(ns.order, ch.Name)
+	}
+	if stored.Default != nil && stored.Default.Validate(ns.channels) == nil {
+		ns.defaults = stored.Default
+	}
+	for id, r := range stored.Terminals {
+		if r.Validate(ns.channels) == nil {
+			ns.byTerminal[id] = r
+		}
+	}
+	for root, r := range stored.SessionRoots {
+		if r.Validate(ns.channels) == nil {
+			ns.bySessionDir[root] = r
+		}
+	}
+}
+
+// persist guarda canales y reglas de forma atómica (contiene secretos: 0600)
+func (ns *NotifierService) persist() {
+	ns.mu.RLock()
+	stored := notificationsFile{
+		Channels:     make([]NotificationChannel, 0, len(ns.order)),
+		Default:      ns.defaults,
+		Terminals:    ns.byTerminal,
+		SessionRoots: ns.bySessionDir,
+	}
+	for _, name := range ns.order {
+		stored.Channels = append(stored.Channels, ns.channels[name])
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	ns.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando notificaciones", "error", err)
+		return
+	}
+	if err := atomicWriteFile(ns.file, data, 0600); err != nil {
+		logger.Error("Error guardando notificaciones", "error", err)
+	}
+}
+
+// Status retorna canales (con secretos ocultos), reglas por defecto y entregas recientes
+func (ns *NotifierService) Status() NotificationStatus {
+	ns.mu.RLock()
+	status := NotificationStatus{
+		Channels: make([]NotificationChannel, 0, len(ns.order)),
+		Default:  ns.defaults,
+	}
+	for _, name := range ns.order {
+		status.Channels = append(status.Channels, ns.channels[name].masked())
+	}
+	ns.mu.RUnlock()
+
+	ns.recentMu.Lock()
+	status.Recent = append([]NotificationDelivery{}, ns.recent...)
+	ns.recentMu.Unlock()
+
+	return status
+}
+
+// SetChannels reemplaza los canales. Los secretos enmascarados conservan el valor
+// anterior si el destino del canal no cambia.
+// Falla si alguna regla referencia un canal que desaparece.
+func (ns *NotifierService) SetChannels(channels []NotificationChannel) error {
+	ns.mu.Lock()
+
+	next := make(map[string]NotificationChannel, len(channels))
+	order := make([]string, 0, len(channels))
+	for i := range channels {
+		ch := channels[i]
+		if prev, ok := ns.channels[ch.Name]; ok {
+			if err := ch.restoreSecrets(prev); err != nil {
+				ns.mu.Unlock()
+				return fmt.Errorf("channels[%d]: %v", i, err)
+			}
+		}
+		if err := ch.Validate(); err != nil {
+			ns.mu.Unlock()
+			return fmt.Errorf("channels[%d]: %v", i, err)
+		}
+		if _, dup := next[ch.Name]; dup {
+			ns.mu.Unlock()
+			return fmt.Errorf("channels[%d]: nombre duplicado: %s", i, ch.Name)
+		}
+		next[ch.Name] = ch
+		order = append(order, ch.Name)
+	}
+
+	check := func(scope string, r *NotificationRules) error {
+		if r == nil {
+			return nil
+		}
+		if err := r.Validate(next); err != nil {
+			return fmt.Errorf("reglas de %s: %v", scope, err)
+		}
+		return nil
+	}
+	if err := check("default", ns.defaults); err != nil {
+		ns.mu.Unlock()
+		return err
+	}
+	for id, r := range ns.byTerminal {
+		if err := check("terminal "+id, r); err != nil {
+			ns.mu.Unlock()
+			return err
+		}
+	}
+	for root, r := range ns.bySessionDir {
+		if err := check("session-root "+root, r); err != nil {
+			ns.mu.Unlock()
+			return err
+		}
+	}
+
+	ns.channels = next
+	ns.order = order
+	ns.mu.Unlock()
+
+	ns.persist()
+	return nil
+}
+
+// setRules valida y guarda reglas en el mapa indicado (nil = reglas por defecto)
+func (ns *NotifierService) setRules(target map[string]*NotificationRules, key string, rules *NotificationRules) error {
+	ns.mu.Lock()
+	if err := rules.Validate(ns.channels); err != nil {
+		ns.mu.Unlock()
+		return err
+	}
+	rules.UpdatedAt = time.Now()
+	if target == nil {
+		ns.defaults = rules
+	} else {
+		target[key] = rules
+	}
+	ns.mu.Unlock()
+
+	ns.persist()
+	return nil
+}
+
+// SetDefaultRules configura las reglas aplicadas a terminales sin reglas propias
+func (ns *NotifierService) SetDefaultRules(rules *NotificationRules) error {
+	return ns.setRules(nil, "", rules)
+}
+
+// GetTerminalRules retorna las reglas de una terminal
+func (ns *NotifierService) GetTerminalRules(terminalID string) (*NotificationRules, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	r, ok := ns.byTerminal[terminalID]
+	return r, ok
+}
+
+// SetTerminalRules configura las reglas de una terminal
+func (ns *NotifierService) SetTerminalRules(terminalID string, rules *NotificationRules) error {
+	return ns.setRules(ns.byTerminal, terminalID, rules)
+}
+
+// DeleteTerminalRules elimina las reglas de una terminal
+func (ns *NotifierService) DeleteTerminalRules(terminalID string) {
+	ns.mu.Lock()
+	delete(ns.byTerminal, terminalID)
+	ns.mu.Unlock()
+	ns.persist()
+}
+
+// GetSessionRootRules retorna las reglas de un session-root
+func (ns *NotifierService) GetSessionRootRules(rootPath string) (*NotificationRules, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	r, ok := ns.bySessionDir[rootPath]
+	return r, ok
+}
+
+// SetSessionRootRules configura las reglas de un session-root
+func (ns *NotifierService) SetSessionRootRules(rootPath string, rules *NotificationRules) error {
+	return ns.setRules(ns.bySessionDir, rootPath, rules)
+}
+
+// DeleteSessionRootRules elimina las reglas de un session-root
+func (ns *NotifierService) DeleteSessionRootRules(rootPath string) {
+	ns.mu.Lock()
+	delete(ns.bySessionDir, rootPath)
+	ns.mu.Unlock()
+	ns.persist()
+}
+
+// ResolveRules retorna las reglas efectivas: terminal, session-root del directorio
+// de trabajo o reglas por defecto
+func (ns *NotifierService) ResolveRules(terminalID, workDir string) (*NotificationRules, string) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	if r, ok := ns.byTerminal[terminalID]; ok {
+		return r, "terminal"
+	}
+	if workDir != "" {
+		if r, ok := ns.bySessionDir[EncodeProjectPath(workDir)]; ok {
+			return r, "session_root"
+		}
+	}
+	if ns.defaults != nil {
+		return ns.defaults, "default"
+	}
+	return nil, ""
+}
+
+// TestChannel envía una notificación de prueba por un canal
+func (ns *NotifierService) TestChannel(name string) (*NotificationDelivery, error) {
+	ns.mu.RLock()
+	ch, ok := ns.channels[name]
+	ns.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("canal no encontrado: %s", name)
+	}
+
+	n := Notification{
+		Event:     "test",
+		Title:     "claude-monitor: notificación de prueba",
+		Message:   fmt.Sprintf("Canal %s (%s) configurado correctamente", ch.Name, ch.Type),
+		Timestamp: time.Now(),
+	}
+	d := ns.deliver(ch, n)
+	return &d, nil
+}
+
+// run procesa los eventos del bus
+func (ns *NotifierService) run(events <-chan Event) {
+	for e := range events {
+		switch e.Type {
+		case EventClaudeState:
+			if data, ok := e.Data.(StateChangeData); ok {
+				ns.handleStateChange(e, ClaudeState(data.OldState), ClaudeState(data.NewState))
+			}
+		case EventClaudePermission:
+			if data, ok := e.Data.(PermissionData); ok {
+				ns.handlePermission(e, data.Tool)
+			}
+		case EventTerminalEnded:
+			ns.clearTurn(e.TerminalID)
+			ns.notify(NotifyExited, e.TerminalID, e.Timestamp, "", 0)
+		}
+	}
+}
+
+// handleStateChange lleva la cuenta de los turnos y notifica errores y fin de turno
+func (ns *NotifierService) handleStateChange(e Event, old, new ClaudeState) {
+	switch new {
+	case StateGenerating, StateToolRunning, StatePermissionPrompt:
+		ns.turnsMu.Lock()
+		if _, ok := ns.turns[e.TerminalID]; !ok {
+			ns.turns[e.TerminalID] = e.Timestamp
+		}
+		ns.turnsMu.Unlock()
+
+	case StateWaitingInput:
+		ns.turnsMu.Lock()
+		start, ok := ns.turns[e.TerminalID]
+		delete(ns.turns, e.TerminalID)
+		ns.turnsMu.Unlock()
+
+		if ok {
+			ns.notify(NotifyWaitingInput, e.TerminalID, e.Timestamp, "", e.Timestamp.Sub(start))
+		}
+
+	case StateError:
+		ns.clearTurn(e.TerminalID)
+		ns.notify(NotifyError, e.TerminalID, e.Timestamp, "", 0)
+	}
+}
+
+// handlePermission notifica el permiso si sigue pendiente tras la espera
+func (ns *NotifierService) handlePermission(e Event, tool string) {
+	time.AfterFunc(ns.permissionDelay, func() {
+		info, err := ns.terminals.GetClaudeState(e.TerminalID)
+		if err != nil || info.State != StatePermissionPrompt {
+			return
+		}
+		ns.notify(NotifyPermissionPrompt, e.TerminalID, e.Timestamp, tool, 0)
+	})
+}
+
+// clearTurn descarta el turno en curso de una terminal
+func (ns *NotifierService) clearTurn(terminalID string) {
+	ns.turnsMu.Lock()
+	delete(ns.turns, terminalID)
+	ns.turnsMu.Unlock()
+}
+
+// notify resuelve las reglas de la terminal y envía la notificación a los canales que correspondan
+func (ns *NotifierService) notify(event NotificationEvent, terminalID string, at time.Time, tool string, turn time.Duration) {
+	n := Notification{
+		Event:        event,
+		TerminalID:   terminalID,
+		TerminalName: terminalID,
+		Tool:         tool,
+		Timestamp:    at,
+	}
+	if info, err := ns.terminals.Get(terminalID); err == nil {
+		if info.Name != "" {
+			n.TerminalName = info.Name
+		}
+		n.WorkDir = info.WorkDir
+	}
+	if event == NotifyWaitingInput {
+		n.DurationSec = int64(turn.Seconds())
+	}
+
+	rules, _ := ns.ResolveRules(terminalID, n.WorkDir)
+	if rules == nil {
+		return
+	}
+
+	targets := make(map[string]bool)
+	for _, rule := range rules.Rules {
+		if !rule.wants(event, turn) {
+			continue
+		}
+		for _, name := range rule.Channels {
+			targets[name] = true
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	n.Title, n.Message = formatNotification(n)
+
+	ns.mu.RLock()
+	var channels []NotificationChannel
+	for name := range targets {
+		if ch, ok := ns.channels[name]; ok && !ch.Disabled {
+			channels = append(channels, ch)
+		}
+	}
+	ns.mu.RUnlock()
+
+	for _, ch := range channels {
+		go ns.deliver(ch, n)
+	}
+}
+
+// wants indica si la regla aplica al evento
+func (r NotificationRule) wants(event NotificationEvent, turn time.Duration) bool {
+	for _, e := range r.Events {
+		if e != event {
+			continue
+		}
+		if event == NotifyWaitingInput && turn < time.Duration(r.MinGenerationSeconds)*time.Second {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// deliver envía por un canal y registra el resultado
+func (ns *NotifierService) deliver(ch NotificationChannel, n Notification) NotificationDelivery {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+	defer cancel()
+
+	err := ns.send(ctx, ch, n)
+
+	d := NotificationDelivery{
+		Channel:    ch.Name,
+		Event:      n.Event,
+		TerminalID: n.TerminalID,
+		Success:    err == nil,
+		SentAt:     time.Now(),
+	}
+	if err != nil {
+		d.Error = err.Error()
+		logger.Warn("Error enviando notificación", "channel", ch.Name, "event", n.Event, "error", err)
+	} else {
+		logger.Debug("Notificación enviada", "channel", ch.Name, "event", n.Event, "terminal_id", n.TerminalID)
+	}
+
+	ns.recentMu.Lock()
+	ns.recent = append(ns.recent, d)
+	if len(ns.recent) > maxRecentNotifications {
+		ns.recent = ns.recent[len(ns.recent)-maxRecentNotifications:]
+	}
+	ns.recentMu.Unlock()
+
+	return d
+}
+
+// formatNotification genera título y mensaje legibles
+func formatNotification(n Notification) (string, string) {
+	var title string
+	switch n.Event {
+	case NotifyPermissionPrompt:
+		title = fmt.Sprintf("%s: Claude pide permiso", n.TerminalName)
+		if n.Tool != "" {
+			title = fmt.Sprintf("%s: Claude pide permiso para %s", n.TerminalName, n.Tool)
+		}
+	case NotifyWaitingInput:
+		title = fmt.Sprintf("%s: Claude espera input", n.TerminalName)
+	case NotifyError:
+		title = fmt.Sprintf("%s: Claude muestra un error", n.TerminalName)
+	case NotifyExited:
+		title = fmt.Sprintf("%s: la terminal terminó", n.TerminalName)
+	default:
+		title = fmt.Sprintf("%s: %s", n.TerminalName, n.Event)
+	}
+
+	msg := title
+	if n.DurationSec > 0 {
+		msg += fmt.Sprintf(" (turno de %s)", (time.Duration(n.DurationSec) * time.Second).String())
+	}
+	if n.WorkDir != "" {
+		msg += "\nDirectorio: " + n.WorkDir
+	}
+	msg += "\nTerminal: " + n.TerminalID
+	return title, msg
+}
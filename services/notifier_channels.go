@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NotificationChannelType tipo de canal de notificación
+type NotificationChannelType string
+
+const (
+	ChannelWebhook NotificationChannelType = "webhook" // JSON POST firmado con HMAC
+	ChannelSlack   NotificationChannelType = "slack"   // incoming webhook compatible con Slack
+	ChannelNtfy    NotificationChannelType = "ntfy"    // push vía ntfy.sh o servidor propio
+	ChannelGotify  NotificationChannelType = "gotify"  // push vía Gotify
+	ChannelSMTP    NotificationChannelType = "smtp"    // email
+)
+
+// Headers de los webhooks genéricos
+const (
+	SignatureHeader = "X-Claude-Monitor-Signature"
+	EventHeader     = "X-Claude-Monitor-Event"
+)
+
+// Timeout de envío por canal
+const notificationSendTimeout = 10 * time.Second
+
+// Valor con el que se ocultan los secretos en la API
+const maskedSecret = "********"
+
+// NotificationChannel destino de notificaciones. Name es el identificador
+// que referencian las reglas.
+type NotificationChannel struct {
+	Name     string                  `json:"name"`
+	Type     NotificationChannelType `json:"type"`
+	Disabled bool                    `json:"disabled,omitempty"`
+
+	// webhook, slack, ntfy (URL del topic) y gotify (URL del servidor)
+	URL string `json:"url,omitempty"`
+	// webhook: clave HMAC-SHA256 del cuerpo
+	Secret string `json:"secret,omitempty"`
+	// ntfy: token Bearer; gotify: token de aplicación
+	Token string `json:"token,omitempty"`
+	// ntfy (1-5) y gotify (0-10)
+	Priority int `json:"priority,omitempty"`
+
+	// smtp
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Validate valida los campos requeridos según el tipo
+func (c *NotificationChannel) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name requerido")
+	}
+
+	switch c.Type {
+	case ChannelWebhook, ChannelSlack, ChannelNtfy, ChannelGotify:
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return fmt.Errorf("%s: url http(s) requerida", c.Name)
+		}
+		if c.Type == ChannelGotify && c.Token == "" {
+			return fmt.Errorf("%s: token requerido para gotify", c.Name)
+		}
+	case ChannelSMTP:
+		if c.SMTPHost == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("%s: smtp_host, from y to requeridos", c.Name)
+		}
+		for _, addr := range append([]string{c.From}, c.To...) {
+			if strings.ContainsAny(addr, "\r\n") {
+				return fmt.Errorf("%s: direccion invalida: %q", c.Name, addr)
+			}
+		}
+		if c.SMTPPort == 0 {
+			c.SMTPPort = 587
+		}
+	default:
+		return fmt.Errorf("%s: tipo de canal invalido: %s", c.Name, c.Type)
+	}
+	return nil
+}
+
+// masked retorna una copia del canal con los secretos ocultos
+func (c NotificationChannel) masked() NotificationChannel {
+	if c.Secret != "" {
+		c.Secret = maskedSecret
+	}
+	if c.Token != "" {
+		c.Token = maskedSecret
+	}
+	if c.Password != "" {
+		c.Password = maskedSecret
+	}
+	return c
+}
+
+// restoreSecrets reemplaza los secretos enmascarados por los del canal anterior.
+// Solo si el destino no cambió: si no, quien cambia la URL o el host recibiría
+// un secreto que nunca ha visto. En ese caso hay que indicarlo de nuevo.
+func (c *NotificationChannel) restoreSecrets(prev NotificationChannel) error {
+	if c.Secret != maskedSecret && c.Token != maskedSecret && c.Password != maskedSecret {
+		return nil
+	}
+	if !c.sameDestination(prev) {
+		return fmt.Errorf("%s: el destino cambio, indica de nuevo secret, token y password", c.Name)
+	}
+	if c.Secret == maskedSecret {
+		c.Secret = prev.Secret
+	}
+	if c.Token == maskedSecret {
+		c.Token = prev.Token
+	}
+	if c.Password == maskedSecret {
+		c.Password = prev.Password
+	}
+	return nil
+}
+
+// sameDestination indica si el canal envía al mismo sitio que prev (URL, o
+// host y puerto SMTP)
+func (c *NotificationChannel) sameDestination(prev NotificationChannel) bool {
+	if c.Type != prev.Type {
+		return false
+	}
+	if c.Type != ChannelSMTP {
+		return c.URL == prev.URL
+	}
+	port := c.SMTPPort
+	if port == 0 {
+		port = 587 // el default de Validate, ya aplicado en prev
+	}
+	return c.SMTPHost == prev.SMTPHost && port == prev.SMTPPort
+}
+
+// SignPayload firma un cuerpo con HMAC-SHA256 en el formato "sha256=<hex>"
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notificationHTTPClient cliente compartido por los canales HTTP
+var notificationHTTPClient = &http.Client{Timeout: notificationSendTimeout}
+
+// sendNotification envía una notificación por el canal
+func sendNotification(ctx context.Context, ch NotificationChannel, n Notification) error {
+	switch ch.Type {
+	case ChannelWebhook:
+		return sendWebhook(ctx, ch, n)
+	case ChannelSlack:
+		return sendSlack(ctx, ch, n)
+	case ChannelNtfy:
+		return sendNtfy(ctx, ch, n)
+	case ChannelGotify:
+		return sendGotify(ctx, ch, n)
+	case ChannelSMTP:
+		return sendSMTP(ch, n)
+	}
+	return fmt.Errorf("tipo de canal invalido: %s", ch.Type)
+}
+
+// sendWebhook JSON POST con la notificación completa y firma HMAC opcional
+func sendWebhook(ctx context.Context, ch NotificationChannel, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		EventHeader:    string(n.Event),
+	}
+	if ch.Secret != "" {
+		headers[SignatureHeader] = SignPayload(ch.Secret, body)
+	}
+	return postNotification(ctx, ch.URL, body, headers)
+}
+
+// sendSlack incoming webhook: {"text": "..."}
+func sendSlack(ctx context.Context, ch NotificationChannel, n Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", n.Title, n.Message),
+	})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, ch.URL, body, map[string]string{"Content-Type": "application/json"})
+}
+
+// sendNtfy publica el mensaje en el topic con título y prioridad en headers
+func sendNtfy(ctx context.Context, ch NotificationChannel, n Notification) error {
+	headers := map[string]string{
+		"Title": headerValue(n.Title),
+		"Tags":  "robot," + string(n.Event),
+	}
+	if ch.Priority > 0 {
+		headers["Priority"] = strconv.Itoa(ch.Priority)
+	}
+	if ch.Token != "" {
+		headers["Authorization"] = "Bearer " + ch.Token
+	}
+	return postNotification(ctx, ch.URL, []byte(n.Message), headers)
+}
+
+// sendGotify POST /message con el token de aplicación
+func sendGotify(ctx context.Context, ch NotificationChannel, n Notification) error {
+	url := strings.TrimSuffix(ch.URL, "/")
+	if !strings.HasSuffix(url, "/message") {
+		url += "/message"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": ch.Priority,
+	})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, url, body, map[string]string{
+		"Content-Type": "application/json",
+		"X-Gotify-Key": ch.Token,
+	})
+}
+
+// sendSMTP envía un email en texto plano
+func sendSMTP(ch NotificationChannel, n Notification) error {
+	addr := net.JoinHostPort(ch.SMTPHost, strconv.Itoa(ch.SMTPPort))
+
+	var auth smtp.Auth
+	if ch.Username != "" {
+		auth = smtp.PlainAuth("", ch.Username, ch.Password, ch.SMTPHost)
+	}
+
+	return smtp.SendMail(addr, auth, ch.From, ch.To, buildSMTPMessage(ch, n))
+}
+
+// buildSMTPMessage construye el email. El título viene de la terminal (nombre,
+// herramienta pendiente...), así que las cabeceras nunca llevan CR/LF y el
+// Subject va codificado (RFC 2047) para admitir UTF-8.
+func buildSMTPMessage(ch NotificationChannel, n Notification) []byte {
+	to := make([]string, len(ch.To))
+	for i, addr := range ch.To {
+		to[i] = headerValue(addr)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", headerValue(ch.From))
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(n.Title)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return msg.Bytes()
+}
+
+// headerValue elimina los saltos de línea de un valor de cabecera (evita
+// inyectar cabeceras nuevas)
+func headerValue(v string) string {
+	return strings.Join(strings.FieldsFunc(v, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+}
+
+// postNotification hace el POST y trata cualquier status fuera de 2xx como error
+func postNotification(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "claude-monitor")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := notificationHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("respuesta %d", resp.StatusCode)
+	}
+	return nil
+}
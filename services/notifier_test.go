@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifier_WebhookAfterTurn(t *testing.T) {
+	received := make(chan Notification, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != SignPayload("s3cret", body) {
+			t.Errorf("signature = %q", got)
+		}
+		var n Notification
+		json.Unmarshal(body, &n)
+		received <- n
+	}))
+	defer server.Close()
+
+	bus := NewEventBus()
+	dir := t.TempDir()
+	ns := NewNotifierService(dir, NewTerminalService(dir), bus)
+	defer ns.Stop()
+
+	if err := ns.SetChannels([]NotificationChannel{{Name: "hook", Type: ChannelWebhook, URL: server.URL, Secret: "s3cret"}}); err != nil {
+		t.Fatalf("SetChannels: %v", err)
+	}
+	if err := ns.SetTerminalRules("t1", &NotificationRules{Rules: []NotificationRule{
+		{Events: []NotificationEvent{NotifyWaitingInput, NotifyError}, Channels: []string{"hook"}},
+	}}); err != nil {
+		t.Fatalf("SetTerminalRules: %v", err)
+	}
+
+	// Sin turno previo no se notifica waiting_input
+	bus.Publish(EventClaudeState, "t1", StateChangeData{OldState: "unknown", NewState: "waiting_input"})
+	bus.Publish(EventClaudeState, "t1", StateChangeData{OldState: "waiting_input", NewState: "generating"})
+	bus.Publish(EventClaudeState, "t1", StateChangeData{OldState: "generating", NewState: "waiting_input"})
+	// Terminal sin reglas
+	bus.Publish(EventClaudeState, "t2", StateChangeData{OldState: "generating", NewState: "error"})
+
+	select {
+	case n := <-received:
+		if n.Event != NotifyWaitingInput || n.TerminalID != "t1" || n.Title == "" {
+			t.Errorf("unexpected notification %+v", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	select {
+	case n := <-received:
+		t.Errorf("unexpected extra notification %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifier_ChannelsValidation(t *testing.T) {
+	dir := t.TempDir()
+	ns := NewNotifierService(dir, NewTerminalService(dir), NewEventBus())
+	defer ns.Stop()
+
+	invalid := [][]NotificationChannel{
+		{{Name: "x", Type: "pager", URL: "https://example.com"}},
+		{{Name: "x", Type: ChannelSlack, URL: "ftp://example.com"}},
+		{{Name: "x", Type: ChannelGotify, URL: "https://gotify.local"}},
+		{{Name: "x", Type: ChannelSMTP, SMTPHost: "smtp.local"}},
+		{{Name: "x", Type: ChannelSMTP, SMTPHost: "smtp.local", From: "a@x.com", To: []string{"b@x.com\r\nBcc: c@evil.com"}}},
+		{{Name: "x", Type: ChannelNtfy, URL: "https://ntfy.sh/a"}, {Name: "x", Type: ChannelNtfy, URL: "https://ntfy.sh/b"}},
+	}
+	for i, channels := range invalid {
+		if err := ns.SetChannels(channels); err == nil {
+			t.Errorf("case %d: SetChannels should fail", i)
+		}
+	}
+
+	if err := ns.SetChannels([]NotificationChannel{{Name: "push", Type: ChannelNtfy, URL: "https://ntfy.sh/t", Token: "tk"}}); err != nil {
+		t.Fatalf("SetChannels: %v", err)
+	}
+	if err := ns.SetDefaultRules(&NotificationRules{Rules: []NotificationRule{{Events: []NotificationEvent{NotifyExited}, Channels: []string{"missing"}}}}); err == nil {
+		t.Error("rules referencing unknown channel should fail")
+	}
+	if err := ns.SetDefaultRules(&NotificationRules{Rules: []NotificationRule{{Events: []NotificationEvent{NotifyExited}, Channels: []string{"push"}}}}); err != nil {
+		t.Fatalf("SetDefaultRules: %v", err)
+	}
+
+	// Los secretos se ocultan y un PUT con el valor oculto conserva el original
+	status := ns.Status()
+	if status.Channels[0].Token != maskedSecret {
+		t.Errorf("token not masked: %q", status.Channels[0].Token)
+	}
+	if err := ns.SetChannels(status.Channels); err != nil {
+		t.Fatalf("SetChannels roundtrip: %v", err)
+	}
+	if ns.channels["push"].Token != "tk" {
+		t.Errorf("token = %q, want original", ns.channels["push"].Token)
+	}
+
+	// Con otro destino el secreto enmascarado no se conserva
+	moved := status.Channels[0]
+	moved.URL = "https://attacker.example/t"
+	if err := ns.SetChannels([]NotificationChannel{moved}); err == nil {
+		t.Error("masked token restored for a new URL")
+	}
+	if ns.channels["push"].URL != "https://ntfy.sh/t" {
+		t.Errorf("channel changed after rejected update: %+v", ns.channels["push"])
+	}
+	moved.Token = "nuevo"
+	if err := ns.SetChannels([]NotificationChannel{moved}); err != nil {
+		t.Errorf("SetChannels with new token: %v", err)
+	}
+
+	// No se puede eliminar un canal usado por reglas
+	if err := ns.SetChannels(nil); err == nil {
+		t.Error("removing referenced channel should fail")
+	}
+
+	// Persistencia
+	reloaded := NewNotifierService(dir, NewTerminalService(dir), NewEventBus())
+	defer reloaded.Stop()
+	if rules, scope := reloaded.ResolveRules("any", ""); rules == nil || scope != "default" {
+		t.Errorf("ResolveRules after reload = %v, %q", rules, scope)
+	}
+}
+
+func TestNotificationChannel_RestoreSecretsSMTP(t *testing.T) {
+	prev := NotificationChannel{Name: "mail", Type: ChannelSMTP, SMTPHost: "smtp.example.com", SMTPPort: 587, Password: "pw"}
+
+	same := NotificationChannel{Name: "mail", Type: ChannelSMTP, SMTPHost: "smtp.example.com", Password: maskedSecret}
+	if err := same.restoreSecrets(prev); err != nil || same.Password != "pw" {
+		t.Errorf("same host: password = %q, err = %v", same.Password, err)
+	}
+	for _, ch := range []NotificationChannel{
+		{Name: "mail", Type: ChannelSMTP, SMTPHost: "smtp.attacker.example", Password: maskedSecret},
+		{Name: "mail", Type: ChannelSMTP, SMTPHost: "smtp.example.com", SMTPPort: 2525, Password: maskedSecret},
+		{Name: "mail", Type: ChannelWebhook, URL: "https://smtp.example.com", Secret: maskedSecret},
+	} {
+		if err := ch.restoreSecrets(prev); err == nil {
+			t.Errorf("masked secret restored for %+v", ch)
+		}
+	}
+}
+
+func TestBuildSMTPMessage_HeaderInjection(t *testing.T) {
+	ch := NotificationChannel{Name: "mail", Type: ChannelSMTP, From: "monitor@x.com", To: []string{"dev@x.com"}}
+	n := Notification{
+		Title:     "Permiso pendiente: evil\r\nBcc: victim@x.com",
+		Message:   "linea 1\nlinea 2",
+		Timestamp: time.Unix(1760000000, 0),
+	}
+
+	msg := string(buildSMTPMessage(ch, n))
+	header, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message without header/body separator: %q", msg)
+	}
+
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(strings.ToLower(line), "bcc:") {
+			t.Errorf("injected header: %q", line)
+		}
+	}
+	if !strings.Contains(header, "\r\nSubject: Permiso pendiente: evil Bcc: victim@x.com\r\n") {
+		t.Errorf("unexpected subject: %q", header)
+	}
+	if strings.ContainsAny(strings.ReplaceAll(header, "\r\n", ""), "\r\n") {
+		t.Errorf("bare CR/LF in headers: %q", header)
+	}
+	if body != "linea 1\r\nlinea 2\r\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestBuildSMTPMessage_UTF8Subject(t *testing.T) {
+	ch := NotificationChannel{Name: "mail", Type: ChannelSMTP, From: "monitor@x.com", To: []string{"dev@x.com"}}
+	msg := string(buildSMTPMessage(ch, Notification{Title: "Sesión terminada", Timestamp: time.Now()}))
+
+	if !strings.Contains(msg, "\r\nSubject: =?utf-8?q?Sesi=C3=B3n_terminada?=\r\n") {
+		t.Errorf("subject not Q-encoded: %q", msg)
+	}
+}
@@ -0,0 +1,616 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// PermissionAction acción para responder un prompt de permiso
+type PermissionAction string
+
+const (
+	PermissionAllow       PermissionAction = "allow"
+	PermissionAlwaysAllow PermissionAction = "always_allow"
+	PermissionDeny        PermissionAction = "deny"
+	PermissionAsk         PermissionAction = "ask" // No responder: dejar la decisión a un humano
+)
+
+// Cooldown para no responder dos veces al mismo prompt (el patrón se detecta en cada chunk)
+const permissionAutoAnswerCooldown = 2 * time.Second
+
+// Keys enviadas por acción. El menú de Claude acepta el número de la opción;
+// los prompts clásicos [y/n] se responden con y/n + Enter.
+var (
+	permissionMenuKeys = map[PermissionAction][]string{
+		PermissionAllow:       {"1"},
+		PermissionAlwaysAllow: {"2"},
+		PermissionDeny:        {"Esc"},
+	}
+	permissionYesNoKeys = map[PermissionAction][]string{
+		PermissionAllow:       {"y", "Enter"},
+		PermissionAlwaysAllow: {"y", "Enter"},
+		PermissionDeny:        {"n", "Enter"},
+	}
+	yesNoPromptRe     = regexp.MustCompile(`\[[yY]/[nN]\]`)
+	toolInvocationRe  = regexp.MustCompile(`(\w+)\((.+)\)`)
+	permissionCmdHead = regexp.MustCompile(`(?i)^(bash )?command$`)
+)
+
+// PermissionRule regla de una política. Tool acepta globs (ej: "mcp__*").
+// Si CommandPatterns no está vacío la regla solo aplica cuando el comando
+// pendiente coincide completo con alguna de las expresiones regulares (se
+// anclan con ^...$, así "git status" no cubre "git status; rm -rf ~").
+type PermissionRule struct {
+	Tool            string           `json:"tool"`
+	Action          PermissionAction `json:"action"`
+	CommandPatterns []string         `json:"command_patterns,omitempty"`
+	Description     string           `json:"description,omitempty"`
+
+	compiled []*regexp.Regexp
+}
+
+// PermissionPolicy conjunto ordenado de reglas; gana la primera que coincide
+type PermissionPolicy struct {
+	Rules     []PermissionRule `json:"rules"`
+	Default   PermissionAction `json:"default"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// PermissionRequest prompt de permiso pendiente
+type PermissionRequest struct {
+	TerminalID string `json:"terminal_id"`
+	Tool       string `json:"tool"`
+	Command    string `json:"command,omitempty"`
+}
+
+// PermissionDecision resultado de evaluar una política
+type PermissionDecision struct {
+	Action PermissionAction `json:"action"`
+	Rule   int              `json:"rule"` // índice de la regla, -1 si aplica el default
+	Reason string           `json:"reason"`
+}
+
+// PermissionAnswer resultado de responder un prompt
+type PermissionAnswer struct {
+	TerminalID string           `json:"terminal_id"`
+	Action     PermissionAction `json:"action"`
+	Tool       string           `json:"tool,omitempty"`
+	Command    string           `json:"command,omitempty"`
+	Keys       []string         `json:"keys"`
+}
+
+// Validate valida y compila la política
+func (p *PermissionPolicy) Validate() error {
+	if p.Default == "" {
+		p.Default = PermissionAsk
+	}
+	if !isValidPolicyAction(p.Default) {
+		return fmt.Errorf("default invalido: %s", p.Default)
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Tool == "" {
+			return fmt.Errorf("rules[%d]: tool requerido", i)
+		}
+		if _, err := path.Match(rule.Tool, ""); err != nil {
+			return fmt.Errorf("rules[%d]: glob invalido: %s", i, rule.Tool)
+		}
+		if !isValidPolicyAction(rule.Action) {
+			return fmt.Errorf("rules[%d]: action invalida: %s", i, rule.Action)
+		}
+
+		rule.compiled = make([]*regexp.Regexp, 0, len(rule.CommandPatterns))
+		for j, pattern := range rule.CommandPatterns {
+			re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+			if err != nil {
+				return fmt.Errorf("rules[%d].command_patterns[%d]: %v", i, j, err)
+			}
+			rule.compiled = append(rule.compiled, re)
+		}
+	}
+
+	return nil
+}
+
+// Evaluate decide qué hacer con un prompt de permiso
+func (p *PermissionPolicy) Evaluate(req PermissionRequest) PermissionDecision {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matches(req) {
+			continue
+		}
+		reason := fmt.Sprintf("regla %d (%s)", i, rule.Tool)
+		if rule.Description != "" {
+			reason += ": " + rule.Description
+		}
+		return PermissionDecision{Action: rule.Action, Rule: i, Reason: reason}
+	}
+
+	return PermissionDecision{Action: p.Default, Rule: -1, Reason: "default"}
+}
+
+// matches verifica si la regla aplica al prompt (requiere Validate previo)
+func (r *PermissionRule) matches(req PermissionRequest) bool {
+	if ok, _ := path.Match(r.Tool, req.Tool); !ok {
+		return false
+	}
+
+	if len(r.CommandPatterns) == 0 {
+		return true
+	}
+	if req.Command == "" {
+		return false
+	}
+
+	for _, re := range r.compiled {
+		if re.MatchString(req.Command) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidPolicyAction(a PermissionAction) bool {
+	switch a {
+	case PermissionAllow, PermissionAlwaysAllow, PermissionDeny, PermissionAsk:
+		return true
+	}
+	return false
+}
+
+// ExtractPendingCommand extrae el comando/argumento del prompt de permiso pendiente.
+// Solo mira el recuadro activo (el último de la pantalla, sin nada debajo), para no
+// confundirlo con invocaciones anteriores del scrollback. Dentro del recuadro toma
+// primero el bloque "Bash command" y si no, una invocación "Bash(npm test)".
+// Retorna "" si no hay recuadro activo o el comando es ambiguo.
+func ExtractPendingCommand(display []string, tool string) string {
+	box := activePermissionBox(display)
+	if box == nil {
+		return ""
+	}
+
+	command, heads := "", 0
+	for i, line := range box {
+		if permissionCmdHead.MatchString(boxLineContent(line)) {
+			heads++
+			command = boxBlockCommand(box[i+1:])
+		}
+	}
+	if heads > 1 {
+		return ""
+	}
+	if heads == 1 {
+		return command
+	}
+
+	for _, line := range box {
+		m := toolInvocationRe.FindStringSubmatch(boxLineContent(line))
+		if len(m) < 3 || (tool != "" && m[1] != tool) {
+			continue
+		}
+		found := strings.TrimSpace(m[2])
+		if command != "" && command != found {
+			return ""
+		}
+		command = found
+	}
+	return command
+}
+
+// activePermissionBox retorna las líneas del recuadro del prompt pendiente: el
+// último recuadro de la pantalla, siempre que debajo solo haya líneas vacías
+func activePermissionBox(display []string) []string {
+	end := len(display) - 1
+	for end >= 0 && strings.TrimSpace(display[end]) == "" {
+		end--
+	}
+	if end < 0 || !isBoxLine(display[end]) {
+		return nil
+	}
+
+	start := end
+	for start > 0 && !strings.HasPrefix(strings.TrimSpace(display[start]), "╭") && isBoxLine(display[start-1]) {
+		start--
+	}
+	return display[start : end+1]
+}
+
+// isBoxLine indica si la línea pertenece a un recuadro (borde superior, lateral o inferior)
+func isBoxLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "│") || strings.HasPrefix(trimmed, "╭") || strings.HasPrefix(trimmed, "╰")
+}
+
+// boxLineContent contenido de una línea sin los bordes del recuadro
+func boxLineContent(line string) string {
+	return strings.TrimSpace(strings.Trim(line, "│╭╮╰╯ "))
+}
+
+// boxBlockCommand primera línea no vacía tras "Bash command". Retorna "" si el
+// comando parece continuar en la línea siguiente (partido por el ancho del
+// recuadro o terminado en un operador), porque solo se vería una parte
+func boxBlockCommand(lines []string) string {
+	for _, line := range lines {
+		command := boxLineContent(line)
+		if command == "" {
+			continue
+		}
+		// Sin espacio entre el texto y el borde derecho: la línea se partió
+		if inner := strings.TrimSpace(line); strings.HasSuffix(inner, "│") {
+			inner = strings.TrimSuffix(inner, "│")
+			if inner == strings.TrimRight(inner, " ") {
+				return ""
+			}
+		}
+		for _, cont := range []string{"\\", "&&", "||", "|", ";"} {
+			if strings.HasSuffix(command, cont) {
+				return ""
+			}
+		}
+		return command
+	}
+	return ""
+}
+
+// permissionPoliciesFile formato persistido de políticas
+type permissionPoliciesFile struct {
+	Terminals    map[string]*PermissionPolicy `json:"terminals"`
+	SessionRoots map[string]*PermissionPolicy `json:"session_roots"`
+}
+
+// PermissionService responde prompts de permiso manualmente o según políticas
+type PermissionService struct {
+	terminals *TerminalService
+	audit     *AuditLog
+	file      string
+
+	mu           sync.RWMutex
+	byTerminal   map[string]*PermissionPolicy
+	bySessionDir map[string]*PermissionPolicy // clave: session-root codificado
+
+	answeredMu sync.Mutex
+	answered   map[string]time.Time
+}
+
+// NewPermissionService crea el servicio y se suscribe a los prompts de permiso
+func NewPermissionService(dataDir string, terminals *TerminalService, audit *AuditLog) *PermissionService {
+	ps := &PermissionService{
+		terminals:    terminals,
+		audit:        audit,
+		file:         filepath.Join(dataDir, "permission_policies.json"),
+		byTerminal:   make(map[string]*PermissionPolicy),
+		bySessionDir: make(map[string]*PermissionPolicy),
+		answered:     make(map[string]time.Time),
+	}
+	ps.load()
+	terminals.SetOnPermissionPrompt(ps.handlePrompt)
+	return ps
+}
+
+// load carga políticas desde disco
+func (ps *PermissionService) load() {
+	data, err := os.ReadFile(ps.file)
+	if err != nil {
+		return
+	}
+
+	var stored permissionPoliciesFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		logger.Error("Error cargando políticas de permisos", "error", err)
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for id, p := range stored.Terminals {
+		if err := p.Validate(); err == nil {
+			ps.byTerminal[id] = p
+		}
+	}
+	for root, p := range stored.SessionRoots {
+		if err := p.Validate(); err == nil {
+			ps.bySessionDir[root] = p
+		}
+	}
+}
+
+// persist guarda políticas a disco de forma atómica
+func (ps *PermissionService) persist() {
+	ps.mu.RLock()
+	stored := permissionPoliciesFile{
+		Terminals:    ps.byTerminal,
+		SessionRoots: ps.bySessionDir,
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	ps.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando políticas de permisos", "error", err)
+		return
+	}
+	if err := atomicWriteFile(ps.file, data, 0600); err != nil {
+		logger.Error("Error guardando políticas de permisos", "error", err)
+	}
+}
+
+// GetTerminalPolicy retorna la política de una terminal
+func (ps *PermissionService) GetTerminalPolicy(terminalID string) (*PermissionPolicy, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	p, ok := ps.byTerminal[terminalID]
+	return p, ok
+}
+
+// SetTerminalPolicy configura la política de una terminal
+func (ps *PermissionService) SetTerminalPolicy(terminalID string, policy *PermissionPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	policy.UpdatedAt = time.Now()
+
+	ps.mu.Lock()
+	ps.byTerminal[terminalID] = policy
+	ps.mu.Unlock()
+	ps.persist()
+	return nil
+}
+
+// DeleteTerminalPolicy elimina la política de una terminal
+func (ps *PermissionService) DeleteTerminalPolicy(terminalID string) {
+	ps.mu.Lock()
+	delete(ps.byTerminal, terminalID)
+	ps.mu.Unlock()
+	ps.persist()
+}
+
+// GetSessionRootPolicy retorna la política de un session-root
+func (ps *PermissionService) GetSessionRootPolicy(rootPath string) (*PermissionPolicy, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	p, ok := ps.bySessionDir[rootPath]
+	return p, ok
+}
+
+// SetSessionRootPolicy configura la política de un session-root
+func (ps *PermissionService) SetSessionRootPolicy(rootPath string, policy *PermissionPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	policy.UpdatedAt = time.Now()
+
+	ps.mu.Lock()
+	ps.bySessionDir[rootPath] = policy
+	ps.mu.Unlock()
+	ps.persist()
+	return nil
+}
+
+// DeleteSessionRootPolicy elimina la política de un session-root
+func (ps *PermissionService) DeleteSessionRootPolicy(rootPath string) {
+	ps.mu.Lock()
+	delete(ps.bySessionDir, rootPath)
+	ps.mu.Unlock()
+	ps.persist()
+}
+
+// ResolvePolicy retorna la política efectiva: primero la de la terminal y luego
+// la del session-root de su directorio de trabajo
+func (ps *PermissionService) ResolvePolicy(terminalID, workDir string) (*PermissionPolicy, string) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	if p, ok := ps.byTerminal[terminalID]; ok {
+		return p, "terminal"
+	}
+	if workDir != "" {
+		if p, ok := ps.bySessionDir[EncodeProjectPath(workDir)]; ok {
+			return p, "session_root"
+		}
+	}
+	return nil, ""
+}
+
+// Answer responde el prompt de permiso pendiente de una terminal
+func (ps *PermissionService) Answer(terminalID string, action PermissionAction, actor string) (*PermissionAnswer, error) {
+	answer, err := ps.answer(terminalID, action, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.audit.Record(AuditEntry{
+		Actor:      actor,
+		Action:     "permission.answer",
+		TerminalID: terminalID,
+		Tool:       answer.Tool,
+		Command:    answer.Command,
+		Decision:   string(action),
+		Reason:     "manual",
+	})
+
+	return answer, nil
+}
+
+// answer envía las teclas correspondientes a la acción. Con expected (respuesta
+// automática), antes de enviarlas relee el recuadro activo y no responde si el
+// prompt ya no es el que se evaluó: "1" aprobaría otro comando.
+func (ps *PermissionService) answer(terminalID string, action PermissionAction, expected *PermissionRequest) (*PermissionAnswer, error) {
+	if action == PermissionAsk || !isValidPolicyAction(action) {
+		return nil, fmt.Errorf("accion invalida: %s", action)
+	}
+
+	tc, err := ps.terminals.getClaudeTerminal(terminalID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := tc.GetClaudeState()
+	if state == nil || !state.PendingPermission {
+		return nil, fmt.Errorf("no hay permiso pendiente: %s", terminalID)
+	}
+
+	display := tc.GetClaudeScreen().GetDisplay()
+	command := ExtractPendingCommand(display, state.PendingTool)
+	if expected != nil && !samePendingPrompt(*expected, state.PendingTool, command) {
+		return nil, fmt.Errorf("el prompt de permiso cambio antes de responder: %s %q (evaluado: %s %q)",
+			state.PendingTool, command, expected.Tool, expected.Command)
+	}
+
+	keys := permissionMenuKeys[action]
+	if yesNoPromptRe.MatchString(strings.Join(display, "\n")) {
+		keys = permissionYesNoKeys[action]
+	}
+
+	if err := ps.terminals.SendKeys(terminalID, keys...); err != nil {
+		return nil, err
+	}
+
+	ps.markAnswered(promptKey(terminalID, state.PendingTool, command))
+
+	return &PermissionAnswer{
+		TerminalID: terminalID,
+		Action:     action,
+		Tool:       state.PendingTool,
+		Command:    command,
+		Keys:       keys,
+	}, nil
+}
+
+// handlePrompt evalúa la política al detectar un prompt y responde si corresponde
+func (ps *PermissionService) handlePrompt(tc *TerminalClaude, tool string) {
+	terminalID := tc.GetID()
+
+	policy, scope := ps.ResolvePolicy(terminalID, tc.GetWorkDir())
+	if policy == nil {
+		return
+	}
+
+	req := PermissionRequest{
+		TerminalID: terminalID,
+		Tool:       tool,
+		Command:    ExtractPendingCommand(tc.GetClaudeScreen().GetDisplay(), tool),
+	}
+
+	key := promptKey(terminalID, req.Tool, req.Command)
+	if ps.recentlyAnswered(key) {
+		return
+	}
+	ps.markAnswered(key)
+
+	decision := evaluatePrompt(policy, req)
+
+	entry := AuditEntry{
+		Actor:      "policy",
+		Action:     "permission.auto",
+		TerminalID: terminalID,
+		Tool:       req.Tool,
+		Command:    req.Command,
+		Decision:   string(decision.Action),
+		Reason:     decision.Reason,
+		Details:    map[string]string{"scope": scope},
+	}
+
+	if decision.Action != PermissionAsk {
+		if _, err := ps.answer(terminalID, decision.Action, &req); err != nil {
+			logger.Warn("Error respondiendo permiso automáticamente",
+				"terminal_id", terminalID,
+				"tool", tool,
+				"error", err,
+			)
+			entry.Details["error"] = err.Error()
+		}
+	}
+
+	ps.audit.Record(entry)
+
+	logger.Info("Permiso evaluado por política",
+		"terminal_id", terminalID,
+		"tool", tool,
+		"decision", decision.Action,
+		"reason", decision.Reason,
+	)
+}
+
+// evaluatePrompt evalúa la política para un prompt visto en pantalla. Sin un
+// comando pendiente identificado sin ambigüedad nunca se aprueba
+// automáticamente: decide el usuario.
+func evaluatePrompt(policy *PermissionPolicy, req PermissionRequest) PermissionDecision {
+	decision := policy.Evaluate(req)
+	if req.Command == "" && (decision.Action == PermissionAllow || decision.Action == PermissionAlwaysAllow) {
+		decision = PermissionDecision{
+			Action: PermissionAsk,
+			Rule:   decision.Rule,
+			Reason: decision.Reason + "; comando pendiente no identificado",
+		}
+	}
+	return decision
+}
+
+// samePendingPrompt verifica que el prompt en pantalla (herramienta y comando
+// del recuadro activo) sea el que se evaluó
+func samePendingPrompt(expected PermissionRequest, tool, command string) bool {
+	return tool == expected.Tool && command == expected.Command
+}
+
+// promptKey identifica un prompt concreto para deduplicar respuestas
+func promptKey(terminalID, tool, command string) string {
+	return terminalID + "|" + tool + "|" + command
+}
+
+// DecideToolUse evalúa la política antes de ejecutar una herramienta (hook PreToolUse).
+// Retorna false si no hay política aplicable o la decisión es "ask".
+func (ps *PermissionService) DecideToolUse(terminalID, workDir string, req PermissionRequest) (PermissionDecision, bool) {
+	policy, scope := ps.ResolvePolicy(terminalID, workDir)
+	if policy == nil {
+		return PermissionDecision{}, false
+	}
+
+	decision := policy.Evaluate(req)
+	if decision.Action == PermissionAsk {
+		return decision, false
+	}
+
+	ps.audit.Record(AuditEntry{
+		Actor:      "policy",
+		Action:     "permission.hook",
+		TerminalID: terminalID,
+		Tool:       req.Tool,
+		Command:    req.Command,
+		Decision:   string(decision.Action),
+		Reason:     decision.Reason,
+		Details:    map[string]string{"scope": scope},
+	})
+
+	return decision, true
+}
+
+// markAnswered registra que el prompt ya fue procesado
+func (ps *PermissionService) markAnswered(key string) {
+	ps.answeredMu.Lock()
+	defer ps.answeredMu.Unlock()
+
+	now := time.Now()
+	for k, t := range ps.answered {
+		if now.Sub(t) > permissionAutoAnswerCooldown {
+			delete(ps.answered, k)
+		}
+	}
+	ps.answered[key] = now
+}
+
+// recentlyAnswered evita responder varias veces al mismo prompt
+func (ps *PermissionService) recentlyAnswered(key string) bool {
+	ps.answeredMu.Lock()
+	defer ps.answeredMu.Unlock()
+	t, ok := ps.answered[key]
+	return ok && time.Since(t) < permissionAutoAnswerCooldown
+}
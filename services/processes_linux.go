@@ -0,0 +1,205 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// processSignals señales que se pueden enviar a un proceso de la terminal
+var processSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// signalProcess envía la señal al proceso
+func signalProcess(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// listProcessTree lee de /proc el proceso root y sus descendientes
+func listProcessTree(root int) ([]ProcessInfo, error) {
+	stats, children, err := readProcTable()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := stats[root]; !ok {
+		return nil, fmt.Errorf("proceso %d no encontrado", root)
+	}
+
+	boot := bootTime()
+	pageSize := uint64(os.Getpagesize())
+	// Los sockets se leen del namespace de red del proceso principal
+	listening := readListeningSockets(root)
+
+	var list []ProcessInfo
+	var walk func(pid, depth int)
+	walk = func(pid, depth int) {
+		st := stats[pid]
+		info := ProcessInfo{
+			PID:        pid,
+			PPID:       st.ppid,
+			Depth:      depth,
+			Name:       st.comm,
+			Cmdline:    readCmdline(pid),
+			State:      st.state,
+			CPUSeconds: float64(st.cpuTicks) / clockTicks,
+			RSSBytes:   st.rssPages * pageSize,
+		}
+		if cwd, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "cwd")); err == nil {
+			info.Cwd = cwd
+		}
+		if !boot.IsZero() {
+			info.StartedAt = boot.Add(time.Duration(st.startTicks) * time.Second / clockTicks)
+		}
+		for _, inode := range socketInodes(pid) {
+			if port, ok := listening[inode]; ok {
+				info.ListeningPorts = append(info.ListeningPorts, port)
+			}
+		}
+		list = append(list, info)
+
+		kids := children[pid]
+		sort.Ints(kids)
+		for _, kid := range kids {
+			walk(kid, depth+1)
+		}
+	}
+	walk(root, 0)
+
+	return list, nil
+}
+
+// readCmdline lee los argumentos del proceso (vacío para hilos del kernel o zombies)
+func readCmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil || len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+}
+
+// bootTime instante de arranque del sistema (btime de /proc/stat)
+func bootTime() time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err == nil {
+				return time.Unix(secs, 0)
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// socketInodes inodos de los sockets abiertos por el proceso
+func socketInodes(pid int) []uint64 {
+	dir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []uint64
+	for _, entry := range entries {
+		link, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if v, ok := strings.CutPrefix(link, "socket:["); ok {
+			if inode, err := strconv.ParseUint(strings.TrimSuffix(v, "]"), 10, 64); err == nil {
+				inodes = append(inodes, inode)
+			}
+		}
+	}
+	return inodes
+}
+
+// readListeningSockets sockets TCP en estado LISTEN indexados por inodo
+func readListeningSockets(pid int) map[uint64]ListeningPort {
+	sockets := make(map[uint64]ListeningPort)
+	for _, proto := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "net", proto))
+		if err != nil {
+			continue
+		}
+		for inode, port := range parseProcNetTCP(data, proto) {
+			sockets[inode] = port
+		}
+	}
+	return sockets
+}
+
+// tcpListenState estado TCP_LISTEN en /proc/net/tcp
+const tcpListenState = "0A"
+
+// parseProcNetTCP interpreta /proc/net/tcp{,6} y retorna los sockets en LISTEN
+func parseProcNetTCP(data []byte, proto string) map[uint64]ListeningPort {
+	sockets := make(map[uint64]ListeningPort)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Scan() // cabecera
+
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+		addr, port, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+		sockets[inode] = ListeningPort{Protocol: proto, Address: addr, Port: port}
+	}
+	return sockets
+}
+
+// parseProcNetAddr convierte "0100007F:1F90" en ("127.0.0.1", 8080). La IP está
+// en hexadecimal en el orden de bytes del host, por palabras de 32 bits.
+func parseProcNetAddr(s string) (string, int, error) {
+	hostHex, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("direccion invalida: %s", s)
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	raw, err := hex.DecodeString(hostHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("direccion invalida: %s", s)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String(), int(port), nil
+}
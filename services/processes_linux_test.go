@@ -0,0 +1,124 @@
+//go:build linux
+
+package services
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseProcNetAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		addr string
+		port int
+	}{
+		{"0100007F:1F90", "127.0.0.1", 8080},
+		{"00000000:0BB8", "0.0.0.0", 3000},
+		{"00000000000000000000000001000000:1538", "::1", 5432},
+		{"00000000000000000000000000000000:0050", "::", 80},
+	}
+	for _, tt := range tests {
+		addr, port, err := parseProcNetAddr(tt.in)
+		if err != nil || addr != tt.addr || port != tt.port {
+			t.Errorf("parseProcNetAddr(%q) = %s, %d, %v; want %s, %d", tt.in, addr, port, err, tt.addr, tt.port)
+		}
+	}
+
+	data := []byte(`  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 43210 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 43211 1 0000000000000000 20 4 30 10 -1
+`)
+	sockets := parseProcNetTCP(data, "tcp")
+	if len(sockets) != 1 || sockets[43210].Port != 8080 {
+		t.Errorf("sockets = %+v, want solo el LISTEN 43210", sockets)
+	}
+}
+
+func TestListProcessTree_ListeningPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	tree, err := listProcessTree(os.Getpid())
+	if err != nil {
+		t.Fatalf("listProcessTree: %v", err)
+	}
+	self := tree[0]
+	if self.PID != os.Getpid() || self.Depth != 0 || len(self.Cmdline) == 0 || self.Cwd == "" {
+		t.Errorf("proceso principal = %+v", self)
+	}
+	found := false
+	for _, p := range self.ListeningPorts {
+		if p.Port == port && p.Address == "127.0.0.1" && p.Protocol == "tcp" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("puerto %d no encontrado en %+v", port, self.ListeningPorts)
+	}
+}
+
+func TestSignalProcess(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 30 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+	}()
+
+	s := NewTerminalService(t.TempDir())
+	s.startResources("t1", cmd.Process.Pid, &terminalResources{})
+
+	var child *ProcessInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for child == nil && time.Now().Before(deadline) {
+		tree, err := s.Processes("t1")
+		if err != nil {
+			t.Fatalf("Processes: %v", err)
+		}
+		if len(tree) == 2 && tree[1].Name == "sleep" {
+			child = &tree[1]
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if child == nil {
+		t.Fatal("proceso hijo sleep no encontrado")
+	}
+	if child.PPID != cmd.Process.Pid || child.Depth != 1 || child.Cmdline[0] != "sleep" {
+		t.Errorf("hijo = %+v", child)
+	}
+
+	if err := s.SignalProcess("t1", cmd.Process.Pid, "TERM"); err == nil {
+		t.Error("se permitio señalar el proceso principal")
+	}
+	if err := s.SignalProcess("t1", child.PID, "BOGUS"); err == nil {
+		t.Error("señal invalida aceptada")
+	}
+	if err := s.SignalProcess("t1", 1, "TERM"); err == nil {
+		t.Error("se permitio señalar un proceso fuera de la terminal")
+	}
+	if err := s.SignalProcess("t1", child.PID, "sigterm"); err != nil {
+		t.Fatalf("SignalProcess: %v", err)
+	}
+
+	// Al morir sleep, wait retorna y sh termina
+	done := make(chan struct{})
+	go func() { cmd.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("el proceso no termino tras SIGTERM")
+	}
+}
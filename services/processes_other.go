@@ -0,0 +1,21 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// processSignals sin señales fuera de Linux
+var processSignals = map[string]syscall.Signal{}
+
+// signalProcess no está soportado fuera de Linux
+func signalProcess(pid int, sig syscall.Signal) error {
+	return fmt.Errorf("signals no soportadas en esta plataforma")
+}
+
+// listProcessTree no está soportado fuera de Linux
+func listProcessTree(root int) ([]ProcessInfo, error) {
+	return nil, fmt.Errorf("arbol de procesos no soportado en esta plataforma")
+}
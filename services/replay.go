@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Tamaño de chunk al reproducir capturas raw (sin tiempos)
+const rawCaptureChunkSize = 256
+
+// AsciicastHeader cabecera de un archivo asciicast v2
+type AsciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// CaptureFrame chunk de output de la PTY con su offset desde el inicio
+type CaptureFrame struct {
+	Offset time.Duration
+	Data   []byte
+}
+
+// Capture grabación de una PTY lista para reproducir
+type Capture struct {
+	Width  int
+	Height int
+	Env    map[string]string // entorno de la cabecera asciicast (CLAUDE_VERSION, TERM...)
+	Frames []CaptureFrame
+}
+
+// ReadAsciicast lee una grabación asciicast v2 (solo eventos "o" de output)
+func ReadAsciicast(r io.Reader) (*Capture, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("asciicast vacio")
+	}
+
+	var header AsciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("cabecera asciicast invalida: %v", err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("version asciicast no soportada: %d", header.Version)
+	}
+
+	capture := &Capture{Width: header.Width, Height: header.Height, Env: header.Env}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			return nil, fmt.Errorf("evento asciicast invalido en linea %d", line)
+		}
+
+		ts, ok1 := event[0].(float64)
+		kind, ok2 := event[1].(string)
+		data, ok3 := event[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("evento asciicast invalido en linea %d", line)
+		}
+		if kind != "o" {
+			continue
+		}
+
+		capture.Frames = append(capture.Frames, CaptureFrame{
+			Offset: time.Duration(math.Round(ts * float64(time.Second))),
+			Data:   []byte(data),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
+
+// ReadRawCapture crea una grabación desde bytes crudos de la PTY. Al no tener
+// tiempos se divide en chunks fijos, todos con offset cero.
+func ReadRawCapture(data []byte, width, height int) *Capture {
+	capture := &Capture{Width: width, Height: height}
+	for len(data) > 0 {
+		n := rawCaptureChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		capture.Frames = append(capture.Frames, CaptureFrame{Data: data[:n]})
+		data = data[n:]
+	}
+	return capture
+}
+
+// AsciicastWriter escribe una grabación asciicast v2
+type AsciicastWriter struct {
+	w       io.Writer
+	start   time.Time
+	partial []byte // runa UTF-8 incompleta al final del chunk anterior
+}
+
+// NewAsciicastWriter escribe la cabecera y retorna el writer
+func NewAsciicastWriter(w io.Writer, header AsciicastHeader) (*AsciicastWriter, error) {
+	header.Version = 2
+	start := time.Now()
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+
+	return &AsciicastWriter{w: w, start: start}, nil
+}
+
+// WriteOutput agrega un evento de output con el tiempo transcurrido desde el
+// inicio. Los eventos son strings JSON: una runa partida entre chunks se
+// retiene y se escribe completa en el evento siguiente.
+func (a *AsciicastWriter) WriteOutput(data []byte) error {
+	if len(a.partial) > 0 {
+		data = append(a.partial, data...)
+		a.partial = nil
+	}
+	if cut := incompleteRuneStart(data); cut < len(data) {
+		a.partial = append([]byte(nil), data[cut:]...)
+		data = data[:cut]
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	elapsed := time.Since(a.start).Seconds()
+	event, err := json.Marshal([]interface{}{math.Round(elapsed*1e6) / 1e6, "o", string(data)})
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(append(event, '\n'))
+	return err
+}
+
+// incompleteRuneStart posición de la runa UTF-8 incompleta al final de data
+// (len(data) si termina en una runa completa)
+func incompleteRuneStart(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// StateObservation cambio de estado/modo observado durante una reproducción
+type StateObservation struct {
+	Offset  time.Duration `json:"-"`
+	At      float64       `json:"at"` // segundos desde el inicio
+	Frame   int           `json:"frame"`
+	State   ClaudeState   `json:"state"`
+	Mode    ClaudeMode    `json:"mode"`
+	VimMode VimSubMode    `json:"vim_sub_mode,omitempty"`
+	Pending string        `json:"pending_tool,omitempty"` // solo en permission_prompt
+}
+
+// Paso del reloj virtual al reproducir y tiempo extra tras el último frame
+const (
+	replayTickStep = 10 * time.Millisecond
+	replayTail     = 2 * time.Second
+)
+
+// ReplayCapture reproduce una grabación a través de ClaudeAwareScreenHandler con
+// un reloj virtual que respeta los tiempos de la grabación (debounce incluido) y
+// retorna la secuencia de cambios de (estado, modo, submodo vim), empezando por el inicial.
+func ReplayCapture(capture *Capture) []StateObservation {
+	width, height := capture.Width, capture.Height
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+
+	base := time.Unix(0, 0)
+	var clock time.Duration
+
+	handler := NewClaudeAwareScreenHandler(width, height)
+	handler.SetClock(func() time.Time { return base.Add(clock) })
+
+	info := handler.GetClaudeState()
+	observations := []StateObservation{{
+		Frame:   -1,
+		State:   info.State,
+		Mode:    info.Mode,
+		VimMode: info.VimSubMode,
+	}}
+
+	frame := -1
+	observe := func() {
+		info := handler.GetClaudeState()
+		last := observations[len(observations)-1]
+		if info.State == last.State && info.Mode == last.Mode && info.VimSubMode == last.VimMode {
+			return
+		}
+
+		obs := StateObservation{
+			Offset:  clock,
+			At:      clock.Seconds(),
+			Frame:   frame,
+			State:   info.State,
+			Mode:    info.Mode,
+			VimMode: info.VimSubMode,
+		}
+		if info.State == StatePermissionPrompt {
+			obs.Pending = info.PendingTool
+		}
+		observations = append(observations, obs)
+	}
+
+	// advance avanza el reloj virtual hasta target evaluando en cada paso
+	advance := func(target time.Duration) {
+		for clock < target {
+			clock += replayTickStep
+			if clock > target {
+				clock = target
+			}
+			handler.Tick()
+			observe()
+		}
+	}
+
+	for i, f := range capture.Frames {
+		advance(f.Offset)
+		handler.Feed(f.Data)
+		frame = i
+		handler.Tick()
+		observe()
+	}
+	advance(clock + replayTail)
+
+	return observations
+}
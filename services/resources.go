@@ -0,0 +1,345 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"claude-monitor/pkg/logger"
+	"claude-monitor/pkg/metrics"
+)
+
+// ResourceLimits límites de recursos de una terminal (0 = sin límite).
+// memory_mb, cpu_percent y max_processes se aplican al árbol completo con
+// cgroup v2 cuando está disponible; el resto son rlimits heredados por cada
+// proceso hijo.
+type ResourceLimits struct {
+	MemoryMB       int64 `json:"memory_mb,omitempty"`        // cgroup memory.max
+	CPUPercent     int64 `json:"cpu_percent,omitempty"`      // cgroup cpu.max (100 = un núcleo)
+	MaxProcesses   int64 `json:"max_processes,omitempty"`    // cgroup pids.max
+	CPUSeconds     int64 `json:"cpu_seconds,omitempty"`      // RLIMIT_CPU por proceso
+	OpenFiles      int64 `json:"open_files,omitempty"`       // RLIMIT_NOFILE
+	FileSizeMB     int64 `json:"file_size_mb,omitempty"`     // RLIMIT_FSIZE
+	AddressSpaceMB int64 `json:"address_space_mb,omitempty"` // RLIMIT_AS por proceso
+}
+
+// Validate verifica que no haya valores negativos
+func (l *ResourceLimits) Validate() error {
+	fields := map[string]int64{
+		"memory_mb":        l.MemoryMB,
+		"cpu_percent":      l.CPUPercent,
+		"max_processes":    l.MaxProcesses,
+		"cpu_seconds":      l.CPUSeconds,
+		"open_files":       l.OpenFiles,
+		"file_size_mb":     l.FileSizeMB,
+		"address_space_mb": l.AddressSpaceMB,
+	}
+	for name, v := range fields {
+		if v < 0 {
+			return fmt.Errorf("limite invalido %s: %d", name, v)
+		}
+	}
+	return nil
+}
+
+// IsZero indica si no hay ningún límite configurado
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// hasCgroupLimits indica si algún límite requiere cgroup
+func (l ResourceLimits) hasCgroupLimits() bool {
+	return l.MemoryMB > 0 || l.CPUPercent > 0 || l.MaxProcesses > 0
+}
+
+// WithDefaults completa los campos no configurados con los de base
+func (l ResourceLimits) WithDefaults(base ResourceLimits) ResourceLimits {
+	pick := func(v, def int64) int64 {
+		if v > 0 {
+			return v
+		}
+		return def
+	}
+	return ResourceLimits{
+		MemoryMB:       pick(l.MemoryMB, base.MemoryMB),
+		CPUPercent:     pick(l.CPUPercent, base.CPUPercent),
+		MaxProcesses:   pick(l.MaxProcesses, base.MaxProcesses),
+		CPUSeconds:     pick(l.CPUSeconds, base.CPUSeconds),
+		OpenFiles:      pick(l.OpenFiles, base.OpenFiles),
+		FileSizeMB:     pick(l.FileSizeMB, base.FileSizeMB),
+		AddressSpaceMB: pick(l.AddressSpaceMB, base.AddressSpaceMB),
+	}
+}
+
+// LimitsConfig configuración global de límites de recursos
+type LimitsConfig struct {
+	Defaults              ResourceLimits `json:"defaults"`                // aplicados si la terminal o el template no los indican
+	CgroupParent          string         `json:"cgroup_parent"`           // cgroup v2 bajo el que se crea uno por terminal
+	SampleIntervalSeconds int            `json:"sample_interval_seconds"` // muestreo de uso de CPU/memoria
+}
+
+// DefaultLimitsConfig configuración por defecto (sin límites)
+func DefaultLimitsConfig() LimitsConfig {
+	return LimitsConfig{
+		CgroupParent:          "/sys/fs/cgroup/claude-monitor",
+		SampleIntervalSeconds: 5,
+	}
+}
+
+// ResourceUsage uso de recursos del árbol de procesos de una terminal
+type ResourceUsage struct {
+	CPUPercent  float64   `json:"cpu_percent"` // 100 = un núcleo
+	RSSBytes    uint64    `json:"rss_bytes"`
+	Processes   int       `json:"processes"`
+	CgroupBytes uint64    `json:"cgroup_memory_bytes,omitempty"` // memory.current (incluye page cache)
+	Cgroup      bool      `json:"cgroup"`                        // límites de cgroup aplicados
+	SampledAt   time.Time `json:"sampled_at"`
+}
+
+// LimitExceeded dato del evento terminal.limit_exceeded
+type LimitExceeded struct {
+	Limit  string `json:"limit"` // memory, cpu_time, file_size
+	Signal string `json:"signal,omitempty"`
+	Count  uint64 `json:"count,omitempty"` // procesos terminados desde la última muestra
+	Detail string `json:"detail"`
+}
+
+// terminalResources límites efectivos y último muestreo de una terminal
+type terminalResources struct {
+	pid      int
+	limits   ResourceLimits
+	cgroup   string // path del cgroup ("" si no se usa)
+	usage    *ResourceUsage
+	cpuTicks uint64
+	oomKills uint64
+}
+
+// SetResourceLimits configura los límites por defecto y el cgroup padre
+func (s *TerminalService) SetResourceLimits(cfg LimitsConfig) {
+	s.limits = cfg
+	if cfg.CgroupParent == "" {
+		return
+	}
+	cg, err := newCgroupManager(cfg.CgroupParent)
+	if err != nil {
+		logger.Warn("cgroup v2 no disponible, solo se aplicarán rlimits", "parent", cfg.CgroupParent, "error", err)
+		return
+	}
+	s.cgroups = cg
+	logger.Info("Límites de recursos con cgroup v2", "parent", cfg.CgroupParent, "controllers", cg.controllers)
+}
+
+// prepareResources crea el cgroup de la terminal antes de iniciar el proceso.
+// Retorna los recursos a registrar y el fd del cgroup (-1 si no aplica).
+func (s *TerminalService) prepareResources(id string, requested *ResourceLimits) (*terminalResources, int) {
+	limits := s.limits.Defaults
+	if requested != nil {
+		limits = requested.WithDefaults(s.limits.Defaults)
+	}
+	res := &terminalResources{limits: limits}
+
+	if !limits.hasCgroupLimits() {
+		return res, -1
+	}
+	if s.cgroups == nil {
+		logger.Warn("Límites de memoria/CPU/procesos ignorados: cgroup v2 no disponible", "terminal_id", id)
+		return res, -1
+	}
+
+	path, fd, err := s.cgroups.create(id, limits)
+	if err != nil {
+		logger.Warn("Error creando cgroup de terminal", "terminal_id", id, "error", err)
+		return res, -1
+	}
+	res.cgroup = path
+	return res, fd
+}
+
+// startResources aplica los rlimits al proceso iniciado y registra la terminal.
+// os/exec no permite fijar rlimits entre fork y exec, así que se aplican con
+// prlimit tras cmd.Start: los hijos que el proceso cree antes de ese momento
+// (en la práctica, ninguno: el CLI tarda en arrancar) no heredan los límites.
+// Los límites de cgroup no tienen este hueco: el proceso nace en su cgroup.
+func (s *TerminalService) startResources(id string, pid int, res *terminalResources) {
+	res.pid = pid
+	if err := applyRlimits(pid, res.limits); err != nil {
+		logger.Warn("Error aplicando rlimits", "terminal_id", id, "pid", pid, "error", err)
+	}
+
+	s.resourcesMu.Lock()
+	s.resources[id] = res
+	s.resourcesMu.Unlock()
+}
+
+// finishResources detecta si un límite terminó el proceso y libera el cgroup
+func (s *TerminalService) finishResources(id string, state *os.ProcessState) {
+	s.resourcesMu.Lock()
+	res, ok := s.resources[id]
+	delete(s.resources, id)
+	s.resourcesMu.Unlock()
+	metrics.ClearTerminalResources(id)
+	if !ok {
+		return
+	}
+
+	if res.cgroup != "" {
+		s.checkOOMKills(id, res)
+		s.cgroups.remove(res.cgroup)
+	}
+
+	if state == nil {
+		return
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return
+	}
+	var limit string
+	switch status.Signal() {
+	case sigXCPU:
+		limit = "cpu_time"
+	case sigXFSZ:
+		limit = "file_size"
+	case syscall.SIGKILL:
+		// Límite duro de RLIMIT_CPU, solo si el proceso consumió de verdad su
+		// tiempo de CPU (SIGKILL también llega de kill -9, del OOM killer...)
+		if reachedCPULimit(state, res.limits.CPUSeconds) {
+			limit = "cpu_time"
+		}
+	}
+	if limit != "" {
+		s.publishLimitExceeded(id, LimitExceeded{
+			Limit:  limit,
+			Signal: status.Signal().String(),
+			Detail: "proceso principal terminado por " + limit,
+		})
+	}
+}
+
+// reachedCPULimit indica si el tiempo de CPU (usuario + sistema) del proceso
+// alcanzó el límite blando de RLIMIT_CPU
+func reachedCPULimit(state *os.ProcessState, cpuSeconds int64) bool {
+	if cpuSeconds <= 0 {
+		return false
+	}
+	return state.UserTime()+state.SystemTime() >= time.Duration(cpuSeconds)*time.Second
+}
+
+// checkOOMKills publica un evento si el OOM killer del cgroup terminó procesos
+func (s *TerminalService) checkOOMKills(id string, res *terminalResources) {
+	kills := s.cgroups.oomKills(res.cgroup)
+	if kills <= res.oomKills {
+		return
+	}
+	s.publishLimitExceeded(id, LimitExceeded{
+		Limit:  "memory",
+		Count:  kills - res.oomKills,
+		Detail: fmt.Sprintf("OOM killer del cgroup (memory_mb=%d)", res.limits.MemoryMB),
+	})
+	res.oomKills = kills
+}
+
+// publishLimitExceeded registra y publica la terminación por límite
+func (s *TerminalService) publishLimitExceeded(id string, ev LimitExceeded) {
+	logger.Warn("Proceso terminado por límite de recursos", "terminal_id", id, "limit", ev.Limit, "detail", ev.Detail)
+	metrics.RecordTerminalLimitKill(ev.Limit)
+	s.events.Publish(EventTerminalLimit, id, ev)
+}
+
+// SampleResources mide el uso de recursos de todas las terminales activas
+func (s *TerminalService) SampleResources(now time.Time) {
+	s.resourcesMu.Lock()
+	defer s.resourcesMu.Unlock()
+
+	for id, res := range s.resources {
+		sample, err := processTreeUsage(res.pid)
+		if err != nil {
+			continue
+		}
+
+		usage := &ResourceUsage{
+			RSSBytes:  sample.rssBytes,
+			Processes: sample.processes,
+			Cgroup:    res.cgroup != "",
+			SampledAt: now,
+		}
+		if prev := res.usage; prev != nil && sample.cpuTicks >= res.cpuTicks {
+			if elapsed := now.Sub(prev.SampledAt).Seconds(); elapsed > 0 {
+				usage.CPUPercent = float64(sample.cpuTicks-res.cpuTicks) / clockTicks / elapsed * 100
+			}
+		}
+		if res.cgroup != "" {
+			usage.CgroupBytes = s.cgroups.memoryCurrent(res.cgroup)
+			s.checkOOMKills(id, res)
+		}
+
+		res.usage = usage
+		res.cpuTicks = sample.cpuTicks
+		metrics.SetTerminalResources(id, usage.CPUPercent, usage.RSSBytes, usage.Processes)
+	}
+}
+
+// terminalResourceInfo retorna los límites y el último uso medido de una terminal
+func (s *TerminalService) terminalResourceInfo(id string) (*ResourceLimits, *ResourceUsage) {
+	s.resourcesMu.Lock()
+	defer s.resourcesMu.Unlock()
+
+	res, ok := s.resources[id]
+	if !ok {
+		return nil, nil
+	}
+	var limits *ResourceLimits
+	if !res.limits.IsZero() {
+		l := res.limits
+		limits = &l
+	}
+	var usage *ResourceUsage
+	if res.usage != nil {
+		u := *res.usage
+		usage = &u
+	}
+	return limits, usage
+}
+
+// ResourceMonitor muestrea periódicamente el uso de recursos de las terminales
+type ResourceMonitor struct {
+	terminals *TerminalService
+	interval  time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewResourceMonitor crea el monitor con el intervalo de cfg
+func NewResourceMonitor(cfg LimitsConfig, terminals *TerminalService) *ResourceMonitor {
+	if cfg.SampleIntervalSeconds <= 0 {
+		cfg.SampleIntervalSeconds = DefaultLimitsConfig().SampleIntervalSeconds
+	}
+	return &ResourceMonitor{
+		terminals: terminals,
+		interval:  time.Duration(cfg.SampleIntervalSeconds) * time.Second,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Run muestrea hasta Stop
+func (m *ResourceMonitor) Run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.terminals.SampleResources(time.Now())
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop detiene el monitor
+func (m *ResourceMonitor) Stop() {
+	m.once.Do(func() { close(m.stop) })
+}
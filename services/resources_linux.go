@@ -0,0 +1,321 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// clockTicks USER_HZ de /proc/<pid>/stat (100 en todas las arquitecturas soportadas)
+const clockTicks = 100
+
+const (
+	sigXCPU = syscall.SIGXCPU
+	sigXFSZ = syscall.SIGXFSZ
+)
+
+// Controladores de cgroup v2 que usan los límites
+var cgroupControllers = []string{"memory", "cpu", "pids"}
+
+// processSample suma de recursos de un árbol de procesos
+type processSample struct {
+	cpuTicks  uint64
+	rssBytes  uint64
+	processes int
+}
+
+// procStat campos de /proc/<pid>/stat usados para el árbol de procesos
+type procStat struct {
+	comm       string
+	state      string
+	ppid       int
+	cpuTicks   uint64 // utime + stime
+	rssPages   uint64
+	startTicks uint64 // desde el arranque del sistema
+}
+
+// parseProcStat interpreta /proc/<pid>/stat. El nombre del comando va entre
+// paréntesis y puede contener espacios, así que se parte desde el último ')'.
+func parseProcStat(data []byte) (procStat, error) {
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return procStat{}, fmt.Errorf("stat invalido")
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	// fields[0] = state (campo 3 de proc(5))
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("stat incompleto")
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procStat{}, err
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	starttime, _ := strconv.ParseUint(fields[19], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	if rss < 0 {
+		rss = 0
+	}
+
+	return procStat{
+		comm:       string(data[start+1 : end]),
+		state:      fields[0],
+		ppid:       ppid,
+		cpuTicks:   utime + stime,
+		rssPages:   uint64(rss),
+		startTicks: starttime,
+	}, nil
+}
+
+// readProcTable lee /proc/<pid>/stat de todos los procesos y los hijos de cada uno
+func readProcTable() (map[int]procStat, map[int][]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats := make(map[int]procStat)
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		p, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue // el proceso terminó mientras se recorría /proc
+		}
+		st, err := parseProcStat(data)
+		if err != nil {
+			continue
+		}
+		stats[p] = st
+		children[st.ppid] = append(children[st.ppid], p)
+	}
+	return stats, children, nil
+}
+
+// processTreeUsage mide CPU acumulada, RSS y cantidad de procesos de pid y sus descendientes
+func processTreeUsage(pid int) (processSample, error) {
+	stats, children, err := readProcTable()
+	if err != nil {
+		return processSample{}, err
+	}
+
+	if _, ok := stats[pid]; !ok {
+		return processSample{}, fmt.Errorf("proceso %d no encontrado", pid)
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	var sample processSample
+	queue := []int{pid}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		st := stats[p]
+		sample.processes++
+		sample.cpuTicks += st.cpuTicks
+		sample.rssBytes += st.rssPages * pageSize
+		queue = append(queue, children[p]...)
+	}
+	return sample, nil
+}
+
+// applyRlimits aplica los rlimits a un proceso ya iniciado; sus hijos los heredan
+func applyRlimits(pid int, limits ResourceLimits) error {
+	const mb = 1024 * 1024
+	rlimits := []struct {
+		resource int
+		value    int64
+		factor   uint64
+		grace    uint64 // margen del límite duro sobre el blando
+	}{
+		// Con límite blando de CPU llega SIGXCPU; el duro (5s después) envía SIGKILL
+		{unix.RLIMIT_CPU, limits.CPUSeconds, 1, 5},
+		{unix.RLIMIT_NOFILE, limits.OpenFiles, 1, 0},
+		{unix.RLIMIT_FSIZE, limits.FileSizeMB, mb, 0},
+		{unix.RLIMIT_AS, limits.AddressSpaceMB, mb, 0},
+	}
+
+	for _, rl := range rlimits {
+		if rl.value <= 0 {
+			continue
+		}
+		var current unix.Rlimit
+		if err := unix.Prlimit(pid, rl.resource, nil, &current); err != nil {
+			return err
+		}
+
+		cur := uint64(rl.value) * rl.factor
+		max := cur + rl.grace
+		// Sin CAP_SYS_RESOURCE no se puede subir el límite duro
+		if max > current.Max {
+			max = current.Max
+		}
+		if cur > max {
+			cur = max
+		}
+		if err := unix.Prlimit(pid, rl.resource, &unix.Rlimit{Cur: cur, Max: max}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cgroupManager crea un cgroup v2 por terminal bajo un cgroup padre delegado
+type cgroupManager struct {
+	parent      string
+	controllers []string
+}
+
+// newCgroupManager prepara el cgroup padre y habilita los controladores disponibles
+func newCgroupManager(parent string) (*cgroupManager, error) {
+	root := filepath.Dir(parent)
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s no es un cgroup v2", root)
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+
+	// Los controladores deben estar habilitados en el padre del padre para aparecer en él
+	for _, dir := range []string{root, parent} {
+		for _, c := range cgroupControllers {
+			os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+c), 0644)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, err
+	}
+	enabled := strings.Fields(string(data))
+	var controllers []string
+	for _, c := range cgroupControllers {
+		for _, e := range enabled {
+			if c == e {
+				controllers = append(controllers, c)
+			}
+		}
+	}
+	if len(controllers) == 0 {
+		return nil, fmt.Errorf("ningun controlador (memory, cpu, pids) habilitado en %s", parent)
+	}
+
+	return &cgroupManager{parent: parent, controllers: controllers}, nil
+}
+
+// hasController indica si un controlador está habilitado
+func (m *cgroupManager) hasController(name string) bool {
+	for _, c := range m.controllers {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// create crea el cgroup de una terminal y retorna su path y un fd para
+// SysProcAttr.CgroupFD (el proceso nace dentro del cgroup)
+func (m *cgroupManager) create(id string, limits ResourceLimits) (string, int, error) {
+	path := filepath.Join(m.parent, id)
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return "", -1, err
+	}
+
+	var settings [][2]string
+	if limits.MemoryMB > 0 && m.hasController("memory") {
+		settings = append(settings, [2]string{"memory.max", strconv.FormatInt(limits.MemoryMB*1024*1024, 10)})
+	}
+	if limits.CPUPercent > 0 && m.hasController("cpu") {
+		const period = 100000
+		settings = append(settings, [2]string{"cpu.max", fmt.Sprintf("%d %d", limits.CPUPercent*period/100, period)})
+	}
+	if limits.MaxProcesses > 0 && m.hasController("pids") {
+		settings = append(settings, [2]string{"pids.max", strconv.FormatInt(limits.MaxProcesses, 10)})
+	}
+	for _, kv := range settings {
+		if err := os.WriteFile(filepath.Join(path, kv[0]), []byte(kv[1]), 0644); err != nil {
+			os.Remove(path)
+			return "", -1, fmt.Errorf("%s: %v", kv[0], err)
+		}
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(path)
+		return "", -1, err
+	}
+	return path, fd, nil
+}
+
+// readCgroupKey lee un valor "clave N" de un archivo de cgroup
+func readCgroupKey(file, key string) uint64 {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			v, _ := strconv.ParseUint(fields[1], 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+// oomKills procesos terminados por el OOM killer dentro del cgroup
+func (m *cgroupManager) oomKills(path string) uint64 {
+	return readCgroupKey(filepath.Join(path, "memory.events"), "oom_kill")
+}
+
+// memoryCurrent memoria cargada al cgroup
+func (m *cgroupManager) memoryCurrent(path string) uint64 {
+	data, err := os.ReadFile(filepath.Join(path, "memory.current"))
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return v
+}
+
+// remove termina los procesos que queden en el cgroup y lo elimina
+func (m *cgroupManager) remove(path string) {
+	os.WriteFile(filepath.Join(path, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 20; i++ {
+		if err := os.Remove(path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// setCgroupFD hace que el proceso nazca dentro del cgroup (clone3 CLONE_INTO_CGROUP)
+func setCgroupFD(cmd *exec.Cmd, fd int) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+}
+
+// closeCgroupFD cierra el fd del cgroup tras iniciar el proceso
+func closeCgroupFD(fd int) {
+	unix.Close(fd)
+}
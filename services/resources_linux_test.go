@@ -0,0 +1,118 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	data := []byte("1234 (node (worker) x) S 1200 1234 1234 34816 1234 4194304 100 0 0 0 250 50 0 0 20 0 11 0 5000 1000000 2560 18446744073709551615")
+	st, err := parseProcStat(data)
+	if err != nil {
+		t.Fatalf("parseProcStat: %v", err)
+	}
+	if st.ppid != 1200 || st.cpuTicks != 300 || st.rssPages != 2560 {
+		t.Errorf("stat = %+v", st)
+	}
+}
+
+func TestProcessTreeUsageAndRlimits(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 30 & sleep 30 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+	}()
+
+	var sample processSample
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		sample, _ = processTreeUsage(cmd.Process.Pid)
+		if sample.processes >= 3 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if sample.processes != 3 || sample.rssBytes == 0 {
+		t.Errorf("sample = %+v, want 3 procesos con RSS", sample)
+	}
+
+	if err := applyRlimits(cmd.Process.Pid, ResourceLimits{OpenFiles: 64}); err != nil {
+		t.Fatalf("applyRlimits: %v", err)
+	}
+	limits, _ := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/limits")
+	for _, line := range strings.Split(string(limits), "\n") {
+		if strings.HasPrefix(line, "Max open files") {
+			if fields := strings.Fields(line); fields[3] != "64" {
+				t.Errorf("Max open files = %q", line)
+			}
+		}
+	}
+}
+
+func TestFinishResources_LimitEvent(t *testing.T) {
+	bus := NewEventBus()
+	ch, cancel := bus.Subscribe(EventFilter{Types: []EventType{EventTerminalLimit}})
+	defer cancel()
+
+	s := NewTerminalService(t.TempDir())
+	s.SetEventBus(bus)
+
+	// El proceso termina con SIGXFSZ, como al superar RLIMIT_FSIZE
+	cmd := exec.Command("sh", "-c", "kill -XFSZ $$")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.startResources("t1", cmd.Process.Pid, &terminalResources{limits: ResourceLimits{FileSizeMB: 1}})
+	cmd.Wait()
+	s.finishResources("t1", cmd.ProcessState)
+
+	select {
+	case ev := <-ch:
+		data, ok := ev.Data.(LimitExceeded)
+		if !ok || data.Limit != "file_size" || ev.TerminalID != "t1" {
+			t.Errorf("evento = %+v", ev)
+		}
+	default:
+		t.Fatal("no se publico terminal.limit_exceeded")
+	}
+
+	if limits, usage := s.terminalResourceInfo("t1"); limits != nil || usage != nil {
+		t.Error("recursos no liberados al terminar")
+	}
+}
+
+func TestFinishResources_SIGKILLWithoutCPUUse(t *testing.T) {
+	bus := NewEventBus()
+	ch, cancel := bus.Subscribe(EventFilter{Types: []EventType{EventTerminalLimit}})
+	defer cancel()
+
+	s := NewTerminalService(t.TempDir())
+	s.SetEventBus(bus)
+
+	// kill -9 externo a un proceso inactivo: no es el límite duro de RLIMIT_CPU
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.startResources("t1", cmd.Process.Pid, &terminalResources{limits: ResourceLimits{CPUSeconds: 60}})
+	cmd.Process.Kill()
+	cmd.Wait()
+	s.finishResources("t1", cmd.ProcessState)
+
+	select {
+	case ev := <-ch:
+		t.Errorf("evento inesperado: %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+const clockTicks = 100
+
+// Sin rlimits aplicados no se atribuyen señales a un límite
+const (
+	sigXCPU = syscall.Signal(-1)
+	sigXFSZ = syscall.Signal(-2)
+)
+
+// processSample suma de recursos de un árbol de procesos
+type processSample struct {
+	cpuTicks  uint64
+	rssBytes  uint64
+	processes int
+}
+
+// processTreeUsage no está soportado fuera de Linux
+func processTreeUsage(pid int) (processSample, error) {
+	return processSample{}, fmt.Errorf("uso de recursos no soportado en esta plataforma")
+}
+
+// applyRlimits no está soportado fuera de Linux
+func applyRlimits(pid int, limits ResourceLimits) error {
+	if limits.CPUSeconds > 0 || limits.OpenFiles > 0 || limits.FileSizeMB > 0 || limits.AddressSpaceMB > 0 {
+		return fmt.Errorf("rlimits no soportados en esta plataforma")
+	}
+	return nil
+}
+
+// cgroupManager sin soporte fuera de Linux
+type cgroupManager struct {
+	controllers []string
+}
+
+func newCgroupManager(parent string) (*cgroupManager, error) {
+	return nil, fmt.Errorf("cgroup v2 solo esta disponible en Linux")
+}
+
+func (m *cgroupManager) create(id string, limits ResourceLimits) (string, int, error) {
+	return "", -1, fmt.Errorf("cgroup v2 solo esta disponible en Linux")
+}
+
+func (m *cgroupManager) oomKills(path string) uint64      { return 0 }
+func (m *cgroupManager) memoryCurrent(path string) uint64 { return 0 }
+func (m *cgroupManager) remove(path string)               {}
+
+func setCgroupFD(cmd *exec.Cmd, fd int) {}
+func closeCgroupFD(fd int)              {}
@@ -0,0 +1,39 @@
+package services
+
+import "testing"
+
+func TestResourceLimits_WithDefaults(t *testing.T) {
+	defaults := ResourceLimits{MemoryMB: 2048, OpenFiles: 4096}
+	got := ResourceLimits{MemoryMB: 512, CPUPercent: 150}.WithDefaults(defaults)
+
+	want := ResourceLimits{MemoryMB: 512, CPUPercent: 150, OpenFiles: 4096}
+	if got != want {
+		t.Errorf("WithDefaults = %+v, want %+v", got, want)
+	}
+	if !(ResourceLimits{}).IsZero() || want.IsZero() {
+		t.Error("IsZero incorrecto")
+	}
+	if !want.hasCgroupLimits() || (ResourceLimits{OpenFiles: 10}).hasCgroupLimits() {
+		t.Error("hasCgroupLimits incorrecto")
+	}
+
+	if err := (&ResourceLimits{CPUSeconds: -1}).Validate(); err == nil {
+		t.Error("limite negativo aceptado")
+	}
+}
+
+func TestTerminalTemplate_ApplyLimits(t *testing.T) {
+	tmpl := TerminalTemplate{Name: "sandbox", Limits: &ResourceLimits{MemoryMB: 1024, MaxProcesses: 64}}
+
+	cfg := tmpl.Apply(TerminalConfig{Limits: &ResourceLimits{MemoryMB: 256}})
+	if want := (ResourceLimits{MemoryMB: 256, MaxProcesses: 64}); cfg.Limits == nil || *cfg.Limits != want {
+		t.Errorf("limits = %+v, want %+v", cfg.Limits, want)
+	}
+
+	// Sin límites en la request se copia el template (no se comparte el puntero)
+	cfg = tmpl.Apply(TerminalConfig{})
+	cfg.Limits.MemoryMB = 1
+	if tmpl.Limits.MemoryMB != 1024 {
+		t.Error("Apply comparte los límites del template")
+	}
+}
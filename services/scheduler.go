@@ -0,0 +1,698 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+const (
+	schedulerTick         = 15 * time.Second
+	scheduleReadyTimeout  = 2 * time.Minute // espera a que Claude acepte input en modo terminal
+	maxScheduleRunHistory = 1000
+)
+
+// ScheduleMode forma de ejecutar el prompt programado
+type ScheduleMode string
+
+const (
+	ScheduleHeadless ScheduleMode = "headless" // job claude -p
+	ScheduleTerminal ScheduleMode = "terminal" // terminal Claude interactiva
+)
+
+// ScheduleTrigger origen de una ejecución
+type ScheduleTrigger string
+
+const (
+	TriggerCron   ScheduleTrigger = "cron"
+	TriggerManual ScheduleTrigger = "manual"
+)
+
+// ScheduleRunStatus estado de una ejecución programada
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunRunning   ScheduleRunStatus = "running"
+	ScheduleRunCompleted ScheduleRunStatus = "completed"
+	ScheduleRunFailed    ScheduleRunStatus = "failed"
+	ScheduleRunSkipped   ScheduleRunStatus = "skipped"
+)
+
+// Schedule prompt guardado que se ejecuta según una expresión cron
+type Schedule struct {
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	Cron          string       `json:"cron"`
+	Timezone      string       `json:"timezone,omitempty"` // IANA (vacío = zona del servidor)
+	Mode          ScheduleMode `json:"mode"`
+	Request       JobRequest   `json:"request"`
+	SkipIfRunning bool         `json:"skip_if_running"`         // omitir si la ejecución anterior sigue en curso
+	KeepTerminal  bool         `json:"keep_terminal,omitempty"` // modo terminal: no archivar ni terminar la terminal al acabar
+	Disabled      bool         `json:"disabled,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	NextRunAt     *time.Time   `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time   `json:"last_run_at,omitempty"`
+}
+
+// Validate valida expresión cron, zona horaria y modo
+func (s *Schedule) Validate() error {
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("timezone invalida: %s", s.Timezone)
+	}
+	switch s.Mode {
+	case ScheduleHeadless, ScheduleTerminal:
+	default:
+		return fmt.Errorf("mode invalido: %s", s.Mode)
+	}
+	return nil
+}
+
+// nextRun calcula la próxima ejecución posterior a after (nil si está deshabilitado o nunca ocurre)
+func (s *Schedule) nextRun(after time.Time) *time.Time {
+	if s.Disabled {
+		return nil
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil
+	}
+	next := cron.Next(after.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// ScheduleRun registro de una ejecución programada
+type ScheduleRun struct {
+	ID           string            `json:"id"`
+	ScheduleID   string            `json:"schedule_id"`
+	ScheduleName string            `json:"schedule_name"`
+	Mode         ScheduleMode      `json:"mode"`
+	Trigger      ScheduleTrigger   `json:"trigger"`
+	Status       ScheduleRunStatus `json:"status"`
+	ScheduledFor time.Time         `json:"scheduled_for"`
+	StartedAt    time.Time         `json:"started_at"`
+	PromptSentAt *time.Time        `json:"prompt_sent_at,omitempty"` // solo modo terminal
+	FinishedAt   *time.Time        `json:"finished_at,omitempty"`
+	JobID        string            `json:"job_id,omitempty"`
+	TerminalID   string            `json:"terminal_id,omitempty"`
+	SessionID    string            `json:"session_id,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Scheduler ejecuta prompts guardados según expresiones cron
+type Scheduler struct {
+	jobs      *JobService
+	terminals *TerminalService
+	events    *EventBus
+	file      string
+	runsFile  string
+
+	mu        sync.RWMutex
+	schedules map[string]*Schedule
+	runs      []*ScheduleRun // de la más antigua a la más reciente
+
+	cancel func()
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// NewScheduler crea el scheduler y carga los schedules persistidos.
+// Las ejecuciones perdidas mientras el servidor estaba detenido no se recuperan.
+func NewScheduler(dataDir string, jobs *JobService, terminals *TerminalService, events *EventBus) *Scheduler {
+	sc := &Scheduler{
+		jobs:      jobs,
+		terminals: terminals,
+		events:    events,
+		file:      filepath.Join(dataDir, "schedules.json"),
+		runsFile:  filepath.Join(dataDir, "schedule_runs.json"),
+		schedules: make(map[string]*Schedule),
+		stop:      make(chan struct{}),
+	}
+	sc.load()
+
+	ch, cancel := events.Subscribe(EventFilter{
+		Types: []EventType{EventJobFinished, EventClaudeState, EventTerminalEnded},
+	})
+	sc.cancel = cancel
+	go sc.watch(ch)
+
+	return sc
+}
+
+// load carga schedules e historial desde disco
+func (sc *Scheduler) load() {
+	now := time.Now()
+
+	if data, err := os.ReadFile(sc.file); err == nil {
+		var schedules []*Schedule
+		if err := json.Unmarshal(data, &schedules); err != nil {
+			logger.Error("Error cargando schedules", "error", err)
+		}
+		for _, s := range schedules {
+			if err := s.Validate(); err != nil {
+				logger.Warn("Schedule invalido", "schedule_id", s.ID, "error", err)
+				continue
+			}
+			s.NextRunAt = s.nextRun(now)
+			sc.schedules[s.ID] = s
+		}
+	}
+
+	if data, err := os.ReadFile(sc.runsFile); err == nil {
+		if err := json.Unmarshal(data, &sc.runs); err != nil {
+			logger.Error("Error cargando historial de schedules", "error", err)
+		}
+	}
+}
+
+// persist guarda los schedules de forma atómica
+func (sc *Scheduler) persist() {
+	sc.mu.RLock()
+	schedules := make([]*Schedule, 0, len(sc.schedules))
+	for _, s := range sc.schedules {
+		schedules = append(schedules, s)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].CreatedAt.Before(schedules[j].CreatedAt) })
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	sc.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando schedules", "error", err)
+		return
+	}
+	if err := atomicWriteFile(sc.file, data, 0600); err != nil {
+		logger.Error("Error guardando schedules", "error", err)
+	}
+}
+
+// persistRuns guarda el historial de ejecuciones
+func (sc *Scheduler) persistRuns() {
+	sc.mu.RLock()
+	data, err := json.MarshalIndent(sc.runs, "", "  ")
+	sc.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando historial de schedules", "error", err)
+		return
+	}
+	if err := atomicWriteFile(sc.runsFile, data, 0600); err != nil {
+		logger.Error("Error guardando historial de schedules", "error", err)
+	}
+}
+
+// Run revisa los schedules periódicamente hasta Stop
+func (sc *Scheduler) Run() {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sc.Check(time.Now())
+		case <-sc.stop:
+			return
+		}
+	}
+}
+
+// Stop detiene el scheduler y cancela la suscripción al bus
+func (sc *Scheduler) Stop() {
+	sc.once.Do(func() {
+		close(sc.stop)
+		sc.cancel()
+	})
+}
+
+// Check lanza los schedules cuya próxima ejecución ya venció
+func (sc *Scheduler) Check(now time.Time) {
+	type due struct {
+		schedule     Schedule
+		scheduledFor time.Time
+	}
+	var pending []due
+
+	sc.mu.Lock()
+	for _, s := range sc.schedules {
+		if s.Disabled || s.NextRunAt == nil || s.NextRunAt.After(now) {
+			continue
+		}
+		pending = append(pending, due{schedule: *s, scheduledFor: *s.NextRunAt})
+		last := now
+		s.LastRunAt = &last
+		s.NextRunAt = s.nextRun(now)
+	}
+	sc.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	sc.persist()
+
+	for _, d := range pending {
+		sc.start(d.schedule, TriggerCron, d.scheduledFor)
+	}
+}
+
+// Create valida y guarda un nuevo schedule
+func (sc *Scheduler) Create(s Schedule) (*Schedule, error) {
+	if err := sc.normalize(&s); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.ID = generateUUID()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	s.NextRunAt = s.nextRun(now)
+	s.LastRunAt = nil
+
+	stored := s
+	sc.mu.Lock()
+	sc.schedules[s.ID] = &stored
+	sc.mu.Unlock()
+	sc.persist()
+
+	logger.Info("Schedule creado", "schedule_id", s.ID, "cron", s.Cron, "mode", s.Mode)
+	return &s, nil
+}
+
+// Update reemplaza la definición de un schedule conservando ID e historial
+func (sc *Scheduler) Update(id string, s Schedule) (*Schedule, error) {
+	if err := sc.normalize(&s); err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	existing, ok := sc.schedules[id]
+	if !ok {
+		sc.mu.Unlock()
+		return nil, fmt.Errorf("schedule no encontrado: %s", id)
+	}
+	now := time.Now()
+	s.ID = id
+	s.CreatedAt = existing.CreatedAt
+	s.LastRunAt = existing.LastRunAt
+	s.UpdatedAt = now
+	s.NextRunAt = s.nextRun(now)
+	stored := s
+	sc.schedules[id] = &stored
+	sc.mu.Unlock()
+	sc.persist()
+
+	return &s, nil
+}
+
+// normalize aplica valores por defecto y valida el schedule y su prompt
+func (sc *Scheduler) normalize(s *Schedule) error {
+	s.Cron = strings.TrimSpace(s.Cron)
+	if s.Mode == "" {
+		s.Mode = ScheduleHeadless
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if s.Mode == ScheduleHeadless {
+		if err := sc.jobs.ValidateRequest(&s.Request); err != nil {
+			return err
+		}
+	} else {
+		// En modo terminal solo aplican prompt, directorio, modelo y herramientas
+		req := s.Request
+		if err := sc.jobs.ValidateRequest(&req); err != nil {
+			return err
+		}
+	}
+
+	if s.Name == "" {
+		s.Name = filepath.Base(s.Request.WorkDir)
+	}
+	return nil
+}
+
+// Delete elimina un schedule (el historial de ejecuciones se conserva)
+func (sc *Scheduler) Delete(id string) error {
+	sc.mu.Lock()
+	if _, ok := sc.schedules[id]; !ok {
+		sc.mu.Unlock()
+		return fmt.Errorf("schedule no encontrado: %s", id)
+	}
+	delete(sc.schedules, id)
+	sc.mu.Unlock()
+
+	sc.persist()
+	return nil
+}
+
+// List retorna los schedules ordenados por fecha de creación
+func (sc *Scheduler) List() []Schedule {
+	sc.mu.RLock()
+	list := make([]Schedule, 0, len(sc.schedules))
+	for _, s := range sc.schedules {
+		list = append(list, *s)
+	}
+	sc.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Get retorna un schedule
+func (sc *Scheduler) Get(id string) (*Schedule, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	s, ok := sc.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("schedule no encontrado: %s", id)
+	}
+	snapshot := *s
+	return &snapshot, nil
+}
+
+// Runs retorna el historial de un schedule de la ejecución más reciente a la más antigua
+func (sc *Scheduler) Runs(scheduleID string, limit int) []ScheduleRun {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	var list []ScheduleRun
+	for i := len(sc.runs) - 1; i >= 0; i-- {
+		if sc.runs[i].ScheduleID != scheduleID {
+			continue
+		}
+		list = append(list, *sc.runs[i])
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+	}
+	return list
+}
+
+// RunNow lanza un schedule inmediatamente, respetando skip_if_running
+func (sc *Scheduler) RunNow(id string) (*ScheduleRun, error) {
+	s, err := sc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return sc.start(*s, TriggerManual, time.Now()), nil
+}
+
+// start registra y lanza una ejecución del schedule
+func (sc *Scheduler) start(s Schedule, trigger ScheduleTrigger, scheduledFor time.Time) *ScheduleRun {
+	run := &ScheduleRun{
+		ID:           generateUUID(),
+		ScheduleID:   s.ID,
+		ScheduleName: s.Name,
+		Mode:         s.Mode,
+		Trigger:      trigger,
+		Status:       ScheduleRunRunning,
+		ScheduledFor: scheduledFor,
+		StartedAt:    time.Now(),
+	}
+
+	skip := s.SkipIfRunning && sc.previousRunning(s.ID)
+
+	sc.mu.Lock()
+	sc.runs = append(sc.runs, run)
+	if len(sc.runs) > maxScheduleRunHistory {
+		sc.runs = sc.runs[len(sc.runs)-maxScheduleRunHistory:]
+	}
+	sc.mu.Unlock()
+
+	switch {
+	case skip:
+		sc.finish(run.ID, ScheduleRunSkipped, "la ejecucion anterior sigue en curso")
+	case s.Mode == ScheduleTerminal:
+		sc.startTerminal(run, s)
+	default:
+		sc.startJob(run, s)
+	}
+
+	sc.mu.RLock()
+	snapshot := *run
+	sc.mu.RUnlock()
+
+	sc.persistRuns()
+	sc.events.Publish(EventScheduleRun, snapshot.TerminalID, snapshot)
+	logger.Info("Schedule ejecutado",
+		"schedule_id", s.ID,
+		"run_id", run.ID,
+		"trigger", trigger,
+		"status", snapshot.Status,
+	)
+
+	return &snapshot
+}
+
+// startJob encola el prompt como job headless
+func (sc *Scheduler) startJob(run *ScheduleRun, s Schedule) {
+	req := s.Request
+	if req.Name == "" {
+		req.Name = s.Name
+	}
+
+	job, err := sc.jobs.Submit(req)
+	if err != nil {
+		sc.finish(run.ID, ScheduleRunFailed, err.Error())
+		return
+	}
+
+	sc.mu.Lock()
+	run.JobID = job.ID
+	sc.mu.Unlock()
+
+	// El job puede haber terminado antes de registrar su ID
+	if current, err := sc.jobs.Get(job.ID); err == nil && current.Status.IsFinished() {
+		sc.applyJob(*current)
+	}
+}
+
+// startTerminal abre una terminal Claude y le envía el prompt cuando acepte input
+func (sc *Scheduler) startTerminal(run *ScheduleRun, s Schedule) {
+	info, err := sc.terminals.Create(TerminalConfig{
+		Name:            s.Name,
+		WorkDir:         s.Request.WorkDir,
+		Type:            "claude",
+		Model:           s.Request.Model,
+		SystemPrompt:    s.Request.SystemPrompt,
+		AllowedTools:    s.Request.AllowedTools,
+		DisallowedTools: s.Request.DisallowedTools,
+		PermissionMode:  s.Request.PermissionMode,
+		CreatedBy:       "scheduler",
+	})
+	if err != nil {
+		sc.finish(run.ID, ScheduleRunFailed, err.Error())
+		return
+	}
+
+	sc.mu.Lock()
+	run.TerminalID = info.ID
+	run.SessionID = info.SessionID
+	sc.mu.Unlock()
+
+	go sc.sendPrompt(run.ID, info.ID, s.Request.Prompt)
+}
+
+// sendPrompt espera a que Claude esté listo y escribe el prompt
+func (sc *Scheduler) sendPrompt(runID, terminalID, prompt string) {
+	tc, err := sc.terminals.getClaudeTerminal(terminalID)
+	if err != nil {
+		sc.finish(runID, ScheduleRunFailed, err.Error())
+		return
+	}
+	if !waitForClaudeState(context.Background(), tc, StateWaitingInput, time.Time{}, scheduleReadyTimeout) {
+		sc.finish(runID, ScheduleRunFailed, "Claude no quedo listo para recibir el prompt")
+		sc.retireTerminal(runID, terminalID)
+		return
+	}
+
+	sentAt := time.Now()
+	sc.mu.Lock()
+	if run := sc.findRun(func(r *ScheduleRun) bool { return r.ID == runID }); run != nil {
+		run.PromptSentAt = &sentAt
+	}
+	sc.mu.Unlock()
+
+	if _, err := sc.terminals.SendInput(context.Background(), terminalID, TerminalInput{Text: prompt, Keys: []string{"Enter"}}); err != nil {
+		sc.finish(runID, ScheduleRunFailed, err.Error())
+		sc.retireTerminal(runID, terminalID)
+		return
+	}
+	sc.persistRuns()
+}
+
+// previousRunning indica si alguna ejecución anterior del schedule sigue en
+// curso. En modo terminal cuenta también la terminal viva de una ejecución ya
+// completada (keep_terminal, o mientras se termina).
+func (sc *Scheduler) previousRunning(scheduleID string) bool {
+	sc.mu.RLock()
+	var previous []ScheduleRun
+	for _, r := range sc.runs {
+		if r.ScheduleID == scheduleID && (r.Status == ScheduleRunRunning || r.TerminalID != "") {
+			previous = append(previous, *r)
+		}
+	}
+	sc.mu.RUnlock()
+
+	for _, r := range previous {
+		switch {
+		case r.JobID != "":
+			if job, err := sc.jobs.Get(r.JobID); err == nil && !job.Status.IsFinished() {
+				return true
+			}
+		case r.TerminalID != "":
+			if sc.terminals.IsActive(r.TerminalID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retireTerminal archiva y termina la terminal de una ejecución acabada, salvo
+// que el schedule tenga keep_terminal
+func (sc *Scheduler) retireTerminal(runID, terminalID string) {
+	sc.mu.RLock()
+	keep := false
+	if run := sc.findRun(func(r *ScheduleRun) bool { return r.ID == runID }); run != nil {
+		if s, ok := sc.schedules[run.ScheduleID]; ok {
+			keep = s.KeepTerminal
+		}
+	}
+	sc.mu.RUnlock()
+
+	if keep || !sc.terminals.IsActive(terminalID) {
+		return
+	}
+
+	// Archive solo acepta terminales pausadas o detenidas
+	if err := sc.terminals.Pause(terminalID); err != nil {
+		logger.Debug("Terminal de schedule no pausada", "terminal_id", terminalID, "error", err)
+	}
+	if err := sc.terminals.Archive(terminalID); err != nil {
+		logger.Warn("Error archivando terminal de schedule", "terminal_id", terminalID, "error", err)
+	}
+	if err := sc.terminals.Kill(terminalID); err != nil {
+		logger.Warn("Error terminando terminal de schedule", "terminal_id", terminalID, "error", err)
+	}
+}
+
+// findRun busca la ejecución más reciente que cumpla match (requiere sc.mu)
+func (sc *Scheduler) findRun(match func(*ScheduleRun) bool) *ScheduleRun {
+	for i := len(sc.runs) - 1; i >= 0; i-- {
+		if match(sc.runs[i]) {
+			return sc.runs[i]
+		}
+	}
+	return nil
+}
+
+// finish cierra una ejecución en curso
+func (sc *Scheduler) finish(runID string, status ScheduleRunStatus, errMsg string) {
+	sc.mu.Lock()
+	run := sc.findRun(func(r *ScheduleRun) bool { return r.ID == runID })
+	if run == nil || run.Status != ScheduleRunRunning {
+		sc.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	run.Status = status
+	run.FinishedAt = &now
+	run.Error = errMsg
+	sc.mu.Unlock()
+}
+
+// watch actualiza el historial con el resultado de jobs y terminales
+func (sc *Scheduler) watch(events <-chan Event) {
+	for e := range events {
+		switch e.Type {
+		case EventJobFinished:
+			if job, ok := e.Data.(Job); ok {
+				sc.applyJob(job)
+			}
+		case EventClaudeState:
+			// Modo terminal: el turno del prompt termina cuando Claude vuelve a pedir input
+			if data, ok := e.Data.(StateChangeData); ok && ClaudeState(data.NewState) == StateWaitingInput {
+				sc.applyTerminal(e.TerminalID, e.Timestamp, false)
+			}
+		case EventTerminalEnded:
+			sc.applyTerminal(e.TerminalID, e.Timestamp, true)
+		}
+	}
+}
+
+// applyJob cierra la ejecución asociada a un job terminado
+func (sc *Scheduler) applyJob(job Job) {
+	sc.mu.Lock()
+	run := sc.findRun(func(r *ScheduleRun) bool { return r.JobID == job.ID })
+	if run == nil || run.Status != ScheduleRunRunning {
+		sc.mu.Unlock()
+		return
+	}
+	run.SessionID = job.SessionID
+	run.FinishedAt = job.FinishedAt
+	if job.Status == JobSucceeded {
+		run.Status = ScheduleRunCompleted
+	} else {
+		run.Status = ScheduleRunFailed
+		run.Error = job.Error
+		if run.Error == "" {
+			run.Error = string(job.Status)
+		}
+	}
+	snapshot := *run
+	sc.mu.Unlock()
+
+	sc.persistRuns()
+	sc.events.Publish(EventScheduleRun, "", snapshot)
+}
+
+// applyTerminal cierra la ejecución de una terminal cuando termina el turno o la terminal
+func (sc *Scheduler) applyTerminal(terminalID string, at time.Time, ended bool) {
+	sc.mu.Lock()
+	run := sc.findRun(func(r *ScheduleRun) bool { return r.TerminalID == terminalID })
+	if run == nil || run.Status != ScheduleRunRunning {
+		sc.mu.Unlock()
+		return
+	}
+
+	switch {
+	case run.PromptSentAt != nil && at.After(*run.PromptSentAt):
+		run.Status = ScheduleRunCompleted
+	case ended:
+		run.Status = ScheduleRunFailed
+		run.Error = "la terminal termino antes de enviar el prompt"
+	default:
+		sc.mu.Unlock()
+		return
+	}
+	run.FinishedAt = &at
+	snapshot := *run
+	sc.mu.Unlock()
+
+	sc.persistRuns()
+	sc.events.Publish(EventScheduleRun, terminalID, snapshot)
+
+	// Fuera del bucle de eventos: terminar la terminal publica terminal.ended
+	if !ended {
+		go sc.retireTerminal(snapshot.ID, terminalID)
+	}
+}
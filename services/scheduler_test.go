@@ -0,0 +1,171 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	loc := time.UTC
+	from := time.Date(2025, 3, 14, 10, 17, 30, 0, loc) // viernes
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 3, 14, 10, 18, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2025, 3, 14, 10, 30, 0, 0, loc)},
+		{"0 7 * * MON-FRI", time.Date(2025, 3, 17, 7, 0, 0, 0, loc)},
+		{"0 7 * * 1-5", time.Date(2025, 3, 17, 7, 0, 0, 0, loc)},
+		{"30 9 1,15 * *", time.Date(2025, 3, 15, 9, 30, 0, 0, loc)},
+		{"0 0 * * 7", time.Date(2025, 3, 16, 0, 0, 0, 0, loc)},
+		{"@monthly", time.Date(2025, 4, 1, 0, 0, 0, 0, loc)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, loc)},
+		// Día del mes y de la semana restringidos: basta con uno
+		{"0 0 20 * SAT", time.Date(2025, 3, 15, 0, 0, 0, 0, loc)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * MON-XYZ", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+// waitForRun espera a que una ejecución del schedule deje de estar en curso
+// (runID vacío = la más reciente)
+func waitForRun(t *testing.T, sc *Scheduler, scheduleID, runID string) ScheduleRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, r := range sc.Runs(scheduleID, 0) {
+			if runID != "" && r.ID != runID {
+				continue
+			}
+			if r.Status != ScheduleRunRunning {
+				return r
+			}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("run did not finish")
+	return ScheduleRun{}
+}
+
+func TestScheduler_HeadlessRunAndSkip(t *testing.T) {
+	dataDir := t.TempDir()
+	js := newTestJobService(t, dataDir, JobsConfig{})
+	bus := js.events
+	sc := NewScheduler(dataDir, js, nil, bus)
+	defer sc.Stop()
+
+	work := t.TempDir()
+	s, err := sc.Create(Schedule{Name: "audit", Cron: "0 7 * * MON-FRI", Request: JobRequest{Prompt: "hola", WorkDir: work}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if s.Mode != ScheduleHeadless || s.NextRunAt == nil || s.NextRunAt.Hour() != 7 {
+		t.Errorf("schedule = %+v", s)
+	}
+
+	// Vence la próxima ejecución
+	sc.Check(s.NextRunAt.Add(time.Second))
+	run := waitForRun(t, sc, s.ID, "")
+	if run.Status != ScheduleRunCompleted || run.Trigger != TriggerCron || run.JobID == "" || run.SessionID != "s-1" {
+		t.Errorf("run = %+v", run)
+	}
+	if got, _ := sc.Get(s.ID); got.LastRunAt == nil || !got.NextRunAt.After(*s.NextRunAt) {
+		t.Errorf("schedule after run = %+v", got)
+	}
+
+	// Con skip_if_running la segunda ejecución se omite mientras la primera sigue en curso
+	slow, _ := sc.Create(Schedule{Cron: "@hourly", SkipIfRunning: true, Request: JobRequest{Prompt: "sleep", WorkDir: work}})
+	first, _ := sc.RunNow(slow.ID)
+	second, _ := sc.RunNow(slow.ID)
+	if first.Status != ScheduleRunRunning || second.Status != ScheduleRunSkipped {
+		t.Errorf("first = %s, second = %s", first.Status, second.Status)
+	}
+	js.Cancel(first.JobID)
+	if done := waitForRun(t, sc, slow.ID, first.ID); done.Status != ScheduleRunFailed {
+		t.Errorf("cancelled run = %+v", done)
+	}
+
+	// Schedules e historial persisten
+	reloaded := NewScheduler(dataDir, js, nil, NewEventBus())
+	defer reloaded.Stop()
+	if len(reloaded.List()) != 2 || len(reloaded.Runs(slow.ID, 0)) != 2 {
+		t.Errorf("reloaded: %d schedules, %d runs", len(reloaded.List()), len(reloaded.Runs(slow.ID, 0)))
+	}
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	invalid := []Schedule{
+		{Cron: "every day", Mode: ScheduleHeadless},
+		{Cron: "@daily", Mode: "cron"},
+		{Cron: "@daily", Mode: ScheduleTerminal, Timezone: "Mars/Olympus"},
+	}
+	for i, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("case %d: Validate should fail", i)
+		}
+	}
+
+	s := Schedule{Cron: "0 7 * * *", Timezone: "America/New_York", Mode: ScheduleHeadless}
+	next := s.nextRun(time.Date(2025, 1, 10, 13, 0, 0, 0, time.UTC))
+	if next == nil || !next.Equal(time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextRun = %v", next)
+	}
+}
+
+func TestScheduler_TerminalRetireAndSkip(t *testing.T) {
+	dataDir := t.TempDir()
+	js := newTestJobService(t, dataDir, JobsConfig{})
+	terminals := NewTerminalService(t.TempDir())
+	sc := NewScheduler(dataDir, js, terminals, js.events)
+	defer sc.Stop()
+
+	work := t.TempDir()
+	keep, _ := sc.Create(Schedule{Cron: "@hourly", Mode: ScheduleTerminal, SkipIfRunning: true, KeepTerminal: true, Request: JobRequest{Prompt: "hola", WorkDir: work}})
+	retire, _ := sc.Create(Schedule{Cron: "@hourly", Mode: ScheduleTerminal, Request: JobRequest{Prompt: "hola", WorkDir: work}})
+
+	// Ejecuciones en curso con el prompt ya enviado a terminales activas
+	sent := time.Now()
+	for id, scheduleID := range map[string]string{"term-keep": keep.ID, "term-retire": retire.ID} {
+		tc := NewTerminalClaude(id, "test", work, TerminalConfig{})
+		tc.MarkActive()
+		terminals.terminals[id] = tc
+		sc.runs = append(sc.runs, &ScheduleRun{ID: "run-" + id, ScheduleID: scheduleID, Mode: ScheduleTerminal, Status: ScheduleRunRunning, TerminalID: id, PromptSentAt: &sent})
+	}
+
+	sc.applyTerminal("term-retire", sent.Add(time.Second), false)
+	sc.applyTerminal("term-keep", sent.Add(time.Second), false)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !terminals.terminals["term-retire"].(*TerminalClaude).GetIsArchived() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !terminals.terminals["term-retire"].(*TerminalClaude).GetIsArchived() {
+		t.Error("terminal of completed run not archived")
+	}
+	if terminals.terminals["term-keep"].(*TerminalClaude).GetIsArchived() {
+		t.Error("keep_terminal run archived its terminal")
+	}
+
+	// La ejecución anterior está completada pero su terminal sigue viva
+	if run, _ := sc.RunNow(keep.ID); run.Status != ScheduleRunSkipped {
+		t.Errorf("run with live previous terminal = %s, want skipped", run.Status)
+	}
+}
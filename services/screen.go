@@ -3,6 +3,7 @@ package services
 import (
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/Azure/go-ansiterm"
 )
@@ -61,6 +62,9 @@ type ScreenHandler struct {
 	history       [][]Cell
 	maxHistory    int
 	historyOffset int
+
+	// Runas UTF-8 pendientes (ver ScreenState.Feed)
+	pendingRunes []rune
 }
 
 // NewScreenHandler crea un nuevo handler con las dimensiones especificadas
@@ -109,6 +113,17 @@ func (h *ScreenHandler) Print(b byte) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	ch := rune(b)
+	if b == utf8Placeholder {
+		// go-ansiterm solo imprime ASCII: el placeholder representa la
+		// siguiente runa UTF-8 del chunk (DEL real se ignora)
+		if len(h.pendingRunes) == 0 {
+			return nil
+		}
+		ch = h.pendingRunes[0]
+		h.pendingRunes = h.pendingRunes[1:]
+	}
+
 	if h.cursorX >= h.width {
 		h.cursorX = 0
 		h.cursorY++
@@ -121,7 +136,7 @@ func (h *ScreenHandler) Print(b byte) error {
 	buf := h.currentBuffer()
 	if h.cursorY >= 0 && h.cursorY < h.height && h.cursorX >= 0 && h.cursorX < h.width {
 		buf[h.cursorY][h.cursorX] = Cell{
-			Char: rune(b),
+			Char: ch,
 			FG:   h.currentFG,
 			BG:   h.currentBG,
 			Bold: h.currentBold,
@@ -829,11 +844,82 @@ func (h *ScreenHandler) Resize(width, height int) {
 // ScreenState wrapper que combina ScreenHandler con AnsiParser
 // ============================================================================
 
+// Byte que reemplaza a cada runa UTF-8 antes del parser (DEL, imprimible para go-ansiterm)
+const utf8Placeholder = 0x7F
+
+// escState posición dentro de una secuencia de escape, para no codificar las
+// runas de sus parámetros (p.ej. el título OSC "✳ Claude"): sus placeholders
+// nunca llegarían a Print y desplazarían las runas siguientes
+type escState int
+
+const (
+	escGround    escState = iota
+	escEscape             // tras ESC
+	escCSI                // ESC [ ... final
+	escString             // OSC, DCS, SOS, PM, APC hasta BEL o ST
+	escStringEsc          // ESC dentro de una cadena (posible ST)
+)
+
+// next avanza el estado con un byte del stream
+func (e escState) next(b byte) escState {
+	if b == 0x18 || b == 0x1A { // CAN y SUB cancelan la secuencia
+		return escGround
+	}
+	switch e {
+	case escGround:
+		if b == 0x1B {
+			return escEscape
+		}
+	case escEscape:
+		switch {
+		case b == '[':
+			return escCSI
+		case b == ']' || b == 'P' || b == 'X' || b == '^' || b == '_':
+			return escString
+		case b == 0x1B:
+			return escEscape
+		case b >= 0x20 && b <= 0x2F: // intermedios (ESC ( B)
+			return escEscape
+		case b >= 0x30 && b <= 0x7E:
+			return escGround
+		}
+		return escEscape
+	case escCSI:
+		switch {
+		case b == 0x1B:
+			return escEscape
+		case b >= 0x40 && b <= 0x7E:
+			return escGround
+		}
+		return escCSI
+	case escString:
+		switch b {
+		case 0x07:
+			return escGround
+		case 0x1B:
+			return escStringEsc
+		}
+		return escString
+	case escStringEsc:
+		if b == '\\' {
+			return escGround
+		}
+		return escEscape.next(b)
+	}
+	return e
+}
+
 // ScreenState combina el handler con el parser de go-ansiterm
 type ScreenState struct {
 	handler *ScreenHandler
 	parser  *ansiterm.AnsiParser
 	mu      sync.Mutex
+
+	// Bytes de una runa UTF-8 incompleta al final del chunk anterior
+	partial []byte
+
+	// Secuencia de escape abierta al final del chunk anterior
+	esc escState
 }
 
 // NewScreenState crea un nuevo ScreenState
@@ -852,7 +938,49 @@ func (s *ScreenState) Feed(data []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.parser.Parse(data)
+	if len(s.partial) > 0 {
+		data = append(s.partial, data...)
+		s.partial = nil
+	}
+
+	// go-ansiterm descarta los bytes >= 0x80: cada runa UTF-8 impresa se
+	// reemplaza por un placeholder ASCII y la runa se encola para que Print la
+	// recupere. Dentro de secuencias de escape los bytes >= 0x80 se descartan.
+	var runes []rune
+	encoded := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf {
+			s.esc = s.esc.next(b)
+			encoded = append(encoded, b)
+			i++
+			continue
+		}
+		if s.esc != escGround {
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			s.partial = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		runes = append(runes, r)
+		encoded = append(encoded, utf8Placeholder)
+		i += size
+	}
+
+	s.handler.mu.Lock()
+	s.handler.pendingRunes = runes
+	s.handler.mu.Unlock()
+
+	_, err := s.parser.Parse(encoded)
+
+	// Placeholders consumidos dentro de secuencias de escape no llegan a Print
+	s.handler.mu.Lock()
+	s.handler.pendingRunes = nil
+	s.handler.mu.Unlock()
+
 	return err
 }
 
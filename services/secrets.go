@@ -0,0 +1,244 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// SecretKeyEnv variable con la clave maestra en base64 (32 bytes). Sin ella se usa
+// el archivo de SecretKeyFileEnv o <dataDir>/secrets.key, que se genera la primera vez.
+const SecretKeyEnv = "CLAUDE_MONITOR_SECRET_KEY"
+
+// SecretKeyFileEnv variable con la ruta del archivo de la clave maestra, para
+// guardarla fuera del directorio de datos (donde está secrets.json)
+const SecretKeyFileEnv = "CLAUDE_MONITOR_SECRET_KEY_FILE"
+
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// SecretInfo metadatos de un secreto (el valor nunca sale del servidor)
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// storedSecret secreto cifrado con AES-256-GCM
+type storedSecret struct {
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SecretStore almacén local de secretos cifrados, referenciados por nombre
+type SecretStore struct {
+	file string
+	aead cipher.AEAD // nil si no se pudo cargar la clave
+
+	mu      sync.RWMutex
+	secrets map[string]storedSecret
+}
+
+// SecretKeyFile ruta del archivo de la clave maestra
+func SecretKeyFile(dataDir string) string {
+	if file := os.Getenv(SecretKeyFileEnv); file != "" {
+		return file
+	}
+	return filepath.Join(dataDir, "secrets.key")
+}
+
+// NewSecretStore abre secrets.json con la clave de SecretKeyEnv o de SecretKeyFile
+func NewSecretStore(dataDir string) *SecretStore {
+	ss := &SecretStore{
+		file:    filepath.Join(dataDir, "secrets.json"),
+		secrets: make(map[string]storedSecret),
+	}
+
+	if os.Getenv(SecretKeyEnv) == "" && os.Getenv(SecretKeyFileEnv) == "" {
+		// Quien pueda leer el directorio de datos puede descifrar los secretos
+		logger.Warn("La clave de secretos está junto a secrets.json; usa "+SecretKeyEnv+" o "+SecretKeyFileEnv+" para guardarla fuera",
+			"path", SecretKeyFile(dataDir))
+	}
+	key, err := loadSecretKey(SecretKeyFile(dataDir))
+	if err != nil {
+		logger.Error("Almacén de secretos no disponible", "error", err)
+		return ss
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		logger.Error("Almacén de secretos no disponible", "error", err)
+		return ss
+	}
+	ss.aead, _ = cipher.NewGCM(block)
+
+	ss.load()
+	return ss
+}
+
+// loadSecretKey lee la clave maestra o genera una nueva en keyFile
+func loadSecretKey(keyFile string) ([]byte, error) {
+	if encoded := os.Getenv(SecretKeyEnv); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s debe ser una clave de 32 bytes en base64", SecretKeyEnv)
+		}
+		return key, nil
+	}
+
+	if data, err := os.ReadFile(keyFile); err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("clave invalida en %s", keyFile)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := atomicWriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	logger.Info("Clave de secretos generada", "path", keyFile)
+	return key, nil
+}
+
+// load carga los secretos cifrados desde disco
+func (ss *SecretStore) load() {
+	data, err := os.ReadFile(ss.file)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &ss.secrets); err != nil {
+		logger.Error("Error cargando secretos", "error", err)
+	}
+}
+
+// persist guarda los secretos cifrados de forma atómica
+func (ss *SecretStore) persist() error {
+	ss.mu.RLock()
+	data, err := json.MarshalIndent(ss.secrets, "", "  ")
+	ss.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(ss.file, data, 0600)
+}
+
+// available verifica que la clave esté cargada
+func (ss *SecretStore) available() error {
+	if ss == nil || ss.aead == nil {
+		return fmt.Errorf("almacen de secretos no disponible")
+	}
+	return nil
+}
+
+// List retorna los nombres de los secretos ordenados
+func (ss *SecretStore) List() []SecretInfo {
+	ss.mu.RLock()
+	list := make([]SecretInfo, 0, len(ss.secrets))
+	for name, s := range ss.secrets {
+		list = append(list, SecretInfo{Name: name, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt})
+	}
+	ss.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Set cifra y guarda un secreto (crea o reemplaza)
+func (ss *SecretStore) Set(name, value string) (*SecretInfo, error) {
+	if err := ss.available(); err != nil {
+		return nil, err
+	}
+	if !secretNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("nombre de secreto invalido: %s", name)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("value requerido")
+	}
+
+	nonce := make([]byte, ss.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// El nombre va como dato adicional: un valor cifrado no puede moverse a otro nombre
+	ciphertext := ss.aead.Seal(nil, nonce, []byte(value), []byte(name))
+
+	now := time.Now()
+	ss.mu.Lock()
+	stored := storedSecret{
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if prev, ok := ss.secrets[name]; ok {
+		stored.CreatedAt = prev.CreatedAt
+	}
+	ss.secrets[name] = stored
+	ss.mu.Unlock()
+
+	if err := ss.persist(); err != nil {
+		return nil, fmt.Errorf("error guardando secretos: %v", err)
+	}
+	return &SecretInfo{Name: name, CreatedAt: stored.CreatedAt, UpdatedAt: stored.UpdatedAt}, nil
+}
+
+// Delete elimina un secreto
+func (ss *SecretStore) Delete(name string) error {
+	ss.mu.Lock()
+	if _, ok := ss.secrets[name]; !ok {
+		ss.mu.Unlock()
+		return fmt.Errorf("secreto no encontrado: %s", name)
+	}
+	delete(ss.secrets, name)
+	ss.mu.Unlock()
+
+	return ss.persist()
+}
+
+// Resolve descifra un secreto. Solo para inyectarlo en procesos, nunca para la API.
+func (ss *SecretStore) Resolve(name string) (string, error) {
+	if err := ss.available(); err != nil {
+		return "", err
+	}
+
+	ss.mu.RLock()
+	stored, ok := ss.secrets[name]
+	ss.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("secreto no encontrado: %s", name)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(stored.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("secreto corrupto: %s", name)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(stored.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("secreto corrupto: %s", name)
+	}
+	plain, err := ss.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("no se pudo descifrar el secreto %s", name)
+	}
+	return string(plain), nil
+}
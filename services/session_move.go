@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Campos JSON con rutas que se reescriben al mover una sesión. El resto del
+// contenido (mensajes, comandos, salidas) no se modifica.
+var transcriptPathKeys = map[string]bool{
+	"cwd":           true,
+	"file_path":     true,
+	"filePath":      true,
+	"notebook_path": true,
+	"path":          true,
+}
+
+// SessionRootMoveResult resultado de mover un session-root completo
+type SessionRootMoveResult struct {
+	OldProjectPath string            `json:"old_project_path"`
+	NewProjectPath string            `json:"new_project_path"`
+	OldRealPath    string            `json:"old_real_path"`
+	NewRealPath    string            `json:"new_real_path"`
+	Moved          []string          `json:"moved"`
+	Failed         map[string]string `json:"failed,omitempty"` // sessionID -> error
+}
+
+// movePair archivo de origen y destino de un movimiento
+type movePair struct {
+	src string
+	dst string
+}
+
+// MoveSession mueve una sesión (y sus subagentes) a otro proyecto,
+// reescribiendo las rutas internas que están bajo el directorio anterior
+func (s *ClaudeService) MoveSession(oldProjectPath, sessionID, newRealPath string) error {
+	if !filepath.IsAbs(newRealPath) {
+		return os.ErrInvalid
+	}
+	newRealPath = filepath.Clean(newRealPath)
+
+	srcDir := filepath.Join(s.claudeDir, oldProjectPath)
+	filePath := filepath.Join(srcDir, sessionID+".jsonl")
+	if _, err := os.Stat(filePath); err != nil {
+		return err
+	}
+
+	newProjectPath := EncodeProjectPath(newRealPath)
+	if oldProjectPath == newProjectPath {
+		return os.ErrExist
+	}
+
+	oldRealPath := extractCwdFromSession(filePath)
+	if oldRealPath == "" {
+		oldRealPath = DecodeProjectPath(oldProjectPath)
+	}
+
+	agentFiles := s.agentFilesBySession(srcDir)[sessionID]
+	return s.moveSessionFiles(oldProjectPath, newProjectPath, sessionID, oldRealPath, newRealPath, agentFiles)
+}
+
+// MoveSessionRoot mueve todas las sesiones de un session-root cuando el
+// repositorio se reubicó en disco. Cada sesión se mueve de forma atómica;
+// las que fallan quedan en el origen y se reportan en Failed.
+func (s *ClaudeService) MoveSessionRoot(oldProjectPath, newRealPath string) (*SessionRootMoveResult, error) {
+	if !filepath.IsAbs(newRealPath) {
+		return nil, os.ErrInvalid
+	}
+	newRealPath = filepath.Clean(newRealPath)
+
+	newProjectPath := EncodeProjectPath(newRealPath)
+	if oldProjectPath == newProjectPath {
+		return nil, os.ErrExist
+	}
+
+	srcDir := filepath.Join(s.claudeDir, oldProjectPath)
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SessionRootMoveResult{
+		OldProjectPath: oldProjectPath,
+		NewProjectPath: newProjectPath,
+		OldRealPath:    s.GetRealPathFromSessions(oldProjectPath),
+		NewRealPath:    newRealPath,
+		Moved:          make([]string, 0),
+	}
+
+	agentIndex := s.agentFilesBySession(srcDir)
+	for _, entry := range entries {
+		if entry.IsDir() || !isValidUUIDSession(entry.Name()) {
+			continue
+		}
+		sessionID := extractSessionID(entry.Name())
+		err := s.moveSessionFiles(oldProjectPath, newProjectPath, sessionID, result.OldRealPath, newRealPath, agentIndex[sessionID])
+		if err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[sessionID] = err.Error()
+			continue
+		}
+		result.Moved = append(result.Moved, sessionID)
+	}
+
+	// Eliminar el directorio anterior solo si quedó vacío
+	if len(result.Failed) == 0 {
+		os.Remove(srcDir)
+	}
+
+	logger.Info("Session-root movido", "from", oldProjectPath, "to", newProjectPath, "moved", len(result.Moved), "failed", len(result.Failed))
+	return result, nil
+}
+
+// moveSessionFiles mueve el transcript de una sesión y los de sus subagentes.
+// Primero escribe todos los destinos (cada uno atómico); luego aparta los
+// orígenes y solo al final los elimina. Un fallo en cualquier paso deshace
+// lo hecho y deja la sesión como estaba.
+func (s *ClaudeService) moveSessionFiles(oldProjectPath, newProjectPath, sessionID, oldRealPath, newRealPath string, agentFiles []string) error {
+	srcDir := filepath.Join(s.claudeDir, oldProjectPath)
+	dstDir := filepath.Join(s.claudeDir, newProjectPath)
+
+	pairs := []movePair{{
+		src: filepath.Join(srcDir, sessionID+".jsonl"),
+		dst: filepath.Join(dstDir, sessionID+".jsonl"),
+	}}
+	// Los subagentes conservan su ubicación relativa (subagents/ o junto a la sesión)
+	for _, src := range agentFiles {
+		rel, err := filepath.Rel(srcDir, src)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, movePair{src: src, dst: filepath.Join(dstDir, rel)})
+	}
+
+	for _, p := range pairs {
+		if _, err := os.Stat(p.dst); err == nil {
+			return os.ErrExist
+		}
+	}
+
+	// Fase 1: escribir destinos
+	var written []string
+	rollback := func() {
+		for _, dst := range written {
+			os.Remove(dst)
+		}
+		os.Remove(filepath.Join(dstDir, sessionID, "subagents"))
+		os.Remove(filepath.Join(dstDir, sessionID))
+	}
+	for _, p := range pairs {
+		if err := os.MkdirAll(filepath.Dir(p.dst), 0755); err != nil {
+			rollback()
+			return err
+		}
+		if err := rewriteTranscriptFile(p.src, p.dst, oldRealPath, newRealPath); err != nil {
+			rollback()
+			return err
+		}
+		written = append(written, p.dst)
+	}
+
+	// Fase 2: apartar orígenes (reversible)
+	var moved []movePair
+	for _, p := range pairs {
+		backup := p.src + ".moving"
+		if err := os.Rename(p.src, backup); err != nil {
+			for _, m := range moved {
+				os.Rename(m.src+".moving", m.src)
+			}
+			rollback()
+			return err
+		}
+		moved = append(moved, p)
+	}
+
+	// Fase 3: eliminar orígenes
+	for _, p := range moved {
+		os.Remove(p.src + ".moving")
+	}
+	os.Remove(filepath.Join(srcDir, sessionID, "subagents"))
+	os.Remove(filepath.Join(srcDir, sessionID))
+
+	return nil
+}
+
+// rewriteTranscriptFile copia un transcript reescribiendo rutas, mediante un
+// archivo temporal que se renombra al terminar
+func rewriteTranscriptFile(src, dst, oldPath, newPath string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	perm := os.FileMode(0600)
+	if info, err := in.Stat(); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmpPath := dst + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	if err := rewriteTranscriptPaths(in, w, oldPath, newPath); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, dst)
+}
+
+// rewriteTranscriptPaths copia un JSONL línea a línea reescribiendo solo los
+// campos de ruta. Las líneas sin cambios (o que no son JSON) se copian tal cual.
+func rewriteTranscriptPaths(r io.Reader, w io.Writer, oldPath, newPath string) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			body := bytes.TrimSuffix(line, []byte("\n"))
+			if _, werr := w.Write(rewriteTranscriptLine(body, oldPath, newPath)); werr != nil {
+				return werr
+			}
+			if len(body) < len(line) {
+				if _, werr := w.Write([]byte("\n")); werr != nil {
+					return werr
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rewriteTranscriptLine reescribe los campos de ruta de una línea JSON
+func rewriteTranscriptLine(line []byte, oldPath, newPath string) []byte {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber() // conservar enteros grandes sin pasar por float64
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return line
+	}
+
+	rewritten, changed := rewriteJSONPaths(v, oldPath, newPath)
+	if !changed {
+		return line
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rewritten); err != nil {
+		return line
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// rewriteJSONPaths recorre un valor JSON y reescribe los strings de los campos de ruta
+func rewriteJSONPaths(v interface{}, oldPath, newPath string) (interface{}, bool) {
+	changed := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if str, ok := child.(string); ok && transcriptPathKeys[k] {
+				if p, ok := rewritePath(str, oldPath, newPath); ok {
+					val[k] = p
+					changed = true
+				}
+				continue
+			}
+			if c, ok := rewriteJSONPaths(child, oldPath, newPath); ok {
+				val[k] = c
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, child := range val {
+			if c, ok := rewriteJSONPaths(child, oldPath, newPath); ok {
+				val[i] = c
+				changed = true
+			}
+		}
+	}
+	return v, changed
+}
+
+// rewritePath reemplaza oldPath por newPath si p es oldPath o está debajo de él.
+// /home/u/app no coincide con /home/u/app2.
+func rewritePath(p, oldPath, newPath string) (string, bool) {
+	if p == "" || oldPath == "" {
+		return "", false
+	}
+	oldClean := strings.TrimSuffix(oldPath, "/")
+	if p == oldPath || p == oldClean {
+		return newPath, true
+	}
+	if strings.HasPrefix(p, oldClean+"/") {
+		return strings.TrimSuffix(newPath, "/") + p[len(oldClean):], true
+	}
+	return "", false
+}
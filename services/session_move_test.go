@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewriteTranscriptPaths(t *testing.T) {
+	in := strings.Join([]string{
+		`{"type":"user","cwd":"/home/u/app","message":{"content":"mira /home/u/app y /home/u/app2"},"n":12345678901234567890}`,
+		`{"type":"assistant","cwd":"/home/u/app/sub","message":{"content":[{"type":"tool_use","input":{"file_path":"/home/u/app/main.go","command":"cat /home/u/app/x <y>"}}]}}`,
+		`{"type":"user","cwd":"/home/u/app2"}`,
+		`not json /home/u/app`,
+		`{"type":"summary","summary":"sin rutas"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := rewriteTranscriptPaths(strings.NewReader(in), &out, "/home/u/app", "/srv/app"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 lines without trailing newline, got %d", len(lines))
+	}
+
+	// cwd reescrito; el texto del mensaje y los números grandes intactos
+	if !strings.Contains(lines[0], `"cwd":"/srv/app"`) || !strings.Contains(lines[0], `mira /home/u/app y /home/u/app2`) || !strings.Contains(lines[0], `12345678901234567890`) {
+		t.Errorf("Line 0: %s", lines[0])
+	}
+	// Rutas anidadas bajo el directorio; el comando no es un campo de ruta
+	if !strings.Contains(lines[1], `"cwd":"/srv/app/sub"`) || !strings.Contains(lines[1], `"file_path":"/srv/app/main.go"`) || !strings.Contains(lines[1], `cat /home/u/app/x <y>`) {
+		t.Errorf("Line 1: %s", lines[1])
+	}
+	// Prefijo sin separador, no JSON y sin cambios: byte a byte
+	for i := 2; i < 5; i++ {
+		if lines[i] != strings.Split(in, "\n")[i] {
+			t.Errorf("Line %d changed: %s", i, lines[i])
+		}
+	}
+}
+
+func TestMoveSession_WithAgents(t *testing.T) {
+	s := newAgentsFixture(t)
+	dir := s.GetClaudeDir()
+	src := filepath.Join(dir, testProject)
+
+	os.WriteFile(filepath.Join(src, testSessionID+".jsonl"),
+		[]byte(`{"type":"user","cwd":"/home/user/proj","sessionId":"`+testSessionID+`","message":{"content":"hola"}}`+"\n"), 0600)
+
+	if err := s.MoveSession(testProject, testSessionID, "/srv/proj"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "-srv-proj")
+	data, err := os.ReadFile(filepath.Join(dst, testSessionID+".jsonl"))
+	if err != nil || !strings.Contains(string(data), `"cwd":"/srv/proj"`) {
+		t.Errorf("Moved session: %s, %v", data, err)
+	}
+	for _, p := range []string{
+		filepath.Join(dst, testSessionID, "subagents", "agent-a1.jsonl"),
+		filepath.Join(dst, "agent-b2.jsonl"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("Agent not moved: %s", p)
+		}
+	}
+	for _, p := range []string{
+		filepath.Join(src, testSessionID+".jsonl"),
+		filepath.Join(src, testSessionID),
+		filepath.Join(src, "agent-b2.jsonl"),
+	} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Source should be removed: %s", p)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(src, "agent-c3.jsonl")); err != nil {
+		t.Error("Agent of another session should stay")
+	}
+
+	if err := s.MoveSession("-srv-proj", testSessionID, "/srv/proj"); err != os.ErrExist {
+		t.Errorf("Expected ErrExist for same project, got %v", err)
+	}
+	if err := s.MoveSession("-srv-proj", testSessionID, "relativo"); err != os.ErrInvalid {
+		t.Errorf("Expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestMoveSession_Rollback(t *testing.T) {
+	s := newAgentsFixture(t)
+	dir := s.GetClaudeDir()
+	src := filepath.Join(dir, testProject)
+
+	// Un "transcript" que no se puede leer hace fallar la copia a mitad
+	if err := os.Mkdir(filepath.Join(src, testSessionID, "subagents", "agent-broken.jsonl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MoveSession(testProject, testSessionID, "/srv/proj"); err == nil {
+		t.Fatal("Expected move to fail")
+	}
+
+	dst := filepath.Join(dir, "-srv-proj")
+	if _, err := os.Stat(filepath.Join(dst, testSessionID+".jsonl")); !os.IsNotExist(err) {
+		t.Error("Destination should be rolled back")
+	}
+	for _, p := range []string{
+		filepath.Join(src, testSessionID+".jsonl"),
+		filepath.Join(src, testSessionID, "subagents", "agent-a1.jsonl"),
+		filepath.Join(src, "agent-b2.jsonl"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("Source should be intact: %s", p)
+		}
+	}
+}
+
+func TestMoveSessionRoot(t *testing.T) {
+	s := newAgentsFixture(t)
+	dir := s.GetClaudeDir()
+	other := "99999999-2222-3333-4444-555555555555"
+	writeTranscript(t, filepath.Join(dir, testProject, other+".jsonl"),
+		`{"type":"user","cwd":"/home/user/proj/pkg","sessionId":"`+other+`","message":{"content":"otra"}}`)
+	os.Remove(filepath.Join(dir, testProject, testSessionID+".jsonl"))
+	writeTranscript(t, filepath.Join(dir, testProject, testSessionID+".jsonl"),
+		`{"type":"user","cwd":"/home/user/proj","sessionId":"`+testSessionID+`","message":{"content":"hola"}}`)
+
+	result, err := s.MoveSessionRoot(testProject, "/srv/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Moved) != 2 || len(result.Failed) != 0 || result.OldRealPath != "/home/user/proj" {
+		t.Fatalf("Result: %+v", result)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "-srv-proj", other+".jsonl"))
+	if !strings.Contains(string(data), `"cwd":"/srv/proj/pkg"`) {
+		t.Errorf("Subdirectory cwd not rewritten: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "-srv-proj", "agent-c3.jsonl")); err != nil {
+		t.Error("Legacy agent should move with its session")
+	}
+	if _, err := os.Stat(filepath.Join(dir, testProject)); !os.IsNotExist(err) {
+		t.Error("Empty source root should be removed")
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Intervalo por defecto entre escaneos de archivos de sesión
+const DefaultSessionPollInterval = 5 * time.Second
+
+// SessionUpdateData datos del evento session.updated
+type SessionUpdateData struct {
+	ProjectPath string    `json:"project_path"` // session-root codificado
+	SessionID   string    `json:"session_id"`
+	Size        int64     `json:"size"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	Created     bool      `json:"created"` // archivo nuevo desde el último escaneo
+}
+
+// sessionFileStamp tamaño y fecha de un archivo de sesión
+type sessionFileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// SessionFilePoller detecta cambios en los archivos .jsonl de sesiones de Claude
+// comparando tamaño y fecha de modificación, y publica session.updated
+type SessionFilePoller struct {
+	claudeDir string
+	events    *EventBus
+
+	mu    sync.Mutex
+	known map[string]sessionFileStamp // path -> último estado visto
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewSessionFilePoller crea un poller sobre el directorio de proyectos de Claude
+func NewSessionFilePoller(claudeDir string, events *EventBus) *SessionFilePoller {
+	return &SessionFilePoller{
+		claudeDir: claudeDir,
+		events:    events,
+		known:     make(map[string]sessionFileStamp),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Run escanea cada interval hasta Stop. El primer escaneo solo registra el estado inicial.
+func (p *SessionFilePoller) Run(interval time.Duration) {
+	p.scan(false)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.scan(true)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Stop detiene el poller
+func (p *SessionFilePoller) Stop() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+// scan recorre <claudeDir>/<proyecto>/<sesión>.jsonl y publica los cambios
+func (p *SessionFilePoller) scan(publish bool) {
+	projects, err := os.ReadDir(p.claudeDir)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var updates []SessionUpdateData
+
+	p.mu.Lock()
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(p.claudeDir, project.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+
+			path := filepath.Join(dir, f.Name())
+			seen[path] = true
+			stamp := sessionFileStamp{size: info.Size(), modTime: info.ModTime()}
+
+			prev, exists := p.known[path]
+			if exists && prev == stamp {
+				continue
+			}
+			p.known[path] = stamp
+
+			if publish {
+				updates = append(updates, SessionUpdateData{
+					ProjectPath: project.Name(),
+					SessionID:   extractSessionID(f.Name()),
+					Size:        stamp.size,
+					ModifiedAt:  stamp.modTime,
+					Created:     !exists,
+				})
+			}
+		}
+	}
+
+	for path := range p.known {
+		if !seen[path] {
+			delete(p.known, path)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, u := range updates {
+		p.events.Publish(EventSessionUpdated, "", u)
+	}
+}
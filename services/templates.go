@@ -0,0 +1,351 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// TerminalTemplate preset de configuración para crear terminales
+type TerminalTemplate struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description,omitempty"`
+	Type            string   `json:"type,omitempty"` // "claude" o "terminal"
+	Model           string   `json:"model,omitempty"`
+	SystemPrompt    string   `json:"system_prompt,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	PermissionMode  string   `json:"permission_mode,omitempty"`
+	AdditionalDirs  []string `json:"additional_dirs,omitempty"`
+	EnableHooks     bool     `json:"enable_hooks,omitempty"`
+	Worktree        bool     `json:"worktree,omitempty"`
+	WatchFiles      bool     `json:"watch_files,omitempty"`
+
+	// Entorno: variables, archivos .env y secretos (variable -> nombre del secreto)
+	Env      map[string]string `json:"env,omitempty"`
+	EnvFiles []string          `json:"env_files,omitempty"`
+	Secrets  map[string]string `json:"secrets,omitempty"`
+
+	// Límites de recursos (se combinan campo a campo con los de la request)
+	Limits *ResourceLimits `json:"limits,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate valida los campos del template
+func (t *TerminalTemplate) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("name requerido")
+	}
+	switch t.Type {
+	case "", "claude", "terminal":
+	default:
+		return fmt.Errorf("type invalido: %s", t.Type)
+	}
+	switch t.PermissionMode {
+	case "", "default", "acceptEdits", "bypassPermissions", "plan":
+	default:
+		return fmt.Errorf("permission_mode invalido: %s", t.PermissionMode)
+	}
+	for _, dir := range t.AdditionalDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("additional_dirs debe contener paths absolutos: %s", dir)
+		}
+	}
+	for name := range t.Env {
+		if err := ValidateEnvName(name); err != nil {
+			return err
+		}
+	}
+	for name, secret := range t.Secrets {
+		if err := ValidateEnvName(name); err != nil {
+			return err
+		}
+		if !secretNameRegex.MatchString(secret) {
+			return fmt.Errorf("nombre de secreto invalido: %s", secret)
+		}
+	}
+	for _, f := range t.EnvFiles {
+		if filepath.IsAbs(f) || strings.Contains(filepath.Clean(f), "..") {
+			return fmt.Errorf("env_files debe contener paths relativos al directorio de trabajo: %s", f)
+		}
+	}
+	if t.Limits != nil {
+		return t.Limits.Validate()
+	}
+	return nil
+}
+
+// Apply completa cfg con los valores del template. Los campos presentes en cfg
+// tienen prioridad; una lista vacía explícita ([]) reemplaza la del template,
+// env, secrets y limits se combinan campo a campo y los booleanos del template
+// solo pueden activarse.
+func (t *TerminalTemplate) Apply(cfg TerminalConfig) TerminalConfig {
+	if cfg.Type == "" {
+		cfg.Type = t.Type
+	}
+	if cfg.Model == "" {
+		cfg.Model = t.Model
+	}
+	if cfg.SystemPrompt == "" {
+		cfg.SystemPrompt = t.SystemPrompt
+	}
+	if cfg.PermissionMode == "" {
+		cfg.PermissionMode = t.PermissionMode
+	}
+	if cfg.AllowedTools == nil {
+		cfg.AllowedTools = t.AllowedTools
+	}
+	if cfg.DisallowedTools == nil {
+		cfg.DisallowedTools = t.DisallowedTools
+	}
+	if cfg.AdditionalDirs == nil {
+		cfg.AdditionalDirs = t.AdditionalDirs
+	}
+	if cfg.EnvFiles == nil {
+		cfg.EnvFiles = t.EnvFiles
+	}
+	cfg.Env = mergeStringMaps(t.Env, cfg.Env)
+	cfg.Secrets = mergeStringMaps(t.Secrets, cfg.Secrets)
+	if t.Limits != nil {
+		limits := *t.Limits
+		if cfg.Limits != nil {
+			limits = cfg.Limits.WithDefaults(*t.Limits)
+		}
+		cfg.Limits = &limits
+	}
+	cfg.EnableHooks = cfg.EnableHooks || t.EnableHooks
+	cfg.Worktree = cfg.Worktree || t.Worktree
+	cfg.WatchFiles = cfg.WatchFiles || t.WatchFiles
+	return cfg
+}
+
+// mergeStringMaps combina dos mapas; override tiene prioridad (nil si ambos están vacíos)
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return override
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// templatesFile formato persistido en templates.json
+type templatesFile struct {
+	Templates []*TerminalTemplate `json:"templates"`
+	Defaults  map[string]string   `json:"session_root_defaults,omitempty"` // session-root -> template ID
+}
+
+// TemplateService almacena templates de terminal y el default de cada session-root
+type TemplateService struct {
+	file string
+
+	mu        sync.RWMutex
+	templates map[string]*TerminalTemplate
+	defaults  map[string]string
+}
+
+// NewTemplateService crea el servicio y carga templates.json
+func NewTemplateService(dataDir string) *TemplateService {
+	ts := &TemplateService{
+		file:      filepath.Join(dataDir, "templates.json"),
+		templates: make(map[string]*TerminalTemplate),
+		defaults:  make(map[string]string),
+	}
+	ts.load()
+	return ts
+}
+
+// load carga templates desde disco
+func (ts *TemplateService) load() {
+	data, err := os.ReadFile(ts.file)
+	if err != nil {
+		return
+	}
+
+	var stored templatesFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		logger.Error("Error cargando templates", "error", err)
+		return
+	}
+
+	for _, t := range stored.Templates {
+		ts.templates[t.ID] = t
+	}
+	for root, id := range stored.Defaults {
+		if _, ok := ts.templates[id]; ok {
+			ts.defaults[root] = id
+		}
+	}
+}
+
+// persist guarda templates y defaults de forma atómica
+func (ts *TemplateService) persist() {
+	ts.mu.RLock()
+	stored := templatesFile{Defaults: ts.defaults}
+	for _, t := range ts.templates {
+		stored.Templates = append(stored.Templates, t)
+	}
+	sort.Slice(stored.Templates, func(i, j int) bool {
+		return stored.Templates[i].CreatedAt.Before(stored.Templates[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(stored, "", "  ")
+	ts.mu.RUnlock()
+
+	if err != nil {
+		logger.Error("Error serializando templates", "error", err)
+		return
+	}
+	if err := atomicWriteFile(ts.file, data, 0600); err != nil {
+		logger.Error("Error guardando templates", "error", err)
+	}
+}
+
+// List retorna los templates ordenados por nombre
+func (ts *TemplateService) List() []TerminalTemplate {
+	ts.mu.RLock()
+	list := make([]TerminalTemplate, 0, len(ts.templates))
+	for _, t := range ts.templates {
+		list = append(list, *t)
+	}
+	ts.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get retorna un template
+func (ts *TemplateService) Get(id string) (*TerminalTemplate, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	t, ok := ts.templates[id]
+	if !ok {
+		return nil, fmt.Errorf("template no encontrado: %s", id)
+	}
+	snapshot := *t
+	return &snapshot, nil
+}
+
+// Create valida y guarda un nuevo template
+func (ts *TemplateService) Create(t TerminalTemplate) (*TerminalTemplate, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	t.ID = generateUUID()
+	t.CreatedAt = now
+	t.UpdatedAt = now
+
+	stored := t
+	ts.mu.Lock()
+	ts.templates[t.ID] = &stored
+	ts.mu.Unlock()
+	ts.persist()
+
+	return &t, nil
+}
+
+// Update reemplaza un template conservando su ID
+func (ts *TemplateService) Update(id string, t TerminalTemplate) (*TerminalTemplate, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	existing, ok := ts.templates[id]
+	if !ok {
+		ts.mu.Unlock()
+		return nil, fmt.Errorf("template no encontrado: %s", id)
+	}
+	t.ID = id
+	t.CreatedAt = existing.CreatedAt
+	t.UpdatedAt = time.Now()
+	stored := t
+	ts.templates[id] = &stored
+	ts.mu.Unlock()
+	ts.persist()
+
+	return &t, nil
+}
+
+// Delete elimina un template y los defaults de session-root que lo usaban
+func (ts *TemplateService) Delete(id string) error {
+	ts.mu.Lock()
+	if _, ok := ts.templates[id]; !ok {
+		ts.mu.Unlock()
+		return fmt.Errorf("template no encontrado: %s", id)
+	}
+	delete(ts.templates, id)
+	for root, templateID := range ts.defaults {
+		if templateID == id {
+			delete(ts.defaults, root)
+		}
+	}
+	ts.mu.Unlock()
+
+	ts.persist()
+	return nil
+}
+
+// GetSessionRootDefault retorna el template por defecto de un session-root
+func (ts *TemplateService) GetSessionRootDefault(rootPath string) (*TerminalTemplate, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	id, ok := ts.defaults[rootPath]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *ts.templates[id]
+	return &snapshot, true
+}
+
+// SetSessionRootDefault asigna el template por defecto de un session-root
+func (ts *TemplateService) SetSessionRootDefault(rootPath, templateID string) error {
+	ts.mu.Lock()
+	if _, ok := ts.templates[templateID]; !ok {
+		ts.mu.Unlock()
+		return fmt.Errorf("template no encontrado: %s", templateID)
+	}
+	ts.defaults[rootPath] = templateID
+	ts.mu.Unlock()
+
+	ts.persist()
+	return nil
+}
+
+// DeleteSessionRootDefault quita el template por defecto de un session-root
+func (ts *TemplateService) DeleteSessionRootDefault(rootPath string) {
+	ts.mu.Lock()
+	delete(ts.defaults, rootPath)
+	ts.mu.Unlock()
+	ts.persist()
+}
+
+// Resolve retorna el template a aplicar: el indicado explícitamente o el default
+// del session-root del directorio de trabajo (nil si no hay ninguno)
+func (ts *TemplateService) Resolve(templateID, workDir string) (*TerminalTemplate, error) {
+	if templateID != "" {
+		return ts.Get(templateID)
+	}
+	if t, ok := ts.GetSessionRootDefault(EncodeProjectPath(workDir)); ok {
+		return t, nil
+	}
+	return nil, nil
+}
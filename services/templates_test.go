@@ -0,0 +1,85 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerminalTemplate_Apply(t *testing.T) {
+	tmpl := TerminalTemplate{
+		Name:           "review",
+		Model:          "opus",
+		SystemPrompt:   "Eres un revisor",
+		AllowedTools:   []string{"Read", "Grep"},
+		PermissionMode: "plan",
+		AdditionalDirs: []string{"/srv/shared"},
+		EnableHooks:    true,
+	}
+
+	cfg := tmpl.Apply(TerminalConfig{
+		WorkDir:         "/home/user/app",
+		Model:           "sonnet",
+		DisallowedTools: []string{"Bash"},
+		AdditionalDirs:  []string{}, // lista vacía explícita: sin directorios adicionales
+	})
+
+	if cfg.Model != "sonnet" || cfg.SystemPrompt != "Eres un revisor" || cfg.PermissionMode != "plan" || !cfg.EnableHooks {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.AllowedTools, []string{"Read", "Grep"}) || !reflect.DeepEqual(cfg.DisallowedTools, []string{"Bash"}) {
+		t.Errorf("tools = %v / %v", cfg.AllowedTools, cfg.DisallowedTools)
+	}
+	if len(cfg.AdditionalDirs) != 0 {
+		t.Errorf("additional dirs = %v", cfg.AdditionalDirs)
+	}
+}
+
+func TestTemplateService_ResolveAndDefaults(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTemplateService(dir)
+
+	if _, err := ts.Create(TerminalTemplate{Name: "x", PermissionMode: "yolo"}); err == nil {
+		t.Error("invalid permission_mode should be rejected")
+	}
+
+	a, _ := ts.Create(TerminalTemplate{Name: "a", Model: "opus"})
+	b, _ := ts.Create(TerminalTemplate{Name: "b", Model: "haiku"})
+
+	workDir := "/home/user/app"
+	root := EncodeProjectPath(workDir)
+	if err := ts.SetSessionRootDefault(root, a.ID); err != nil {
+		t.Fatalf("SetSessionRootDefault: %v", err)
+	}
+	if err := ts.SetSessionRootDefault(root, "missing"); err == nil {
+		t.Error("unknown template should be rejected")
+	}
+
+	// Sin template_id se usa el default del session-root; el explícito tiene prioridad
+	if got, _ := ts.Resolve("", workDir); got == nil || got.ID != a.ID {
+		t.Errorf("default = %+v", got)
+	}
+	if got, _ := ts.Resolve(b.ID, workDir); got == nil || got.ID != b.ID {
+		t.Errorf("explicit = %+v", got)
+	}
+	if got, _ := ts.Resolve("", "/other"); got != nil {
+		t.Errorf("no default expected, got %+v", got)
+	}
+	if _, err := ts.Resolve("missing", workDir); err == nil {
+		t.Error("unknown template_id should fail")
+	}
+
+	// Persisten entre reinicios
+	reloaded := NewTemplateService(dir)
+	if len(reloaded.List()) != 2 {
+		t.Errorf("reloaded templates = %d", len(reloaded.List()))
+	}
+	if got, ok := reloaded.GetSessionRootDefault(root); !ok || got.ID != a.ID {
+		t.Error("default not persisted")
+	}
+
+	// Eliminar el template quita el default
+	ts.Delete(a.ID)
+	if _, ok := ts.GetSessionRootDefault(root); ok {
+		t.Error("default should be removed with its template")
+	}
+}
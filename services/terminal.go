@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"claude-monitor/pkg/logger"
+	"claude-monitor/pkg/metrics"
 
 	"github.com/gorilla/websocket"
 )
@@ -27,7 +28,19 @@ type TerminalService struct {
 	savedMu             sync.RWMutex
 	sessionsFile        string
 	onTerminalEnd       func(id string)
+	onPermissionPrompt  func(tc *TerminalClaude, tool string)
 	allowedPathPrefixes []string
+	hooks               *hookRegistry
+	events              *EventBus
+	secrets             *SecretStore
+	limits              LimitsConfig
+	cgroups             *cgroupManager // nil si cgroup v2 no está disponible
+	git                 *GitService
+	watch               WatchConfig
+	watches             map[string]*fileWatch // Registro de cambios de archivos por terminal
+	watchesMu           sync.Mutex
+	resources           map[string]*terminalResources
+	resourcesMu         sync.Mutex
 }
 
 // SavedTerminal terminal guardada para persistencia
@@ -43,6 +56,8 @@ type SavedTerminal struct {
 	Status       string              `json:"status"`
 	Config       TerminalConfig      `json:"config"`
 	ClaudeState  *ClaudeStateSnapshot `json:"claude_state,omitempty"` // Estado Claude extendido
+	Worktree     *TerminalWorktree    `json:"worktree,omitempty"`     // Worktree dedicado
+	CreatedBy    string               `json:"created_by,omitempty"`   // Usuario que creó la terminal
 }
 
 // TerminalConfig configuración para crear terminal
@@ -61,6 +76,20 @@ type TerminalConfig struct {
 	Resume          bool     `json:"resume,omitempty"`
 	Continue        bool     `json:"continue,omitempty"`
 	DangerouslySkip bool     `json:"dangerously_skip,omitempty"`
+	EnableHooks     bool     `json:"enable_hooks,omitempty"`    // Generar settings con hooks hacia /api/hooks/{id}
+	SettingsFile    string   `json:"settings_file,omitempty"`   // Archivo pasado a claude con --settings
+	Worktree        bool     `json:"worktree,omitempty"`        // Crear un git worktree y rama dedicados
+	WorktreeBranch  string   `json:"worktree_branch,omitempty"` // Rama del worktree (por defecto claude/<id>)
+	WatchFiles      bool     `json:"watch_files,omitempty"`     // Observar cambios de archivos en work_dir
+	CreatedBy       string   `json:"created_by,omitempty"`      // Usuario que creó la terminal (lo asigna el servidor)
+
+	// Entorno del proceso
+	Env      map[string]string `json:"env,omitempty"`
+	EnvFiles []string          `json:"env_files,omitempty"` // Archivos .env relativos a work_dir
+	Secrets  map[string]string `json:"secrets,omitempty"`   // Variable -> nombre del secreto
+
+	// Límites de recursos (los no indicados se toman de la configuración global)
+	Limits *ResourceLimits `json:"limits,omitempty"`
 }
 
 // TerminalInfo información de terminal para API
@@ -79,6 +108,11 @@ type TerminalInfo struct {
 	CreatedAt    time.Time           `json:"created_at,omitempty"`
 	LastAccessAt time.Time           `json:"last_access_at,omitempty"`
 	ClaudeState  *ClaudeStateSnapshot `json:"claude_state,omitempty"` // Solo para tipo claude
+	Limits       *ResourceLimits      `json:"limits,omitempty"`       // Límites efectivos
+	Usage        *ResourceUsage       `json:"usage,omitempty"`        // Último muestreo de CPU/memoria
+	Git          *GitSummary          `json:"git,omitempty"`          // Estado del repositorio del WorkDir
+	Worktree     *TerminalWorktree    `json:"worktree,omitempty"`     // Worktree dedicado de la terminal
+	CreatedBy    string               `json:"created_by,omitempty"`   // Usuario que creó la terminal
 }
 
 // DirectoryEntry entrada de directorio
@@ -95,8 +129,15 @@ func NewTerminalService(dataDir string, allowedPathPrefixes ...string) *Terminal
 	ts := &TerminalService{
 		terminals:           make(map[string]Terminal),
 		saved:               make(map[string]*SavedTerminal),
+		resources:           make(map[string]*terminalResources),
+		watch:               DefaultWatchConfig(),
+		watches:             make(map[string]*fileWatch),
 		sessionsFile:        sessionsFile,
 		allowedPathPrefixes: allowedPathPrefixes,
+		hooks: &hookRegistry{
+			dir:    filepath.Join(dataDir, "hooks"),
+			tokens: make(map[string]string),
+		},
 	}
 	ts.loadSaved()
 	return ts
@@ -112,6 +153,26 @@ func (s *TerminalService) SetOnTerminalEnd(fn func(id string)) {
 	s.onTerminalEnd = fn
 }
 
+// SetOnPermissionPrompt configura callback cuando Claude solicita un permiso
+func (s *TerminalService) SetOnPermissionPrompt(fn func(tc *TerminalClaude, tool string)) {
+	s.onPermissionPrompt = fn
+}
+
+// SetSecretStore configura el almacén de secretos inyectables en terminales
+func (s *TerminalService) SetSecretStore(store *SecretStore) {
+	s.secrets = store
+}
+
+// SetGitService configura el servicio que reporta el estado git del WorkDir
+func (s *TerminalService) SetGitService(git *GitService) {
+	s.git = git
+}
+
+// SetEventBus configura el bus donde se publican los eventos de las terminales
+func (s *TerminalService) SetEventBus(bus *EventBus) {
+	s.events = bus
+}
+
 // loadSaved carga terminales guardadas
 func (s *TerminalService) loadSaved() {
 	data, err := os.ReadFile(s.sessionsFile)
@@ -277,6 +338,10 @@ func buildClaudeArgs(cfg TerminalConfig) []string {
 		args = append(args, "--dangerously-skip-permissions")
 	}
 
+	if cfg.SettingsFile != "" {
+		args = append(args, "--settings", cfg.SettingsFile)
+	}
+
 	return args
 }
 
@@ -316,6 +381,12 @@ func (s *TerminalService) Create(cfg TerminalConfig) (*TerminalInfo, error) {
 	}
 	s.mu.RUnlock()
 
+	// Worktree dedicado: nuevo o el de una ejecución anterior
+	worktree, newWorktree, err := s.prepareWorktree(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("worktree invalido: %v", err)
+	}
+
 	// Construir comando
 	var cmd *exec.Cmd
 	if cfg.Type == "terminal" {
@@ -328,18 +399,53 @@ func (s *TerminalService) Create(cfg TerminalConfig) (*TerminalInfo, error) {
 			cmd = exec.Command(shell, args...)
 		}
 	} else {
+		if cfg.EnableHooks {
+			settingsFile, err := s.writeHooksSettings(cfg.ID)
+			if err != nil {
+				return nil, fmt.Errorf("error generando settings de hooks: %v", err)
+			}
+			cfg.SettingsFile = settingsFile
+		}
 		args := buildClaudeArgs(cfg)
 		cmd = exec.Command("claude", args...)
 	}
 	cmd.Dir = cfg.WorkDir
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+
+	env, secretValues, err := s.buildTerminalEnv(cfg)
+	if err != nil {
+		s.removeHooksSettings(cfg.ID)
+		if newWorktree {
+			worktree.remove(true)
+			runGit(worktree.RepoRoot, "branch", "-D", worktree.Branch)
+		}
+		return nil, fmt.Errorf("entorno invalido: %v", err)
+	}
+	cmd.Env = env
+
+	// Límites de recursos: el proceso nace dentro de su cgroup si corresponde
+	resources, cgroupFD := s.prepareResources(cfg.ID, cfg.Limits)
+	if cgroupFD >= 0 {
+		setCgroupFD(cmd, cgroupFD)
+	}
 
 	// Iniciar PTY
 	starter := NewPTYStarter()
 	ptyInstance, err := starter.Start(cmd)
+	if cgroupFD >= 0 {
+		closeCgroupFD(cgroupFD)
+	}
 	if err != nil {
+		s.removeHooksSettings(cfg.ID)
+		if resources.cgroup != "" {
+			s.cgroups.remove(resources.cgroup)
+		}
+		if newWorktree {
+			worktree.remove(true)
+			runGit(worktree.RepoRoot, "branch", "-D", worktree.Branch)
+		}
 		return nil, fmt.Errorf("error iniciando PTY: %v", err)
 	}
+	s.startResources(cfg.ID, cmd.Process.Pid, resources)
 
 	// Crear terminal según tipo
 	var terminal Terminal
@@ -369,6 +475,8 @@ func (s *TerminalService) Create(cfg TerminalConfig) (*TerminalInfo, error) {
 	s.terminals[cfg.ID] = terminal
 	s.mu.Unlock()
 
+	s.startFileWatch(terminal, cfg)
+
 	// Guardar
 	s.savedMu.Lock()
 	s.saved[cfg.ID] = &SavedTerminal{
@@ -382,31 +490,55 @@ func (s *TerminalService) Create(cfg TerminalConfig) (*TerminalInfo, error) {
 		LastAccessAt: time.Now(),
 		Status:       "running",
 		Config:       cfg,
+		Worktree:     worktree,
+		CreatedBy:    cfg.CreatedBy,
 	}
 	s.savedMu.Unlock()
 	s.persistSaved()
 
 	// Goroutine para leer output
-	go s.readLoopNew(terminal)
+	go s.readLoopNew(terminal, secretValues)
 
 	// Goroutine para detectar terminación
 	go func() {
 		cmd.Wait()
+		s.finishResources(cfg.ID, cmd.ProcessState)
 		s.cleanupNew(terminal)
 	}()
 
 	logger.Get().Terminal("created", cfg.ID, "name", cfg.Name, "work_dir", cfg.WorkDir, "type", cfg.Type)
 
-	return s.toTerminalInfoNew(terminal, true), nil
+	result := s.toTerminalInfoNew(terminal, true)
+	s.events.Publish(EventTerminalCreated, cfg.ID, *result)
+
+	return result, nil
 }
 
-// readLoopNew lee output del PTY usando interface
-func (s *TerminalService) readLoopNew(t Terminal) {
+// readLoopNew lee output del PTY usando interface. Los valores de secretos
+// inyectados se enmascaran antes de llegar a la pantalla y a los clientes.
+func (s *TerminalService) readLoopNew(t Terminal, secretValues []string) {
 	pty := t.GetPty()
 	if pty == nil {
 		return
 	}
 
+	emit := func(data []byte) {
+		// Alimentar screen según tipo
+		switch term := t.(type) {
+		case *TerminalRaw:
+			term.FeedScreen(data)
+		case *TerminalClaude:
+			term.FeedScreen(data)
+		}
+
+		t.Broadcast(data)
+	}
+
+	masker := newOutputMasker(secretValues, emit)
+	if masker != nil {
+		defer masker.Flush()
+	}
+
 	buf := make([]byte, 4096)
 	for {
 		n, err := pty.Read(buf)
@@ -417,15 +549,11 @@ func (s *TerminalService) readLoopNew(t Terminal) {
 			break
 		}
 
-		// Alimentar screen según tipo
-		switch term := t.(type) {
-		case *TerminalRaw:
-			term.FeedScreen(buf[:n])
-		case *TerminalClaude:
-			term.FeedScreen(buf[:n])
+		if masker != nil {
+			masker.Write(buf[:n])
+		} else {
+			emit(buf[:n])
 		}
-
-		t.Broadcast(buf[:n])
 	}
 }
 
@@ -469,15 +597,23 @@ func (s *TerminalService) setupClaudeCallbacksNew(tc *TerminalClaude) {
 
 	cs.OnStateChange = func(old, new ClaudeState) {
 		logger.Debug("Claude state change", "terminal_id", tc.GetID(), "old", old, "new", new)
-		tc.BroadcastClaudeEvent("state", StateChangeData{
+		metrics.RecordClaudeStateChange(string(old), string(new))
+		metrics.SetClaudeState(tc.GetID(), string(new))
+		data := StateChangeData{
 			OldState: string(old),
 			NewState: string(new),
-		})
+		}
+		tc.BroadcastClaudeEvent("state", data)
+		s.events.Publish(EventClaudeState, tc.GetID(), data)
 	}
 
 	cs.OnPermissionPrompt = func(tool string) {
 		logger.Debug("Claude permission prompt", "terminal_id", tc.GetID(), "tool", tool)
 		tc.BroadcastClaudeEvent("permission", PermissionData{Tool: tool})
+		s.events.Publish(EventClaudePermission, tc.GetID(), PermissionData{Tool: tool})
+		if s.onPermissionPrompt != nil {
+			s.onPermissionPrompt(tc, tool)
+		}
 	}
 
 	cs.OnSlashCommand = func(cmd string, args string) {
@@ -523,11 +659,17 @@ func (s *TerminalService) cleanupNew(t Terminal) {
 	}
 
 	id := t.GetID()
+	s.stopFileWatch(id, false)
 
 	s.mu.Lock()
 	delete(s.terminals, id)
 	s.mu.Unlock()
 
+	s.removeHooksSettings(id)
+	if _, ok := t.(*TerminalClaude); ok {
+		metrics.ClearClaudeState(id)
+	}
+
 	// Actualizar estado guardado
 	s.savedMu.Lock()
 	if saved, ok := s.saved[id]; ok {
@@ -542,9 +684,20 @@ func (s *TerminalService) cleanupNew(t Terminal) {
 	s.savedMu.Unlock()
 	s.persistSaved()
 
+	// Terminal archivada: liberar su worktree si no tiene cambios sin commit
+	if tc, ok := t.(*TerminalClaude); ok && tc.GetIsArchived() {
+		if wt, err := s.savedWorktree(id); err == nil {
+			current := *wt
+			if err := s.releaseWorktree(id, &current, false, false); err != nil {
+				logger.Warn("Worktree de terminal archivada conservado", "terminal_id", id, "path", current.Path, "error", err)
+			}
+		}
+	}
+
 	if s.onTerminalEnd != nil {
 		s.onTerminalEnd(id)
 	}
+	s.events.Publish(EventTerminalEnded, id, nil)
 
 	logger.Get().Terminal("terminated", id)
 }
@@ -556,12 +709,18 @@ func (s *TerminalService) List() []TerminalInfo {
 	// Terminales activas
 	s.mu.RLock()
 	activeIDs := make(map[string]bool)
+	active := make([]Terminal, 0, len(s.terminals))
 	for _, t := range s.terminals {
 		activeIDs[t.GetID()] = true
-		list = append(list, *s.toTerminalInfoNew(t, true))
+		active = append(active, t)
 	}
 	s.mu.RUnlock()
 
+	// Fuera del lock: el estado git puede requerir ejecutar git
+	for _, t := range active {
+		list = append(list, *s.toTerminalInfoNew(t, true))
+	}
+
 	// Terminales guardadas no activas
 	s.savedMu.RLock()
 	for _, t := range s.saved {
@@ -579,6 +738,8 @@ func (s *TerminalService) List() []TerminalInfo {
 				CreatedAt:    t.CreatedAt,
 				LastAccessAt: t.LastAccessAt,
 				ClaudeState:  t.ClaudeState,
+				Worktree:     t.Worktree,
+				CreatedBy:    t.CreatedBy,
 			})
 		}
 	}
@@ -614,6 +775,8 @@ func (s *TerminalService) Get(id string) (*TerminalInfo, error) {
 			CreatedAt:    t.CreatedAt,
 			LastAccessAt: t.LastAccessAt,
 			ClaudeState:  t.ClaudeState,
+			Worktree:     t.Worktree,
+			CreatedBy:    t.CreatedBy,
 		}, nil
 	}
 	s.savedMu.RUnlock()
@@ -631,6 +794,10 @@ func (s *TerminalService) Resume(id string) (*TerminalInfo, error) {
 		return nil, fmt.Errorf("terminal no encontrada: %s", id)
 	}
 
+	if err := s.restoreSavedWorktree(id); err != nil {
+		return nil, fmt.Errorf("worktree invalido: %v", err)
+	}
+
 	cfg := saved.Config
 	cfg.Resume = true
 
@@ -659,6 +826,17 @@ func (s *TerminalService) Delete(id string) error {
 	}
 	s.mu.RUnlock()
 
+	// El worktree se elimina salvo que tenga cambios sin commit; la rama se
+	// conserva si no está integrada
+	if wt, err := s.savedWorktree(id); err == nil {
+		current := *wt
+		if err := s.releaseWorktree(id, &current, false, false); err != nil {
+			return fmt.Errorf("no se puede eliminar la terminal: %w", err)
+		}
+	}
+
+	s.stopFileWatch(id, true)
+
 	s.savedMu.Lock()
 	delete(s.saved, id)
 	s.savedMu.Unlock()
@@ -748,6 +926,24 @@ func (s *TerminalService) Archive(id string) error {
 	return tc.Archive()
 }
 
+// getClaudeTerminal retorna una terminal Claude activa
+func (s *TerminalService) getClaudeTerminal(id string) (*TerminalClaude, error) {
+	s.mu.RLock()
+	terminal, ok := s.terminals[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("terminal no encontrada o no activa: %s", id)
+	}
+
+	tc, ok := terminal.(*TerminalClaude)
+	if !ok {
+		return nil, fmt.Errorf("terminal no es de tipo claude: %s", id)
+	}
+
+	return tc, nil
+}
+
 // GetClaudeState retorna el estado de Claude para una terminal
 func (s *TerminalService) GetClaudeState(id string) (*ClaudeStateInfo, error) {
 	s.mu.RLock()
@@ -951,6 +1147,17 @@ func (s *TerminalService) toTerminalInfoNew(t Terminal, active bool) *TerminalIn
 		info.ClaudeState = tc.GetClaudeStateSnapshot()
 	}
 
+	if active {
+		info.Limits, info.Usage = s.terminalResourceInfo(info.ID)
+		if s.git != nil {
+			info.Git = s.git.Summary(info.WorkDir)
+		}
+	}
+	if wt, err := s.savedWorktree(info.ID); err == nil {
+		info.Worktree = wt
+	}
+	info.CreatedBy = t.GetConfig().CreatedBy
+
 	return info
 }
 
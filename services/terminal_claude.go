@@ -225,10 +225,17 @@ func (t *TerminalClaude) Broadcast(data []byte) {
 	t.clientsMu.RLock()
 	defer t.clientsMu.RUnlock()
 
-	msg := map[string]string{
-		"type": "output",
-		"data": string(data),
+	msg := outputMessage(data)
+
+	for client := range t.clients {
+		client.WriteJSON(msg)
 	}
+}
+
+// BroadcastJSON envía un mensaje JSON a todos los clientes
+func (t *TerminalClaude) BroadcastJSON(msg interface{}) {
+	t.clientsMu.RLock()
+	defer t.clientsMu.RUnlock()
 
 	for client := range t.clients {
 		client.WriteJSON(msg)
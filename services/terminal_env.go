@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+const (
+	// Los secretos más cortos no se enmascaran (se confundirían con texto normal)
+	minMaskedSecretLen = 4
+	// Tiempo máximo que se retiene un posible inicio de secreto al final de un chunk
+	maskFlushDelay = 50 * time.Millisecond
+)
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvName valida el nombre de una variable de entorno
+func ValidateEnvName(name string) error {
+	if !envNameRegex.MatchString(name) {
+		return fmt.Errorf("nombre de variable invalido: %s", name)
+	}
+	return nil
+}
+
+// parseDotEnv interpreta un archivo .env: KEY=VALUE por línea, con prefijo
+// export opcional, comentarios (#), comillas simples literales y comillas
+// dobles con escapes \n, \t, \" y \\
+func parseDotEnv(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("linea %d: se esperaba KEY=VALUE", lineNum)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if err := ValidateEnvName(key); err != nil {
+			return nil, fmt.Errorf("linea %d: %v", lineNum, err)
+		}
+
+		value := strings.TrimSpace(line[eq+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && strings.LastIndex(value, "'") > 0:
+			value = value[1:strings.LastIndex(value, "'")]
+		case len(value) >= 2 && value[0] == '"' && strings.LastIndex(value, `"`) > 0:
+			value = unescapeDotEnv(value[1:strings.LastIndex(value, `"`)])
+		default:
+			// Comentario al final de un valor sin comillas
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		env[key] = value
+	}
+
+	return env, scanner.Err()
+}
+
+// unescapeDotEnv resuelve los escapes de un valor entre comillas dobles
+func unescapeDotEnv(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+	return r.Replace(s)
+}
+
+// loadEnvFiles lee los archivos .env indicados, relativos al directorio de trabajo.
+// Los archivos que no existen se ignoran; los posteriores sobrescriben a los anteriores.
+func loadEnvFiles(workDir string, files []string) (map[string]string, error) {
+	env := make(map[string]string)
+
+	for _, name := range files {
+		if filepath.IsAbs(name) || strings.Contains(filepath.Clean(name), "..") {
+			return nil, fmt.Errorf("env_file debe ser relativo al directorio de trabajo: %s", name)
+		}
+
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				logger.Debug("env_file no encontrado", "work_dir", workDir, "file", name)
+				continue
+			}
+			return nil, err
+		}
+
+		vars, err := parseDotEnv(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+
+	return env, nil
+}
+
+// buildTerminalEnv construye el entorno del proceso: entorno del servidor, TERM,
+// archivos .env, variables de la config y secretos (en ese orden de prioridad
+// creciente). También retorna los valores de los secretos para enmascararlos.
+func (s *TerminalService) buildTerminalEnv(cfg TerminalConfig) ([]string, []string, error) {
+	env := append(os.Environ(), "TERM=xterm-256color")
+
+	fileVars, err := loadEnvFiles(cfg.WorkDir, cfg.EnvFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	env = appendEnvMap(env, fileVars)
+
+	for name := range cfg.Env {
+		if err := ValidateEnvName(name); err != nil {
+			return nil, nil, err
+		}
+	}
+	env = appendEnvMap(env, cfg.Env)
+
+	var secretValues []string
+	if len(cfg.Secrets) > 0 {
+		secretVars := make(map[string]string, len(cfg.Secrets))
+		for name, secret := range cfg.Secrets {
+			if err := ValidateEnvName(name); err != nil {
+				return nil, nil, err
+			}
+			value, err := s.secrets.Resolve(secret)
+			if err != nil {
+				return nil, nil, err
+			}
+			secretVars[name] = value
+			secretValues = append(secretValues, value)
+		}
+		env = appendEnvMap(env, secretVars)
+	}
+
+	// exec.Cmd usa el último valor de cada variable duplicada
+	return env, secretValues, nil
+}
+
+// appendEnvMap agrega variables en orden estable
+func appendEnvMap(env []string, vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
+
+// outputMasker reemplaza valores de secretos en la salida del PTY antes de
+// alimentar la pantalla y los clientes. Un secreto puede quedar partido entre
+// dos lecturas, así que un posible inicio de secreto al final del chunk se
+// retiene hasta la siguiente lectura o hasta maskFlushDelay.
+type outputMasker struct {
+	mu      sync.Mutex
+	secrets [][]byte
+	pending []byte
+	timer   *time.Timer
+	gen     int // invalida flushes programados antes de la última escritura
+	emit    func([]byte)
+}
+
+// newOutputMasker crea un masker; retorna nil si no hay secretos enmascarables
+func newOutputMasker(values []string, emit func([]byte)) *outputMasker {
+	var secrets [][]byte
+	for _, v := range values {
+		if len(v) >= minMaskedSecretLen {
+			secrets = append(secrets, []byte(v))
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	// Los más largos primero, por si un secreto contiene a otro
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return &outputMasker{secrets: secrets, emit: emit}
+}
+
+// Write enmascara y emite un chunk de salida
+func (m *outputMasker) Write(p []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.gen++
+
+	data := append(m.pending, p...)
+	for _, secret := range m.secrets {
+		data = bytes.ReplaceAll(data, secret, []byte(maskedSecret))
+	}
+
+	hold := m.partialSuffix(data)
+	m.pending = append([]byte(nil), data[len(data)-hold:]...)
+	if out := data[:len(data)-hold]; len(out) > 0 {
+		m.emit(out)
+	}
+	if hold > 0 {
+		gen := m.gen
+		m.timer = time.AfterFunc(maskFlushDelay, func() { m.flush(gen) })
+	}
+}
+
+// Flush emite lo retenido
+func (m *outputMasker) Flush() {
+	m.flush(-1)
+}
+
+// flush emite lo retenido si no hubo escrituras desde gen (-1 = siempre)
+func (m *outputMasker) flush(gen int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if gen >= 0 && gen != m.gen {
+		return
+	}
+	if len(m.pending) > 0 {
+		m.emit(m.pending)
+		m.pending = nil
+	}
+}
+
+// partialSuffix longitud del sufijo más largo de data que es prefijo propio de un secreto
+func (m *outputMasker) partialSuffix(data []byte) int {
+	longest := 0
+	for _, secret := range m.secrets {
+		max := len(secret) - 1
+		if max > len(data) {
+			max = len(data)
+		}
+		for n := max; n > longest; n-- {
+			if bytes.Equal(data[len(data)-n:], secret[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
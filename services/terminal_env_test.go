@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSecretStore_RoundTrip(t *testing.T) {
+	t.Setenv(SecretKeyEnv, "")
+	dir := t.TempDir()
+
+	ss := NewSecretStore(dir)
+	if _, err := ss.Set("project-a", "sk-ant-123456"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := ss.Set("bad name", "x"); err == nil {
+		t.Error("nombre invalido aceptado")
+	}
+
+	raw, _ := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	if strings.Contains(string(raw), "sk-ant-123456") {
+		t.Error("secrets.json contiene el valor en claro")
+	}
+
+	// Reabrir con la misma clave (secrets.key generada)
+	reopened := NewSecretStore(dir)
+	if v, err := reopened.Resolve("project-a"); err != nil || v != "sk-ant-123456" {
+		t.Errorf("Resolve = %q, %v", v, err)
+	}
+	if list := reopened.List(); len(list) != 1 || list[0].Name != "project-a" {
+		t.Errorf("List = %+v", list)
+	}
+
+	// Otra clave no puede descifrar
+	t.Setenv(SecretKeyEnv, base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if _, err := NewSecretStore(dir).Resolve("project-a"); err == nil {
+		t.Error("descifrado con clave incorrecta")
+	}
+
+	if err := reopened.Delete("project-a"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if _, err := reopened.Resolve("project-a"); err == nil {
+		t.Error("secreto eliminado sigue disponible")
+	}
+}
+
+func TestSecretStore_KeyFileOutsideDataDir(t *testing.T) {
+	t.Setenv(SecretKeyEnv, "")
+	dir := t.TempDir()
+	keyFile := filepath.Join(t.TempDir(), "monitor.key")
+	t.Setenv(SecretKeyFileEnv, keyFile)
+
+	if _, err := NewSecretStore(dir).Set("project-a", "sk-ant-123456"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Errorf("key file not generated: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secrets.key")); !os.IsNotExist(err) {
+		t.Errorf("secrets.key should not be created in the data dir: %v", err)
+	}
+	if v, err := NewSecretStore(dir).Resolve("project-a"); err != nil || v != "sk-ant-123456" {
+		t.Errorf("Resolve = %q, %v", v, err)
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	data := []byte(`# comentario
+ANTHROPIC_MODEL=claude-sonnet-4-5
+export DEBUG=1 # inline
+QUOTED="linea1\nlinea2"
+LITERAL='sin $escapes\n'
+EMPTY=
+`)
+	env, err := parseDotEnv(data)
+	if err != nil {
+		t.Fatalf("parseDotEnv: %v", err)
+	}
+	want := map[string]string{
+		"ANTHROPIC_MODEL": "claude-sonnet-4-5",
+		"DEBUG":           "1",
+		"QUOTED":          "linea1\nlinea2",
+		"LITERAL":         `sin $escapes\n`,
+		"EMPTY":           "",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %#v", env)
+	}
+
+	if _, err := parseDotEnv([]byte("NO_EQUALS\n")); err == nil {
+		t.Error("linea sin = aceptada")
+	}
+}
+
+func TestBuildTerminalEnv_Precedence(t *testing.T) {
+	t.Setenv(SecretKeyEnv, "")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("A=file\nB=file\nC=file\n"), 0600)
+
+	ss := NewSecretStore(t.TempDir())
+	ss.Set("key-c", "secret-value")
+	s := &TerminalService{secrets: ss}
+
+	env, secrets, err := s.buildTerminalEnv(TerminalConfig{
+		WorkDir:  dir,
+		EnvFiles: []string{".env", ".env.missing"},
+		Env:      map[string]string{"B": "cfg"},
+		Secrets:  map[string]string{"C": "key-c"},
+	})
+	if err != nil {
+		t.Fatalf("buildTerminalEnv: %v", err)
+	}
+
+	// exec.Cmd usa el último valor de cada variable
+	last := make(map[string]string)
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 {
+			last[kv[:i]] = kv[i+1:]
+		}
+	}
+	if last["A"] != "file" || last["B"] != "cfg" || last["C"] != "secret-value" || last["TERM"] != "xterm-256color" {
+		t.Errorf("env = A:%q B:%q C:%q TERM:%q", last["A"], last["B"], last["C"], last["TERM"])
+	}
+	if !reflect.DeepEqual(secrets, []string{"secret-value"}) {
+		t.Errorf("secrets = %v", secrets)
+	}
+
+	if _, _, err := s.buildTerminalEnv(TerminalConfig{WorkDir: dir, Secrets: map[string]string{"X": "missing"}}); err == nil {
+		t.Error("secreto inexistente aceptado")
+	}
+	if _, _, err := s.buildTerminalEnv(TerminalConfig{WorkDir: dir, EnvFiles: []string{"../.env"}}); err == nil {
+		t.Error("env_file fuera del work_dir aceptado")
+	}
+}
+
+func TestOutputMasker(t *testing.T) {
+	var mu sync.Mutex
+	var out strings.Builder
+	m := newOutputMasker([]string{"sk-ant-SECRET", "ab"}, func(p []byte) {
+		mu.Lock()
+		out.Write(p)
+		mu.Unlock()
+	})
+
+	// Secreto partido entre dos lecturas
+	m.Write([]byte("key=sk-ant-SE"))
+	m.Write([]byte("CRET done\r\n"))
+	// Prefijo de secreto al final que nunca se completa: se emite tras maskFlushDelay
+	m.Write([]byte("prompt sk-"))
+	time.Sleep(3 * maskFlushDelay)
+
+	mu.Lock()
+	got := out.String()
+	mu.Unlock()
+	if want := "key=" + maskedSecret + " done\r\nprompt sk-"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	if newOutputMasker([]string{"ab"}, func([]byte) {}) != nil {
+		t.Error("masker creado sin secretos enmascarables")
+	}
+}
+
+func TestTerminalTemplate_ApplyEnv(t *testing.T) {
+	tmpl := TerminalTemplate{
+		Name:     "project-a",
+		Env:      map[string]string{"ANTHROPIC_MODEL": "opus", "LANG": "es_ES.UTF-8"},
+		EnvFiles: []string{".env"},
+		Secrets:  map[string]string{"ANTHROPIC_API_KEY": "project-a"},
+	}
+
+	cfg := tmpl.Apply(TerminalConfig{Env: map[string]string{"ANTHROPIC_MODEL": "sonnet"}})
+
+	want := map[string]string{"ANTHROPIC_MODEL": "sonnet", "LANG": "es_ES.UTF-8"}
+	if !reflect.DeepEqual(cfg.Env, want) {
+		t.Errorf("env = %v", cfg.Env)
+	}
+	if !reflect.DeepEqual(cfg.EnvFiles, []string{".env"}) || cfg.Secrets["ANTHROPIC_API_KEY"] != "project-a" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	tmpl.EnvFiles = []string{"/etc/environment"}
+	if err := tmpl.Validate(); err == nil {
+		t.Error("env_file absoluto aceptado")
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// namedKeys secuencias de bytes para teclas con nombre (xterm)
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"return":    "\r",
+	"tab":       "\t",
+	"shift-tab": "\x1b[Z",
+	"esc":       "\x1b",
+	"escape":    "\x1b",
+	"backspace": "\x7f",
+	"space":     " ",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+	"delete":    "\x1b[3~",
+}
+
+// Valores por defecto para la espera de estado. La espera ocurre dentro de la
+// petición HTTP, así que el máximo queda por debajo del WriteTimeout del servidor (30s)
+const (
+	defaultInputWaitTimeout = 25 * time.Second
+	maxInputWaitTimeout     = 25 * time.Second
+	inputWaitPollInterval   = 100 * time.Millisecond
+)
+
+// TerminalInput entrada a enviar a una terminal via API REST
+type TerminalInput struct {
+	Text        string        `json:"text,omitempty"`
+	Keys        []string      `json:"keys,omitempty"`
+	WaitFor     ClaudeState   `json:"wait_for,omitempty"`
+	WaitTimeout time.Duration `json:"-"`
+}
+
+// TerminalInputResult resultado de enviar entrada a una terminal
+type TerminalInputResult struct {
+	BytesWritten int              `json:"bytes_written"`
+	WaitedFor    ClaudeState      `json:"waited_for,omitempty"`
+	Matched      bool             `json:"matched"`
+	TimedOut     bool             `json:"timed_out"`
+	Elapsed      string           `json:"elapsed"`
+	ClaudeState  *ClaudeStateInfo `json:"claude_state,omitempty"`
+}
+
+// EncodeKey convierte el nombre de una tecla a su secuencia de bytes.
+// Acepta nombres como "Enter", "Esc", "Shift-Tab", "Up" y combinaciones
+// "Ctrl-<letra>" (ej: "Ctrl-C"). No distingue mayúsculas.
+func EncodeKey(name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, "_", "-")
+	key = strings.ReplaceAll(key, "+", "-")
+
+	if seq, ok := namedKeys[key]; ok {
+		return seq, nil
+	}
+
+	for _, prefix := range []string{"ctrl-", "c-"} {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if len(rest) == 1 && rest[0] >= 'a' && rest[0] <= 'z' {
+			return string(rune(rest[0] - 'a' + 1)), nil
+		}
+		switch rest {
+		case "[":
+			return "\x1b", nil
+		case "\\":
+			return "\x1c", nil
+		case "]":
+			return "\x1d", nil
+		case "space", "@":
+			return "\x00", nil
+		}
+	}
+
+	return "", fmt.Errorf("tecla desconocida: %s", name)
+}
+
+// EncodeInput construye los bytes a escribir: primero el texto y luego las teclas en orden
+func EncodeInput(text string, keys []string) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(text)
+
+	for _, k := range keys {
+		seq, err := EncodeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(seq)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// SendInput escribe texto y teclas en una terminal y opcionalmente espera un estado de Claude.
+// La espera solo se satisface por un cambio de estado posterior al envío, de forma que
+// esperar "waiting_input" no retorne inmediatamente si Claude ya estaba esperando.
+// La espera termina también al cancelarse ctx (el cliente se desconectó).
+func (s *TerminalService) SendInput(ctx context.Context, id string, input TerminalInput) (*TerminalInputResult, error) {
+	s.mu.RLock()
+	terminal, ok := s.terminals[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("terminal no encontrada: %s", id)
+	}
+
+	data, err := EncodeInput(input.Text, input.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("entrada vacia: se requiere text o keys")
+	}
+
+	tc, isClaude := terminal.(*TerminalClaude)
+	if input.WaitFor != "" && !isClaude {
+		return nil, fmt.Errorf("wait_for solo disponible para terminales claude: %s", id)
+	}
+
+	start := time.Now()
+	n, err := terminal.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("error escribiendo en terminal: %v", err)
+	}
+
+	result := &TerminalInputResult{
+		BytesWritten: n,
+		WaitedFor:    input.WaitFor,
+	}
+
+	if input.WaitFor != "" {
+		timeout := input.WaitTimeout
+		if timeout <= 0 {
+			timeout = defaultInputWaitTimeout
+		}
+		if timeout > maxInputWaitTimeout {
+			timeout = maxInputWaitTimeout
+		}
+		result.Matched = waitForClaudeState(ctx, tc, input.WaitFor, start, timeout)
+		if err := ctx.Err(); err != nil && !result.Matched {
+			return nil, err
+		}
+		result.TimedOut = !result.Matched
+	}
+
+	if isClaude {
+		result.ClaudeState = tc.GetClaudeState()
+	}
+	result.Elapsed = time.Since(start).Round(time.Millisecond).String()
+
+	logger.Debug("Input enviado a terminal",
+		"terminal_id", id,
+		"bytes", n,
+		"keys", len(input.Keys),
+		"wait_for", input.WaitFor,
+		"matched", result.Matched,
+	)
+
+	return result, nil
+}
+
+// SendKeys envía teclas con nombre a una terminal
+func (s *TerminalService) SendKeys(id string, keys ...string) error {
+	_, err := s.SendInput(context.Background(), id, TerminalInput{Keys: keys})
+	return err
+}
+
+// waitForClaudeState espera hasta que Claude entre en el estado dado después de
+// since. Retorna false al vencer timeout o cancelarse ctx.
+func waitForClaudeState(ctx context.Context, tc *TerminalClaude, want ClaudeState, since time.Time, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(inputWaitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if state := tc.GetClaudeState(); state != nil {
+			if state.State == want && !state.StateChangedAt.Before(since) {
+				return true
+			}
+		}
+		if !tc.IsActive() {
+			return false
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEncodeKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"enter", "Enter", "\r"},
+		{"esc", "Esc", "\x1b"},
+		{"escape upper", "ESCAPE", "\x1b"},
+		{"shift tab", "Shift-Tab", "\x1b[Z"},
+		{"shift tab underscore", "shift_tab", "\x1b[Z"},
+		{"up", "up", "\x1b[A"},
+		{"ctrl c", "Ctrl-C", "\x03"},
+		{"ctrl plus d", "ctrl+d", "\x04"},
+		{"c-z", "C-z", "\x1a"},
+		{"ctrl bracket", "Ctrl-[", "\x1b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EncodeKey(tc.key)
+			if err != nil {
+				t.Fatalf("EncodeKey(%q) error: %v", tc.key, err)
+			}
+			if got != tc.want {
+				t.Errorf("EncodeKey(%q): got %q, want %q", tc.key, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeKey_Unknown(t *testing.T) {
+	for _, key := range []string{"", "F13", "ctrl-1", "ctrl-ab"} {
+		if _, err := EncodeKey(key); err == nil {
+			t.Errorf("EncodeKey(%q) should fail", key)
+		}
+	}
+}
+
+func TestEncodeInput(t *testing.T) {
+	data, err := EncodeInput("hola", []string{"Enter", "Esc"})
+	if err != nil {
+		t.Fatalf("EncodeInput error: %v", err)
+	}
+	if string(data) != "hola\r\x1b" {
+		t.Errorf("EncodeInput: got %q", string(data))
+	}
+
+	if _, err := EncodeInput("hola", []string{"Enter", "nope"}); err == nil {
+		t.Error("EncodeInput should fail with unknown key")
+	}
+}
+
+func TestTerminalService_SendInput_NotFound(t *testing.T) {
+	s := NewTerminalService(t.TempDir())
+
+	if _, err := s.SendInput(context.Background(), "missing", TerminalInput{Text: "x"}); err == nil {
+		t.Error("SendInput should fail for missing terminal")
+	}
+}
+
+func TestWaitForClaudeState_ContextCancelled(t *testing.T) {
+	tc := &TerminalClaude{status: "running", state: TerminalStateActive}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if waitForClaudeState(ctx, tc, StateGenerating, start, maxInputWaitTimeout) {
+		t.Fatal("Expected no match")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("wait kept running %s after the context was cancelled", elapsed)
+	}
+}
@@ -48,6 +48,7 @@ type Terminal interface {
 	RemoveClient(conn *websocket.Conn)
 	GetClientCount() int
 	Broadcast(data []byte)
+	BroadcastJSON(msg interface{})
 
 	// PTY
 	GetPty() PTY
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"encoding/base64"
 	"os/exec"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
@@ -172,14 +174,35 @@ func (t *TerminalRaw) GetClientCount() int {
 	return len(t.clients)
 }
 
+// outputMessage mensaje "output" del WebSocket. Un chunk de la PTY puede
+// cortar una runa UTF-8 y en un string JSON esos bytes se perderían: si no es
+// UTF-8 válido se envían también los bytes exactos en "raw" (base64).
+func outputMessage(data []byte) map[string]string {
+	msg := map[string]string{
+		"type": "output",
+		"data": string(data),
+	}
+	if !utf8.Valid(data) {
+		msg["raw"] = base64.StdEncoding.EncodeToString(data)
+	}
+	return msg
+}
+
 func (t *TerminalRaw) Broadcast(data []byte) {
 	t.clientsMu.RLock()
 	defer t.clientsMu.RUnlock()
 
-	msg := map[string]string{
-		"type": "output",
-		"data": string(data),
+	msg := outputMessage(data)
+
+	for client := range t.clients {
+		client.WriteJSON(msg)
 	}
+}
+
+// BroadcastJSON envía un mensaje JSON a todos los clientes
+func (t *TerminalRaw) BroadcastJSON(msg interface{}) {
+	t.clientsMu.RLock()
+	defer t.clientsMu.RUnlock()
 
 	for client := range t.clients {
 		client.WriteJSON(msg)
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/auth"
+	"claude-monitor/pkg/logger"
+)
+
+// Errores del almacén de usuarios
+var (
+	ErrUserNotFound = errors.New("usuario no encontrado")
+	ErrUserExists   = errors.New("el usuario ya existe")
+	ErrLastAdmin    = errors.New("debe quedar al menos un admin activo")
+)
+
+// Tiempo durante el que una contraseña verificada no se vuelve a derivar
+// (PBKDF2 es deliberadamente lento y Basic Auth la envía en cada petición)
+const verifiedPasswordTTL = 10 * time.Minute
+
+var usernameRegex = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+// User usuario de la API (sin la contraseña)
+type User struct {
+	Username  string    `json:"username"`
+	Role      auth.Role `json:"role"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserUpdate cambios de un usuario (nil = sin cambios)
+type UserUpdate struct {
+	Password *string    `json:"password,omitempty"`
+	Role     *auth.Role `json:"role,omitempty"`
+	Disabled *bool      `json:"disabled,omitempty"`
+}
+
+// storedUser usuario con el hash de su contraseña
+type storedUser struct {
+	User
+	PasswordHash string `json:"password_hash"`
+}
+
+// verifiedPassword contraseña verificada recientemente
+type verifiedPassword struct {
+	digest  [32]byte
+	expires time.Time
+}
+
+// UserStore usuarios con contraseña hasheada y rol, persistidos en users.json
+type UserStore struct {
+	file string
+
+	mu       sync.RWMutex
+	users    map[string]*storedUser
+	verified map[string]verifiedPassword
+}
+
+// NewUserStore abre <dataDir>/users.json
+func NewUserStore(dataDir string) *UserStore {
+	us := &UserStore{
+		file:     filepath.Join(dataDir, "users.json"),
+		users:    make(map[string]*storedUser),
+		verified: make(map[string]verifiedPassword),
+	}
+	us.load()
+	return us
+}
+
+// load carga los usuarios desde disco
+func (us *UserStore) load() {
+	data, err := os.ReadFile(us.file)
+	if err != nil {
+		return
+	}
+	var users []*storedUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		logger.Error("Error cargando usuarios", "error", err)
+		return
+	}
+	for _, u := range users {
+		us.users[u.Username] = u
+	}
+}
+
+// persist guarda los usuarios de forma atómica
+func (us *UserStore) persist() error {
+	us.mu.RLock()
+	users := make([]*storedUser, 0, len(us.users))
+	for _, u := range us.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	data, err := json.MarshalIndent(users, "", "  ")
+	us.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(us.file, data, 0600)
+}
+
+// Count número de usuarios
+func (us *UserStore) Count() int {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	return len(us.users)
+}
+
+// List retorna los usuarios ordenados por nombre
+func (us *UserStore) List() []User {
+	us.mu.RLock()
+	list := make([]User, 0, len(us.users))
+	for _, u := range us.users {
+		list = append(list, u.User)
+	}
+	us.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// Get retorna un usuario
+func (us *UserStore) Get(username string) (*User, error) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	u, ok := us.users[username]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	user := u.User
+	return &user, nil
+}
+
+// Create crea un usuario
+func (us *UserStore) Create(username, password string, role auth.Role) (*User, error) {
+	if !usernameRegex.MatchString(username) {
+		return nil, fmt.Errorf("nombre de usuario invalido: %s", username)
+	}
+	if !auth.ValidRole(role) {
+		return nil, fmt.Errorf("rol invalido: %s", role)
+	}
+	if password == "" {
+		return nil, fmt.Errorf("password requerido")
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	us.mu.Lock()
+	if _, ok := us.users[username]; ok {
+		us.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, username)
+	}
+	u := &storedUser{
+		User:         User{Username: username, Role: role, CreatedAt: now, UpdatedAt: now},
+		PasswordHash: hash,
+	}
+	us.users[username] = u
+	us.mu.Unlock()
+
+	if err := us.persist(); err != nil {
+		return nil, fmt.Errorf("error guardando usuarios: %v", err)
+	}
+	user := u.User
+	return &user, nil
+}
+
+// Update cambia la contraseña, el rol o el estado de un usuario
+func (us *UserStore) Update(username string, update UserUpdate) (*User, error) {
+	if update.Role != nil && !auth.ValidRole(*update.Role) {
+		return nil, fmt.Errorf("rol invalido: %s", *update.Role)
+	}
+	var hash string
+	if update.Password != nil {
+		if *update.Password == "" {
+			return nil, fmt.Errorf("password requerido")
+		}
+		h, err := auth.HashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		hash = h
+	}
+
+	us.mu.Lock()
+	u, ok := us.users[username]
+	if !ok {
+		us.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	next := *u
+	if update.Role != nil {
+		next.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		next.Disabled = *update.Disabled
+	}
+	if hash != "" {
+		next.PasswordHash = hash
+	}
+	if us.isLastAdmin(u) && (next.Role != auth.RoleAdmin || next.Disabled) {
+		us.mu.Unlock()
+		return nil, ErrLastAdmin
+	}
+	next.UpdatedAt = time.Now()
+	us.users[username] = &next
+	delete(us.verified, username)
+	us.mu.Unlock()
+
+	if err := us.persist(); err != nil {
+		return nil, fmt.Errorf("error guardando usuarios: %v", err)
+	}
+	user := next.User
+	return &user, nil
+}
+
+// Delete elimina un usuario
+func (us *UserStore) Delete(username string) error {
+	us.mu.Lock()
+	u, ok := us.users[username]
+	if !ok {
+		us.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if us.isLastAdmin(u) {
+		us.mu.Unlock()
+		return ErrLastAdmin
+	}
+	delete(us.users, username)
+	delete(us.verified, username)
+	us.mu.Unlock()
+
+	return us.persist()
+}
+
+// isLastAdmin indica si u es el único admin activo (con us.mu tomado)
+func (us *UserStore) isLastAdmin(u *storedUser) bool {
+	if u.Role != auth.RoleAdmin || u.Disabled {
+		return false
+	}
+	for _, other := range us.users {
+		if other != u && other.Role == auth.RoleAdmin && !other.Disabled {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticate verifica usuario y contraseña. Retorna nil si no son válidos
+// o el usuario está deshabilitado.
+func (us *UserStore) Authenticate(username, password string) *User {
+	us.mu.RLock()
+	u, ok := us.users[username]
+	cached, hit := us.verified[username]
+	us.mu.RUnlock()
+	if !ok || u.Disabled {
+		return nil
+	}
+
+	// El hash almacenado forma parte del digest: cambiar la contraseña lo invalida
+	digest := sha256.Sum256([]byte(u.PasswordHash + "\x00" + password))
+	if hit && time.Now().Before(cached.expires) && subtle.ConstantTimeCompare(digest[:], cached.digest[:]) == 1 {
+		user := u.User
+		return &user
+	}
+
+	if !auth.VerifyPassword(u.PasswordHash, password) {
+		return nil
+	}
+
+	us.mu.Lock()
+	us.verified[username] = verifiedPassword{digest: digest, expires: time.Now().Add(verifiedPasswordTTL)}
+	us.mu.Unlock()
+
+	user := u.User
+	return &user
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"claude-monitor/pkg/auth"
+)
+
+// fastPasswordHashing reduce las iteraciones de PBKDF2 durante el test
+func fastPasswordHashing(t *testing.T) {
+	previous := auth.PasswordIterations
+	auth.PasswordIterations = 1000
+	t.Cleanup(func() { auth.PasswordIterations = previous })
+}
+
+func newUsersFixture(t *testing.T) (*UserStore, string) {
+	t.Helper()
+	fastPasswordHashing(t)
+	dir := t.TempDir()
+	return NewUserStore(dir), dir
+}
+
+func TestUserStore_Authenticate(t *testing.T) {
+	us, dir := newUsersFixture(t)
+
+	if _, err := us.Create("ana", "clave", auth.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.Create("ana", "otra", auth.RoleAdmin); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if _, err := us.Create("con espacio", "x", auth.RoleViewer); err == nil {
+		t.Error("Expected invalid username error")
+	}
+	if _, err := us.Create("bob", "x", "root"); err == nil {
+		t.Error("Expected invalid role error")
+	}
+
+	if u := us.Authenticate("ana", "clave"); u == nil || u.Role != auth.RoleViewer {
+		t.Fatalf("Authenticate: %+v", u)
+	}
+	// Segunda vez desde el cache
+	if us.Authenticate("ana", "clave") == nil || us.Authenticate("ana", "mala") != nil {
+		t.Error("Cached authentication")
+	}
+
+	// Cambiar la contraseña invalida el cache
+	newPass := "nueva"
+	if _, err := us.Update("ana", UserUpdate{Password: &newPass}); err != nil {
+		t.Fatal(err)
+	}
+	if us.Authenticate("ana", "clave") != nil || us.Authenticate("ana", "nueva") == nil {
+		t.Error("Expected password change to apply")
+	}
+
+	disabled := true
+	us.Update("ana", UserUpdate{Disabled: &disabled})
+	if us.Authenticate("ana", "nueva") != nil {
+		t.Error("Expected disabled user to fail")
+	}
+
+	// Persistencia
+	reloaded := NewUserStore(dir)
+	if reloaded.Count() != 1 {
+		t.Fatalf("Expected 1 user after reload, got %d", reloaded.Count())
+	}
+	if u, err := reloaded.Get("ana"); err != nil || !u.Disabled {
+		t.Errorf("Reloaded user: %+v, %v", u, err)
+	}
+}
+
+func TestUserStore_LastAdmin(t *testing.T) {
+	us, _ := newUsersFixture(t)
+	us.Create("root", "x", auth.RoleAdmin)
+
+	viewer := auth.RoleViewer
+	if _, err := us.Update("root", UserUpdate{Role: &viewer}); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin on demotion, got %v", err)
+	}
+	if err := us.Delete("root"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin on delete, got %v", err)
+	}
+
+	us.Create("root2", "x", auth.RoleAdmin)
+	if err := us.Delete("root"); err != nil {
+		t.Errorf("Expected delete with another admin, got %v", err)
+	}
+	if err := us.Delete("root"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if list := us.List(); len(list) != 1 || list[0].Username != "root2" {
+		t.Errorf("List: %+v", list)
+	}
+}
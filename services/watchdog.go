@@ -0,0 +1,314 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// WatchdogConfig umbrales del watchdog de terminales Claude (0 = deshabilitado)
+type WatchdogConfig struct {
+	Enabled               bool     `json:"enabled"`
+	IntervalSeconds       int      `json:"interval_seconds"`
+	GeneratingTimeoutSec  int      `json:"generating_timeout_seconds"`   // atascado generando
+	ToolRunningTimeoutSec int      `json:"tool_running_timeout_seconds"` // atascado ejecutando herramienta
+	PermissionIdleSec     int      `json:"permission_idle_seconds"`      // prompt de permiso sin responder
+	WaitingInputIdleSec   int      `json:"waiting_input_idle_seconds"`   // esperando input sin actividad
+	AutoInterrupt         bool     `json:"auto_interrupt"`               // interrumpir terminales atascadas
+	InterruptKeys         []string `json:"interrupt_keys,omitempty"`     // teclas enviadas al interrumpir
+}
+
+// DefaultWatchdogConfig configuración por defecto
+func DefaultWatchdogConfig() WatchdogConfig {
+	return WatchdogConfig{
+		Enabled:               true,
+		IntervalSeconds:       30,
+		GeneratingTimeoutSec:  15 * 60,
+		ToolRunningTimeoutSec: 30 * 60,
+		PermissionIdleSec:     10 * 60,
+		WaitingInputIdleSec:   0,
+		AutoInterrupt:         false,
+		InterruptKeys:         []string{"Esc"},
+	}
+}
+
+// WatchdogAlertKind tipo de alerta
+type WatchdogAlertKind string
+
+const (
+	WatchdogStuck WatchdogAlertKind = "stuck" // generating/tool_running demasiado tiempo
+	WatchdogIdle  WatchdogAlertKind = "idle"  // permission_prompt/waiting_input sin actividad
+)
+
+// WatchdogAlert alerta activa de una terminal
+type WatchdogAlert struct {
+	TerminalID    string            `json:"terminal_id"`
+	TerminalName  string            `json:"terminal_name"`
+	Kind          WatchdogAlertKind `json:"kind"`
+	State         ClaudeState       `json:"state"`
+	PendingTool   string            `json:"pending_tool,omitempty"`
+	Since         time.Time         `json:"since"` // inicio del periodo medido
+	DurationSec   int64             `json:"duration_seconds"`
+	ThresholdSec  int               `json:"threshold_seconds"`
+	DetectedAt    time.Time         `json:"detected_at"`
+	Interrupted   bool              `json:"interrupted"`
+	InterruptedAt *time.Time        `json:"interrupted_at,omitempty"`
+	InterruptErr  string            `json:"interrupt_error,omitempty"`
+}
+
+// WatchdogStatus estado del watchdog para la API
+type WatchdogStatus struct {
+	Config    WatchdogConfig  `json:"config"`
+	LastCheck *time.Time      `json:"last_check,omitempty"`
+	Alerts    []WatchdogAlert `json:"alerts"`
+}
+
+// evaluateWatchdog decide si un estado Claude supera algún umbral.
+// Los estados activos se miden desde el cambio de estado; los de espera desde
+// la última actividad, para no alertar mientras el usuario escribe.
+func evaluateWatchdog(cfg WatchdogConfig, info *ClaudeStateInfo, now time.Time) (WatchdogAlertKind, time.Time, int) {
+	var (
+		kind      WatchdogAlertKind
+		since     = info.StateChangedAt
+		threshold int
+	)
+
+	switch info.State {
+	case StateGenerating:
+		kind, threshold = WatchdogStuck, cfg.GeneratingTimeoutSec
+	case StateToolRunning:
+		kind, threshold = WatchdogStuck, cfg.ToolRunningTimeoutSec
+	case StatePermissionPrompt:
+		kind, threshold = WatchdogIdle, cfg.PermissionIdleSec
+	case StateWaitingInput:
+		kind, threshold = WatchdogIdle, cfg.WaitingInputIdleSec
+	default:
+		return "", time.Time{}, 0
+	}
+
+	if kind == WatchdogIdle && info.LastActivity.After(since) {
+		since = info.LastActivity
+	}
+	if threshold <= 0 || since.IsZero() || now.Sub(since) < time.Duration(threshold)*time.Second {
+		return "", time.Time{}, 0
+	}
+	return kind, since, threshold
+}
+
+// Watchdog vigila terminales Claude atascadas u ociosas
+type Watchdog struct {
+	terminals *TerminalService
+	events    *EventBus
+
+	mu        sync.RWMutex
+	config    WatchdogConfig
+	alerts    map[string]*WatchdogAlert // terminal ID -> alerta
+	lastCheck time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewWatchdog crea un nuevo watchdog
+func NewWatchdog(cfg WatchdogConfig, terminals *TerminalService, events *EventBus) *Watchdog {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = DefaultWatchdogConfig().IntervalSeconds
+	}
+	if len(cfg.InterruptKeys) == 0 {
+		cfg.InterruptKeys = DefaultWatchdogConfig().InterruptKeys
+	}
+
+	return &Watchdog{
+		terminals: terminals,
+		events:    events,
+		config:    cfg,
+		alerts:    make(map[string]*WatchdogAlert),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Run ejecuta el watchdog hasta Stop (no hace nada si está deshabilitado)
+func (w *Watchdog) Run() {
+	if !w.config.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(w.config.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Check(time.Now())
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Stop detiene el watchdog
+func (w *Watchdog) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// Check revisa todas las terminales Claude activas
+func (w *Watchdog) Check(now time.Time) {
+	cfg := w.Config()
+	seen := make(map[string]bool)
+
+	for _, tc := range w.terminals.activeClaudeTerminals() {
+		info := tc.GetClaudeState()
+		if info == nil {
+			continue
+		}
+
+		id := tc.GetID()
+		seen[id] = true
+
+		kind, since, threshold := evaluateWatchdog(cfg, info, now)
+		if kind == "" {
+			w.resolve(id)
+			continue
+		}
+
+		w.mu.Lock()
+		alert, exists := w.alerts[id]
+		if exists && (alert.Kind != kind || alert.State != info.State || !alert.Since.Equal(since)) {
+			exists = false
+		}
+		if !exists {
+			alert = &WatchdogAlert{
+				TerminalID:   id,
+				TerminalName: tc.GetName(),
+				Kind:         kind,
+				State:        info.State,
+				PendingTool:  info.PendingTool,
+				Since:        since,
+				ThresholdSec: threshold,
+				DetectedAt:   now,
+			}
+			w.alerts[id] = alert
+		}
+		alert.DurationSec = int64(now.Sub(since).Seconds())
+		interrupt := kind == WatchdogStuck && cfg.AutoInterrupt && !alert.Interrupted
+		snapshot := *alert
+		w.mu.Unlock()
+
+		if !exists {
+			logger.Warn("Watchdog: terminal Claude "+string(kind),
+				"terminal_id", id,
+				"state", info.State,
+				"duration_seconds", snapshot.DurationSec,
+			)
+			eventType := EventWatchdogStuck
+			if kind == WatchdogIdle {
+				eventType = EventWatchdogIdle
+			}
+			w.events.Publish(eventType, id, snapshot)
+		}
+
+		if interrupt {
+			w.interrupt(id, cfg.InterruptKeys, now)
+		}
+	}
+
+	w.mu.Lock()
+	w.lastCheck = now
+	var gone []string
+	for id := range w.alerts {
+		if !seen[id] {
+			gone = append(gone, id)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, id := range gone {
+		w.resolve(id)
+	}
+}
+
+// interrupt envía las teclas de interrupción a una terminal atascada
+func (w *Watchdog) interrupt(id string, keys []string, now time.Time) {
+	err := w.terminals.SendKeys(id, keys...)
+
+	w.mu.Lock()
+	alert, ok := w.alerts[id]
+	if ok {
+		alert.Interrupted = true
+		alert.InterruptedAt = &now
+		if err != nil {
+			alert.InterruptErr = err.Error()
+		}
+	}
+	var snapshot WatchdogAlert
+	if ok {
+		snapshot = *alert
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Watchdog: error interrumpiendo terminal", "terminal_id", id, "error", err)
+	} else {
+		logger.Info("Watchdog: terminal interrumpida", "terminal_id", id, "keys", keys)
+	}
+	if ok {
+		w.events.Publish(EventWatchdogInterrupt, id, snapshot)
+	}
+}
+
+// resolve elimina la alerta de una terminal que volvió a la normalidad
+func (w *Watchdog) resolve(id string) {
+	w.mu.Lock()
+	alert, ok := w.alerts[id]
+	delete(w.alerts, id)
+	w.mu.Unlock()
+
+	if ok {
+		w.events.Publish(EventWatchdogResolved, id, *alert)
+	}
+}
+
+// Config retorna la configuración actual
+func (w *Watchdog) Config() WatchdogConfig {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config
+}
+
+// Status retorna la configuración y las alertas activas
+func (w *Watchdog) Status() WatchdogStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	status := WatchdogStatus{
+		Config: w.config,
+		Alerts: make([]WatchdogAlert, 0, len(w.alerts)),
+	}
+	if !w.lastCheck.IsZero() {
+		last := w.lastCheck
+		status.LastCheck = &last
+	}
+	for _, a := range w.alerts {
+		status.Alerts = append(status.Alerts, *a)
+	}
+	sort.Slice(status.Alerts, func(i, j int) bool {
+		return status.Alerts[i].Since.Before(status.Alerts[j].Since)
+	})
+	return status
+}
+
+// activeClaudeTerminals retorna las terminales Claude activas
+func (s *TerminalService) activeClaudeTerminals() []*TerminalClaude {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*TerminalClaude
+	for _, t := range s.terminals {
+		if tc, ok := t.(*TerminalClaude); ok && tc.IsActive() {
+			list = append(list, tc)
+		}
+	}
+	return list
+}
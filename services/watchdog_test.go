@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateWatchdog(t *testing.T) {
+	cfg := WatchdogConfig{
+		GeneratingTimeoutSec:  600,
+		ToolRunningTimeoutSec: 1200,
+		PermissionIdleSec:     300,
+		WaitingInputIdleSec:   0,
+	}
+	now := time.Unix(100000, 0)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	testCases := []struct {
+		name     string
+		info     ClaudeStateInfo
+		wantKind WatchdogAlertKind
+	}{
+		{"generating below threshold", ClaudeStateInfo{State: StateGenerating, StateChangedAt: ago(5 * time.Minute)}, ""},
+		{"generating stuck", ClaudeStateInfo{State: StateGenerating, StateChangedAt: ago(11 * time.Minute), LastActivity: now}, WatchdogStuck},
+		{"tool running stuck", ClaudeStateInfo{State: StateToolRunning, StateChangedAt: ago(21 * time.Minute)}, WatchdogStuck},
+		{"permission idle", ClaudeStateInfo{State: StatePermissionPrompt, StateChangedAt: ago(6 * time.Minute), LastActivity: ago(6 * time.Minute)}, WatchdogIdle},
+		{"permission recent activity", ClaudeStateInfo{State: StatePermissionPrompt, StateChangedAt: ago(6 * time.Minute), LastActivity: ago(time.Minute)}, ""},
+		{"waiting input disabled", ClaudeStateInfo{State: StateWaitingInput, StateChangedAt: ago(24 * time.Hour)}, ""},
+		{"exited ignored", ClaudeStateInfo{State: StateExited, StateChangedAt: ago(24 * time.Hour)}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kind, _, _ := evaluateWatchdog(cfg, &tc.info, now)
+			if kind != tc.wantKind {
+				t.Errorf("kind = %q, want %q", kind, tc.wantKind)
+			}
+		})
+	}
+}
+
+func TestEventBus_SubscribeFilter(t *testing.T) {
+	bus := NewEventBus()
+
+	all, cancelAll := bus.Subscribe(EventFilter{})
+	defer cancelAll()
+	stuck, cancelStuck := bus.Subscribe(EventFilter{Types: []EventType{EventWatchdogStuck}, TerminalID: "t1"})
+
+	bus.Publish(EventClaudeState, "t1", StateChangeData{OldState: "unknown", NewState: "generating"})
+	bus.Publish(EventWatchdogStuck, "t2", nil)
+	bus.Publish(EventWatchdogStuck, "t1", nil)
+
+	if got := len(all); got != 3 {
+		t.Errorf("unfiltered subscriber received %d events, want 3", got)
+	}
+	if got := len(stuck); got != 1 {
+		t.Fatalf("filtered subscriber received %d events, want 1", got)
+	}
+	if e := <-stuck; e.Type != EventWatchdogStuck || e.TerminalID != "t1" || e.ID == "" {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	cancelStuck()
+	cancelStuck()
+	if _, ok := <-stuck; ok {
+		t.Error("channel should be closed after cancel")
+	}
+	if n := bus.SubscriberCount(); n != 1 {
+		t.Errorf("SubscriberCount = %d, want 1", n)
+	}
+
+	var nilBus *EventBus
+	nilBus.Publish(EventTerminalEnded, "t1", nil)
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Reintentos de entrega: backoff exponencial desde webhookBaseBackoff hasta webhookMaxBackoff
+const (
+	webhookMaxAttempts    = 6
+	webhookBaseBackoff    = 2 * time.Second
+	webhookMaxBackoff     = 5 * time.Minute
+	webhookTimeout        = 10 * time.Second
+	maxWebhookDeliveries  = 1000             // entregas conservadas en memoria
+	maxWebhookLogSize     = 10 * 1024 * 1024 // tamaño del log antes de rotar
+	webhookDeliveryHeader = "X-Claude-Monitor-Delivery"
+)
+
+// WebhookDeliveryStatus estado de una entrega
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookSubscription suscripción a eventos del monitor
+type WebhookSubscription struct {
+	ID          string      `json:"id"`
+	URL         string      `json:"url"`
+	Events      []EventType `json:"events,omitempty"`      // vacío = todos
+	TerminalID  string      `json:"terminal_id,omitempty"` // vacío = todas las terminales
+	Secret      string      `json:"secret,omitempty"`      // clave HMAC-SHA256
+	Description string      `json:"description,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// WebhookDelivery entrega de un evento a una suscripción
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      EventType             `json:"event_type"`
+	URL            string                `json:"url"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	RedeliveryOf   string                `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	Payload        json.RawMessage       `json:"payload"`
+}
+
+// WebhookDeliveryFilter filtros para listar entregas
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         WebhookDeliveryStatus
+	Limit          int
+}
+
+// Validate valida la suscripción
+func (s *WebhookSubscription) Validate() error {
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		return fmt.Errorf("url http(s) requerida")
+	}
+	for _, e := range s.Events {
+		if !IsKnownEventType(e) {
+			return fmt.Errorf("evento desconocido: %s", e)
+		}
+	}
+	return nil
+}
+
+// matches verifica si un evento corresponde a la suscripción
+func (s *WebhookSubscription) matches(e Event) bool {
+	filter := EventFilter{Types: s.Events, TerminalID: s.TerminalID}
+	return filter.matches(e)
+}
+
+// masked retorna una copia con el secreto oculto
+func (s WebhookSubscription) masked() WebhookSubscription {
+	if s.Secret != "" {
+		s.Secret = maskedSecret
+	}
+	return s
+}
+
+// webhookBackoff espera antes del intento siguiente a attempt
+func webhookBackoff(attempt int) time.Duration {
+	d := webhookBaseBackoff << uint(attempt-1)
+	if d <= 0 || d > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return d
+}
+
+// WebhookService entrega eventos del bus a las suscripciones registradas
+type WebhookService struct {
+	events  *EventBus
+	file    string
+	logPath string
+	client  *http.Client
+
+	mu            sync.RWMutex
+	subscriptions map[string]*WebhookSubscription
+	deliveries    map[string]*WebhookDelivery
+	order         []string // IDs de entregas por fecha de creación
+
+	logMu   sync.Mutex
+	cancel  func()
+	backoff func(attempt int) time.Duration
+}
+
+// NewWebhookService crea el servicio, carga suscripciones y log, y se suscribe al bus
+func NewWebhookService(dataDir string, events *EventBus) *WebhookService {
+	ws := &WebhookService{
+		events:        events,
+		file:          filepath.Join(dataDir, "webhooks.json"),
+		logPath:       filepath.Join(dataDir, "webhook_deliveries.jsonl"),
+		client:        &http.Client{Timeout: webhookTimeout},
+		subscriptions: make(map[string]*WebhookSubscription),
+		deliveries:    make(map[string]*WebhookDelivery),
+		backoff:       webhookBackoff,
+	}
+	ws.load()
+	ws.loadDeliveries()
+
+	ch, cancel := events.Subscribe(EventFilter{})
+	ws.cancel = cancel
+	go ws.run(ch)
+
+	return ws
+}
+
+// Stop cancela la suscripción al bus
+func (ws *WebhookService) Stop() {
+	ws.cancel()
+}
+
+// load carga suscripciones desde disco
+func (ws *WebhookService) load() {
+	data, err := os.ReadFile(ws.file)
+	if err != nil {
+		return
+	}
+
+	var subs []*WebhookSubscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		logger.Error("Error cargando webhooks", "error", err)
+		return
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, s := range subs {
+		if s.ID != "" && s.Validate() == nil {
+			ws.subscriptions[s.ID] = s
+		}
+	}
+}
+
+// persist guarda suscripciones de forma atómica (contiene secretos: 0600)
+func (ws *WebhookService) persist() {
+	ws.mu.RLock()
+	subs := make([]*WebhookSubscription, 0, len(ws.subscriptions))
+	for _, s := range ws.subscriptions {
+		subs = append(subs, s)
+	}
+	ws.mu.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		logger.Error("Error serializando webhooks", "error", err)
+		return
+	}
+	if err := atomicWriteFile(ws.file, data, 0600); err != nil {
+		logger.Error("Error guardando webhooks", "error", err)
+	}
+}
+
+// loadDeliveries reconstruye las entregas desde el log (la última línea de cada ID gana)
+// y reanuda las que quedaron pendientes
+func (ws *WebhookService) loadDeliveries() {
+	f, err := os.Open(ws.logPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	ws.mu.Lock()
+	for scanner.Scan() {
+		var d WebhookDelivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil || d.ID == "" {
+			continue
+		}
+		if _, ok := ws.deliveries[d.ID]; !ok {
+			ws.order = append(ws.order, d.ID)
+		}
+		ws.deliveries[d.ID] = &d
+	}
+	ws.trimLocked()
+
+	var pending []string
+	for _, id := range ws.order {
+		if ws.deliveries[id].Status == DeliveryPending {
+			pending = append(pending, id)
+		}
+	}
+	ws.mu.Unlock()
+
+	for _, id := range pending {
+		ws.schedule(id, 0)
+	}
+}
+
+// appendLog agrega el estado actual de una entrega al log
+func (ws *WebhookService) appendLog(d WebhookDelivery) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+
+	ws.logMu.Lock()
+	defer ws.logMu.Unlock()
+
+	if info, err := os.Stat(ws.logPath); err == nil && info.Size() >= maxWebhookLogSize {
+		if err := os.Rename(ws.logPath, ws.logPath+".1"); err != nil {
+			logger.Warn("Error rotando log de webhooks", "error", err)
+		}
+	}
+
+	f, err := os.OpenFile(ws.logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Error("Error abriendo log de webhooks", "error", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		logger.Error("Error escribiendo log de webhooks", "error", err)
+	}
+}
+
+// trimLocked descarta de memoria las entregas terminadas más antiguas
+func (ws *WebhookService) trimLocked() {
+	for len(ws.order) > maxWebhookDeliveries {
+		id := ws.order[0]
+		if d := ws.deliveries[id]; d != nil && d.Status == DeliveryPending {
+			break
+		}
+		delete(ws.deliveries, id)
+		ws.order = ws.order[1:]
+	}
+}
+
+// Create registra una suscripción
+func (ws *WebhookService) Create(sub WebhookSubscription) (*WebhookSubscription, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+	sub.ID = generateUUID()
+	sub.CreatedAt = time.Now()
+
+	ws.mu.Lock()
+	ws.subscriptions[sub.ID] = &sub
+	ws.mu.Unlock()
+	ws.persist()
+
+	masked := sub.masked()
+	return &masked, nil
+}
+
+// List retorna las suscripciones (secretos ocultos) por fecha de creación
+func (ws *WebhookService) List() []WebhookSubscription {
+	ws.mu.RLock()
+	list := make([]WebhookSubscription, 0, len(ws.subscriptions))
+	for _, s := range ws.subscriptions {
+		list = append(list, s.masked())
+	}
+	ws.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Get retorna una suscripción (secreto oculto)
+func (ws *WebhookService) Get(id string) (*WebhookSubscription, error) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	s, ok := ws.subscriptions[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook no encontrado: %s", id)
+	}
+	masked := s.masked()
+	return &masked, nil
+}
+
+// Delete elimina una suscripción. Las entregas pendientes dejan de reintentarse.
+func (ws *WebhookService) Delete(id string) error {
+	ws.mu.Lock()
+	if _, ok := ws.subscriptions[id]; !ok {
+		ws.mu.Unlock()
+		return fmt.Errorf("webhook no encontrado: %s", id)
+	}
+	delete(ws.subscriptions, id)
+	ws.mu.Unlock()
+
+	ws.persist()
+	return nil
+}
+
+// Deliveries lista entregas, de la más reciente a la más antigua
+func (ws *WebhookService) Deliveries(filter WebhookDeliveryFilter) []WebhookDelivery {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	list := make([]WebhookDelivery, 0)
+	for i := len(ws.order) - 1; i >= 0; i-- {
+		d := ws.deliveries[ws.order[i]]
+		if filter.SubscriptionID != "" && d.SubscriptionID != filter.SubscriptionID {
+			continue
+		}
+		if filter.Status != "" && d.Status != filter.Status {
+			continue
+		}
+		list = append(list, *d)
+		if filter.Limit > 0 && len(list) >= filter.Limit {
+			break
+		}
+	}
+	return list
+}
+
+// GetDelivery retorna una entrega
+func (ws *WebhookService) GetDelivery(id string) (*WebhookDelivery, error) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	d, ok := ws.deliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("entrega no encontrada: %s", id)
+	}
+	copy := *d
+	return &copy, nil
+}
+
+// Redeliver crea una nueva entrega con el mismo payload que una anterior
+func (ws *WebhookService) Redeliver(deliveryID string) (*WebhookDelivery, error) {
+	ws.mu.RLock()
+	orig, ok := ws.deliveries[deliveryID]
+	var sub *WebhookSubscription
+	if ok {
+		sub = ws.subscriptions[orig.SubscriptionID]
+	}
+	ws.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("entrega no encontrada: %s", deliveryID)
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("webhook no encontrado: %s", orig.SubscriptionID)
+	}
+
+	return ws.enqueue(sub, orig.EventID, orig.EventType, orig.Payload, deliveryID), nil
+}
+
+// run crea entregas para cada evento del bus
+func (ws *WebhookService) run(events <-chan Event) {
+	for e := range events {
+		ws.mu.RLock()
+		var targets []*WebhookSubscription
+		for _, s := range ws.subscriptions {
+			if s.matches(e) {
+				targets = append(targets, s)
+			}
+		}
+		ws.mu.RUnlock()
+
+		if len(targets) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(e)
+		if err != nil {
+			logger.Error("Error serializando evento para webhook", "type", e.Type, "error", err)
+			continue
+		}
+		for _, s := range targets {
+			ws.enqueue(s, e.ID, e.Type, payload, "")
+		}
+	}
+}
+
+// enqueue registra una entrega pendiente, programa el primer intento y retorna una copia
+func (ws *WebhookService) enqueue(sub *WebhookSubscription, eventID string, eventType EventType, payload json.RawMessage, redeliveryOf string) *WebhookDelivery {
+	now := time.Now()
+	d := &WebhookDelivery{
+		ID:             generateUUID(),
+		SubscriptionID: sub.ID,
+		EventID:        eventID,
+		EventType:      eventType,
+		URL:            sub.URL,
+		Status:         DeliveryPending,
+		RedeliveryOf:   redeliveryOf,
+		CreatedAt:      now,
+		NextAttemptAt:  &now,
+		Payload:        payload,
+	}
+
+	ws.mu.Lock()
+	ws.deliveries[d.ID] = d
+	ws.order = append(ws.order, d.ID)
+	ws.trimLocked()
+	snapshot := *d
+	ws.mu.Unlock()
+
+	ws.appendLog(snapshot)
+	ws.schedule(d.ID, 0)
+	return &snapshot
+}
+
+// schedule programa un intento de entrega
+func (ws *WebhookService) schedule(id string, delay time.Duration) {
+	time.AfterFunc(delay, func() { ws.attempt(id) })
+}
+
+// attempt realiza un intento de entrega y programa el siguiente si falla
+func (ws *WebhookService) attempt(id string) {
+	ws.mu.Lock()
+	d, ok := ws.deliveries[id]
+	if !ok || d.Status != DeliveryPending {
+		ws.mu.Unlock()
+		return
+	}
+	sub, ok := ws.subscriptions[d.SubscriptionID]
+	if !ok {
+		d.Status = DeliveryFailed
+		d.Error = "webhook eliminado"
+		d.NextAttemptAt = nil
+		snapshot := *d
+		ws.mu.Unlock()
+		ws.appendLog(snapshot)
+		return
+	}
+	url, secret, payload, eventType := sub.URL, sub.Secret, d.Payload, d.EventType
+	ws.mu.Unlock()
+
+	status, err := ws.post(url, secret, id, eventType, payload)
+
+	now := time.Now()
+	ws.mu.Lock()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.ResponseStatus = status
+	d.URL = url
+	var retry time.Duration
+	switch {
+	case err == nil:
+		d.Status = DeliverySucceeded
+		d.Error = ""
+		d.NextAttemptAt = nil
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = DeliveryFailed
+		d.Error = err.Error()
+		d.NextAttemptAt = nil
+	default:
+		d.Error = err.Error()
+		retry = ws.backoff(d.Attempts)
+		next := now.Add(retry)
+		d.NextAttemptAt = &next
+	}
+	snapshot := *d
+	ws.mu.Unlock()
+
+	ws.appendLog(snapshot)
+
+	if snapshot.Status == DeliveryPending {
+		logger.Debug("Entrega de webhook fallida, reintentando",
+			"delivery_id", id, "attempt", snapshot.Attempts, "retry_in", retry.String(), "error", err)
+		ws.schedule(id, retry)
+	} else if snapshot.Status == DeliveryFailed {
+		logger.Warn("Entrega de webhook fallida", "delivery_id", id, "url", url, "attempts", snapshot.Attempts, "error", err)
+	}
+}
+
+// post envía el payload firmado y retorna el status HTTP
+func (ws *WebhookService) post(url, secret, deliveryID string, eventType EventType, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-monitor")
+	req.Header.Set(EventHeader, string(eventType))
+	req.Header.Set(webhookDeliveryHeader, deliveryID)
+	if secret != "" {
+		req.Header.Set(SignatureHeader, SignPayload(secret, payload))
+	}
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("respuesta %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForDelivery espera a que una entrega deje de estar pendiente
+func waitForDelivery(t *testing.T, ws *WebhookService, subID string) WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		list := ws.Deliveries(WebhookDeliveryFilter{SubscriptionID: subID})
+		if len(list) > 0 && list[0].Status != DeliveryPending {
+			return list[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("delivery did not complete")
+	return WebhookDelivery{}
+}
+
+func TestWebhookService_RetryAndRedeliver(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != SignPayload("k", body) {
+			t.Errorf("invalid signature")
+		}
+		if r.Header.Get(EventHeader) != string(EventClaudeState) {
+			t.Errorf("event header = %q", r.Header.Get(EventHeader))
+		}
+		// Los dos primeros intentos fallan
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	bus := NewEventBus()
+	ws := NewWebhookService(dir, bus)
+	ws.backoff = func(int) time.Duration { return 10 * time.Millisecond }
+	defer ws.Stop()
+
+	sub, err := ws.Create(WebhookSubscription{URL: server.URL, Events: []EventType{EventClaudeState}, Secret: "k"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if sub.Secret != maskedSecret {
+		t.Errorf("secret not masked: %q", sub.Secret)
+	}
+
+	bus.Publish(EventTerminalCreated, "t1", nil) // filtrado
+	bus.Publish(EventClaudeState, "t1", StateChangeData{OldState: "generating", NewState: "waiting_input"})
+
+	d := waitForDelivery(t, ws, sub.ID)
+	if d.Status != DeliverySucceeded || d.Attempts != 3 || d.ResponseStatus != http.StatusNoContent {
+		t.Errorf("delivery = %+v", d)
+	}
+	if n := len(ws.Deliveries(WebhookDeliveryFilter{})); n != 1 {
+		t.Errorf("deliveries = %d, want 1", n)
+	}
+
+	re, err := ws.Redeliver(d.ID)
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if re.RedeliveryOf != d.ID || string(re.Payload) != string(d.Payload) {
+		t.Errorf("redelivery = %+v", re)
+	}
+	if d2 := waitForDelivery(t, ws, sub.ID); d2.ID != re.ID || d2.Status != DeliverySucceeded {
+		t.Errorf("redelivery result = %+v", d2)
+	}
+
+	// El log persiste entre reinicios
+	reloaded := NewWebhookService(dir, NewEventBus())
+	defer reloaded.Stop()
+	if n := len(reloaded.Deliveries(WebhookDeliveryFilter{SubscriptionID: sub.ID})); n != 2 {
+		t.Errorf("reloaded deliveries = %d, want 2", n)
+	}
+	if len(reloaded.List()) != 1 {
+		t.Error("subscription not persisted")
+	}
+}
+
+func TestWebhookService_GivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	bus := NewEventBus()
+	ws := NewWebhookService(t.TempDir(), bus)
+	ws.backoff = func(int) time.Duration { return time.Millisecond }
+	defer ws.Stop()
+
+	sub, _ := ws.Create(WebhookSubscription{URL: server.URL})
+	bus.Publish(EventTerminalEnded, "t1", nil)
+
+	d := waitForDelivery(t, ws, sub.ID)
+	if d.Status != DeliveryFailed || d.Attempts != webhookMaxAttempts || d.Error == "" {
+		t.Errorf("delivery = %+v", d)
+	}
+}
+
+func TestWebhookSubscription_Validate(t *testing.T) {
+	invalid := []WebhookSubscription{
+		{URL: "ftp://example.com"},
+		{URL: "https://example.com", Events: []EventType{"terminal.exploded"}},
+	}
+	for i, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("case %d: Validate should fail", i)
+		}
+	}
+
+	if got := webhookBackoff(1); got != webhookBaseBackoff {
+		t.Errorf("backoff(1) = %v", got)
+	}
+	if got := webhookBackoff(30); got != webhookMaxBackoff {
+		t.Errorf("backoff(30) = %v", got)
+	}
+}
+
+func TestSessionFilePoller(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "-home-user-app")
+	os.MkdirAll(project, 0755)
+	existing := filepath.Join(project, "11111111-1111-1111-1111-111111111111.jsonl")
+	os.WriteFile(existing, []byte("{}\n"), 0644)
+
+	bus := NewEventBus()
+	events, cancel := bus.Subscribe(EventFilter{Types: []EventType{EventSessionUpdated}})
+	defer cancel()
+
+	p := NewSessionFilePoller(dir, bus)
+	p.scan(false)
+	if len(events) != 0 {
+		t.Fatal("initial scan should not publish")
+	}
+
+	os.WriteFile(existing, []byte("{}\n{}\n"), 0644)
+	os.WriteFile(filepath.Join(project, "22222222-2222-2222-2222-222222222222.jsonl"), []byte("{}\n"), 0644)
+	p.scan(true)
+
+	got := map[string]bool{}
+	for len(events) > 0 {
+		e := <-events
+		data := e.Data.(SessionUpdateData)
+		if data.ProjectPath != "-home-user-app" {
+			t.Errorf("project = %s", data.ProjectPath)
+		}
+		got[data.SessionID] = data.Created
+	}
+	if len(got) != 2 || got["11111111-1111-1111-1111-111111111111"] || !got["22222222-2222-2222-2222-222222222222"] {
+		t.Errorf("updates = %v", got)
+	}
+
+	p.scan(true)
+	if len(events) != 0 {
+		t.Error("unchanged files should not publish")
+	}
+}
@@ -0,0 +1,555 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"claude-monitor/pkg/logger"
+)
+
+// Errores de worktrees que los handlers traducen a códigos HTTP
+var (
+	ErrNoWorktree       = errors.New("la terminal no tiene worktree")
+	ErrWorktreeDirty    = errors.New("el worktree tiene cambios sin commit")
+	ErrWorktreeActive   = errors.New("la terminal esta activa")
+	ErrMergeConflict    = errors.New("conflicto al integrar la rama")
+	ErrMergeTargetDirty = errors.New("el directorio de la rama destino tiene cambios sin commit")
+)
+
+// Directorio de los worktrees dentro del repositorio (excluido vía info/exclude)
+const worktreesDir = ".claude/worktrees"
+
+// TerminalWorktree worktree y rama dedicados de una terminal
+type TerminalWorktree struct {
+	RepoRoot   string    `json:"repo_root"`
+	Path       string    `json:"path"`     // raíz del worktree
+	WorkDir    string    `json:"work_dir"` // directorio de la terminal dentro del worktree
+	Branch     string    `json:"branch"`
+	BaseBranch string    `json:"base_branch,omitempty"` // rama del repositorio al crear el worktree
+	BaseCommit string    `json:"base_commit"`
+	CreatedAt  time.Time `json:"created_at"`
+	Removed    bool      `json:"removed,omitempty"` // directorio eliminado; la rama se conserva
+}
+
+// WorktreeStatus worktree de una terminal con su estado actual
+type WorktreeStatus struct {
+	TerminalID string `json:"terminal_id"`
+	Active     bool   `json:"active"`
+	TerminalWorktree
+	BranchExists bool `json:"branch_exists"`
+	Dirty        bool `json:"dirty"`
+	ChangedFiles int  `json:"changed_files"`
+	Ahead        int  `json:"ahead"` // commits de la rama que no están en base_branch
+	Merged       bool `json:"merged"`
+}
+
+// WorktreeMergeOptions opciones para integrar la rama de un worktree
+type WorktreeMergeOptions struct {
+	Into    string `json:"into,omitempty"`    // rama destino (por defecto base_branch)
+	Squash  bool   `json:"squash,omitempty"`  // un único commit en la rama destino
+	Message string `json:"message,omitempty"` // mensaje del commit de merge/squash
+}
+
+// WorktreeMergeResult resultado de integrar la rama de un worktree
+type WorktreeMergeResult struct {
+	Branch      string   `json:"branch"`
+	Into        string   `json:"into"`
+	Commit      string   `json:"commit"` // nuevo HEAD de la rama destino
+	Commits     int      `json:"commits"`
+	FastForward bool     `json:"fast_forward"`
+	Squash      bool     `json:"squash"`
+	Conflicts   []string `json:"conflicts,omitempty"`
+}
+
+// createWorktree crea un worktree con una rama nueva a partir del HEAD del
+// repositorio que contiene workDir. La terminal arranca en el mismo
+// subdirectorio dentro del worktree.
+func createWorktree(terminalID, workDir, branch string, envFiles []string) (*TerminalWorktree, error) {
+	root, err := gitRoot(workDir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, workDir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, ErrNotGitRepo
+	}
+
+	short := terminalID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	if branch == "" {
+		branch = "claude/" + short
+	}
+	if _, err := runGit(root, "check-ref-format", "--branch", branch); err != nil {
+		return nil, fmt.Errorf("rama invalida: %s", branch)
+	}
+
+	out, err := runGit(root, "rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("el repositorio no tiene commits")
+	}
+	wt := &TerminalWorktree{
+		RepoRoot:   root,
+		Path:       filepath.Join(root, filepath.FromSlash(worktreesDir), short),
+		Branch:     branch,
+		BaseCommit: strings.TrimSpace(string(out)),
+		CreatedAt:  time.Now(),
+	}
+	wt.WorkDir = filepath.Join(wt.Path, rel)
+	if out, err := runGit(root, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		wt.BaseBranch = strings.TrimSpace(string(out))
+	}
+
+	if err := excludeWorktreesDir(root); err != nil {
+		logger.Warn("No se pudo excluir el directorio de worktrees", "repo", root, "error", err)
+	}
+	if _, err := runGit(root, "worktree", "add", "-b", branch, wt.Path, wt.BaseCommit); err != nil {
+		return nil, err
+	}
+
+	// Los .env ignorados por git no llegan al worktree: se copian para que la
+	// terminal los encuentre (sin afectar a git status del worktree)
+	for _, name := range envFiles {
+		if _, err := runGit(workDir, "check-ignore", "-q", name); err == nil {
+			copyFileIfMissing(filepath.Join(workDir, name), filepath.Join(wt.WorkDir, name))
+		}
+	}
+
+	logger.Info("Worktree creado", "terminal_id", terminalID, "path", wt.Path, "branch", branch)
+	return wt, nil
+}
+
+// excludeWorktreesDir añade el directorio de worktrees a info/exclude para que
+// no aparezca como sin versionar en el repositorio principal
+func excludeWorktreesDir(root string) error {
+	out, err := runGit(root, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return err
+	}
+	commonDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(root, commonDir)
+	}
+
+	excludeFile := filepath.Join(commonDir, "info", "exclude")
+	pattern := "/" + worktreesDir + "/"
+	data, _ := os.ReadFile(excludeFile)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(excludeFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(excludeFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		pattern = "\n" + pattern
+	}
+	_, err = f.WriteString(pattern + "\n")
+	return err
+}
+
+// copyFileIfMissing copia src a dst si src existe y dst no
+func copyFileIfMissing(src, dst string) {
+	if _, err := os.Stat(dst); err == nil {
+		return
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return
+	}
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(src); err == nil {
+		perm = info.Mode().Perm()
+	}
+	os.MkdirAll(filepath.Dir(dst), 0755)
+	os.WriteFile(dst, data, perm)
+}
+
+// restore vuelve a crear el directorio de un worktree eliminado a partir de su rama
+func (wt *TerminalWorktree) restore() error {
+	if !wt.Removed {
+		if _, err := os.Stat(wt.Path); err == nil {
+			return nil
+		}
+		// Eliminado fuera del monitor: limpiar el registro de git antes de recrearlo
+		runGit(wt.RepoRoot, "worktree", "prune")
+	}
+	if !branchExists(wt.RepoRoot, wt.Branch) {
+		return fmt.Errorf("la rama %s ya no existe", wt.Branch)
+	}
+	if _, err := runGit(wt.RepoRoot, "worktree", "add", wt.Path, wt.Branch); err != nil {
+		return err
+	}
+	wt.Removed = false
+	return nil
+}
+
+// remove elimina el directorio del worktree. Sin force falla con
+// ErrWorktreeDirty si hay cambios sin commit o archivos sin versionar.
+func (wt *TerminalWorktree) remove(force bool) error {
+	if wt.Removed {
+		return nil
+	}
+	if _, err := os.Stat(wt.Path); err != nil {
+		runGit(wt.RepoRoot, "worktree", "prune")
+		wt.Removed = true
+		return nil
+	}
+
+	if !force {
+		out, err := runGit(wt.Path, "status", "--porcelain")
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(out))) > 0 {
+			return ErrWorktreeDirty
+		}
+	}
+
+	args := []string{"worktree", "remove", wt.Path}
+	if force {
+		args = append(args, "--force")
+	}
+	if _, err := runGit(wt.RepoRoot, args...); err != nil {
+		return err
+	}
+	wt.Removed = true
+	os.Remove(filepath.Dir(wt.Path)) // .claude/worktrees si quedó vacío
+	return nil
+}
+
+// branchExists indica si existe la rama local
+func branchExists(root, branch string) bool {
+	_, err := runGit(root, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+// countCommits número de commits alcanzables desde to y no desde from
+func countCommits(root, from, to string) (int, error) {
+	out, err := runGit(root, "rev-list", "--count", from+".."+to)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// status retorna el estado actual del worktree
+func (wt *TerminalWorktree) status(terminalID string, active bool) *WorktreeStatus {
+	st := &WorktreeStatus{
+		TerminalID:       terminalID,
+		Active:           active,
+		TerminalWorktree: *wt,
+		BranchExists:     branchExists(wt.RepoRoot, wt.Branch),
+	}
+
+	if !wt.Removed {
+		if out, err := runGit(wt.Path, "status", "--porcelain"); err == nil {
+			for _, line := range strings.Split(string(out), "\n") {
+				if strings.TrimSpace(line) != "" {
+					st.ChangedFiles++
+				}
+			}
+			st.Dirty = st.ChangedFiles > 0
+		}
+	}
+
+	if st.BranchExists {
+		base := wt.BaseBranch
+		if base == "" || !branchExists(wt.RepoRoot, base) {
+			base = wt.BaseCommit
+		}
+		if ahead, err := countCommits(wt.RepoRoot, base, wt.Branch); err == nil {
+			st.Ahead = ahead
+			st.Merged = ahead == 0
+		}
+	}
+	return st
+}
+
+// merge integra la rama del worktree en opts.Into. Si la rama destino está
+// activa en el repositorio principal se hace merge (o squash) allí; si no
+// está activa en ningún worktree solo se admite fast-forward.
+func (wt *TerminalWorktree) merge(opts WorktreeMergeOptions) (*WorktreeMergeResult, error) {
+	into := opts.Into
+	if into == "" {
+		into = wt.BaseBranch
+	}
+	if into == "" {
+		return nil, fmt.Errorf("rama destino requerida: el worktree se creo con HEAD desacoplado")
+	}
+	if into == wt.Branch {
+		return nil, fmt.Errorf("rama destino invalida: %s", into)
+	}
+	if !branchExists(wt.RepoRoot, wt.Branch) {
+		return nil, fmt.Errorf("la rama %s ya no existe", wt.Branch)
+	}
+	if !branchExists(wt.RepoRoot, into) {
+		return nil, fmt.Errorf("rama destino invalida: %s", into)
+	}
+
+	// Los cambios sin commit del worktree no se integrarían
+	if !wt.Removed {
+		if out, err := runGit(wt.Path, "status", "--porcelain", "--untracked-files=no"); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+			return nil, ErrWorktreeDirty
+		}
+	}
+
+	commits, err := countCommits(wt.RepoRoot, into, wt.Branch)
+	if err != nil {
+		return nil, err
+	}
+	result := &WorktreeMergeResult{Branch: wt.Branch, Into: into, Commits: commits, Squash: opts.Squash}
+	if commits == 0 {
+		result.Commit = revParse(wt.RepoRoot, into)
+		return result, nil
+	}
+
+	message := opts.Message
+	if message == "" {
+		message = fmt.Sprintf("Merge branch '%s' into %s", wt.Branch, into)
+	}
+
+	current := ""
+	if out, err := runGit(wt.RepoRoot, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		current = strings.TrimSpace(string(out))
+	}
+
+	if current != into {
+		// Rama destino no activa: actualizarla solo si es fast-forward
+		if opts.Squash {
+			return nil, fmt.Errorf("squash requiere que %s este activa en %s", into, wt.RepoRoot)
+		}
+		if _, err := runGit(wt.RepoRoot, "fetch", ".", wt.Branch+":"+into); err != nil {
+			return nil, fmt.Errorf("%w: %s no es fast-forward de %s y no esta activa en %s", ErrMergeConflict, wt.Branch, into, wt.RepoRoot)
+		}
+		result.FastForward = true
+		result.Commit = revParse(wt.RepoRoot, into)
+		return result, nil
+	}
+
+	if out, err := runGit(wt.RepoRoot, "status", "--porcelain", "--untracked-files=no"); err != nil || len(strings.TrimSpace(string(out))) > 0 {
+		return nil, ErrMergeTargetDirty
+	}
+
+	if opts.Squash {
+		_, err = runGit(wt.RepoRoot, "merge", "--squash", wt.Branch)
+		if err == nil {
+			_, err = runGit(wt.RepoRoot, "commit", "-m", message)
+		}
+	} else {
+		_, err = runGit(wt.RepoRoot, "merge", "--no-ff", "-m", message, wt.Branch)
+	}
+	if err != nil {
+		result.Conflicts = conflictedFiles(wt.RepoRoot)
+		runGit(wt.RepoRoot, "reset", "--merge")
+		if len(result.Conflicts) > 0 {
+			return result, ErrMergeConflict
+		}
+		return nil, err
+	}
+
+	result.Commit = revParse(wt.RepoRoot, "HEAD")
+	return result, nil
+}
+
+// conflictedFiles archivos con conflictos sin resolver
+func conflictedFiles(root string) []string {
+	out, err := runGit(root, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// revParse retorna el hash de ref (vacío si no existe)
+func revParse(root, ref string) string {
+	out, err := runGit(root, "rev-parse", ref)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// prepareWorktree crea el worktree pedido en cfg, o reutiliza el registrado
+// para la terminal, y mueve cfg.WorkDir dentro de él. Retorna true si el
+// worktree es nuevo.
+func (s *TerminalService) prepareWorktree(cfg *TerminalConfig) (*TerminalWorktree, bool, error) {
+	if wt, err := s.savedWorktree(cfg.ID); err == nil {
+		current := *wt
+		if err := current.restore(); err != nil {
+			return nil, false, err
+		}
+		cfg.WorkDir = current.WorkDir
+		return &current, false, nil
+	}
+	if !cfg.Worktree {
+		return nil, false, nil
+	}
+
+	wt, err := createWorktree(cfg.ID, cfg.WorkDir, cfg.WorktreeBranch, cfg.EnvFiles)
+	if err != nil {
+		return nil, false, err
+	}
+	// La configuración guardada apunta al worktree: reanudar no crea otro
+	cfg.WorkDir = wt.WorkDir
+	cfg.Worktree = false
+	cfg.WorktreeBranch = ""
+	return wt, true, nil
+}
+
+// restoreSavedWorktree recrea el worktree eliminado de una terminal guardada
+// antes de reanudarla
+func (s *TerminalService) restoreSavedWorktree(id string) error {
+	wt, err := s.savedWorktree(id)
+	if err != nil {
+		return nil
+	}
+	current := *wt
+	if err := current.restore(); err != nil {
+		return err
+	}
+
+	s.savedMu.Lock()
+	if saved, ok := s.saved[id]; ok {
+		saved.Worktree = &current
+	}
+	s.savedMu.Unlock()
+	s.persistSaved()
+	return nil
+}
+
+// savedWorktree retorna el worktree registrado de una terminal
+func (s *TerminalService) savedWorktree(id string) (*TerminalWorktree, error) {
+	s.savedMu.RLock()
+	defer s.savedMu.RUnlock()
+	saved, ok := s.saved[id]
+	if !ok {
+		return nil, fmt.Errorf("terminal no encontrada: %s", id)
+	}
+	if saved.Worktree == nil {
+		return nil, ErrNoWorktree
+	}
+	return saved.Worktree, nil
+}
+
+// ListWorktrees lista los worktrees de todas las terminales con su estado
+func (s *TerminalService) ListWorktrees() []WorktreeStatus {
+	s.savedMu.RLock()
+	worktrees := make(map[string]TerminalWorktree)
+	for id, saved := range s.saved {
+		if saved.Worktree != nil {
+			worktrees[id] = *saved.Worktree
+		}
+	}
+	s.savedMu.RUnlock()
+
+	list := make([]WorktreeStatus, 0, len(worktrees))
+	for id, wt := range worktrees {
+		list = append(list, *wt.status(id, s.IsActive(id)))
+	}
+	return list
+}
+
+// GetWorktree retorna el worktree de una terminal con su estado
+func (s *TerminalService) GetWorktree(id string) (*WorktreeStatus, error) {
+	wt, err := s.savedWorktree(id)
+	if err != nil {
+		return nil, err
+	}
+	current := *wt
+	return current.status(id, s.IsActive(id)), nil
+}
+
+// MergeWorktree integra la rama del worktree de una terminal en su rama base
+// (u opts.Into). La terminal puede seguir activa.
+func (s *TerminalService) MergeWorktree(id string, opts WorktreeMergeOptions) (*WorktreeMergeResult, error) {
+	wt, err := s.savedWorktree(id)
+	if err != nil {
+		return nil, err
+	}
+	current := *wt
+	result, err := current.merge(opts)
+	if err == nil {
+		logger.Info("Rama de worktree integrada", "terminal_id", id, "branch", result.Branch, "into", result.Into, "commits", result.Commits)
+		if s.git != nil {
+			s.git.status.Clear() // el estado cacheado de otras terminales del repo cambió
+		}
+	}
+	return result, err
+}
+
+// RemoveWorktree elimina el worktree de una terminal no activa. force descarta
+// los cambios sin commit; deleteBranch elimina también la rama aunque no esté
+// integrada. Sin deleteBranch la rama se elimina solo si ya está integrada.
+func (s *TerminalService) RemoveWorktree(id string, force, deleteBranch bool) (*WorktreeStatus, error) {
+	if s.IsActive(id) {
+		return nil, ErrWorktreeActive
+	}
+
+	s.savedMu.Lock()
+	saved, ok := s.saved[id]
+	if !ok {
+		s.savedMu.Unlock()
+		return nil, fmt.Errorf("terminal no encontrada: %s", id)
+	}
+	if saved.Worktree == nil {
+		s.savedMu.Unlock()
+		return nil, ErrNoWorktree
+	}
+	wt := *saved.Worktree
+	s.savedMu.Unlock()
+
+	if err := s.releaseWorktree(id, &wt, force, deleteBranch); err != nil {
+		return nil, err
+	}
+	return wt.status(id, false), nil
+}
+
+// releaseWorktree elimina el directorio del worktree y la rama si corresponde,
+// y actualiza el registro guardado. Si la rama ya no existe se olvida el worktree.
+func (s *TerminalService) releaseWorktree(id string, wt *TerminalWorktree, force, deleteBranch bool) error {
+	if err := wt.remove(force); err != nil {
+		return err
+	}
+
+	flag := "-d" // solo si está integrada en su upstream o en HEAD
+	if deleteBranch {
+		flag = "-D"
+	}
+	if _, err := runGit(wt.RepoRoot, "branch", flag, wt.Branch); err != nil {
+		logger.Debug("Rama de worktree conservada", "terminal_id", id, "branch", wt.Branch, "error", err)
+	}
+
+	s.savedMu.Lock()
+	if saved, ok := s.saved[id]; ok {
+		if branchExists(wt.RepoRoot, wt.Branch) {
+			saved.Worktree = wt
+		} else {
+			saved.Worktree = nil
+		}
+	}
+	s.savedMu.Unlock()
+	s.persistSaved()
+
+	logger.Info("Worktree eliminado", "terminal_id", id, "path", wt.Path, "branch", wt.Branch)
+	return nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWorktreeRepo repositorio con un commit en main e identidad configurada
+func newWorktreeRepo(t *testing.T) (string, func(dir string, args ...string) string) {
+	t.Helper()
+	dir, commit := newGitRepo(t)
+	commit("init", time.Now().Add(-time.Hour))
+
+	git := func(dir string, args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(dir, "config", "user.name", "t")
+	git(dir, "config", "user.email", "t@t")
+	return dir, git
+}
+
+func TestCreateWorktree(t *testing.T) {
+	dir, git := newWorktreeRepo(t)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(".env\n"), 0644)
+	git(dir, "add", ".")
+	git(dir, "commit", "-qm", "gitignore")
+	os.WriteFile(filepath.Join(dir, "sub", ".env"), []byte("A=1\n"), 0600)
+
+	wt, err := createWorktree("0123456789abcdef", filepath.Join(dir, "sub"), "", []string{".env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wt.Branch != "claude/01234567" || wt.BaseBranch != "main" || wt.WorkDir != filepath.Join(wt.Path, "sub") {
+		t.Errorf("Worktree: %+v", wt)
+	}
+	if data, err := os.ReadFile(filepath.Join(wt.WorkDir, ".env")); err != nil || string(data) != "A=1\n" {
+		t.Errorf("Expected .env copied, got %q, %v", data, err)
+	}
+
+	// El directorio de worktrees no ensucia el repositorio principal
+	if out := git(dir, "status", "--porcelain"); out != "" {
+		t.Errorf("Expected clean main repo, got %q", out)
+	}
+
+	if _, err := createWorktree("fedcba9876543210", dir, "rama invalida..", nil); err == nil {
+		t.Error("Expected error for invalid branch")
+	}
+	if _, err := createWorktree("fedcba9876543210", t.TempDir(), "", nil); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+}
+
+func TestWorktreeMerge(t *testing.T) {
+	dir, git := newWorktreeRepo(t)
+	wt, err := createWorktree("aaaaaaaa-1", dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(wt.Path, "feature.txt"), []byte("x\n"), 0644)
+	if _, err := wt.merge(WorktreeMergeOptions{}); err != nil {
+		t.Fatalf("Untracked files should not block merge: %v", err)
+	}
+	git(wt.Path, "add", ".")
+	os.WriteFile(filepath.Join(wt.Path, "feature.txt"), []byte("y\n"), 0644)
+	if _, err := wt.merge(WorktreeMergeOptions{}); !errors.Is(err, ErrWorktreeDirty) {
+		t.Errorf("Expected ErrWorktreeDirty, got %v", err)
+	}
+	git(wt.Path, "commit", "-qam", "feature")
+
+	st := wt.status("t1", false)
+	if st.Ahead != 1 || st.Merged || st.Dirty {
+		t.Errorf("Status before merge: %+v", st)
+	}
+
+	result, err := wt.merge(WorktreeMergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Into != "main" || result.Commits != 1 || result.FastForward || result.Commit != git(dir, "rev-parse", "HEAD") {
+		t.Errorf("Merge: %+v", result)
+	}
+	if !wt.status("t1", false).Merged {
+		t.Error("Expected merged after merge")
+	}
+
+	// Rama destino no activa: solo fast-forward
+	git(dir, "branch", "release", wt.BaseCommit)
+	git(wt.Path, "commit", "-q", "--allow-empty", "-m", "otra")
+	result, err = wt.merge(WorktreeMergeOptions{Into: "release"})
+	if err != nil || !result.FastForward || result.Commit != git(dir, "rev-parse", wt.Branch) {
+		t.Errorf("Fast-forward: %+v, %v", result, err)
+	}
+
+	// Conflicto: se deshace el merge y se reportan los archivos
+	os.WriteFile(filepath.Join(dir, "feature.txt"), []byte("main\n"), 0644)
+	git(dir, "commit", "-qam", "main cambia feature")
+	os.WriteFile(filepath.Join(wt.Path, "feature.txt"), []byte("worktree\n"), 0644)
+	git(wt.Path, "commit", "-qam", "worktree cambia feature")
+	result, err = wt.merge(WorktreeMergeOptions{Squash: true})
+	if !errors.Is(err, ErrMergeConflict) || result == nil || len(result.Conflicts) != 1 || result.Conflicts[0] != "feature.txt" {
+		t.Errorf("Expected conflict on feature.txt, got %+v, %v", result, err)
+	}
+	if out := git(dir, "status", "--porcelain", "--untracked-files=no"); out != "" {
+		t.Errorf("Expected merge aborted, got %q", out)
+	}
+}
+
+func TestTerminalService_RemoveWorktree(t *testing.T) {
+	dir, git := newWorktreeRepo(t)
+	s := &TerminalService{
+		terminals:    make(map[string]Terminal),
+		saved:        make(map[string]*SavedTerminal),
+		sessionsFile: filepath.Join(t.TempDir(), "terminals.json"),
+	}
+
+	cfg := TerminalConfig{ID: "bbbbbbbb-1", WorkDir: dir, Worktree: true}
+	wt, created, err := s.prepareWorktree(&cfg)
+	if err != nil || !created || cfg.WorkDir != wt.WorkDir || cfg.Worktree {
+		t.Fatalf("prepareWorktree: %+v, %v, %v", cfg, created, err)
+	}
+	s.saved[cfg.ID] = &SavedTerminal{ID: cfg.ID, WorkDir: cfg.WorkDir, Config: cfg, Worktree: wt}
+
+	os.WriteFile(filepath.Join(wt.Path, "wip.txt"), []byte("x"), 0644)
+	if _, err := s.RemoveWorktree(cfg.ID, false, false); !errors.Is(err, ErrWorktreeDirty) {
+		t.Errorf("Expected ErrWorktreeDirty, got %v", err)
+	}
+	if err := s.Delete(cfg.ID); !errors.Is(err, ErrWorktreeDirty) {
+		t.Errorf("Expected Delete blocked by dirty worktree, got %v", err)
+	}
+
+	git(wt.Path, "add", ".")
+	git(wt.Path, "commit", "-qm", "wip")
+
+	// Rama sin integrar: se conserva y el worktree se puede recrear
+	st, err := s.RemoveWorktree(cfg.ID, false, false)
+	if err != nil || !st.Removed || !st.BranchExists || st.Ahead != 1 {
+		t.Fatalf("RemoveWorktree: %+v, %v", st, err)
+	}
+	if _, err := os.Stat(wt.Path); !os.IsNotExist(err) {
+		t.Error("Expected worktree directory removed")
+	}
+	if err := s.restoreSavedWorktree(cfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.Path, "wip.txt")); err != nil {
+		t.Errorf("Expected worktree restored from branch: %v", err)
+	}
+
+	// Sin rama el registro se olvida
+	st, err = s.RemoveWorktree(cfg.ID, false, true)
+	if err != nil || st.BranchExists {
+		t.Fatalf("RemoveWorktree delete_branch: %+v, %v", st, err)
+	}
+	if _, err := s.GetWorktree(cfg.ID); !errors.Is(err, ErrNoWorktree) {
+		t.Errorf("Expected ErrNoWorktree, got %v", err)
+	}
+}